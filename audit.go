@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAuditSchema       = "audit"
+	defaultAuditActorSetting = "app.actor"
+	auditTriggerName         = "audit_record_change"
+)
+
+// auditTables returns the tables with generate_audit set.
+func auditTables(schemas []GenerationSchema) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Config.GenerateAudit {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+	}
+	return tables
+}
+
+const auditFunctionTemplate = `CREATE OR REPLACE FUNCTION %[1]s.record_change() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    new_record jsonb := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    old_record jsonb := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+BEGIN
+    INSERT INTO %[1]s.record_version (table_schema, table_name, operation, record_id, record, old_record, actor)
+    VALUES (
+        TG_TABLE_SCHEMA,
+        TG_TABLE_NAME,
+        TG_OP,
+        CASE WHEN TG_NARGS > 0 THEN coalesce(new_record, old_record) ->> TG_ARGV[0] END,
+        new_record,
+        old_record,
+        coalesce(nullif(current_setting(%[2]s, true), ''), current_user)
+    );
+    RETURN NULL;
+END;
+$$;
+`
+
+// auditMigration creates an audit log table, a trigger function recording
+// every row change into it, and a trigger on each table with generate_audit
+// set. The log stores the new and old rows as jsonb, the primary key value,
+// the actor (the audit_actor_setting setting, falling back to current_user),
+// and the time of the change.
+func auditMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	tables := auditTables(schemas)
+	if len(tables) == 0 {
+		return Migration{Name: "audit"}, nil
+	}
+	auditSchema := cfg.AuditSchema
+	if auditSchema == "" {
+		auditSchema = defaultAuditSchema
+	}
+	actorSetting := cfg.AuditActorSetting
+	if actorSetting == "" {
+		actorSetting = defaultAuditActorSetting
+	}
+	schema := ddlIdent(auditSchema)
+
+	up := strings.Builder{}
+	fmt.Fprintf(&up, "CREATE SCHEMA IF NOT EXISTS %s;\n\n", schema)
+	fmt.Fprintf(&up, `CREATE TABLE IF NOT EXISTS %[1]s.record_version (
+    id bigserial PRIMARY KEY,
+    table_schema text NOT NULL,
+    table_name text NOT NULL,
+    operation text NOT NULL,
+    record_id text,
+    record jsonb,
+    old_record jsonb,
+    actor text,
+    changed_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS record_version_table_idx ON %[1]s.record_version (table_schema, table_name, record_id, changed_at);
+
+`, schema)
+	fmt.Fprintf(&up, auditFunctionTemplate, schema, ddlLiteral(actorSetting))
+
+	down := strings.Builder{}
+	for _, table := range tables {
+		name := ddlTableName(&table.Table)
+		argument := ""
+		if table.HasColumn(table.Config.PrimaryKey) {
+			argument = ddlLiteral(table.Config.PrimaryKey)
+		}
+		fmt.Fprintf(&up, "\nDROP TRIGGER IF EXISTS %s ON %s;\n", auditTriggerName, name)
+		fmt.Fprintf(&up, "CREATE TRIGGER %s\n    AFTER INSERT OR UPDATE OR DELETE ON %s\n    FOR EACH ROW EXECUTE FUNCTION %s.record_change(%s);\n", auditTriggerName, name, schema, argument)
+		fmt.Fprintf(&down, "DROP TRIGGER IF EXISTS %s ON %s;\n", auditTriggerName, name)
+	}
+	// The schema may hold other objects, so it's left in place.
+	fmt.Fprintf(&down, "DROP FUNCTION IF EXISTS %s.record_change();\n", schema)
+	fmt.Fprintf(&down, "DROP TABLE IF EXISTS %s.record_version;\n", schema)
+	return Migration{Name: "audit", Up: up.String(), Down: down.String()}, nil
+}
+
+// generateAudit writes the audit log table, trigger function, and triggers
+// from auditMigration.
+func generateAudit(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(auditTables(schemas)) == 0 {
+		return errors.New("No tables have generate_audit set")
+	}
+	return writeMigrationUp(w, auditMigration, schemas, cfg)
+}
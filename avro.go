@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// avroTypes maps column kinds to Avro types. Kinds not listed, and kinds
+// without an exact Avro equivalent (intervals, JSON), are strings.
+var avroTypes = map[TypeKind]interface{}{
+	KindInt16:       "int",
+	KindInt32:       "int",
+	KindInt64:       "long",
+	KindFloat32:     "float",
+	KindFloat64:     "double",
+	KindBool:        "boolean",
+	KindString:      "string",
+	KindBytes:       "bytes",
+	KindUUID:        AvroLogicalType{Type: "string", LogicalType: "uuid"},
+	KindTimestamp:   AvroLogicalType{Type: "long", LogicalType: "local-timestamp-micros"},
+	KindTimestamptz: AvroLogicalType{Type: "long", LogicalType: "timestamp-micros"},
+	KindDate:        AvroLogicalType{Type: "int", LogicalType: "date"},
+	KindTime:        AvroLogicalType{Type: "long", LogicalType: "time-micros"},
+}
+
+var (
+	avroNamePattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	avroInvalidNamePattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// AvroRecord is an Avro record schema.
+type AvroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Doc       string      `json:"doc,omitempty"`
+	Fields    []AvroField `json:"fields"`
+}
+
+// AvroField is a field of a record. Default is only set for nullable fields,
+// whose default is null.
+type AvroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Doc     string          `json:"doc,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// AvroLogicalType is a primitive type annotated with a logical type.
+type AvroLogicalType struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+	Precision   int    `json:"precision,omitempty"`
+	Scale       int    `json:"scale,omitempty"`
+}
+
+// AvroEnum is an Avro enum schema.
+type AvroEnum struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Symbols []string `json:"symbols"`
+}
+
+// AvroArray is an Avro array schema.
+type AvroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+// avroName replaces the characters Avro doesn't allow in names.
+func avroName(name string) string {
+	if avroNamePattern.MatchString(name) {
+		return name
+	}
+	name = avroInvalidNamePattern.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// avroElementType returns the Avro type of a single (non-array) value of the
+// column. Enum types are defined where they're first used in the record and
+// referenced by name after that, as Avro requires. Enums with values that
+// aren't valid Avro symbols are strings.
+func avroElementType(c Column, defined map[string]bool) interface{} {
+	typ := c.Type()
+	switch typ.Kind {
+	case KindNumeric:
+		if c.NumericPrecision == 0 {
+			// Unconstrained numerics have no fixed scale.
+			return "string"
+		}
+		return AvroLogicalType{Type: "bytes", LogicalType: "decimal", Precision: c.NumericPrecision, Scale: c.NumericScale}
+	case KindEnum:
+		for _, value := range c.EnumValues {
+			if !avroNamePattern.MatchString(value) {
+				return "string"
+			}
+		}
+		name := c.EnumTypeName()
+		if defined[name] {
+			return name
+		}
+		defined[name] = true
+		return AvroEnum{Type: "enum", Name: name, Symbols: c.EnumValues}
+	}
+	if avroType, ok := avroTypes[typ.Kind]; ok {
+		return avroType
+	}
+	return "string"
+}
+
+// avroRecord describes a row of the table as a record named after the table's
+// type, in the namespace avro_namespace or else the table's schema. Nullable
+// columns are unions with null, defaulting to null.
+func avroRecord(t *Table, cfg GeneratorConfiguration) AvroRecord {
+	namespace := cfg.AvroNamespace
+	if namespace == "" {
+		namespace = avroName(t.Schema)
+	}
+	record := AvroRecord{
+		Type:      "record",
+		Name:      t.TypeName(),
+		Namespace: namespace,
+		Doc:       t.Comment,
+		Fields:    []AvroField{},
+	}
+	defined := map[string]bool{}
+	if record.Doc == "" {
+		record.Doc = fmt.Sprintf("A row of %s.%s.", t.Schema, t.Name)
+	}
+	for _, c := range t.Columns {
+		field := AvroField{Name: avroName(c.Name), Doc: c.Comment, Type: avroElementType(c, defined)}
+		if c.Type().Array {
+			field.Type = AvroArray{Type: "array", Items: field.Type}
+		}
+		if c.Nullable {
+			field.Type = []interface{}{"null", field.Type}
+			field.Default = json.RawMessage("null")
+		}
+		record.Fields = append(record.Fields, field)
+	}
+	return record
+}
+
+// generateAvro writes a JSON array of Avro record schemas, one per table.
+// Each record defines the enum types it uses, so it can be registered on its
+// own.
+func generateAvro(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	records := []AvroRecord{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			records = append(records, avroRecord(&schema.Tables[i].Table, cfg))
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(records)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// extractCheckpointInterval is how often an extraction saves its checkpoint.
+var extractCheckpointInterval = 10 * time.Second
+
+// extractCheckpoint is the progress of an extraction, saved to a file so an
+// interrupted extraction resumes where it was saved, and a finished one can
+// be run again to extract only the rows it didn't find.
+type extractCheckpoint struct {
+	// Seed describes the seed rows, so an extraction doesn't resume another.
+	Seed string `json:"seed"`
+	// Complete is set once the rows have been written out.
+	Complete bool `json:"complete"`
+	// Tables hold the rows found, by qualified table name.
+	Tables map[string]*extractCheckpointTable `json:"tables"`
+	// Queue, Done, and Truncated are the state of an incomplete walk.
+	Queue     []extractCheckpointLookup `json:"queue,omitempty"`
+	Done      []string                  `json:"done,omitempty"`
+	Truncated []string                  `json:"truncated,omitempty"`
+	// References and Referenced are the values of the foreign keys of the
+	// rows found, by relation.
+	References map[string][]string `json:"references,omitempty"`
+	Referenced map[string][]string `json:"referenced,omitempty"`
+
+	path string
+}
+
+// extractCheckpointTable holds the rows of a table found by an extraction.
+type extractCheckpointTable struct {
+	// Known are the keys of the rows written out by earlier runs.
+	Known []string `json:"known,omitempty"`
+	// Keys are the keys of the rows found by an incomplete run, Rows the
+	// number of them written, and Size the size of their file.
+	Keys []string `json:"keys,omitempty"`
+	Rows int      `json:"rows,omitempty"`
+	Size int64    `json:"size,omitempty"`
+}
+
+// extractCheckpointLookup is a queued lookup, with its values as text.
+type extractCheckpointLookup struct {
+	Table    string   `json:"table"`
+	Column   string   `json:"column,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Where    string   `json:"where,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+	Percent  float64  `json:"percent,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Depth    int      `json:"depth"`
+	Relation string   `json:"relation"`
+}
+
+// loadExtractCheckpoint reads a checkpoint, which is empty if the file
+// doesn't exist yet.
+func loadExtractCheckpoint(path string) (*extractCheckpoint, error) {
+	c := &extractCheckpoint{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithMessage(err, "Unable to read checkpoint")
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Invalid checkpoint %s", path))
+		}
+	}
+	if c.Tables == nil {
+		c.Tables = map[string]*extractCheckpointTable{}
+	}
+	return c, nil
+}
+
+// resume restores the extraction saved in the checkpoint, returning the
+// lookups left to run. The rows of an incomplete run are kept in the sink, up
+// to where they were saved, and its walk continues; the rows of a complete
+// run are walked again, but only rows it didn't find are written.
+func (c *extractCheckpoint) resume(schemas []GenerationSchema, extraction *Extraction, queue []extractLookup, done map[string]bool) ([]extractLookup, error) {
+	if c.Seed == "" {
+		return queue, nil
+	}
+	if c.Seed != extraction.source() {
+		return nil, errors.Errorf("Checkpoint %s is of the extraction from %s", c.path, c.Seed)
+	}
+	for name, saved := range c.Tables {
+		table, err := extractSeedTable(schemas, name)
+		if err != nil {
+			return nil, errors.Errorf("Checkpointed table %s is not one of the selected tables", name)
+		}
+		extracted := extraction.table(table)
+		for _, key := range saved.Known {
+			extracted.known[key] = true
+		}
+		if c.Complete {
+			continue
+		}
+		for _, key := range saved.Keys {
+			extracted.keys[key] = true
+		}
+		if saved.Rows > 0 {
+			extracted.count = saved.Rows
+			extraction.rows += saved.Rows
+			if err := extraction.sink.restore(extracted, saved.Rows, saved.Size); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.Complete {
+		return queue, nil
+	}
+
+	extraction.Truncated = c.Truncated
+	for relation, values := range c.References {
+		for _, value := range values {
+			extractRecord(extraction.references, relation, value)
+		}
+	}
+	for relation, values := range c.Referenced {
+		for _, value := range values {
+			extractRecord(extraction.referenced, relation, value)
+		}
+	}
+	for _, key := range c.Done {
+		done[key] = true
+	}
+	restored := make([]extractLookup, 0, len(c.Queue))
+	for _, saved := range c.Queue {
+		table, err := extractSeedTable(schemas, saved.Table)
+		if err != nil {
+			return nil, errors.Errorf("Checkpointed table %s is not one of the selected tables", saved.Table)
+		}
+		lookup := extractLookup{Table: table, Column: saved.Column, Value: saved.Value, Cast: true, Where: saved.Where, Limit: saved.Limit, Percent: saved.Percent, Required: saved.Required, Depth: saved.Depth, Relation: saved.Relation}
+		for _, arg := range saved.Args {
+			lookup.Args = append(lookup.Args, arg)
+		}
+		restored = append(restored, lookup)
+	}
+	return restored, nil
+}
+
+// save saves the progress of an incomplete extraction, after writing out the
+// rows found so far.
+func (c *extractCheckpoint) save(extraction *Extraction, queue []extractLookup, done map[string]bool) error {
+	sizes, err := extraction.sink.sync()
+	if err != nil {
+		return err
+	}
+	c.Seed, c.Complete, c.Truncated = extraction.source(), false, extraction.Truncated
+	c.Tables = map[string]*extractCheckpointTable{}
+	for table, extracted := range extraction.Tables {
+		name := table.Schema + "." + table.Name
+		saved := &extractCheckpointTable{Known: extractCheckpointKeys(extracted.known), Keys: extractCheckpointKeys(extracted.keys), Rows: extracted.count, Size: sizes[name]}
+		c.Tables[name] = saved
+	}
+	c.Queue = make([]extractCheckpointLookup, 0, len(queue))
+	for _, lookup := range queue {
+		saved := extractCheckpointLookup{Table: lookup.Table.Schema + "." + lookup.Table.Name, Column: lookup.Column, Where: lookup.Where, Limit: lookup.Limit, Percent: lookup.Percent, Required: lookup.Required, Depth: lookup.Depth, Relation: lookup.Relation}
+		if lookup.Where == "" {
+			saved.Value = extractText(lookup.Value)
+		}
+		for _, arg := range lookup.Args {
+			saved.Args = append(saved.Args, extractText(arg))
+		}
+		c.Queue = append(c.Queue, saved)
+	}
+	c.Done = extractCheckpointKeys(done)
+	c.References, c.Referenced = extractCheckpointSets(extraction.references), extractCheckpointSets(extraction.referenced)
+	return c.write()
+}
+
+// complete saves the rows of a finished extraction, once they've been
+// written out, for the next run to skip.
+func (c *extractCheckpoint) complete(extraction *Extraction) error {
+	c.Seed, c.Complete = extraction.source(), true
+	c.Queue, c.Done, c.Truncated = nil, nil, nil
+	c.References, c.Referenced = nil, nil
+	c.Tables = map[string]*extractCheckpointTable{}
+	for table, extracted := range extraction.Tables {
+		known := map[string]bool{}
+		for key := range extracted.known {
+			known[key] = true
+		}
+		for key := range extracted.keys {
+			known[key] = true
+		}
+		c.Tables[table.Schema+"."+table.Name] = &extractCheckpointTable{Known: extractCheckpointKeys(known)}
+	}
+	return c.write()
+}
+
+// write replaces the checkpoint file, through a temporary file so an
+// interruption doesn't leave it half written.
+func (c *extractCheckpoint) write() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path+".tmp", data, 0644); err != nil {
+		return errors.WithMessage(err, "Unable to write checkpoint")
+	}
+	if err := os.Rename(c.path+".tmp", c.path); err != nil {
+		return errors.WithMessage(err, "Unable to write checkpoint")
+	}
+	return nil
+}
+
+// extractCheckpointKeys returns the keys of a set, sorted so the checkpoint
+// doesn't change needlessly.
+func extractCheckpointKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// extractCheckpointSets returns the keys of sets by name.
+func extractCheckpointSets(sets map[string]map[string]bool) map[string][]string {
+	keys := map[string][]string{}
+	for name, set := range sets {
+		keys[name] = extractCheckpointKeys(set)
+	}
+	return keys
+}
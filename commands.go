@@ -0,0 +1,912 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"gopkg.in/yaml.v3"
+)
+
+// command is a subcommand of pginspector, run as pginspector <name> [flags]
+// [args], with its own flags.
+type command struct {
+	name string
+	// args describes the positional arguments, for the usage.
+	args    string
+	summary string
+	// description is printed in the command's usage, after the summary.
+	description string
+	// flags defines the command's flags on fs, returning the function that
+	// parses args with them and runs the command. Defining the flags does
+	// nothing else, so completion can list them.
+	flags func(fs *flag.FlagSet) func(ctx context.Context, args []string)
+}
+
+var commands = []command{
+	{
+		name:        "inspect",
+		args:        "[schema...]",
+		summary:     "Inspect schemas and print them to stdout in the configuration file format",
+		description: "The schemas are those passed with -schema or as the arguments, every schema with tables except the system schemas with -all-schemas, or public, in one config, or with -format json as their tables, columns, types, nullability, defaults, indexes, constraints, and relations in JSON sorted by name.",
+		flags:       inspectFlags,
+	},
+	{
+		name:        "init",
+		args:        "[schema...]",
+		summary:     "Inspect schemas and write a config file for them to edit",
+		description: "The schemas are public unless they're passed as the arguments. The config is written to -config, with each table's primary key, and fails if the file already exists.",
+		flags:       initFlags,
+	},
+	{
+		name:        "edit",
+		args:        "[schema]",
+		summary:     "Choose tables and their options interactively and write them to the config file",
+		description: "The schema is public unless it's passed as the argument. Its tables are listed with checkboxes on the terminal: the arrow keys move between them, space selects one, u, f, and s toggle its generate_upsert, generate_field_mask_update, and soft_delete_column, and / filters them by name. When stdin isn't a terminal, commands are read from it a line at a time instead, e.g. 3 5-8 or u 3. w writes the choices to -config, as skip_tables (and include_tables, if it's set) and table_config, keeping the rest of the file and its comments. The config is started as init writes it if the file doesn't exist.",
+		flags:       editFlags,
+	},
+	{
+		name:        "generate",
+		args:        "[target]",
+		summary:     "Generate SQL queries, or another target, from the configuration file",
+		description: generateTargetsDescription(),
+		flags:       generateFlags,
+	},
+	{
+		name:        "extract",
+		summary:     "Copy the rows reachable through foreign keys from seed rows, or from samples of root tables",
+		description: "The extract section of the config sets the seed rows or sampled tables, which relations are followed, the output format, and the masks anonymizing columns.",
+		flags:       extractFlags,
+	},
+	{
+		name:        "config",
+		args:        "validate",
+		summary:     "Check the config file against the database",
+		description: "validate reports tables in table_config, skip_tables, and include_tables that don't exist, primary keys that aren't columns of their table, and proto names used by more than one table, at their line and column in the config file, exiting non-zero if there are any.",
+		flags:       configCommandFlags,
+	},
+	{
+		name:        "snapshot",
+		summary:     "Save the configured schemas as inspected to a JSON file",
+		description: "Save the configured schemas as inspected to a JSON file (-output, default schema-snapshot.json), which extract loads from extract.schema_snapshot instead of inspecting the database.",
+		flags:       snapshotFlags,
+	},
+	{
+		name:        "diff",
+		summary:     "Compare the configured schemas of the database with a schema snapshot",
+		description: "Print a unified diff from the CREATE statements of the selected tables in -snapshot (default schema-snapshot.json) to those of the database, exiting non-zero if they differ.",
+		flags:       diffFlags,
+	},
+	{
+		name:        "lint",
+		summary:     "Report problems in the selected tables",
+		description: "Report tables without a primary key and foreign keys without an index starting with their columns, exiting non-zero if there are any.",
+		flags:       lintFlags,
+	},
+	{
+		name:        "migration",
+		summary:     "Write golang-migrate up/down files",
+		description: "Write golang-migrate up/down files for each of -migrations into the -output directory (default migrations), numbered after the files already there.",
+		flags:       migrationFlags,
+	},
+	{
+		name:        "flyway",
+		summary:     "Write Flyway migrations reconstructing the selected tables",
+		description: "Write a Flyway V<n>__baseline.sql reconstructing the selected tables into the -output directory (default migrations), or with flyway_split_tables a migration per table in dependency order.",
+		flags:       flywayFlags,
+	},
+	{
+		name:        "compat",
+		summary:     "Report which generated queries differ or fail per server version",
+		description: "Generate against -database-url and each of -compat-database-urls, prepare each query on the database it was generated from in a transaction that's rolled back, and report which queries differ or fail to prepare per server version.",
+		flags:       compatFlags,
+	},
+}
+
+// generateTargets describe what generate renders besides SQL queries, in the
+// order they're listed in its usage.
+var generateTargets = [][2]string{
+	{"go", "Generate a Go package with one struct per table and a Scanner/Valuer string type per enum (set go_package in the config to name the package)"},
+	{"proto", "Generate a .proto file with one message per table (package comes from proto_name or proto_package in the config; set proto_services for a Get/List/Update/Delete service per table)"},
+	{"graphql", "Generate a read-only GraphQL schema with one type per table and get/list Query fields"},
+	{"openapi", "Generate an OpenAPI 3 document with CRUD paths and schemas per table (operation IDs match the generated query names)"},
+	{"typescript", "Generate TypeScript interfaces per table and union types per enum (set typescript_timestamp_type to \"Date\" to type timestamps as Date)"},
+	{"kysely", "Generate a Kysely Database interface with a table interface per table, typing columns with defaults as Generated, and its Selectable, Insertable, and Updateable row types"},
+	{"jsonschema", "Generate a JSON Schema document with a definition per table's row shape (including enums, lengths, and numeric precision)"},
+	{"avro", "Generate an Avro record schema per table with logical types for timestamps, dates, decimals, and UUIDs, and nullable columns as unions with null (avro_namespace overrides the schema name as namespace)"},
+	{"debezium", "Generate a Debezium Postgres connector definition capturing the tables, keyed on their primary keys (debezium_name names the connector and topics, debezium_config adds or overrides properties)"},
+	{"zod", "Generate Zod schemas and inferred types per table and enum (honors typescript_timestamp_type)"},
+	{"diagram", "Render an entity relationship diagram of the selected tables (-format mermaid, dot, or plantuml)"},
+	{"dbml", "Generate DBML (dbdiagram.io) with refs for foreign keys and notes from table and column comments"},
+	{"report", "Generate a self-contained HTML report with a searchable table list, column details, relation links, and a diagram"},
+	{"ent", "Generate an ent (entgo.io) schema package with fields, edges from foreign keys, and indexes per table"},
+	{"sqlalchemy", "Generate SQLAlchemy 2.0 declarative models with a class per table, relationships from foreign keys, and enum classes from Postgres enums"},
+	{"django", "Generate unmanaged Django models like inspectdb, with ForeignKey fields from foreign keys, TextChoices from Postgres enums, and unique_together from unique constraints"},
+	{"prisma", "Generate a schema.prisma with a model per table, relations from foreign keys, and enums from Postgres enums"},
+	{"repository", "Generate a Repository interface and pgx implementation per table (builds on the go target's structs; use the same go_package; set repository_mocks for a mock per interface)"},
+	{"handlers", "Generate net/http handlers per table over the repository target's repositories: paginated list, get, PATCH with a field mask, and delete"},
+	{"validate", "Generate a Validate method per table struct from NOT NULL, varchar length, enum, and CHECK constraints (use the go target's go_package)"},
+	{"ddl", "Reconstruct CREATE TYPE, CREATE TABLE (with constraints), CREATE INDEX, and COMMENT statements for the selected tables, ordered by dependency"},
+	{"liquibase", "Generate a Liquibase YAML changelog creating the tables in dependency order, then their foreign keys (liquibase_author sets the changeSet author)"},
+	{"audit", "Generate an audit log table, a row change trigger function, and a trigger per table with generate_audit set (audit_schema and audit_actor_setting configure where changes are logged and who made them)"},
+	{"updated_at", "Generate a set_updated_at() trigger function and a trigger per table with an updated_at column (updated_at_column overrides the name), skipping tables that already have one"},
+	{"history", "Generate a <table>_history table with valid_from and valid_to columns and a trigger recording old rows on UPDATE and DELETE for each table with generate_history set"},
+	{"outbox", "Generate a transactional outbox table and a trigger per table with generate_outbox set writing row changes as jsonb events, including the aggregates the row references (outbox_schema sets where the table is created)"},
+	{"rls", "Generate row level security policies for each table with rls_column set, matching the column against current_setting(rls_setting) or, with rls_style supabase, auth.uid()"},
+	{"grants", "Generate GRANT and REVOKE statements giving each role in roles its default access (read, write, or none) to every table, overridden per table by grants"},
+	{"comments", "Generate COMMENT ON statements from table description and column_descriptions (inspect fills these in from existing comments)"},
+	{"pgtap", "Generate a pgTAP test file asserting the tables, column types and nullability, primary keys, unique constraints, and foreign keys"},
+	{"partitions", "Generate a function creating upcoming partitions and dropping expired ones for each range-partitioned table with a partitions interval set, optionally scheduled with pg_cron"},
+	{"postgrest", "Generate an API schema (postgrest_schema) with a view per table, grants for the web roles in postgrest_roles, and the matching PostgREST configuration"},
+}
+
+// generateTargetsDescription lists the targets of generate for its usage.
+func generateTargetsDescription() string {
+	b := strings.Builder{}
+	b.WriteString("The target is sql, for the SQL queries of each table, written to one file, or with output_layout per_schema or per_table to a file per schema or per table in the -output directory (default queries) with a README.md index, unless it's one of:")
+	for _, target := range generateTargets {
+		fmt.Fprintf(&b, "\n  %s: %s", target[0], target[1])
+	}
+	return b.String()
+}
+
+// findCommand returns the command with a name.
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newCommandFlags returns the flag set of a command, printing its usage on
+// -h or a bad flag.
+func newCommandFlags(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet("pginspector "+cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: pginspector %s [flags] %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+		if cmd.description != "" {
+			fmt.Fprintf(w, "\n%s\n", cmd.description)
+		}
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// writeUsage writes the usage of pginspector, listing the commands.
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: pginspector <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "  %-10s %s\n", "help", "Print this help message, or a command's with help <command>")
+	fmt.Fprintf(w, "\nRun pginspector <command> -h for a command's flags.\n")
+}
+
+// commandOptions are the flags the commands share.
+type commandOptions struct {
+	databaseURL string
+	configPath  string
+	outputPath  string
+	// debug is set when debug output is logged, by -debug or -log-level.
+	debug     bool
+	logLevel  string
+	logFormat string
+}
+
+// databaseFlags defines -database-url and the logging flags.
+func (o *commandOptions) databaseFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.databaseURL, "database-url", os.Getenv("DATABASE_URL"), "Database URL to connect to")
+	fs.BoolVar(&o.debug, "debug", false, "Log debug output, the same as -log-level debug")
+	fs.StringVar(&o.logLevel, "log-level", "info", "Lowest level logged to stderr: debug, info, warn, or error")
+	fs.StringVar(&o.logFormat, "log-format", "text", "Format of the log: text or json")
+}
+
+// configFlags defines -database-url, the logging flags, and -config.
+func (o *commandOptions) configFlags(fs *flag.FlagSet) {
+	o.databaseFlags(fs)
+	fs.StringVar(&o.configPath, "config", "pginspector.yaml", "Path to config file, merging in the files listed in its include")
+}
+
+// parse parses the flags of a command, checking the shared ones.
+func (o *commandOptions) parse(fs *flag.FlagSet, args []string) {
+	// ExitOnError flag sets exit rather than returning an error.
+	_ = fs.Parse(args)
+	if o.debug {
+		o.logLevel = "debug"
+	}
+	logger, err := newLogger(os.Stderr, o.logLevel, o.logFormat)
+	if err != nil {
+		fatalf("Invalid logging flags: %v\n", err)
+	}
+	slog.SetDefault(logger)
+	o.debug = logger.Enabled(context.Background(), slog.LevelDebug)
+	if o.databaseURL == "" {
+		fatalf("-database-url (or DATABASE_URL environment variable) must be set (no default assumed)")
+	}
+	if fs.Lookup("config") != nil && o.configPath == "" {
+		fatalf("-config must not be empty if set (defaults to pginspector.yaml when not set)")
+	}
+	if output := fs.Lookup("output"); output != nil && output.DefValue != "" && o.outputPath == "" {
+		fatalf("-output must not be empty if set (defaults to %s when not set)", output.DefValue)
+	}
+}
+
+// config reads the config file.
+func (o *commandOptions) config() GeneratorConfiguration {
+	cfg, err := ReadConfigFile(o.configPath)
+	if err != nil {
+		fatalf("Unable to read config file: %v\n", err)
+	}
+	return cfg
+}
+
+// checkOutput compares the output of a command with -output, printing a
+// unified diff and exiting non-zero if they differ, e.g. because the schema
+// changed since it was generated.
+func (o *commandOptions) checkOutput(output *bytes.Buffer) {
+	current, err := os.ReadFile(o.outputPath)
+	if err != nil && !os.IsNotExist(err) {
+		fatalf("Unable to read output file: %v\n", err)
+	}
+	diff := driftDiff(o.outputPath, o.outputPath+" (regenerated)", string(current), output.String())
+	if diff == "" {
+		slog.Info("Output is up to date", "path", o.outputPath)
+		return
+	}
+	fmt.Print(diff)
+	slog.Error("Output is out of date, regenerate it", "path", o.outputPath)
+	os.Exit(1)
+}
+
+// writeOutput writes the output of a command to -output, or stdout for -.
+func (o *commandOptions) writeOutput(output *bytes.Buffer) {
+	if o.outputPath == "-" {
+		_, err := io.Copy(os.Stdout, output)
+		if err != nil {
+			fatalf("Unable to write output to stdout: %v\n", err)
+		}
+		return
+	}
+	err := os.WriteFile(o.outputPath, output.Bytes(), 0644)
+	if err != nil {
+		fatalf("Unable to write output to file: %v\n", err)
+	}
+}
+
+func inspectFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.databaseFlags(fs)
+	schemaNames := []string{}
+	fs.Func("schema", "Schema to inspect, repeated for several (default public)", func(schemaName string) error {
+		schemaNames = append(schemaNames, schemaName)
+		return nil
+	})
+	allSchemas := fs.Bool("all-schemas", false, "Inspect every schema with tables, except the system schemas")
+	format := fs.String("format", "yaml", "Output format: yaml, for an example config, or json, for the inspected tables, columns, types, and relations")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		if *format != "yaml" && *format != "json" {
+			fatalf("Unknown format %s, expected yaml or json\n", *format)
+		}
+		schemaNames = append(schemaNames, fs.Args()...)
+		if *allSchemas {
+			if len(schemaNames) > 0 {
+				fatalf("-all-schemas inspects every schema, so it can't be combined with -schema\n")
+			}
+			var err error
+			schemaNames, err = listSchemas(ctx, opts.databaseURL, opts.debug)
+			if err != nil {
+				fatalf("Unable to list schemas: %v\n", err)
+			}
+			if len(schemaNames) == 0 {
+				fatalf("No schemas with tables found\n")
+			}
+		}
+		if len(schemaNames) == 0 {
+			slog.Info("Assuming schema \"public\" since no schema name was provided (pass -schema or -all-schemas to override)")
+			schemaNames = []string{"public"}
+		}
+
+		schemas := map[string]Schema{}
+		for _, schemaName := range schemaNames {
+			if _, ok := schemas[schemaName]; ok {
+				continue
+			}
+			schema, err := inspectTablesInSchema(ctx, opts.databaseURL, schemaName, []string{}, opts.debug)
+			if err != nil {
+				fatalf("Unable to inspect schema: %v\n", err)
+			}
+			if len(schema.Tables) == 0 {
+				fatalf("No tables found in schema %s\n", schemaName)
+			}
+			schemas[schemaName] = schema
+		}
+
+		if *format == "json" {
+			if err := writeInspectJSON(os.Stdout, schemas); err != nil {
+				fatalf("Unable to write output to stdout: %v\n", err)
+			}
+			return
+		}
+		outputConfig := GeneratorConfiguration{SchemaConfig: map[string]SchemaConfig{}}
+		for schemaName, schema := range schemas {
+			outputConfig.SchemaConfig[schemaName] = inspectSchemaConfig(schema)
+		}
+		_, err := fmt.Fprintf(os.Stdout, "# And example configuration for the provided database follows.\n# You may need to edit this to suit your needs.\n\n")
+		if err != nil {
+			fatalf("Unable to write output to stdout: %v\n", err)
+		}
+		err = yaml.NewEncoder(os.Stdout).Encode(outputConfig)
+		if err != nil {
+			fatalf("Unable to encode schema: %v\n", err)
+		}
+	}
+}
+
+// inspectSchemaConfig returns the example config inspect prints for a schema.
+func inspectSchemaConfig(schema Schema) SchemaConfig {
+	tableConfig := map[string]TableConfig{}
+	for tableName, table := range schema.Tables {
+		var columnDescriptions map[string]string
+		for _, c := range table.Columns {
+			if c.Comment == "" {
+				continue
+			}
+			if columnDescriptions == nil {
+				columnDescriptions = map[string]string{}
+			}
+			columnDescriptions[c.Name] = c.Comment
+		}
+		tableConfig[tableName] = TableConfig{
+			ProtoName:               fmt.Sprintf("foo.v1.%s", strcase.ToCamel(tableName)),
+			PrimaryKey:              "id",
+			GenerateFieldMaskUpdate: true,
+			Description:             table.Comment,
+			ColumnDescriptions:      columnDescriptions,
+		}
+	}
+	return SchemaConfig{
+		SkipTables: []string{
+			"\"add tables to skip here (likely a migrations table)\"",
+		},
+		DefaultPrimaryKeyColumn: "id",
+		TableConfig:             tableConfig,
+	}
+}
+
+func initFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		if _, err := os.Stat(opts.configPath); err == nil {
+			fatalf("%s already exists, so it's left as it is\n", opts.configPath)
+		}
+		schemaNames := fs.Args()
+		if len(schemaNames) == 0 {
+			schemaNames = []string{"public"}
+		}
+		schemas := map[string]Schema{}
+		for _, schemaName := range schemaNames {
+			schema, err := inspectTablesInSchema(ctx, opts.databaseURL, schemaName, []string{}, opts.debug)
+			if err != nil {
+				fatalf("Unable to inspect schema: %v\n", err)
+			}
+			if len(schema.Tables) == 0 {
+				fatalf("No tables found in schema %s\n", schemaName)
+			}
+			schemas[schemaName] = schema
+		}
+		file, err := os.OpenFile(opts.configPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			fatalf("%s already exists, so it's left as it is\n", opts.configPath)
+		}
+		if err != nil {
+			fatalf("Unable to write config file: %v\n", err)
+		}
+		err = writeScaffoldConfig(file, schemas)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fatalf("Unable to write config file: %v\n", err)
+		}
+		fmt.Printf("Wrote %s\n", opts.configPath)
+	}
+}
+
+func editFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		schemaName := fs.Arg(0)
+		if schemaName == "" {
+			schemaName = "public"
+		}
+		schema, err := inspectTablesInSchema(ctx, opts.databaseURL, schemaName, []string{}, opts.debug)
+		if err != nil {
+			fatalf("Unable to inspect schema: %v\n", err)
+		}
+		if len(schema.Tables) == 0 {
+			fatalf("No tables found in schema %s\n", schemaName)
+		}
+
+		source, err := os.ReadFile(opts.configPath)
+		if os.IsNotExist(err) {
+			b := bytes.Buffer{}
+			err = writeScaffoldConfig(&b, map[string]Schema{schemaName: schema})
+			source = b.Bytes()
+		}
+		if err != nil {
+			fatalf("Unable to read config file: %v\n", err)
+		}
+		// The choices are started from the files the config includes too, but
+		// only written to the config itself, overriding them.
+		cfg, err := decodeConfig(opts.configPath, source)
+		if err != nil {
+			fatalf("Unable to read config file: %v\n", err)
+		}
+		root := yaml.Node{}
+		if err := yaml.Unmarshal(source, &root); err != nil {
+			fatalf("Unable to parse config file: %v\n", err)
+		}
+
+		editor := newTableEditor(schemaName, schema, cfg.SchemaConfig[schemaName])
+		var write bool
+		if restore, ok := rawTerminal(os.Stdin); ok {
+			write, err = editor.runScreen(os.Stdin, os.Stdout, terminalHeight(os.Stdin))
+			restore()
+		} else {
+			write, err = editor.run(os.Stdin, os.Stdout)
+		}
+		if err != nil {
+			fatalf("Unable to read commands: %v\n", err)
+		}
+		if !write {
+			fmt.Printf("%s left as it is\n", opts.configPath)
+			return
+		}
+		editor.update(&root)
+		b := bytes.Buffer{}
+		encoder := yaml.NewEncoder(&b)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&root)
+		if closeErr := encoder.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fatalf("Unable to encode config: %v\n", err)
+		}
+		if err := os.WriteFile(opts.configPath, b.Bytes(), 0644); err != nil {
+			fatalf("Unable to write config file: %v\n", err)
+		}
+		fmt.Printf("Wrote %s\n", opts.configPath)
+	}
+}
+
+func generateFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	fs.StringVar(&opts.outputPath, "output", "generated.sql", "Path to output file, or - for stdout, or the directory of a split output_layout (default queries)")
+	format := fs.String("format", "", "Output format for the diagram target (mermaid, dot, or plantuml)")
+	check := fs.Bool("check", false, "Compare the output with -output instead of writing it, printing a unified diff and exiting non-zero if they differ")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		if *check && opts.outputPath == "-" {
+			fatalf("-check compares the output with -output, so it can't be stdout\n")
+		}
+		target := fs.Arg(0)
+		if target == "" {
+			target = "sql"
+		}
+		generator, ok := targetGenerators[target]
+		if !ok && target != "sql" {
+			fatalf("Unknown target %s (run pginspector generate -h for the targets)\n", target)
+		}
+		cfg := opts.config()
+		if *format != "" {
+			cfg.DiagramFormat = *format
+		}
+		split, err := isSplitOutputLayout(cfg.OutputLayout)
+		if err != nil {
+			fatalf("Invalid config: %v\n", err)
+		}
+		if !ok && split {
+			runGenerateSplit(ctx, fs, opts, cfg, *check)
+			return
+		}
+
+		outputBuffer := bytes.NewBuffer([]byte{})
+		if ok {
+			schemas, err := loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug)
+			if err != nil {
+				fatalf("Unable to load schemas: %v\n", err)
+			}
+			err = generator(outputBuffer, schemas, cfg)
+			if err != nil {
+				fatalf("Unable to generate %s output: %v\n", target, err)
+			}
+		} else {
+			err := generate(ctx, opts.databaseURL, cfg, outputBuffer, opts.debug)
+			if err != nil {
+				fatalf("Unable to generate SQL: %v\n", err)
+			}
+		}
+		if *check {
+			opts.checkOutput(outputBuffer)
+			return
+		}
+		opts.writeOutput(outputBuffer)
+	}
+}
+
+// runGenerateSplit generates SQL in a split output_layout, into the -output
+// directory, queries unless it's set.
+func runGenerateSplit(ctx context.Context, fs *flag.FlagSet, opts *commandOptions, cfg GeneratorConfiguration, check bool) {
+	dir := "queries"
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "output" {
+			dir = opts.outputPath
+		}
+	})
+	if dir == "-" {
+		fatalf("output_layout %s writes a directory, so -output can't be stdout\n", cfg.OutputLayout)
+	}
+	schemas, err := loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug)
+	if err != nil {
+		fatalf("Unable to load schemas: %v\n", err)
+	}
+	files, err := splitSQL(ctx, schemas, cfg)
+	if err != nil {
+		fatalf("Unable to generate SQL: %v\n", err)
+	}
+	if check {
+		diff, err := diffOutputFiles(dir, files)
+		if err != nil {
+			fatalf("Unable to compare output: %v\n", err)
+		}
+		if diff == "" {
+			slog.Info("Output is up to date", "path", dir)
+			return
+		}
+		fmt.Print(diff)
+		slog.Error("Output is out of date, regenerate it", "path", dir)
+		os.Exit(1)
+	}
+	_, removed, err := writeOutputFiles(dir, files)
+	if err != nil {
+		fatalf("Unable to write output: %v\n", err)
+	}
+	for _, path := range removed {
+		slog.Info("Removed output file, which is no longer generated", "path", path)
+	}
+}
+
+func extractFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	fs.StringVar(&opts.outputPath, "output", "", "Path to output file, or directory for ndjson and csv, overriding extract.output (default extract.sql)")
+	direction := fs.String("direction", "", "Direction foreign keys are followed in (parents, children, or both), overriding extract.direction (default parents with samples)")
+	replace := fs.String("replace", "", "How the rows replaced are cleared before loading them, so loading again replaces them (truncate or delete), overriding extract.replace")
+	dryRun := fs.Bool("dry-run", false, "Report the tables that would be visited, with row counts estimated by a count(*) query, and the relations that wouldn't be followed, without extracting rows")
+	seedTable := fs.String("seed-table", "", "Table of the seed rows, qualified by its schema unless it's in public, overriding extract.seed_table and extract.samples")
+	seedValue := fs.String("seed-value", "", "Value of the seed key in the seed row, overriding extract.seed_value, extract.seed_values, and extract.seed_where")
+	targetURL := fs.String("target-database-url", "", "Database URL the extracted rows are inserted into in a transaction, instead of writing them to -output")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		if *targetURL == opts.databaseURL {
+			fatalf("-target-database-url must not be the database extracted from\n")
+		}
+		if *seedTable != "" {
+			cfg.Extract.SeedTable, cfg.Extract.Samples = *seedTable, nil
+		}
+		if *seedValue != "" {
+			cfg.Extract.SeedValue, cfg.Extract.SeedValues, cfg.Extract.SeedWhere, cfg.Extract.SeedArgs = *seedValue, nil, "", nil
+		}
+		if *direction != "" {
+			cfg.Extract.Direction = *direction
+		}
+		if *replace != "" {
+			cfg.Extract.Replace = *replace
+		}
+		format, output, err := extractOutput(cfg.Extract)
+		if err != nil {
+			fatalf("Invalid extract config: %v\n", err)
+		}
+		if opts.outputPath != "" {
+			output = opts.outputPath
+		}
+		if (format == "ndjson" || format == "csv") && output == "-" {
+			fatalf("The %s format writes a directory, so it can't be written to stdout\n", format)
+		}
+		var schemas []GenerationSchema
+		if cfg.Extract.SchemaSnapshot != "" {
+			snapshotFile, err := os.Open(cfg.Extract.SchemaSnapshot)
+			if err != nil {
+				fatalf("Unable to open schema snapshot: %v\n", err)
+			}
+			snapshot, err := readSchemaSnapshot(snapshotFile)
+			snapshotFile.Close()
+			if err != nil {
+				fatalf("Unable to load schemas: %v\n", err)
+			}
+			schemas, err = buildGenerationSchemas(cfg, snapshot.inspect)
+			if err != nil {
+				fatalf("Unable to load schemas: %v\n", err)
+			}
+		} else if schemas, err = loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug); err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		pool, err := connect(ctx, opts.databaseURL, opts.debug)
+		if err != nil {
+			fatalf("Unable to connect: %v\n", err)
+		}
+		defer pool.Close()
+		if *dryRun {
+			plan, err := extractPlan(ctx, pool, schemas, cfg.Extract)
+			if err != nil {
+				fatalf("Unable to plan extraction: %v\n", err)
+			}
+			err = writeExtractPlan(os.Stdout, plan)
+			if err != nil {
+				fatalf("Unable to write extraction plan: %v\n", err)
+			}
+			return
+		}
+		var extraction *Extraction
+		if *targetURL != "" {
+			target, err := connect(ctx, *targetURL, opts.debug)
+			if err != nil {
+				fatalf("Unable to connect to the target database: %v\n", err)
+			}
+			defer target.Close()
+			clone, err := newExtractClone(ctx, target, cfg.Extract)
+			if err != nil {
+				fatalf("Unable to clone extracted rows: %v\n", err)
+			}
+			defer clone.close()
+			extraction, err = extractStreaming(ctx, pool, schemas, cfg.Extract, clone)
+			if err != nil {
+				fatalf("Unable to extract rows: %v\n", err)
+			}
+			err = clone.finish(schemas, extraction)
+			if err != nil {
+				fatalf("Unable to clone extracted rows: %v\n", err)
+			}
+			format, output = "clone", ""
+		} else {
+			spool, err := newExtractSpool(format, output, cfg.Extract)
+			if err != nil {
+				fatalf("Unable to write extracted rows: %v\n", err)
+			}
+			defer spool.close()
+			extraction, err = extractStreaming(ctx, pool, schemas, cfg.Extract, spool)
+			if err != nil {
+				fatalf("Unable to extract rows: %v\n", err)
+			}
+			var w io.Writer = os.Stdout
+			if (format == "sql" || format == "json") && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					fatalf("Unable to write output: %v\n", err)
+				}
+				defer file.Close()
+				w = file
+			}
+			err = spool.finish(w, schemas, extraction)
+			if err != nil {
+				fatalf("Unable to write extracted rows: %v\n", err)
+			}
+		}
+		manifest := extractManifest(schemas, extraction, cfg.Extract, format, output)
+		if cfg.Extract.Manifest != "" {
+			manifestBuffer := bytes.NewBuffer([]byte{})
+			err = writeExtractManifest(manifestBuffer, manifest)
+			if err == nil {
+				err = os.WriteFile(cfg.Extract.Manifest, manifestBuffer.Bytes(), 0644)
+			}
+			if err != nil {
+				fatalf("Unable to write extract manifest: %v\n", err)
+			}
+		}
+		fmt.Fprint(os.Stderr, extractSummary(manifest))
+	}
+}
+
+func configCommandFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	return func(ctx context.Context, args []string) {
+		subcommand := ""
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			subcommand, args = args[0], args[1:]
+		}
+		opts.parse(fs, args)
+		if subcommand == "" {
+			subcommand = fs.Arg(0)
+		}
+		if subcommand != "validate" {
+			fatalf("Unknown config command %q, expected validate\n", subcommand)
+		}
+		source, err := os.ReadFile(opts.configPath)
+		if err != nil {
+			fatalf("Unable to open config file: %v\n", err)
+		}
+		diagnostics, err := validateConfig(opts.configPath, source, func(schemaName string, excludedTableNames []string) (Schema, error) {
+			return inspectTablesInSchema(ctx, opts.databaseURL, schemaName, excludedTableNames, opts.debug)
+		})
+		if err != nil {
+			fatalf("Unable to validate config file: %v\n", err)
+		}
+		err = writeConfigDiagnostics(os.Stdout, diagnostics)
+		if err != nil {
+			fatalf("Unable to write output to stdout: %v\n", err)
+		}
+		if len(diagnostics) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+func snapshotFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	fs.StringVar(&opts.outputPath, "output", "schema-snapshot.json", "Path to output file")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		snapshot, err := takeSchemaSnapshot(ctx, opts.databaseURL, cfg, opts.debug)
+		if err != nil {
+			fatalf("Unable to snapshot schemas: %v\n", err)
+		}
+		snapshotBuffer := bytes.NewBuffer([]byte{})
+		err = writeSchemaSnapshot(snapshotBuffer, snapshot)
+		if err == nil {
+			err = os.WriteFile(opts.outputPath, snapshotBuffer.Bytes(), 0644)
+		}
+		if err != nil {
+			fatalf("Unable to write schema snapshot: %v\n", err)
+		}
+	}
+}
+
+func diffFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	snapshotPath := fs.String("snapshot", "schema-snapshot.json", "Path to the schema snapshot to compare with, as written by the snapshot command")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		snapshotFile, err := os.Open(*snapshotPath)
+		if err != nil {
+			fatalf("Unable to open schema snapshot: %v\n", err)
+		}
+		snapshot, err := readSchemaSnapshot(snapshotFile)
+		snapshotFile.Close()
+		if err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		snapshotSchemas, err := buildGenerationSchemas(cfg, snapshot.inspect)
+		if err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		databaseSchemas, err := loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug)
+		if err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		diff, err := schemaDiff(*snapshotPath, snapshotSchemas, databaseSchemas, cfg)
+		if err != nil {
+			fatalf("Unable to compare schemas: %v\n", err)
+		}
+		if diff == "" {
+			slog.Info("Database matches the schema snapshot", "path", *snapshotPath)
+			return
+		}
+		fmt.Print(diff)
+		os.Exit(1)
+	}
+}
+
+func lintFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		schemas, err := loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug)
+		if err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		problems := lintSchemas(schemas)
+		err = writeLintProblems(os.Stdout, problems)
+		if err != nil {
+			fatalf("Unable to write output to stdout: %v\n", err)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+func migrationFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	return migrationsFlags(fs, false)
+}
+
+func flywayFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	return migrationsFlags(fs, true)
+}
+
+// migrationsFlags defines the flags of migration, or flyway, returning the
+// function writing migration files, for golang-migrate from each of
+// -migrations, or for Flyway.
+func migrationsFlags(fs *flag.FlagSet, flyway bool) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	fs.StringVar(&opts.outputPath, "output", "migrations", "Directory the migrations are written to")
+	var migrations *string
+	if !flyway {
+		migrations = fs.String("migrations", "ddl", "Comma-separated migration sources (ddl, audit, updated_at, history, outbox, rls, grants, comments, partitions, postgrest)")
+	}
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		schemas, err := loadGenerationSchemas(ctx, opts.databaseURL, cfg, opts.debug)
+		if err != nil {
+			fatalf("Unable to load schemas: %v\n", err)
+		}
+		dir := opts.outputPath
+		var paths []string
+		if flyway {
+			paths, err = writeFlywayMigrations(dir, schemas, cfg)
+		} else {
+			sources := []string{}
+			for _, source := range strings.Split(*migrations, ",") {
+				if source = strings.TrimSpace(source); source != "" {
+					sources = append(sources, source)
+				}
+			}
+			paths, err = writeMigrations(dir, sources, schemas, cfg)
+		}
+		if err != nil {
+			fatalf("Unable to write migrations: %v\n", err)
+		}
+		if len(paths) == 0 {
+			slog.Info("Nothing to migrate")
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+	}
+}
+
+func compatFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	opts := &commandOptions{}
+	opts.configFlags(fs)
+	compatURLs := fs.String("compat-database-urls", "", "Comma-separated database URLs to compare generation against")
+	return func(ctx context.Context, args []string) {
+		opts.parse(fs, args)
+		cfg := opts.config()
+		databaseURLs := []string{opts.databaseURL}
+		for _, u := range strings.Split(*compatURLs, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				databaseURLs = append(databaseURLs, u)
+			}
+		}
+		err := compatibilityMatrix(ctx, databaseURLs, cfg, os.Stdout, opts.debug)
+		if err != nil {
+			fatalf("Unable to build compatibility matrix: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// commentValue returns a comment as a literal, or NULL to remove it.
+func commentValue(comment string) string {
+	if comment == "" {
+		return "NULL"
+	}
+	return ddlLiteral(comment)
+}
+
+// hasDescriptions reports whether any table has a description or column
+// descriptions configured.
+func hasDescriptions(schemas []GenerationSchema) bool {
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			if table.Config.Description != "" || len(table.Config.ColumnDescriptions) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commentMigration sets the comments on tables and columns from their
+// description and column_descriptions in the config. The down script restores
+// the comments that were inspected.
+func commentMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	up := strings.Builder{}
+	down := strings.Builder{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			name := ddlTableName(&table.Table)
+			for column := range table.Config.ColumnDescriptions {
+				if !table.HasColumn(column) {
+					return Migration{}, errors.Errorf("Described column %s not found in table %s.%s", column, table.Schema, table.Name)
+				}
+			}
+			if table.Config.Description != "" {
+				fmt.Fprintf(&up, "COMMENT ON TABLE %s IS %s;\n", name, ddlLiteral(table.Config.Description))
+				fmt.Fprintf(&down, "COMMENT ON TABLE %s IS %s;\n", name, commentValue(table.Comment))
+			}
+			for _, c := range table.Columns {
+				description, ok := table.Config.ColumnDescriptions[c.Name]
+				if !ok || description == "" {
+					continue
+				}
+				fmt.Fprintf(&up, "COMMENT ON COLUMN %s.%s IS %s;\n", name, ddlIdent(c.Name), ddlLiteral(description))
+				fmt.Fprintf(&down, "COMMENT ON COLUMN %s.%s IS %s;\n", name, ddlIdent(c.Name), commentValue(c.Comment))
+			}
+		}
+	}
+	return Migration{Name: "comments", Up: up.String(), Down: down.String()}, nil
+}
+
+// generateComments writes the COMMENT ON statements from commentMigration.
+func generateComments(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if !hasDescriptions(schemas) {
+		return errors.New("No tables have a description or column_descriptions set")
+	}
+	return writeMigrationUp(w, commentMigration, schemas, cfg)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/parrotmac/pginspector/models"
+	"github.com/pkg/errors"
+)
+
+// CompatTarget is the result of running generation against a single database.
+type CompatTarget struct {
+	Label   string
+	Version string
+	Queries map[string]string
+	// Failures are the errors preparing the generated queries on the
+	// database, by query name.
+	Failures map[string]string
+	Err      error
+}
+
+// CompatDifference describes how a target's generated queries differ from the
+// baseline (the first target).
+type CompatDifference struct {
+	Missing   []string
+	Extra     []string
+	Changed   []string
+	Identical bool
+}
+
+// splitNamedQueries splits generated output into individual queries keyed on
+// the name given in their "-- name:" annotation.
+func splitNamedQueries(output string) map[string]string {
+	queries := map[string]string{}
+	name := ""
+	body := strings.Builder{}
+	flush := func() {
+		if name != "" {
+			queries[name] = strings.TrimSpace(body.String())
+		}
+		body.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "-- name: ") {
+			flush()
+			fields := strings.Fields(strings.TrimPrefix(line, "-- name: "))
+			if len(fields) > 0 {
+				name = fields[0]
+			}
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return queries
+}
+
+// compareQueries reports the queries in target that are missing, extra, or
+// changed relative to baseline.
+func compareQueries(baseline map[string]string, target map[string]string) CompatDifference {
+	diff := CompatDifference{}
+	for name, query := range baseline {
+		other, ok := target[name]
+		if !ok {
+			diff.Missing = append(diff.Missing, name)
+			continue
+		}
+		if other != query {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range target {
+		if _, ok := baseline[name]; !ok {
+			diff.Extra = append(diff.Extra, name)
+		}
+	}
+	sort.Strings(diff.Missing)
+	sort.Strings(diff.Extra)
+	sort.Strings(diff.Changed)
+	diff.Identical = len(diff.Missing) == 0 && len(diff.Extra) == 0 && len(diff.Changed) == 0
+	return diff
+}
+
+// describeDatabaseURL returns a label for a database URL that doesn't leak
+// credentials into the report.
+func describeDatabaseURL(databaseURL string) string {
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return "<invalid database url>"
+	}
+	return fmt.Sprintf("%s:%d/%s", cfg.ConnConfig.Host, cfg.ConnConfig.Port, cfg.ConnConfig.Database)
+}
+
+func serverVersion(ctx context.Context, databaseURL string, debug bool) (string, error) {
+	pool, err := connect(ctx, databaseURL, debug)
+	if err != nil {
+		return "", err
+	}
+	defer pool.Close()
+
+	version, err := models.NewQuerier(pool).GetServerVersion(ctx)
+	if err != nil {
+		return "", errors.WithMessage(err, "Unable to query server version")
+	}
+	return Unwrap(version), nil
+}
+
+func runCompatTarget(ctx context.Context, databaseURL string, cfg GeneratorConfiguration, debug bool) CompatTarget {
+	target := CompatTarget{
+		Label: describeDatabaseURL(databaseURL),
+	}
+
+	version, err := serverVersion(ctx, databaseURL, debug)
+	if err != nil {
+		target.Err = err
+		return target
+	}
+	target.Version = version
+
+	// Positional arguments are generated so the queries can be prepared.
+	cfg.Dialect = DialectPositional
+	outputBuffer := bytes.NewBuffer([]byte{})
+	err = generate(ctx, databaseURL, cfg, outputBuffer, debug)
+	if err != nil {
+		target.Err = err
+		return target
+	}
+	target.Queries = splitNamedQueries(outputBuffer.String())
+
+	pool, err := connect(ctx, databaseURL, debug)
+	if err != nil {
+		target.Err = err
+		return target
+	}
+	defer pool.Close()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		target.Err = errors.WithMessage(err, "Unable to begin transaction")
+		return target
+	}
+	// Nothing is executed, but the transaction is rolled back all the same.
+	defer tx.Rollback(ctx)
+	target.Failures, err = prepareQueries(ctx, tx, target.Queries)
+	if err != nil {
+		target.Err = err
+	}
+	return target
+}
+
+// compatTx is the part of a transaction prepareQueries uses.
+type compatTx interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Prepare(ctx context.Context, name string, sql string) (*pgconn.StatementDescription, error)
+}
+
+// prepareQueries prepares each query in a transaction, returning the errors
+// of those that fail by name. Each is prepared in a savepoint, since a
+// failure aborts the transaction.
+func prepareQueries(ctx context.Context, tx compatTx, queries map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := map[string]string{}
+	for i, name := range names {
+		if _, err := tx.Exec(ctx, "SAVEPOINT pginspector_compat"); err != nil {
+			return nil, errors.WithMessage(err, "Unable to create savepoint")
+		}
+		_, err := tx.Prepare(ctx, fmt.Sprintf("pginspector_compat_%d", i), queries[name])
+		if err == nil {
+			continue
+		}
+		failures[name] = err.Error()
+		if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT pginspector_compat"); err != nil {
+			return nil, errors.WithMessage(err, "Unable to roll back to savepoint")
+		}
+	}
+	return failures, nil
+}
+
+// compatibilityMatrix runs generation against every database URL and writes a
+// report comparing each target to the first one.
+func compatibilityMatrix(ctx context.Context, databaseURLs []string, cfg GeneratorConfiguration, w io.Writer, debug bool) error {
+	if len(databaseURLs) < 2 {
+		return errors.New("At least two database URLs are required to build a compatibility matrix")
+	}
+
+	targets := make([]CompatTarget, 0, len(databaseURLs))
+	for _, databaseURL := range databaseURLs {
+		targets = append(targets, runCompatTarget(ctx, databaseURL, cfg, debug))
+	}
+
+	baseline := targets[0]
+	if baseline.Err != nil {
+		return errors.WithMessagef(baseline.Err, "Unable to generate against baseline %s", baseline.Label)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "TARGET\tVERSION\tSTATUS\n")
+	fmt.Fprintf(tw, "%s\t%s\tbaseline (%d queries)%s\n", baseline.Label, baseline.Version, len(baseline.Queries), compatFailureStatus(baseline))
+	details := compatFailureDetails(baseline)
+	for _, target := range targets[1:] {
+		if target.Err != nil {
+			fmt.Fprintf(tw, "%s\t%s\tfailed\n", target.Label, target.Version)
+			details = append(details, fmt.Sprintf("%s: %v", target.Label, target.Err))
+			continue
+		}
+		details = append(details, compatFailureDetails(target)...)
+		diff := compareQueries(baseline.Queries, target.Queries)
+		if diff.Identical {
+			fmt.Fprintf(tw, "%s\t%s\tidentical%s\n", target.Label, target.Version, compatFailureStatus(target))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d changed, %d missing, %d extra%s\n", target.Label, target.Version, len(diff.Changed), len(diff.Missing), len(diff.Extra), compatFailureStatus(target))
+		for _, name := range diff.Changed {
+			details = append(details, fmt.Sprintf("%s: %s differs", target.Label, name))
+		}
+		for _, name := range diff.Missing {
+			details = append(details, fmt.Sprintf("%s: %s missing", target.Label, name))
+		}
+		for _, name := range diff.Extra {
+			details = append(details, fmt.Sprintf("%s: %s only generated here", target.Label, name))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.WithMessage(err, "Unable to write compatibility matrix")
+	}
+
+	if len(details) > 0 {
+		fmt.Fprintf(w, "\n")
+		for _, detail := range details {
+			fmt.Fprintf(w, "%s\n", detail)
+		}
+	}
+	return nil
+}
+
+// compatFailureStatus summarizes the queries that fail to prepare on a
+// target, for its status.
+func compatFailureStatus(target CompatTarget) string {
+	if len(target.Failures) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d fail to prepare", len(target.Failures))
+}
+
+// compatFailureDetails describes each query that fails to prepare on a
+// target, with the error of its server version.
+func compatFailureDetails(target CompatTarget) []string {
+	names := make([]string, 0, len(target.Failures))
+	for name := range target.Failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	details := make([]string, 0, len(names))
+	for _, name := range names {
+		details = append(details, fmt.Sprintf("%s (%s): %s fails: %s", target.Label, target.Version, name, target.Failures[name]))
+	}
+	return details
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	// completion is added here rather than in commands since it completes
+	// the commands itself.
+	commands = append(commands, command{
+		name:        "completion",
+		args:        "bash|zsh|fish",
+		summary:     "Print a shell completion script",
+		description: "The script completes the commands, their flags, the values of flags like -log-level and -format, generate targets, and schema and table names, listed from -database-url on the command line or DATABASE_URL. Load it with source <(pginspector completion bash) in bash, source <(pginspector completion zsh) in zsh, or pginspector completion fish | source in fish.",
+		flags:       completionFlags,
+	})
+}
+
+// completionScripts are the completion scripts by shell. They pass the words
+// typed so far to pginspector completion -complete, falling back to files
+// when it completes nothing, e.g. for -config.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for pginspector
+_pginspector() {
+	local IFS=$'\n'
+	COMPREPLY=($(pginspector completion -complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _pginspector pginspector
+`,
+	"zsh": `#compdef pginspector
+# zsh completion for pginspector
+_pginspector() {
+	local -a completions
+	completions=("${(@f)$(pginspector completion -complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${completions[1]}" ]]; then
+		compadd -a completions
+	else
+		_files
+	fi
+}
+compdef _pginspector pginspector
+`,
+	"fish": `# fish completion for pginspector
+function __pginspector_complete
+	set -l words (commandline -opc)[2..-1] (commandline -ct)
+	set -l completions (pginspector completion -complete -- $words 2>/dev/null)
+	if test (count $completions) -gt 0
+		printf '%s\n' $completions
+	else
+		__fish_complete_path (commandline -ct)
+	end
+end
+complete -c pginspector -f -a '(__pginspector_complete)'
+`,
+}
+
+// flagCompletions are the values completed for flags, by name, or by command
+// and name for flags that differ between commands.
+var flagCompletions = map[string][]string{
+	"log-level":         {"debug", "info", "warn", "error"},
+	"log-format":        {"text", "json"},
+	"inspect format":    {"yaml", "json"},
+	"generate format":   {"mermaid", "dot", "plantuml"},
+	"extract direction": {"parents", "children", "both"},
+	"extract replace":   {"truncate", "delete"},
+}
+
+// Kinds of names completionNames lists from the database.
+const (
+	completeSchemas = "schemas"
+	completeTables  = "tables"
+)
+
+// nameFlagCompletions are the flags completed with the names of schemas or
+// tables in the database, by command and name.
+var nameFlagCompletions = map[string]string{
+	"inspect schema":     completeSchemas,
+	"extract seed-table": completeTables,
+}
+
+// completionNames lists the names of a kind, schemas or tables, in a
+// database.
+type completionNames func(ctx context.Context, databaseURL string, kind string) ([]string, error)
+
+func completionFlags(fs *flag.FlagSet) func(ctx context.Context, args []string) {
+	complete := fs.Bool("complete", false, "Print the completions of the words after --, as the completion scripts do")
+	return func(ctx context.Context, args []string) {
+		// ExitOnError flag sets exit rather than returning an error.
+		_ = fs.Parse(args)
+		if *complete {
+			ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+			defer cancel()
+			for _, completion := range completeWords(ctx, fs.Args(), listCompletionNames) {
+				fmt.Println(completion)
+			}
+			return
+		}
+		script, ok := completionScripts[fs.Arg(0)]
+		if !ok {
+			fatalf("Unknown shell %q, expected bash, zsh, or fish\n", fs.Arg(0))
+		}
+		fmt.Print(script)
+	}
+}
+
+// listCompletionNames lists the names of schemas or tables in a database for
+// completion.
+func listCompletionNames(ctx context.Context, databaseURL string, kind string) ([]string, error) {
+	if kind == completeTables {
+		return listTables(ctx, databaseURL, false)
+	}
+	return listSchemas(ctx, databaseURL, false)
+}
+
+// commandFlags returns the flags a command defines.
+func commandFlags(cmd command) []*flag.Flag {
+	fs := flag.NewFlagSet("pginspector "+cmd.name, flag.ContinueOnError)
+	cmd.flags(fs)
+	flags := []*flag.Flag{}
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// completeWords returns the completions of the last of the words typed after
+// pginspector, which is "" when completing a new word. Schema and table
+// names are listed from the database of -database-url among the words, or
+// DATABASE_URL, if either is set.
+func completeWords(ctx context.Context, words []string, listNames completionNames) []string {
+	current := ""
+	if len(words) > 0 {
+		current = words[len(words)-1]
+	}
+	commandNames := []string{"help"}
+	for _, cmd := range commands {
+		commandNames = append(commandNames, cmd.name)
+	}
+	if len(words) <= 1 {
+		return completionsWithPrefix(commandNames, current)
+	}
+	if words[0] == "help" {
+		if len(words) == 2 {
+			return completionsWithPrefix(commandNames[1:], current)
+		}
+		return nil
+	}
+	cmd, ok := findCommand(words[0])
+	if !ok {
+		return nil
+	}
+	flags := commandFlags(cmd)
+	flagsByName := map[string]*flag.Flag{}
+	for _, f := range flags {
+		flagsByName[f.Name] = f
+	}
+	databaseNames := func(kind string) []string {
+		databaseURL := os.Getenv("DATABASE_URL")
+		for i, word := range words[1 : len(words)-1] {
+			name, value, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+			if name == "database-url" && strings.HasPrefix(word, "-") {
+				if hasValue {
+					databaseURL = value
+				} else if next := i + 2; next < len(words)-1 {
+					if words[next] == "=" && next+1 < len(words)-1 {
+						next++
+					}
+					databaseURL = words[next]
+				}
+			}
+		}
+		if databaseURL == "" {
+			return nil
+		}
+		names, err := listNames(ctx, databaseURL, kind)
+		if err != nil {
+			return nil
+		}
+		return names
+	}
+	flagValues := func(name string) []string {
+		if kind, ok := nameFlagCompletions[cmd.name+" "+name]; ok {
+			return databaseNames(kind)
+		}
+		if values, ok := flagCompletions[cmd.name+" "+name]; ok {
+			return values
+		}
+		return flagCompletions[name]
+	}
+
+	// The value of a flag, as -flag=value, or -flag value, which bash splits
+	// into -flag, =, and value.
+	if strings.HasPrefix(current, "-") && strings.Contains(current, "=") {
+		name, value, _ := strings.Cut(current, "=")
+		completions := []string{}
+		for _, completion := range completionsWithPrefix(flagValues(strings.TrimLeft(name, "-")), value) {
+			completions = append(completions, name+"="+completion)
+		}
+		return completions
+	}
+	previous := words[len(words)-2]
+	if previous == "=" && len(words) > 2 {
+		previous = words[len(words)-3]
+	}
+	if f, ok := flagsByName[strings.TrimLeft(previous, "-")]; ok && strings.HasPrefix(previous, "-") && !isBoolFlag(f) {
+		return completionsWithPrefix(flagValues(f.Name), current)
+	}
+
+	if strings.HasPrefix(current, "-") {
+		dashes := "-"
+		if strings.HasPrefix(current, "--") {
+			dashes = "--"
+		}
+		names := []string{}
+		for _, f := range flags {
+			names = append(names, dashes+f.Name)
+		}
+		return completionsWithPrefix(names, current)
+	}
+	switch cmd.name {
+	case "inspect", "init", "edit":
+		return completionsWithPrefix(databaseNames(completeSchemas), current)
+	case "generate":
+		targets := []string{"sql"}
+		for _, target := range generateTargets {
+			targets = append(targets, target[0])
+		}
+		return completionsWithPrefix(targets, current)
+	case "config":
+		return completionsWithPrefix([]string{"validate"}, current)
+	case "completion":
+		return completionsWithPrefix([]string{"bash", "zsh", "fish"}, current)
+	}
+	return nil
+}
+
+// isBoolFlag reports whether a flag is a bool flag, which takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// completionsWithPrefix returns the values starting with a prefix.
+func completionsWithPrefix(values []string, prefix string) []string {
+	completions := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			completions = append(completions, value)
+		}
+	}
+	return completions
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigDiagnostic is a problem with the config, at the line and column of
+// the YAML it was found in.
+type ConfigDiagnostic struct {
+	// Path is the file of the config, or of a file it includes.
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// validateConfig checks a config against the schemas it configures, as
+// inspect finds them: tables in table_config, skip_tables, and
+// include_tables that don't exist, primary keys that aren't columns of their
+// table, and proto names used by more than one table. Problems in files the
+// config includes are reported in those files.
+func validateConfig(path string, source []byte, inspect func(schemaName string, excludedTableNames []string) (Schema, error)) ([]ConfigDiagnostic, error) {
+	sources := configSources{}
+	document, err := readConfigDocument(path, source, sources)
+	if err != nil {
+		return nil, err
+	}
+	cfg := GeneratorConfiguration{}
+	if err := document.Decode(&cfg); err != nil {
+		return nil, errors.WithMessage(err, "Unable to parse config file")
+	}
+
+	diagnostics := []ConfigDiagnostic{}
+	report := func(node *yaml.Node, format string, args ...interface{}) {
+		diagnostic := ConfigDiagnostic{Path: path, Message: fmt.Sprintf(format, args...)}
+		if node != nil {
+			diagnostic.Line, diagnostic.Column = node.Line, node.Column
+			if source, ok := sources[node]; ok {
+				diagnostic.Path = source
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	_, schemaConfigsNode := configNode(document, "schema_config")
+	schemaNames := make([]string, 0, len(cfg.SchemaConfig))
+	for schemaName := range cfg.SchemaConfig {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	protoNames := map[string]string{}
+
+	for _, schemaName := range schemaNames {
+		schemaConfig := cfg.SchemaConfig[schemaName]
+		schemaKey, schemaNode := configNode(schemaConfigsNode, schemaName)
+		// Skipped tables are inspected too, so skip_tables can be checked.
+		schema, err := inspect(schemaName, []string{})
+		if err != nil {
+			return nil, errors.WithMessage(err, "Unable to inspect schema")
+		}
+		if len(schema.Tables) == 0 {
+			report(schemaKey, "Schema %s has no tables", schemaName)
+			continue
+		}
+
+		_, tableConfigsNode := configNode(schemaNode, "table_config")
+		for _, tableKey := range configKeys(tableConfigsNode) {
+			if _, ok := schema.Tables[tableKey.Value]; !ok {
+				report(tableKey, "Table %s.%s in table_config does not exist", schemaName, tableKey.Value)
+				continue
+			}
+			_, tableNode := configNode(tableConfigsNode, tableKey.Value)
+			if _, protoNode := configNode(tableNode, "proto_name"); protoNode != nil && protoNode.Value != "" {
+				name := schemaName + "." + tableKey.Value
+				if other, ok := protoNames[protoNode.Value]; ok {
+					report(protoNode, "Proto name %s of %s is also used by %s", protoNode.Value, name, other)
+				} else {
+					protoNames[protoNode.Value] = name
+				}
+			}
+		}
+
+		for _, list := range []struct{ key, name string }{{"skip_tables", "Skipped"}, {"include_tables", "Included"}} {
+			_, listNode := configNode(schemaNode, list.key)
+			if listNode == nil {
+				continue
+			}
+			for _, entryNode := range listNode.Content {
+				if err := checkTablePattern(entryNode.Value); err != nil {
+					report(entryNode, "%v", err)
+					continue
+				}
+				matched := false
+				for tableName := range schema.Tables {
+					matched = matched || matchTablePattern(entryNode.Value, tableName)
+				}
+				switch {
+				case matched:
+				case isTablePattern(entryNode.Value):
+					report(entryNode, "%s table pattern %s matches no table in schema %s", list.name, entryNode.Value, schemaName)
+				default:
+					report(entryNode, "%s table %s matches no table in schema %s", list.name, entryNode.Value, schemaName)
+				}
+			}
+		}
+
+		defaultKey, _ := configNode(schemaNode, "default_primary_key_name")
+		tableNames := make([]string, 0, len(schema.Tables))
+		for tableName := range schema.Tables {
+			tableNames = append(tableNames, tableName)
+		}
+		sort.Strings(tableNames)
+		for _, tableName := range tableNames {
+			if schemaConfig.ShouldSkipTable(tableName) {
+				continue
+			}
+			table := schema.Tables[tableName]
+			_, tableNode := configNode(tableConfigsNode, tableName)
+			_, primaryKeyNode := configNode(tableNode, "primary_key")
+			switch {
+			case primaryKeyNode != nil && primaryKeyNode.Value != "":
+				if !table.HasColumn(primaryKeyNode.Value) {
+					report(primaryKeyNode, "Primary key %s is not a column of %s.%s", primaryKeyNode.Value, schemaName, tableName)
+				}
+			case schemaConfig.DefaultPrimaryKeyColumn == "":
+				report(schemaKey, "No primary key for table %s.%s: set default_primary_key_name or table_config.%s.primary_key", schemaName, tableName, tableName)
+			case !table.HasColumn(schemaConfig.DefaultPrimaryKeyColumn):
+				report(defaultKey, "Default primary key %s is not a column of %s.%s", schemaConfig.DefaultPrimaryKeyColumn, schemaName, tableName)
+			}
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Path != diagnostics[j].Path {
+			return diagnostics[i].Path < diagnostics[j].Path
+		}
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics, nil
+}
+
+// configNode returns the key and value nodes of a key of a mapping node, or
+// nils if it's not set.
+func configNode(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// configKeys returns the key nodes of a mapping node, in the order they're
+// written.
+func configKeys(mapping *yaml.Node) []*yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make([]*yaml.Node, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keys = append(keys, mapping.Content[i])
+	}
+	return keys
+}
+
+// writeConfigDiagnostics writes diagnostics as path:line:column: message.
+func writeConfigDiagnostics(w io.Writer, diagnostics []ConfigDiagnostic) error {
+	for _, diagnostic := range diagnostics {
+		_, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", diagnostic.Path, diagnostic.Line, diagnostic.Column, diagnostic.Message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dbmlName returns the DBML name of a table. Tables in the public schema
+// (DBML's default) use their bare name.
+func dbmlName(t *Table) string {
+	if t.Schema == "" || t.Schema == "public" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// dbmlType quotes types that contain spaces, such as
+// "timestamp without time zone".
+func dbmlType(c Column) string {
+	typ := c.SQLType()
+	if strings.ContainsAny(typ, " []") {
+		return fmt.Sprintf("%q", typ)
+	}
+	return typ
+}
+
+// dbmlString renders a DBML string literal, switching to a multi-line string
+// when needed.
+func dbmlString(s string) string {
+	if strings.Contains(s, "\n") {
+		return "'''" + strings.ReplaceAll(s, "'''", "\\'''") + "'''"
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func dbmlColumnSettings(t *GenerationTable, c Column) []string {
+	settings := []string{}
+	if c.Name == t.Config.PrimaryKey {
+		settings = append(settings, "pk")
+	}
+	if c.Nullable {
+		settings = append(settings, "null")
+	} else {
+		settings = append(settings, "not null")
+	}
+	if c.Default != "" {
+		settings = append(settings, "default: `"+c.Default+"`")
+	}
+	if c.Comment != "" {
+		settings = append(settings, "note: "+dbmlString(c.Comment))
+	}
+	return settings
+}
+
+// generateDBML writes the selected tables as DBML (dbdiagram.io), with a Ref
+// per foreign key and notes from table and column comments.
+func generateDBML(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	b := strings.Builder{}
+	first := true
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			if !first {
+				b.WriteString("\n")
+			}
+			first = false
+
+			fmt.Fprintf(&b, "Table %s {\n", dbmlName(&table.Table))
+			for _, c := range table.Columns {
+				fmt.Fprintf(&b, "  %s %s [%s]\n", c.Name, dbmlType(c), strings.Join(dbmlColumnSettings(table, c), ", "))
+			}
+			if table.Comment != "" {
+				fmt.Fprintf(&b, "\n  Note: %s\n", dbmlString(table.Comment))
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	edges := diagramEdges(schemas)
+	if len(edges) > 0 {
+		b.WriteString("\n")
+	}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		target := edge.Column.Relation.Column.Name
+		fmt.Fprintf(&b, "Ref: %s.%s > %s.%s\n", dbmlName(&edge.From.Table), edge.Column.Name, dbmlName(&edge.To.Table), target)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ddlReservedWords are keywords that can't be used as bare identifiers.
+var ddlReservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true, "array": true,
+	"as": true, "asc": true, "both": true, "case": true, "cast": true, "check": true,
+	"collate": true, "column": true, "constraint": true, "create": true, "default": true,
+	"desc": true, "distinct": true, "do": true, "else": true, "end": true, "except": true,
+	"false": true, "for": true, "foreign": true, "from": true, "grant": true, "group": true,
+	"having": true, "in": true, "limit": true, "not": true, "null": true, "offset": true,
+	"on": true, "only": true, "or": true, "order": true, "primary": true, "references": true,
+	"select": true, "table": true, "then": true, "to": true, "true": true, "union": true,
+	"unique": true, "user": true, "using": true, "when": true, "where": true, "with": true,
+}
+
+var ddlBareIdentPattern = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// ddlIdent quotes an identifier unless it can be written bare.
+func ddlIdent(name string) string {
+	if ddlBareIdentPattern.MatchString(name) && !ddlReservedWords[name] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func ddlTableName(t *Table) string {
+	return ddlIdent(t.Schema) + "." + ddlIdent(t.Name)
+}
+
+func ddlLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ddlUserType returns the qualified name of a user-defined type.
+func ddlUserType(c Column, name string) string {
+	if c.UDTSchema == "" || c.UDTSchema == "pg_catalog" {
+		return ddlIdent(name)
+	}
+	return ddlIdent(c.UDTSchema) + "." + ddlIdent(name)
+}
+
+// ddlType returns the column's type as written in CREATE TABLE, including
+// the declared length, precision, and scale.
+func ddlType(c Column) string {
+	switch {
+	case c.PGType == "USER-DEFINED":
+		return ddlUserType(c, c.UDTName)
+	case c.PGType == "ARRAY":
+		return ddlUserType(c, strings.TrimPrefix(c.UDTName, "_")) + "[]"
+	case (c.PGType == "character varying" || c.PGType == "character") && c.MaxLength > 0:
+		return fmt.Sprintf("%s(%d)", c.PGType, c.MaxLength)
+	case c.PGType == "numeric" && c.NumericPrecision > 0:
+		return fmt.Sprintf("numeric(%d,%d)", c.NumericPrecision, c.NumericScale)
+	}
+	return c.PGType
+}
+
+// ddlSerialTypes are written for integer columns defaulting to a sequence, so
+// that the sequence is created along with the table.
+var ddlSerialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+// ddlColumn renders a column definition.
+func ddlColumn(c Column) string {
+	b := strings.Builder{}
+	b.WriteString(ddlIdent(c.Name) + " ")
+	if serial, ok := ddlSerialTypes[c.PGType]; ok && strings.HasPrefix(c.Default, "nextval(") {
+		b.WriteString(serial)
+		c.Default = ""
+	} else {
+		b.WriteString(ddlType(c))
+	}
+	switch {
+	case c.GenerationExpression != "":
+		fmt.Fprintf(&b, " GENERATED ALWAYS AS (%s) STORED", c.GenerationExpression)
+	case c.Identity != "":
+		fmt.Fprintf(&b, " GENERATED %s AS IDENTITY", c.Identity)
+	case c.Default != "":
+		b.WriteString(" DEFAULT " + c.Default)
+	}
+	if !c.Nullable {
+		b.WriteString(" NOT NULL")
+	}
+	return b.String()
+}
+
+// ddlOrderedTables returns the tables ordered so that every table comes after
+// the tables it references. Tables in a reference cycle keep their inspected
+// order; the foreign keys that close the cycle are added afterwards.
+func ddlOrderedTables(schemas []GenerationSchema) []*GenerationTable {
+	remaining := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			remaining = append(remaining, &schema.Tables[i])
+		}
+	}
+	dependencies := map[*GenerationTable][]*GenerationTable{}
+	for _, edge := range diagramEdges(schemas) {
+		if edge.From != edge.To {
+			dependencies[edge.From] = append(dependencies[edge.From], edge.To)
+		}
+	}
+
+	created := map[*GenerationTable]bool{}
+	ordered := make([]*GenerationTable, 0, len(remaining))
+	for len(remaining) > 0 {
+		next := 0
+		for i, table := range remaining {
+			ready := true
+			for _, dependency := range dependencies[table] {
+				ready = ready && created[dependency]
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		created[remaining[next]] = true
+		ordered = append(ordered, remaining[next])
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return ordered
+}
+
+// ddlReferencePattern extracts the referenced table from a foreign key
+// definition such as FOREIGN KEY (vehicle_id) REFERENCES vehicle(id).
+var ddlReferencePattern = regexp.MustCompile(`REFERENCES ((?:"(?:[^"]|"")+"|[^\s(."]+)(?:\.(?:"(?:[^"]|"")+"|[^\s(."]+))?)\(`)
+
+// ddlReferencedTable returns the schema-qualified name of the table a foreign
+// key constraint references, unquoted.
+func ddlReferencedTable(t *Table, definition string) string {
+	match := ddlReferencePattern.FindStringSubmatch(definition)
+	if match == nil {
+		return ""
+	}
+	parts := []string{}
+	part := strings.Builder{}
+	quoted := false
+	name := match[1]
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '"' && quoted && i+1 < len(name) && name[i+1] == '"':
+			part.WriteByte('"')
+			i++
+		case name[i] == '"':
+			quoted = !quoted
+		case name[i] == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(name[i])
+		}
+	}
+	parts = append(parts, part.String())
+	if len(parts) == 1 {
+		return t.Schema + "." + parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// ddlEnum is an enum type used by the tables and the statement creating it.
+type ddlEnum struct {
+	Name      string
+	Statement string
+}
+
+// ddlEnums returns the enum types used by the tables, sorted by name.
+func ddlEnums(tables []*GenerationTable) []ddlEnum {
+	seen := map[string]bool{}
+	enums := []ddlEnum{}
+	for _, table := range tables {
+		for _, c := range table.Columns {
+			if c.Type().Kind != KindEnum {
+				continue
+			}
+			name := ddlUserType(c, strings.TrimPrefix(c.UDTName, "_"))
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			values := make([]string, 0, len(c.EnumValues))
+			for _, value := range c.EnumValues {
+				values = append(values, ddlLiteral(value))
+			}
+			enums = append(enums, ddlEnum{
+				Name:      name,
+				Statement: fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);\n", name, strings.Join(values, ", ")),
+			})
+		}
+	}
+	sort.Slice(enums, func(i, j int) bool {
+		return enums[i].Name < enums[j].Name
+	})
+	return enums
+}
+
+// ddlStatements is the DDL reconstructing the selected tables, split into the
+// steps it runs in. Each step starts with a blank line.
+type ddlStatements struct {
+	// Types creates the schemas and enum types.
+	Types string
+	// Tables creates each table with its indexes and comments, in dependency
+	// order.
+	Tables []ddlTableStatements
+	// ForeignKeys adds the foreign keys that reference a table created later
+	// (reference cycles).
+	ForeignKeys string
+	// Down drops everything in reverse.
+	Down string
+}
+
+type ddlTableStatements struct {
+	Table *GenerationTable
+	SQL   string
+}
+
+// ddlBuild reconstructs the selected tables from the inspected catalog: enum
+// types, tables with their columns and constraints in dependency order,
+// indexes, and comments. Foreign keys that reference a table created later
+// are added with ALTER TABLE at the end. Indexes on expressions are not
+// inspected, so they're missing from the output.
+func ddlBuild(schemas []GenerationSchema) ddlStatements {
+	tables := ddlOrderedTables(schemas)
+	selected := map[string]bool{}
+	for _, table := range tables {
+		selected[table.Schema+"."+table.Name] = true
+	}
+
+	statements := ddlStatements{}
+	types := strings.Builder{}
+	for _, schema := range schemas {
+		if schema.Name != "public" && len(schema.Tables) > 0 {
+			fmt.Fprintf(&types, "\nCREATE SCHEMA IF NOT EXISTS %s;\n", ddlIdent(schema.Name))
+		}
+	}
+	enums := ddlEnums(tables)
+	if len(enums) > 0 {
+		types.WriteString("\n")
+		for _, enum := range enums {
+			types.WriteString(enum.Statement)
+		}
+	}
+	statements.Types = types.String()
+
+	created := map[string]bool{}
+	deferred := []string{}
+	deferredDrops := []string{}
+	for _, table := range tables {
+		b := strings.Builder{}
+		name := ddlTableName(&table.Table)
+		created[table.Schema+"."+table.Name] = true
+
+		columns := make([]Column, len(table.Columns))
+		copy(columns, table.Columns)
+		sort.SliceStable(columns, func(i, j int) bool {
+			return columns[i].Position < columns[j].Position
+		})
+		lines := []string{}
+		for _, c := range columns {
+			lines = append(lines, ddlColumn(c))
+		}
+		constraintNames := map[string]bool{}
+		for _, constraint := range table.Constraints {
+			constraintNames[constraint.Name] = true
+			definition := fmt.Sprintf("CONSTRAINT %s %s", ddlIdent(constraint.Name), constraint.Definition)
+			if constraint.Type == ConstraintForeignKey {
+				target := ddlReferencedTable(&table.Table, constraint.Definition)
+				if selected[target] && !created[target] {
+					deferred = append(deferred, fmt.Sprintf("ALTER TABLE %s ADD %s;\n", name, definition))
+					deferredDrops = append(deferredDrops, fmt.Sprintf("ALTER TABLE IF EXISTS %s DROP CONSTRAINT IF EXISTS %s;\n", name, ddlIdent(constraint.Name)))
+					continue
+				}
+			}
+			lines = append(lines, definition)
+		}
+		fmt.Fprintf(&b, "\nCREATE TABLE %s (\n    %s\n);\n", name, strings.Join(lines, ",\n    "))
+
+		for _, index := range table.Indexes {
+			// Primary key and unique constraints create their own indexes.
+			if index.Primary || constraintNames[index.Name] || index.Definition == "" {
+				continue
+			}
+			b.WriteString(index.Definition + ";\n")
+		}
+
+		if table.Comment != "" {
+			fmt.Fprintf(&b, "COMMENT ON TABLE %s IS %s;\n", name, ddlLiteral(table.Comment))
+		}
+		for _, c := range columns {
+			if c.Comment != "" {
+				fmt.Fprintf(&b, "COMMENT ON COLUMN %s.%s IS %s;\n", name, ddlIdent(c.Name), ddlLiteral(c.Comment))
+			}
+		}
+		statements.Tables = append(statements.Tables, ddlTableStatements{Table: table, SQL: b.String()})
+	}
+
+	if len(deferred) > 0 {
+		statements.ForeignKeys = "\n" + strings.Join(deferred, "")
+	}
+
+	down := strings.Builder{}
+	for _, statement := range deferredDrops {
+		down.WriteString(statement)
+	}
+	for i := len(tables) - 1; i >= 0; i-- {
+		fmt.Fprintf(&down, "DROP TABLE IF EXISTS %s;\n", ddlTableName(&tables[i].Table))
+	}
+	for _, enum := range enums {
+		fmt.Fprintf(&down, "DROP TYPE IF EXISTS %s;\n", enum.Name)
+	}
+	statements.Down = down.String()
+	return statements
+}
+
+// Up returns all of the statements as one script.
+func (s ddlStatements) Up() string {
+	b := strings.Builder{}
+	b.WriteString(s.Types)
+	for _, table := range s.Tables {
+		b.WriteString(table.SQL)
+	}
+	b.WriteString(s.ForeignKeys)
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+// ddlMigration creates the selected tables as reconstructed by ddlBuild.
+func ddlMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	statements := ddlBuild(schemas)
+	return Migration{Name: "schema", Up: statements.Up(), Down: statements.Down}, nil
+}
+
+// generateDDL writes the CREATE statements from ddlMigration.
+func generateDDL(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	return writeMigrationUp(w, ddlMigration, schemas, cfg)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const defaultDebeziumName = "pginspector"
+
+// debeziumSourceTypeKinds are the kinds Kafka Connect has no exact schema type
+// for. Their columns carry the source type as a schema parameter so consumers
+// can tell them apart.
+var debeziumSourceTypeKinds = map[TypeKind]bool{
+	KindUnknown:  true,
+	KindNumeric:  true,
+	KindInterval: true,
+	KindJSON:     true,
+	KindEnum:     true,
+}
+
+// DebeziumConnector is a Kafka Connect connector definition.
+type DebeziumConnector struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+// debeziumTableName returns the pattern matching a table in Debezium's
+// include lists, which are regular expressions.
+func debeziumTableName(t *Table) string {
+	return regexp.QuoteMeta(t.Schema + "." + t.Name)
+}
+
+// generateDebezium writes a Debezium Postgres connector definition capturing
+// the selected tables: the tables to include, message keys from the configured
+// primary keys, and source type hints for columns without an exact Kafka
+// Connect type. The connector is named debezium_name (pginspector by default),
+// which is also the topic prefix. Connection settings are read from the
+// standard libpq environment variables by Kafka Connect's env config provider,
+// and properties in debezium_config are added, overriding the generated ones.
+func generateDebezium(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	name := cfg.DebeziumName
+	if name == "" {
+		name = defaultDebeziumName
+	}
+	tables := []string{}
+	keys := []string{}
+	sourceTypes := []string{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			tables = append(tables, debeziumTableName(&table.Table))
+			if table.HasColumn(table.Config.PrimaryKey) {
+				keys = append(keys, debeziumTableName(&table.Table)+":"+table.Config.PrimaryKey)
+			}
+			for _, c := range table.Columns {
+				if debeziumSourceTypeKinds[c.Type().Kind] {
+					sourceTypes = append(sourceTypes, debeziumTableName(&table.Table)+`\.`+regexp.QuoteMeta(c.Name))
+				}
+			}
+		}
+	}
+
+	config := map[string]string{
+		"connector.class":             "io.debezium.connector.postgresql.PostgresConnector",
+		"plugin.name":                 "pgoutput",
+		"database.hostname":           "${env:PGHOST}",
+		"database.port":               "${env:PGPORT}",
+		"database.user":               "${env:PGUSER}",
+		"database.password":           "${env:PGPASSWORD}",
+		"database.dbname":             "${env:PGDATABASE}",
+		"topic.prefix":                name,
+		"slot.name":                   strings.ReplaceAll(name, "-", "_"),
+		"publication.name":            strings.ReplaceAll(name, "-", "_"),
+		"publication.autocreate.mode": "filtered",
+		"table.include.list":          strings.Join(tables, ","),
+		"decimal.handling.mode":       "precise",
+		"time.precision.mode":         "adaptive_time_microseconds",
+	}
+	if len(keys) > 0 {
+		config["message.key.columns"] = strings.Join(keys, ";")
+	}
+	if len(sourceTypes) > 0 {
+		config["column.propagate.source.type"] = strings.Join(sourceTypes, ",")
+	}
+	for key, value := range cfg.DebeziumConfig {
+		config[key] = value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(DebeziumConnector{Name: name, Config: config})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DiagramFormatMermaid renders a Mermaid erDiagram (the default format).
+	DiagramFormatMermaid = "mermaid"
+	// DiagramFormatDOT renders the relation graph as Graphviz DOT.
+	DiagramFormatDOT = "dot"
+	// DiagramFormatPlantUML renders a PlantUML entity diagram.
+	DiagramFormatPlantUML = "plantuml"
+)
+
+// DiagramRenderer renders the inspected schemas in one diagram format. New
+// formats only need a renderer registered in diagramRenderers; edges and
+// entity names are shared through diagramEdges and diagramEntityName.
+type DiagramRenderer func(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error
+
+// diagramRenderers are selected by -format (or diagram_format in the config).
+var diagramRenderers = map[string]DiagramRenderer{
+	DiagramFormatMermaid:  renderMermaidDiagram,
+	DiagramFormatDOT:      renderDOTDiagram,
+	DiagramFormatPlantUML: renderPlantUMLDiagram,
+}
+
+// generateDiagram dispatches to the renderer for the configured format.
+func generateDiagram(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	format := cfg.DiagramFormat
+	if format == "" {
+		format = DiagramFormatMermaid
+	}
+	renderer, ok := diagramRenderers[format]
+	if !ok {
+		formats := make([]string, 0, len(diagramRenderers))
+		for name := range diagramRenderers {
+			formats = append(formats, name)
+		}
+		sort.Strings(formats)
+		return errors.Errorf("Unsupported diagram format %q (expected one of %s)", format, strings.Join(formats, ", "))
+	}
+	return renderer(w, schemas, cfg)
+}
+
+// DiagramEdge is a foreign key between two tables that are both part of the
+// diagram.
+type DiagramEdge struct {
+	From   *GenerationTable
+	Column Column
+	To     *GenerationTable
+}
+
+// diagramEdges returns the foreign keys between generated tables. Relations to
+// skipped tables are left out so diagrams only show what was selected.
+func diagramEdges(schemas []GenerationSchema) []DiagramEdge {
+	tables := map[string]*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			tables[table.Schema+"."+table.Name] = table
+		}
+	}
+
+	edges := []DiagramEdge{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			for _, c := range table.ForeignKeyColumns() {
+				target, ok := tables[c.Relation.Table.Schema+"."+c.Relation.Table.Name]
+				if !ok {
+					continue
+				}
+				edges = append(edges, DiagramEdge{From: table, Column: c, To: target})
+			}
+		}
+	}
+	return edges
+}
+
+// diagramEntityName returns an identifier for the table that is unique across
+// schemas. Tables in the public schema use their bare name.
+func diagramEntityName(t *Table) string {
+	if t.Schema == "" || t.Schema == "public" {
+		return t.Name
+	}
+	return t.Schema + "_" + t.Name
+}
+
+// diagramKeys returns the key markers (PK, FK) for a column.
+func diagramKeys(t *GenerationTable, c Column) []string {
+	keys := []string{}
+	if c.Name == t.Config.PrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if c.Relation.Table != nil {
+		keys = append(keys, "FK")
+	}
+	return keys
+}
+
+func renderMermaidDiagram(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	b := strings.Builder{}
+	b.WriteString("erDiagram\n")
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			fmt.Fprintf(&b, "    %s {\n", diagramEntityName(&table.Table))
+			for _, c := range table.Columns {
+				fmt.Fprintf(&b, "        %s %s", strings.ReplaceAll(c.SQLType(), " ", "_"), c.Name)
+				if keys := diagramKeys(table, c); len(keys) > 0 {
+					fmt.Fprintf(&b, " %s", strings.Join(keys, ", "))
+				}
+				if c.Nullable {
+					b.WriteString(` "nullable"`)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("    }\n")
+		}
+	}
+	for _, edge := range diagramEdges(schemas) {
+		// The referenced row is required unless the foreign key is nullable.
+		parent := "||"
+		if edge.Column.Nullable {
+			parent = "|o"
+		}
+		fmt.Fprintf(&b, "    %s %s--o{ %s : %q\n", diagramEntityName(&edge.To.Table), parent, diagramEntityName(&edge.From.Table), edge.Column.Name)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// diagramPalette colors table categories that have no configured color.
+var diagramPalette = []string{"lightblue", "lightgoldenrod", "palegreen", "lightpink", "lavender", "lightsalmon", "lightcyan", "wheat"}
+
+// diagramCategoryColors assigns a fill color to every table category.
+// Configured colors win; the rest take palette colors in category order so
+// output is stable.
+func diagramCategoryColors(schemas []GenerationSchema, cfg GeneratorConfiguration) map[string]string {
+	categories := []string{}
+	seen := map[string]bool{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			category := table.Config.Category
+			if category == "" || seen[category] {
+				continue
+			}
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	colors := map[string]string{}
+	next := 0
+	for _, category := range categories {
+		if color, ok := cfg.DiagramCategoryColors[category]; ok {
+			colors[category] = color
+			continue
+		}
+		colors[category] = diagramPalette[next%len(diagramPalette)]
+		next++
+	}
+	return colors
+}
+
+func dotNode(b *strings.Builder, indent string, table *GenerationTable, colors map[string]string) {
+	fmt.Fprintf(b, "%s%q [label=%q", indent, table.Schema+"."+table.Name, table.Name)
+	if color, ok := colors[table.Config.Category]; ok {
+		fmt.Fprintf(b, ", fillcolor=%q", color)
+	}
+	b.WriteString("];\n")
+}
+
+// renderDOTDiagram writes the table graph with an edge from each referencing
+// table to the table it references. Nullable foreign keys are dashed.
+func renderDOTDiagram(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	colors := diagramCategoryColors(schemas, cfg)
+
+	b := strings.Builder{}
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=filled, fillcolor=\"white\"];\n")
+	for _, schema := range schemas {
+		indent := "  "
+		if cfg.DiagramClusterBySchema {
+			fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+schema.Name)
+			fmt.Fprintf(&b, "    label=%q;\n", schema.Name)
+			indent = "    "
+		}
+		for i := range schema.Tables {
+			dotNode(&b, indent, &schema.Tables[i], colors)
+		}
+		if cfg.DiagramClusterBySchema {
+			b.WriteString("  }\n")
+		}
+	}
+	for _, edge := range diagramEdges(schemas) {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q", edge.From.Schema+"."+edge.From.Name, edge.To.Schema+"."+edge.To.Name, edge.Column.Name)
+		if edge.Column.Nullable {
+			b.WriteString(", style=dashed")
+		}
+		b.WriteString("];\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renderPlantUMLDiagram writes an entity per table with the primary key above
+// the separator. Mandatory (NOT NULL) columns are starred, as is conventional
+// in PlantUML IE diagrams.
+func renderPlantUMLDiagram(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	b := strings.Builder{}
+	b.WriteString("@startuml\n")
+	b.WriteString("hide circle\n")
+	b.WriteString("skinparam linetype ortho\n")
+	writeColumn := func(table *GenerationTable, c Column) {
+		marker := "  "
+		if !c.Nullable {
+			marker = "* "
+		}
+		fmt.Fprintf(&b, "  %s%s : %s", marker, c.Name, c.SQLType())
+		for _, key := range diagramKeys(table, c) {
+			fmt.Fprintf(&b, " <<%s>>", key)
+		}
+		b.WriteString("\n")
+	}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			fmt.Fprintf(&b, "\nentity %q as %s {\n", table.Schema+"."+table.Name, diagramEntityName(&table.Table))
+			if table.HasColumn(table.Config.PrimaryKey) {
+				writeColumn(table, table.PrimaryKeyColumn())
+				b.WriteString("  --\n")
+			}
+			for _, c := range table.Columns {
+				if c.Name != table.Config.PrimaryKey {
+					writeColumn(table, c)
+				}
+			}
+			b.WriteString("}\n")
+		}
+	}
+	edges := diagramEdges(schemas)
+	if len(edges) > 0 {
+		b.WriteString("\n")
+	}
+	for _, edge := range edges {
+		parent := "||"
+		if edge.Column.Nullable {
+			parent = "o|"
+		}
+		fmt.Fprintf(&b, "%s }o--%s %s : %s\n", diagramEntityName(&edge.From.Table), parent, diagramEntityName(&edge.To.Table), edge.Column.Name)
+	}
+	b.WriteString("@enduml\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DialectPggen emits pggen.arg('name') placeholders (the default).
+	DialectPggen = "pggen"
+	// DialectPositional emits $1, $2, ... placeholders with a comment
+	// documenting the argument order, for use with raw pgx or database/sql.
+	DialectPositional = "positional"
+)
+
+// sqlArgs renders the query arguments of SQL templates in a dialect.
+// Positional arguments are numbered per query in order of first use.
+type sqlArgs struct {
+	dialect string
+	// queries are the argument names of each query, found by a first
+	// execution of the template, so the second can document them below the
+	// query's name.
+	queries [][]string
+	// query is the index of the query being rendered, and names its
+	// arguments so far.
+	query int
+	names []string
+}
+
+func newSQLArgs(dialect string) *sqlArgs {
+	return &sqlArgs{dialect: dialect, query: -1}
+}
+
+// templateFuncs returns the functions available to every SQL template. Query
+// arguments must always be written with Arg, and every query's name line end
+// with QueryArgs, so the placeholder style is changed in one place.
+func templateFuncs(args *sqlArgs) template.FuncMap {
+	return template.FuncMap{
+		"ToCamel":   strcase.ToCamel,
+		"Arg":       args.Arg,
+		"QueryArgs": args.QueryArgs,
+	}
+}
+
+// Arg renders a named query argument.
+func (a *sqlArgs) Arg(name string) string {
+	if a.dialect != DialectPositional {
+		return fmt.Sprintf("pggen.arg('%s')", name)
+	}
+	for i, n := range a.names {
+		if n == name {
+			return fmt.Sprintf("$%d", i+1)
+		}
+	}
+	a.names = append(a.names, name)
+	return fmt.Sprintf("$%d", len(a.names))
+}
+
+// QueryArgs starts a query, returning the comment documenting its positional
+// arguments, to follow its name line.
+func (a *sqlArgs) QueryArgs() string {
+	a.endQuery()
+	a.query++
+	if a.dialect != DialectPositional || a.query >= len(a.queries) {
+		return ""
+	}
+	b := strings.Builder{}
+	for i, name := range a.queries[a.query] {
+		fmt.Fprintf(&b, "\n-- $%d: %s", i+1, name)
+	}
+	return b.String()
+}
+
+// endQuery records the arguments of the query rendered last.
+func (a *sqlArgs) endQuery() {
+	if a.query >= 0 && a.query >= len(a.queries) {
+		a.queries = append(a.queries, a.names)
+	}
+	a.names = nil
+}
+
+// execute executes a SQL template. With positional arguments the template is
+// executed twice, first to find the arguments of each query.
+func (a *sqlArgs) execute(w io.Writer, tmpl *template.Template, data interface{}) error {
+	if a.dialect == DialectPositional {
+		if err := tmpl.Execute(io.Discard, data); err != nil {
+			return err
+		}
+		a.endQuery()
+		a.query = -1
+	}
+	return tmpl.Execute(w, data)
+}
+
+// ValidateDialect checks that dialect is one of the supported output dialects.
+func ValidateDialect(dialect string) error {
+	switch dialect {
+	case "", DialectPggen, DialectPositional:
+		return nil
+	}
+	return errors.Errorf("Unsupported dialect %q (expected %q or %q)", dialect, DialectPggen, DialectPositional)
+}
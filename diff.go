@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+)
+
+// schemaDiff returns a unified diff turning the CREATE statements of the
+// tables in a snapshot into those of the tables in the database, or "" if
+// they're the same.
+func schemaDiff(snapshotName string, snapshotSchemas []GenerationSchema, databaseSchemas []GenerationSchema, cfg GeneratorConfiguration) (string, error) {
+	before, after := bytes.Buffer{}, bytes.Buffer{}
+	if err := generateDDL(&before, snapshotSchemas, cfg); err != nil {
+		return "", err
+	}
+	if err := generateDDL(&after, databaseSchemas, cfg); err != nil {
+		return "", err
+	}
+	return driftDiff(snapshotName, "database", before.String(), after.String()), nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// djangoFields maps column kinds to Django model fields. Kinds not listed are
+// text fields, marked as guessed.
+var djangoFields = map[TypeKind]string{
+	KindInt16:       "SmallIntegerField",
+	KindInt32:       "IntegerField",
+	KindInt64:       "BigIntegerField",
+	KindFloat32:     "FloatField",
+	KindFloat64:     "FloatField",
+	KindNumeric:     "DecimalField",
+	KindBool:        "BooleanField",
+	KindString:      "TextField",
+	KindUUID:        "UUIDField",
+	KindTimestamp:   "DateTimeField",
+	KindTimestamptz: "DateTimeField",
+	KindDate:        "DateField",
+	KindTime:        "TimeField",
+	KindInterval:    "DurationField",
+	KindJSON:        "JSONField",
+	KindBytes:       "BinaryField",
+	KindEnum:        "CharField",
+}
+
+// djangoAutoFields are the fields of integer primary keys the database
+// numbers.
+var djangoAutoFields = map[TypeKind]string{
+	KindInt16: "SmallAutoField",
+	KindInt32: "AutoField",
+	KindInt64: "BigAutoField",
+}
+
+// djangoField returns the field class and the arguments describing the
+// column's type, with a comment when the type is a guess.
+func djangoField(c Column, primaryKey bool) (string, []string, string) {
+	typ := c.Type()
+	field, ok := djangoFields[typ.Kind]
+	arguments := []string{}
+	comment := ""
+	switch {
+	case !ok:
+		field = "TextField"
+		comment = "This field type is a guess."
+	case primaryKey && !typ.Array && (c.Identity != "" || strings.HasPrefix(c.Default, "nextval(")) && djangoAutoFields[typ.Kind] != "":
+		field = djangoAutoFields[typ.Kind]
+	case typ.Kind == KindNumeric:
+		if c.NumericPrecision > 0 {
+			arguments = append(arguments, fmt.Sprintf("max_digits=%d", c.NumericPrecision), fmt.Sprintf("decimal_places=%d", c.NumericScale))
+		} else {
+			arguments = append(arguments, "max_digits=65535", "decimal_places=65535")
+			comment = "max_digits and decimal_places have been guessed, as the column is an unconstrained numeric."
+		}
+	case typ.Kind == KindString && c.MaxLength > 0:
+		field = "CharField"
+		arguments = append(arguments, fmt.Sprintf("max_length=%d", c.MaxLength))
+	case typ.Kind == KindEnum:
+		length := 1
+		for _, value := range c.EnumValues {
+			length = max(length, len(value))
+		}
+		arguments = append(arguments, fmt.Sprintf("max_length=%d", length), "choices="+c.EnumTypeName()+".choices")
+	}
+	if typ.Array {
+		base := "models." + field + "(" + strings.Join(arguments, ", ") + ")"
+		return "ArrayField", []string{base}, comment
+	}
+	return "models." + field, arguments, comment
+}
+
+// djangoChoiceName returns the member name of an enum label in a TextChoices
+// class. Labels that don't make a usable name fall back to their position.
+func djangoChoiceName(label string, position int, seen map[string]bool) string {
+	name, _ := pythonIdent(strings.ToUpper(label))
+	if seen[name] || !unicode.IsLetter(rune(name[0])) {
+		name = fmt.Sprintf("VALUE_%d", position)
+	}
+	seen[name] = true
+	return name
+}
+
+// djangoForeignKey is a foreign key column rendered as a ForeignKey field.
+type djangoForeignKey struct {
+	Name        string
+	Target      string
+	ToField     string
+	RelatedName string
+}
+
+// djangoForeignKeys returns the ForeignKey fields of each model by column,
+// for the foreign keys between selected tables. Reverse accessors are named
+// after the referencing table, qualified by the field when a pair of models
+// has more than one relationship between them.
+func djangoForeignKeys(schemas []GenerationSchema) map[*GenerationTable]map[string]djangoForeignKey {
+	edges := diagramEdges(schemas)
+	pairs := map[[2]*GenerationTable]int{}
+	for _, edge := range edges {
+		pairs[[2]*GenerationTable{edge.From, edge.To}]++
+	}
+
+	foreignKeys := map[*GenerationTable]map[string]djangoForeignKey{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		column, _ := pythonIdent(edge.Column.Name)
+		name := strings.TrimSuffix(column, "_id")
+		if name == column || edge.From.HasColumn(name) {
+			name = column + "_ref"
+		}
+		foreignKey := djangoForeignKey{Name: name, Target: strconv.Quote(edge.To.TypeName())}
+		if edge.From == edge.To {
+			foreignKey.Target = strconv.Quote("self")
+		}
+		if target := edge.Column.Relation.Column.Name; target != edge.To.Config.PrimaryKey {
+			foreignKey.ToField = target
+		}
+		related, _ := pythonIdent(pluralize(edge.From.Name))
+		if pairs[[2]*GenerationTable{edge.From, edge.To}] > 1 || edge.From == edge.To {
+			related += "_" + name
+		}
+		foreignKey.RelatedName = related
+		if foreignKeys[edge.From] == nil {
+			foreignKeys[edge.From] = map[string]djangoForeignKey{}
+		}
+		foreignKeys[edge.From][edge.Column.Name] = foreignKey
+	}
+	return foreignKeys
+}
+
+// djangoUniqueTogether returns the column sets of the table's multi-column
+// unique constraints, and of its primary key when it spans columns.
+func djangoUniqueTogether(t *GenerationTable) [][]string {
+	constraints := map[string]bool{}
+	for _, constraint := range t.Constraints {
+		if constraint.Type == ConstraintUnique {
+			constraints[constraint.Name] = true
+		}
+	}
+	together := [][]string{}
+	for _, index := range t.Indexes {
+		if len(index.Columns) > 1 && (index.Primary || constraints[index.Name]) {
+			together = append(together, index.Columns)
+		}
+	}
+	return together
+}
+
+// generateDjango writes unmanaged Django models for the selected tables, in
+// the style of inspectdb: a TextChoices class per enum type, and a model per
+// table with a field per column, a ForeignKey per foreign key between
+// selected tables, and unique_together from the multi-column unique
+// constraints. Django models have a single primary key column, so tables with
+// a composite key use its first column and keep the key in unique_together.
+func generateDjango(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	imports := pythonImports{}
+	imports.add("django.db", "models")
+	foreignKeys := djangoForeignKeys(schemas)
+
+	body := strings.Builder{}
+	for _, enum := range goEnums(schemas) {
+		fmt.Fprintf(&body, "\n\nclass %s(models.TextChoices):\n", enum.Name)
+		seen := map[string]bool{}
+		for i, constant := range enum.Constants {
+			fmt.Fprintf(&body, "    %s = %s\n", djangoChoiceName(constant.Value, i, seen), strconv.Quote(constant.Value))
+		}
+	}
+
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			primaryKey := table.Config.PrimaryKey
+			for _, index := range table.Indexes {
+				if index.Primary {
+					primaryKey = index.Columns[0]
+				}
+			}
+
+			fmt.Fprintf(&body, "\n\nclass %s(models.Model):\n", table.TypeName())
+			if table.Comment != "" {
+				fmt.Fprintf(&body, "    %s\n\n", pythonDocstring(table.Comment, "    "))
+			}
+			if !table.HasColumn(primaryKey) {
+				fmt.Fprintf(&body, "    # %s.%s has no primary key; Django adds an id field, which doesn't exist.\n", table.Schema, table.Name)
+			}
+			for _, c := range table.Columns {
+				name, renamed := pythonIdent(c.Name)
+				field, arguments, comment := djangoField(c, c.Name == primaryKey)
+				if foreignKey, ok := foreignKeys[table][c.Name]; ok {
+					name, renamed = foreignKey.Name, foreignKey.Name+"_id" != c.Name
+					field, arguments = "models.ForeignKey", []string{foreignKey.Target, "models.DO_NOTHING"}
+					if foreignKey.ToField != "" {
+						arguments = append(arguments, "to_field="+strconv.Quote(foreignKey.ToField))
+					}
+					arguments = append(arguments, "related_name="+strconv.Quote(foreignKey.RelatedName))
+				}
+				if field == "ArrayField" {
+					imports.add("django.contrib.postgres.fields", "ArrayField")
+				}
+				if renamed {
+					arguments = append(arguments, "db_column="+strconv.Quote(c.Name))
+				}
+				if c.Name == primaryKey {
+					arguments = append(arguments, "primary_key=True")
+				} else if table.IsUnique(c.Name) {
+					arguments = append(arguments, "unique=True")
+				}
+				if c.Nullable {
+					arguments = append(arguments, "blank=True", "null=True")
+				}
+				if c.Comment != "" {
+					arguments = append(arguments, "db_comment="+strconv.Quote(c.Comment))
+				}
+				fmt.Fprintf(&body, "    %s = %s(%s)", name, field, strings.Join(arguments, ", "))
+				if comment != "" {
+					body.WriteString("  # " + comment)
+				}
+				body.WriteString("\n")
+			}
+
+			dbTable := table.Name
+			if table.Schema != "public" {
+				// Django quotes the name as one identifier unless it's
+				// quoted already.
+				dbTable = `"` + strings.ReplaceAll(table.Schema, `"`, `""`) + `"."` + strings.ReplaceAll(table.Name, `"`, `""`) + `"`
+			}
+			body.WriteString("\n    class Meta:\n        managed = False\n")
+			fmt.Fprintf(&body, "        db_table = %s\n", strconv.Quote(dbTable))
+			if table.Comment != "" {
+				fmt.Fprintf(&body, "        db_table_comment = %s\n", strconv.Quote(table.Comment))
+			}
+			if together := djangoUniqueTogether(table); len(together) > 0 {
+				sets := []string{}
+				for _, columns := range together {
+					names := []string{}
+					for _, column := range columns {
+						name, _ := pythonIdent(column)
+						if foreignKey, ok := foreignKeys[table][column]; ok {
+							name = foreignKey.Name
+						}
+						names = append(names, strconv.Quote(name))
+					}
+					sets = append(sets, "("+strings.Join(names, ", ")+")")
+				}
+				fmt.Fprintf(&body, "        unique_together = (%s,)\n", strings.Join(sets, ", "))
+			}
+		}
+	}
+
+	b := strings.Builder{}
+	b.WriteString("# Code generated by pginspector. DO NOT EDIT.\n\n")
+	imports.write(&b)
+	b.WriteString(body.String())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// driftContext is the number of unchanged lines shown around each change.
+const driftContext = 3
+
+// driftEdit is a line of an edit script: kept (' '), deleted from the old
+// text ('-'), or inserted from the new one ('+'), at its index in each.
+type driftEdit struct {
+	op   byte
+	from int
+	to   int
+}
+
+// driftLines splits a text into lines, keeping their line endings.
+func driftLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// driftEdits returns the shortest edit script turning from into to, found
+// with Myers' algorithm.
+func driftEdits(from []string, to []string) []driftEdit {
+	n, m := len(from), len(to)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && from[x] == to[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	edits := []driftEdit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		previous := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			previous = k + 1
+		}
+		previousX := v[offset+previous]
+		previousY := previousX - previous
+		for x > previousX && y > previousY {
+			x, y = x-1, y-1
+			edits = append(edits, driftEdit{op: ' ', from: x, to: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == previousX {
+			edits = append(edits, driftEdit{op: '+', from: x, to: previousY})
+		} else {
+			edits = append(edits, driftEdit{op: '-', from: previousX, to: y})
+		}
+		x, y = previousX, previousY
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// driftDiff returns a unified diff turning one text into another, or "" if
+// they're the same.
+func driftDiff(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+	fromLines, toLines := driftLines(from), driftLines(to)
+	edits := driftEdits(fromLines, toLines)
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// A hunk runs from the context before a change to the context after
+		// the last change within twice the context of the one before.
+		first := max(start-driftContext, 0)
+		end := start
+		for i := start; i < len(edits) && i <= end+2*driftContext; i++ {
+			if edits[i].op != ' ' {
+				end = i
+			}
+		}
+		last := min(end+driftContext, len(edits)-1)
+
+		fromCount, toCount := 0, 0
+		for _, edit := range edits[first : last+1] {
+			if edit.op != '+' {
+				fromCount++
+			}
+			if edit.op != '-' {
+				toCount++
+			}
+		}
+		fromStart, toStart := edits[first].from, edits[first].to
+		if fromCount > 0 {
+			fromStart++
+		}
+		if toCount > 0 {
+			toStart++
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, edit := range edits[first : last+1] {
+			line := ""
+			switch edit.op {
+			case '+':
+				line = toLines[edit.to]
+			default:
+				line = fromLines[edit.from]
+			}
+			b.WriteByte(edit.op)
+			b.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = last + 1
+	}
+	return b.String()
+}
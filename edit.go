@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// editTable is a table of the schema being edited, with the options the
+// edit command toggles.
+type editTable struct {
+	table     Table
+	selected  bool
+	upsert    bool
+	fieldMask bool
+	// softDelete is the table's own soft delete column. The schema's
+	// soft_delete_column applies to tables with that column otherwise.
+	softDelete string
+	// skippedBy is the skip_tables pattern skipping the table, which can't
+	// be selected without editing the pattern.
+	skippedBy string
+}
+
+// tableEditor holds the choices of the edit command for a schema, until
+// they're written to the config.
+type tableEditor struct {
+	schemaName        string
+	tables            []editTable
+	defaultSoftDelete string
+	// skipTables and includeTables are the schema's lists as configured,
+	// including from the files the config includes.
+	skipTables    []string
+	includeTables []string
+	// filter limits the tables listed to those whose name contains it.
+	filter string
+	// cursor is the position of the highlighted table among those listed on
+	// the screen, and offset that of the first table shown.
+	cursor int
+	offset int
+}
+
+const editScreenHelp = "↑/↓ move  space select  u upsert  f field mask  s soft delete  a/n all/none  / filter  w write  q quit"
+
+const editHelp = `Commands:
+  3 5-8         select or unselect tables by number, skipping those unselected
+  a, n          select all or none of the tables
+  u 3 5-8       toggle generate_upsert
+  f 3 5-8       toggle generate_field_mask_update
+  s 3 [column]  toggle soft_delete_column, deleted_at unless a column is given
+  /text         list only tables whose name contains text (/ lists all)
+  w             write the config and quit
+  q             quit without writing
+`
+
+// newTableEditor returns an editor for the inspected tables of a schema,
+// starting from its config.
+func newTableEditor(schemaName string, schema Schema, schemaConfig SchemaConfig) *tableEditor {
+	e := &tableEditor{
+		schemaName:        schemaName,
+		defaultSoftDelete: schemaConfig.SoftDeleteColumn,
+		skipTables:        schemaConfig.SkipTables,
+		includeTables:     schemaConfig.IncludeTables,
+	}
+	tableNames := make([]string, 0, len(schema.Tables))
+	for tableName := range schema.Tables {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	for _, tableName := range tableNames {
+		tableConfig := schemaConfig.TableConfig[tableName]
+		t := editTable{
+			table:      schema.Tables[tableName],
+			selected:   !schemaConfig.ShouldSkipTable(tableName),
+			upsert:     tableConfig.GenerateUpsert,
+			fieldMask:  tableConfig.GenerateFieldMaskUpdate,
+			softDelete: tableConfig.SoftDeleteColumn,
+		}
+		if skippedBy := schemaConfig.SkippedBy(tableName); isTablePattern(skippedBy) {
+			t.skippedBy = skippedBy
+		}
+		e.tables = append(e.tables, t)
+	}
+	return e
+}
+
+// render lists the tables matching the filter, by number, with their
+// options.
+func (e *tableEditor) render(w io.Writer) {
+	fmt.Fprintf(w, "\n%s\n", e.summary())
+	for _, i := range e.listed() {
+		fmt.Fprintf(w, "%4d %s\n", i+1, e.describe(i))
+	}
+}
+
+// summary returns the line heading the tables listed.
+func (e *tableEditor) summary() string {
+	selected := 0
+	for _, t := range e.tables {
+		if t.selected {
+			selected++
+		}
+	}
+	summary := fmt.Sprintf("%s: %d of %d tables selected", e.schemaName, selected, len(e.tables))
+	if e.filter != "" {
+		summary += fmt.Sprintf(", listing those matching %q", e.filter)
+	}
+	return summary
+}
+
+// listed returns the indexes of the tables matching the filter.
+func (e *tableEditor) listed() []int {
+	indexes := []int{}
+	for i, t := range e.tables {
+		if strings.Contains(t.table.Name, e.filter) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// describe returns a table's checkbox, name, and options.
+func (e *tableEditor) describe(i int) string {
+	t := e.tables[i]
+	check := " "
+	if t.selected {
+		check = "x"
+	}
+	options := []string{}
+	if t.skippedBy != "" {
+		options = append(options, "skipped by "+t.skippedBy)
+	}
+	if t.upsert {
+		options = append(options, "upsert")
+	}
+	if t.fieldMask {
+		options = append(options, "field mask")
+	}
+	if t.softDelete != "" {
+		options = append(options, "soft delete "+t.softDelete)
+	} else if e.defaultSoftDelete != "" && t.table.HasColumn(e.defaultSoftDelete) {
+		options = append(options, "soft delete "+e.defaultSoftDelete+" (schema default)")
+	}
+	line := fmt.Sprintf("[%s] %s", check, t.table.Name)
+	if len(options) > 0 {
+		line += "  " + strings.Join(options, ", ")
+	}
+	return line
+}
+
+// selectable returns an error if a table is skipped by a pattern, so it
+// can't be selected.
+func (e *tableEditor) selectable(i int) error {
+	if e.tables[i].skippedBy != "" {
+		return errors.Errorf("Table %s is skipped by %s in skip_tables, so it can't be selected", e.tables[i].table.Name, e.tables[i].skippedBy)
+	}
+	return nil
+}
+
+// selectAll selects all of the tables that can be, or none.
+func (e *tableEditor) selectAll(all bool) {
+	for i := range e.tables {
+		e.tables[i].selected = all && e.tables[i].skippedBy == ""
+	}
+}
+
+// toggleSoftDelete clears a table's soft delete column, or sets it to
+// column.
+func (e *tableEditor) toggleSoftDelete(i int, column string) error {
+	t := &e.tables[i]
+	switch {
+	case t.softDelete != "":
+		t.softDelete = ""
+	case !t.table.HasColumn(column):
+		return errors.Errorf("Table %s has no %s column", t.table.Name, column)
+	default:
+		t.softDelete = column
+	}
+	return nil
+}
+
+// editNumbers returns the indexes of the tables numbered by arguments like
+// 3, 5-8, or 3,5-8.
+func (e *tableEditor) editNumbers(args []string) ([]int, error) {
+	indexes := []int{}
+	for _, arg := range args {
+		for _, part := range strings.Split(arg, ",") {
+			if part == "" {
+				continue
+			}
+			first, last, isRange := strings.Cut(part, "-")
+			start, err := strconv.Atoi(first)
+			end := start
+			if err == nil && isRange {
+				end, err = strconv.Atoi(last)
+			}
+			if err != nil || start < 1 || end > len(e.tables) || start > end {
+				return nil, errors.Errorf("Invalid table number %s, expected 1 to %d", part, len(e.tables))
+			}
+			for n := start; n <= end; n++ {
+				indexes = append(indexes, n-1)
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// apply runs a command, returning whether it ends the edit and whether the
+// config should then be written.
+func (e *tableEditor) apply(line string) (bool, bool, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "/") {
+		e.filter = strings.TrimSpace(line[1:])
+		return false, false, nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, false, nil
+	}
+	switch fields[0] {
+	case "w":
+		return true, true, nil
+	case "q":
+		return true, false, nil
+	case "a", "n":
+		e.selectAll(fields[0] == "a")
+		return false, false, nil
+	case "u", "f":
+		indexes, err := e.editNumbers(fields[1:])
+		if err != nil {
+			return false, false, err
+		}
+		for _, i := range indexes {
+			if fields[0] == "u" {
+				e.tables[i].upsert = !e.tables[i].upsert
+			} else {
+				e.tables[i].fieldMask = !e.tables[i].fieldMask
+			}
+		}
+		return false, false, nil
+	case "s":
+		if len(fields) < 2 || len(fields) > 3 {
+			return false, false, errors.New("Expected s, a table number, and optionally a column")
+		}
+		indexes, err := e.editNumbers(fields[1:2])
+		if err != nil {
+			return false, false, err
+		}
+		column := "deleted_at"
+		if len(fields) == 3 {
+			column = fields[2]
+		}
+		for _, i := range indexes {
+			if err := e.toggleSoftDelete(i, column); err != nil {
+				return false, false, err
+			}
+		}
+		return false, false, nil
+	}
+	indexes, err := e.editNumbers(fields)
+	if err != nil {
+		return false, false, errors.Errorf("Unknown command %q, expected a table number or one of a, n, u, f, s, /, w, or q", line)
+	}
+	for _, i := range indexes {
+		if err := e.selectable(i); err != nil {
+			return false, false, err
+		}
+	}
+	for _, i := range indexes {
+		e.tables[i].selected = !e.tables[i].selected
+	}
+	return false, false, nil
+}
+
+// run lists the tables and applies the commands read from r until one ends
+// the edit, returning whether the config should be written.
+func (e *tableEditor) run(r io.Reader, w io.Writer) (bool, error) {
+	fmt.Fprint(w, editHelp)
+	scanner := bufio.NewScanner(r)
+	for {
+		e.render(w)
+		fmt.Fprint(w, "> ")
+		if !scanner.Scan() {
+			return false, scanner.Err()
+		}
+		done, write, err := e.apply(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(w, err)
+			continue
+		}
+		if done {
+			return write, nil
+		}
+	}
+}
+
+// editInput is a line of text typed at the bottom of the screen, applied
+// once it's entered.
+type editInput struct {
+	label string
+	text  string
+	apply func(text string) error
+}
+
+// runScreen lists the tables on a terminal in raw mode, a screen of height
+// lines at a time, with a cursor moved by the arrow keys (or j and k) and
+// keys toggling the options of the table under it, until w or q ends the
+// edit. It returns whether the config should be written.
+func (e *tableEditor) runScreen(r io.Reader, w io.Writer, height int) (bool, error) {
+	in := bufio.NewReader(r)
+	var input *editInput
+	message := ""
+	defer fmt.Fprint(w, "\x1b[H\x1b[2J\x1b[?25h")
+	for {
+		e.draw(w, height, input, message)
+		key, err := readEditKey(in)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		message = ""
+		if key == "ctrl-c" {
+			return false, nil
+		}
+		if input != nil {
+			switch key {
+			case "enter":
+				if err := input.apply(input.text); err != nil {
+					message = err.Error()
+				}
+				input = nil
+			case "esc":
+				input = nil
+			case "backspace":
+				if text := []rune(input.text); len(text) > 0 {
+					input.text = string(text[:len(text)-1])
+				}
+			default:
+				if len([]rune(key)) == 1 {
+					input.text += key
+				}
+			}
+			continue
+		}
+
+		listed := e.listed()
+		current := -1
+		if e.cursor < len(listed) {
+			current = listed[e.cursor]
+		}
+		switch key {
+		case "up", "k":
+			if e.cursor > 0 {
+				e.cursor--
+			}
+		case "down", "j":
+			if e.cursor < len(listed)-1 {
+				e.cursor++
+			}
+		case "a", "n":
+			e.selectAll(key == "a")
+		case "/":
+			input = &editInput{label: "Filter", text: e.filter, apply: func(text string) error {
+				e.filter, e.cursor = text, 0
+				return nil
+			}}
+		case "w":
+			return true, nil
+		case "q":
+			return false, nil
+		}
+		if current < 0 {
+			continue
+		}
+		switch key {
+		case " ", "x":
+			if err := e.selectable(current); err != nil {
+				message = err.Error()
+			} else {
+				e.tables[current].selected = !e.tables[current].selected
+			}
+		case "u":
+			e.tables[current].upsert = !e.tables[current].upsert
+		case "f":
+			e.tables[current].fieldMask = !e.tables[current].fieldMask
+		case "s":
+			if e.tables[current].softDelete != "" {
+				e.tables[current].softDelete = ""
+				break
+			}
+			input = &editInput{label: "Soft delete column", text: "deleted_at", apply: func(text string) error {
+				return e.toggleSoftDelete(current, text)
+			}}
+		}
+	}
+}
+
+// draw clears the screen and draws the tables listed, scrolled to keep the
+// cursor on it, with the keys, and the input being typed or a message.
+func (e *tableEditor) draw(w io.Writer, height int, input *editInput, message string) {
+	listed := e.listed()
+	if e.cursor >= len(listed) {
+		e.cursor = max(len(listed)-1, 0)
+	}
+	// The summary, the keys, and the input or message take a line each.
+	rows := max(height-3, 1)
+	if e.cursor < e.offset {
+		e.offset = e.cursor
+	}
+	if e.cursor >= e.offset+rows {
+		e.offset = e.cursor - rows + 1
+	}
+	lines := []string{e.summary()}
+	for n := e.offset; n < len(listed) && n < e.offset+rows; n++ {
+		marker := " "
+		if n == e.cursor {
+			marker = ">"
+		}
+		lines = append(lines, marker+" "+e.describe(listed[n]))
+	}
+	lines = append(lines, editScreenHelp)
+	if input != nil {
+		lines = append(lines, input.label+": "+input.text)
+	} else {
+		lines = append(lines, message)
+	}
+	// Raw mode doesn't return the carriage at a newline.
+	fmt.Fprint(w, "\x1b[?25l\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
+
+// readEditKey reads a key pressed on a terminal in raw mode: up, down,
+// enter, esc, backspace, ctrl-c, or the character typed.
+func readEditKey(in *bufio.Reader) (string, error) {
+	r, _, err := in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch r {
+	case '\x1b':
+		// Arrow keys send ESC [ A or ESC O A, all at once.
+		if in.Buffered() < 2 {
+			return "esc", nil
+		}
+		next, _ := in.Peek(2)
+		if next[0] != '[' && next[0] != 'O' {
+			return "esc", nil
+		}
+		in.Discard(2)
+		switch next[1] {
+		case 'A':
+			return "up", nil
+		case 'B':
+			return "down", nil
+		}
+		return "", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 0x7f, '\b':
+		return "backspace", nil
+	case 0x03:
+		return "ctrl-c", nil
+	}
+	return string(r), nil
+}
+
+// update writes the choices to a config's YAML, changing only the schema's
+// skip_tables and include_tables and the toggled options of its tables, so
+// the rest of the config, including its comments, is kept.
+func (e *tableEditor) update(root *yaml.Node) {
+	if root.Kind != yaml.DocumentNode {
+		*root = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	schemaNode := editMapping(editMapping(root.Content[0], "schema_config"), e.schemaName)
+
+	tables := map[string]editTable{}
+	for _, t := range e.tables {
+		tables[t.table.Name] = t
+	}
+	// With include_tables, selecting a table lists it there, and unselecting
+	// a table listed by name takes it off the list.
+	schemaConfig := SchemaConfig{}
+	for _, pattern := range e.includeTables {
+		if t, ok := tables[pattern]; !ok || t.selected {
+			schemaConfig.IncludeTables = append(schemaConfig.IncludeTables, pattern)
+		}
+	}
+	if len(e.includeTables) > 0 {
+		for _, t := range e.tables {
+			if t.selected && !schemaConfig.IncludesTable(t.table.Name) {
+				schemaConfig.IncludeTables = append(schemaConfig.IncludeTables, t.table.Name)
+			}
+		}
+		editList(schemaNode, "include_tables", schemaConfig.IncludeTables)
+	}
+	// Unselected tables that are still included are skipped by name.
+	for _, pattern := range e.skipTables {
+		if t, ok := tables[pattern]; !ok || !t.selected {
+			schemaConfig.SkipTables = append(schemaConfig.SkipTables, pattern)
+		}
+	}
+	for _, t := range e.tables {
+		if !t.selected && !schemaConfig.ShouldSkipTable(t.table.Name) {
+			schemaConfig.SkipTables = append(schemaConfig.SkipTables, t.table.Name)
+		}
+	}
+	editList(schemaNode, "skip_tables", schemaConfig.SkipTables)
+
+	tableConfigsNode := editMapping(schemaNode, "table_config")
+	for _, t := range e.tables {
+		_, tableNode := configNode(tableConfigsNode, t.table.Name)
+		if tableNode == nil && !t.upsert && !t.fieldMask && t.softDelete == "" {
+			continue
+		}
+		tableNode = editMapping(tableConfigsNode, t.table.Name)
+		editSet(tableNode, "generate_upsert", t.upsert, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+		editSet(tableNode, "generate_field_mask_update", t.fieldMask, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+		editSet(tableNode, "soft_delete_column", t.softDelete != "", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t.softDelete})
+	}
+}
+
+// editValue returns the value of a key of a mapping node, adding it as an
+// empty node of a kind if it's not set, or replacing it if it's another kind.
+func editValue(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	_, value := configNode(mapping, key)
+	if value == nil {
+		value = &yaml.Node{}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+	if value.Kind != kind {
+		*value = yaml.Node{Kind: kind}
+	}
+	return value
+}
+
+// editList sets a key of a mapping node to a list of strings, keeping the
+// nodes of the values it already lists, with their comments.
+func editList(mapping *yaml.Node, key string, values []string) {
+	list := editValue(mapping, key, yaml.SequenceNode)
+	existing := map[string]*yaml.Node{}
+	for _, entry := range list.Content {
+		existing[entry.Value] = entry
+	}
+	list.Content = make([]*yaml.Node, 0, len(values))
+	for _, value := range values {
+		entry, ok := existing[value]
+		if !ok {
+			entry = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		}
+		list.Content = append(list.Content, entry)
+	}
+	list.Style = 0
+	if len(values) == 0 {
+		list.Style = yaml.FlowStyle
+	}
+}
+
+// editMapping returns the mapping node of a key of a mapping node, adding
+// it if it's not set.
+func editMapping(mapping *yaml.Node, key string) *yaml.Node {
+	value := editValue(mapping, key, yaml.MappingNode)
+	value.Style = 0
+	return value
+}
+
+// editSet sets a key of a mapping node to a value, or removes it when it's
+// not set, leaving it out as it defaults.
+func editSet(mapping *yaml.Node, key string, set bool, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		if set {
+			mapping.Content[i+1] = value
+		} else {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		}
+		return
+	}
+	if set {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+)
+
+// entFields maps column kinds to ent field builders. Kinds not listed are
+// declared with field.Other using the Go type from goTypes.
+var entFields = map[TypeKind]string{
+	KindInt16:       "field.Int16",
+	KindInt32:       "field.Int32",
+	KindInt64:       "field.Int64",
+	KindFloat32:     "field.Float32",
+	KindFloat64:     "field.Float",
+	KindBool:        "field.Bool",
+	KindString:      "field.String",
+	KindTimestamp:   "field.Time",
+	KindTimestamptz: "field.Time",
+	KindDate:        "field.Time",
+	KindBytes:       "field.Bytes",
+	KindEnum:        "field.Enum",
+}
+
+// entImports maps qualifiers used in generated ent schemas to import paths.
+var entImports = map[string]string{
+	"dialect": "entgo.io/ent/dialect",
+	"edge":    "entgo.io/ent/schema/edge",
+	"entsql":  "entgo.io/ent/dialect/entsql",
+	"field":   "entgo.io/ent/schema/field",
+	"index":   "entgo.io/ent/schema/index",
+	"json":    "encoding/json",
+	"pgtype":  "github.com/jackc/pgtype",
+	"uuid":    "github.com/google/uuid",
+}
+
+// entField returns the ent field declaration for the column along with the
+// package qualifiers it uses. ent always names the primary key field "id", so
+// other primary key columns keep their name through StorageKey.
+func entField(c Column, primaryKey bool) (string, []string) {
+	typ := c.Type()
+	name := c.Name
+	if primaryKey {
+		name = "id"
+	}
+	qualifiers := []string{"field"}
+	b := strings.Builder{}
+	builder, native := entFields[typ.Kind]
+	switch {
+	case typ.Array:
+		fmt.Fprintf(&b, "field.Other(%q, &%s{})", name, goTypes[typ.Kind].Array)
+	case typ.Kind == KindUUID:
+		fmt.Fprintf(&b, "field.UUID(%q, uuid.UUID{})", name)
+		qualifiers = append(qualifiers, "uuid")
+	case typ.Kind == KindJSON:
+		fmt.Fprintf(&b, "field.JSON(%q, json.RawMessage{})", name)
+		qualifiers = append(qualifiers, "json")
+	case typ.Kind == KindString && c.PGType == "text":
+		fmt.Fprintf(&b, "field.Text(%q)", name)
+	case native:
+		fmt.Fprintf(&b, "%s(%q)", builder, name)
+	default:
+		fmt.Fprintf(&b, "field.Other(%q, &%s{})", name, goTypes[typ.Kind].Nullable)
+	}
+	if typ.Array || !native && typ.Kind != KindUUID && typ.Kind != KindJSON {
+		// Types without a native ent field are stored with the pgtype value
+		// that scans them.
+		fmt.Fprintf(&b, ".\n\t\t\tSchemaType(map[string]string{dialect.Postgres: %q})", c.SQLType())
+		qualifiers = append(qualifiers, "pgtype", "dialect")
+	}
+
+	switch {
+	case typ.Array:
+	case typ.Kind == KindString && c.PGType != "text" && c.MaxLength > 0:
+		fmt.Fprintf(&b, ".\n\t\t\tMaxLen(%d)", c.MaxLength)
+	case typ.Kind == KindEnum:
+		values := make([]string, 0, len(c.EnumValues))
+		for _, value := range c.EnumValues {
+			values = append(values, fmt.Sprintf("%q", value))
+		}
+		fmt.Fprintf(&b, ".\n\t\t\tValues(%s)", strings.Join(values, ", "))
+	case typ.Kind == KindDate:
+		b.WriteString(".\n\t\t\tSchemaType(map[string]string{dialect.Postgres: \"date\"})")
+		qualifiers = append(qualifiers, "dialect")
+	}
+	if name != c.Name {
+		fmt.Fprintf(&b, ".\n\t\t\tStorageKey(%q)", c.Name)
+	}
+	if c.Nullable {
+		b.WriteString(".\n\t\t\tOptional().\n\t\t\tNillable()")
+	}
+	if c.Default != "" {
+		if !c.Nullable {
+			b.WriteString(".\n\t\t\tOptional()")
+		}
+		fmt.Fprintf(&b, ".\n\t\t\tAnnotations(entsql.DefaultExpr(%q))", c.Default)
+	}
+	if c.Generated {
+		b.WriteString(".\n\t\t\tImmutable()")
+	}
+	if c.Comment != "" {
+		fmt.Fprintf(&b, ".\n\t\t\tComment(%q)", c.Comment)
+	}
+	return b.String(), qualifiers
+}
+
+// entEdgeNames names both ends of every foreign key edge. The child end is
+// named after the foreign key column (vehicle_id becomes vehicle), the parent
+// end after the child table, qualified by the column when a child references
+// the same parent more than once.
+func entEdgeNames(edges []DiagramEdge) ([]string, []string) {
+	counts := map[string]int{}
+	for _, edge := range edges {
+		counts[edge.From.Schema+"."+edge.From.Name+">"+edge.To.Schema+"."+edge.To.Name]++
+	}
+	children := make([]string, 0, len(edges))
+	parents := make([]string, 0, len(edges))
+	for _, edge := range edges {
+		children = append(children, strcase.ToLowerCamel(edge.Column.RelationAlias()))
+		parent := pluralize(edge.From.Name)
+		if counts[edge.From.Schema+"."+edge.From.Name+">"+edge.To.Schema+"."+edge.To.Name] > 1 {
+			parent = edge.Column.RelationAlias() + "_" + parent
+		}
+		parents = append(parents, strcase.ToLowerCamel(parent))
+	}
+	return children, parents
+}
+
+// EntEntity is a table rendered as an ent schema type.
+type EntEntity struct {
+	Table   *GenerationTable
+	Fields  []string
+	Edges   []string
+	Indexes []string
+}
+
+// entEntities builds the ent schema types for every table and returns them
+// with the package qualifiers their declarations use.
+func entEntities(schemas []GenerationSchema) ([]*EntEntity, map[string]bool) {
+	qualifiers := map[string]bool{"entsql": true}
+	entities := []*EntEntity{}
+	byTable := map[*GenerationTable]*EntEntity{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			entity := &EntEntity{Table: table}
+			for _, c := range table.Columns {
+				field, used := entField(c, c.Name == table.Config.PrimaryKey)
+				entity.Fields = append(entity.Fields, field)
+				for _, qualifier := range used {
+					qualifiers[qualifier] = true
+				}
+			}
+			for _, idx := range table.Indexes {
+				if idx.Primary || !tableHasColumns(&table.Table, idx.Columns) {
+					continue
+				}
+				fields := make([]string, 0, len(idx.Columns))
+				for _, name := range idx.Columns {
+					fields = append(fields, fmt.Sprintf("%q", name))
+				}
+				declaration := fmt.Sprintf("index.Fields(%s)", strings.Join(fields, ", "))
+				if idx.Unique {
+					declaration += ".\n\t\t\tUnique()"
+				}
+				declaration += fmt.Sprintf(".\n\t\t\tStorageKey(%q)", idx.Name)
+				entity.Indexes = append(entity.Indexes, declaration)
+				qualifiers["index"] = true
+			}
+			entities = append(entities, entity)
+			byTable[table] = entity
+		}
+	}
+
+	edges := diagramEdges(schemas)
+	children, parents := entEdgeNames(edges)
+	for i, edge := range edges {
+		child := byTable[edge.From]
+		parent := byTable[edge.To]
+		parent.Edges = append(parent.Edges, fmt.Sprintf("edge.To(%q, %s.Type)", parents[i], child.Table.TypeName()))
+		declaration := fmt.Sprintf("edge.From(%q, %s.Type).\n\t\t\tRef(%q).\n\t\t\tField(%q).\n\t\t\tUnique()", children[i], parent.Table.TypeName(), parents[i], edge.Column.Name)
+		if !edge.Column.Nullable {
+			declaration += ".\n\t\t\tRequired()"
+		}
+		child.Edges = append(child.Edges, declaration)
+		qualifiers["edge"] = true
+	}
+	return entities, qualifiers
+}
+
+func tableHasColumns(t *Table, names []string) bool {
+	for _, name := range names {
+		if !t.HasColumn(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeEntList(b *strings.Builder, entity string, method string, returnType string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n// %s of the %s.\n", method, entity)
+	fmt.Fprintf(b, "func (%s) %s() []%s {\n\treturn []%s{\n", entity, method, returnType, returnType)
+	for _, item := range items {
+		fmt.Fprintf(b, "\t\t%s,\n", item)
+	}
+	b.WriteString("\t}\n}\n")
+}
+
+// generateEnt writes an ent schema package (entgo.io/ent) with one schema type
+// per table, including fields, edges from foreign keys, and indexes.
+func generateEnt(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	entities, qualifiers := entEntities(schemas)
+	body := strings.Builder{}
+	for _, entity := range entities {
+		name := entity.Table.TypeName()
+		fmt.Fprintf(&body, "\n// %s holds the schema definition for %s.%s.\n", name, entity.Table.Schema, entity.Table.Name)
+		fmt.Fprintf(&body, "type %s struct {\n\tent.Schema\n}\n", name)
+		fmt.Fprintf(&body, "\n// Annotations of the %s.\n", name)
+		fmt.Fprintf(&body, "func (%s) Annotations() []schema.Annotation {\n\treturn []schema.Annotation{\n\t\tentsql.Annotation{Table: %q},\n\t}\n}\n", name, entity.Table.Name)
+		writeEntList(&body, name, "Fields", "ent.Field", entity.Fields)
+		writeEntList(&body, name, "Edges", "ent.Edge", entity.Edges)
+		writeEntList(&body, name, "Indexes", "ent.Index", entity.Indexes)
+	}
+
+	imports := []string{"entgo.io/ent", "entgo.io/ent/schema"}
+	for qualifier := range qualifiers {
+		imports = append(imports, entImports[qualifier])
+	}
+	sort.Strings(imports)
+
+	src := bytes.NewBufferString("// Code generated by pginspector. DO NOT EDIT.\n\npackage schema\n\nimport (\n")
+	for _, path := range imports {
+		fmt.Fprintf(src, "\t%q\n", path)
+	}
+	src.WriteString(")\n")
+	src.WriteString(body.String())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to format generated Go code:\n%s", src.String()))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
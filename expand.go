@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// expandConfigNode replaces ${VAR} in the scalars of a config with the
+// environment variable, failing if it's not set, or ${VAR:-default} with the
+// default if it's unset or empty. $${ is written as a literal ${. Plain
+// scalars are typed again after expanding, so ${VAR} can set a number or a
+// bool, and quoted ones stay strings.
+func expandConfigNode(name string, node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "${") {
+		value, err := expandConfigValue(node.Value)
+		if err != nil {
+			return errors.WithMessagef(err, "Unable to expand %s line %d", name, node.Line)
+		}
+		node.Value = value
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	for _, child := range node.Content {
+		if err := expandConfigNode(name, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandConfigValue replaces the environment variable references in a value.
+func expandConfigValue(value string) (string, error) {
+	b := strings.Builder{}
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		if start > 0 && value[start-1] == '$' {
+			b.WriteString(value[:start-1] + "${")
+			value = value[start+2:]
+			continue
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", errors.Errorf("Unterminated ${ in %q", value)
+		}
+		reference := value[start+2 : start+end]
+		variable, fallback, hasDefault := strings.Cut(reference, ":-")
+		if variable == "" {
+			return "", errors.Errorf("Empty environment variable name in %q", value)
+		}
+		expanded, ok := os.LookupEnv(variable)
+		if hasDefault && expanded == "" {
+			expanded, ok = fallback, true
+		}
+		if !ok {
+			return "", errors.Errorf("Environment variable %s is not set (use ${%s:-default} for a default)", variable, variable)
+		}
+		b.WriteString(value[:start] + expanded)
+		value = value[start+end+1:]
+	}
+}
@@ -0,0 +1,1795 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultExtractPseudonymKey = "PGINSPECTOR_PSEUDONYM_KEY"
+	defaultExtractConcurrency  = 4
+	defaultExtractChunkSize    = 500
+)
+
+// defaultExtractOutputs are where each format is written by default. ndjson
+// and csv write a directory, with a file per table.
+var defaultExtractOutputs = map[string]string{
+	"sql":    "extract.sql",
+	"json":   "extract.json",
+	"ndjson": "extract",
+	"csv":    "extract",
+}
+
+// ExtractConfig configures the extract command, which copies the rows
+// reachable from a seed row, or from samples of root tables, through foreign
+// keys.
+type ExtractConfig struct {
+	// SeedTable is the table of the seed row, qualified by its schema unless
+	// it's in public.
+	SeedTable string `yaml:"seed_table"`
+	// SeedKey is the column identifying the seed row, the table's primary
+	// key by default.
+	SeedKey string `yaml:"seed_key"`
+	// SeedValue is the value of SeedKey in the seed row.
+	SeedValue string `yaml:"seed_value"`
+	// SeedValues seeds the extraction from several rows, by their values of
+	// SeedKey, along with SeedValue.
+	SeedValues []string `yaml:"seed_values"`
+	// SeedWhere seeds the extraction from the rows of the seed table matching
+	// a predicate, e.g. org_id = $1, instead of by SeedKey.
+	SeedWhere string `yaml:"seed_where"`
+	// SeedArgs are bound to the placeholders of SeedWhere.
+	SeedArgs []string `yaml:"seed_args"`
+	// Samples extract a subset of the whole database instead of the rows
+	// around a seed, from rows sampled from root tables.
+	Samples []ExtractSample `yaml:"samples"`
+	// MaxDepth limits how many foreign keys away from the seed row rows are
+	// followed (0 for no limit).
+	MaxDepth int `yaml:"max_depth"`
+	// MaxRows limits the number of rows extracted (0 for no limit).
+	MaxRows int `yaml:"max_rows"`
+	// MaxRowsPerTable limits the number of rows extracted from each table
+	// (0 for no limit).
+	MaxRowsPerTable int `yaml:"max_rows_per_table"`
+	// Dangling is what's done about foreign keys of the extracted rows
+	// referencing rows left out, by the limits, the directions followed, or
+	// skipped tables, which make the rows fail to load: report lists them
+	// (the default), fetch extracts the missing rows and the rows they
+	// reference, beyond the limits, and error fails the extraction.
+	Dangling string `yaml:"dangling"`
+	// Concurrency is the number of lookups run at once, each on a connection
+	// of the pool, 4 by default.
+	Concurrency int `yaml:"concurrency"`
+	// Direction is the way foreign keys are followed: parents to follow them
+	// to the rows a row references, children to follow them back to the rows
+	// referencing it, or both (the default, or parents with samples).
+	// Following only children can leave out rows the extracted rows
+	// reference.
+	Direction string `yaml:"direction"`
+	// Relations override how specific foreign keys are followed.
+	Relations []ExtractRelation `yaml:"relations"`
+	// DisableTriggers loads the rows with triggers, including those checking
+	// foreign keys, disabled for the transaction, which takes a superuser.
+	// Deferring constraints only helps with foreign keys declared DEFERRABLE,
+	// so this is how rows referencing each other through immediate foreign
+	// keys load.
+	DisableTriggers bool `yaml:"disable_triggers"`
+	// Replace clears what the rows replace before they're loaded, so loading
+	// them again replaces rather than duplicates them: truncate truncates the
+	// tables with extracted rows, cascading to the tables referencing them,
+	// and delete deletes the extracted rows by primary key.
+	Replace string `yaml:"replace"`
+	// SchemaConfig leaves tables and columns out of the extraction, in the
+	// format of the generation config: tables in skip_tables are never
+	// queried, and the exclude_columns of a table's table_config are neither
+	// read nor written.
+	SchemaConfig map[string]SchemaConfig `yaml:"schema_config"`
+	// Masks anonymize columns of the extracted rows.
+	Masks []ExtractMask `yaml:"masks"`
+	// PseudonymKeyEnv names the environment variable holding the key of
+	// hmac masks, PGINSPECTOR_PSEUDONYM_KEY by default.
+	PseudonymKeyEnv string `yaml:"pseudonym_key_env"`
+	// ChunkSize is the number of rows inserted per INSERT statement, 500 by
+	// default, so statements stay small enough to parse quickly.
+	ChunkSize int `yaml:"chunk_size"`
+	// Format is sql for INSERT statements (the default), json for a document
+	// with the rows of each table, ndjson for a file per table with a row
+	// per line, or csv for a CSV file per table.
+	Format string `yaml:"format"`
+	// CSVNull is how csv writes NULL, an empty field by default.
+	CSVNull string `yaml:"csv_null"`
+	// Output is the file the rows are written to, or the directory for
+	// ndjson and csv, named extract after the format by default.
+	Output string `yaml:"output"`
+	// Checkpoint is a file the progress of the extraction is saved to, so an
+	// interrupted run resumes from it, and a finished one extracts only the
+	// rows it didn't find when run again. sql and json rows are kept next to
+	// it, in checkpoint.spool, until they're written out.
+	Checkpoint string `yaml:"checkpoint"`
+	// SchemaSnapshot is a file written by the snapshot command the schemas
+	// are loaded from, instead of inspecting the database.
+	SchemaSnapshot string `yaml:"schema_snapshot"`
+	// Manifest is a JSON file recording the seed, the rows written from each
+	// table, the truncated relations, and the masks, for auditing.
+	Manifest string `yaml:"manifest"`
+}
+
+// extractOutput returns the format and the default output of the config.
+func extractOutput(cfg ExtractConfig) (string, string, error) {
+	format := cfg.Format
+	if format == "" {
+		format = "sql"
+	}
+	output, ok := defaultExtractOutputs[format]
+	if !ok {
+		return "", "", errors.Errorf("Unknown extract format %q, expected sql, json, ndjson, or csv", cfg.Format)
+	}
+	if cfg.Output != "" {
+		output = cfg.Output
+	}
+	return format, output, nil
+}
+
+// ExtractMask replaces the values of a column in the extracted rows, so
+// personal data doesn't leave the database it was extracted from.
+type ExtractMask struct {
+	// Table is qualified by its schema unless it's in public.
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+	// Action is null to clear the values, constant to replace them with
+	// Value, parsed as the column's type, redact to replace the matches of
+	// Pattern with Value, or hash to replace them with the SHA-256 of their
+	// text in the form of the column's type, which keeps equal values equal.
+	// hmac pseudonymizes them with a keyed hash instead, so they can't be
+	// recovered by hashing guesses; see extractPseudonym.
+	// fake replaces them with realistic values of the kind in Value (name,
+	// first_name, last_name, email, phone, address, city, postal_code, or
+	// company), guessed from the column's name when Value is empty.
+	Action  string `yaml:"action"`
+	Value   string `yaml:"value"`
+	Pattern string `yaml:"pattern"`
+}
+
+// extractMasker replaces a value of a masked column.
+type extractMasker func(value interface{}) interface{}
+
+// extractPseudonym returns the keyed hash of a value in the form of the
+// column's type, so it can stand in for the value: a UUID for uuid columns, a
+// positive number for integer columns, and hex text otherwise, formatted by
+// the mask's value (e.g. user-%s@example.com) and cut to the column's length.
+// The same key gives the same pseudonym for a value in every table and every
+// extraction, so masked keys still join and unique values stay unique, bar
+// hash collisions in short integer and text columns.
+func extractPseudonym(key []byte, c Column, format string, value interface{}) interface{} {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(extractText(value)))
+	return extractDigest(c, format, mac.Sum(nil))
+}
+
+// extractDigest returns a hash sum in the form of the column's type, as
+// described for extractPseudonym.
+func extractDigest(c Column, format string, sum []byte) interface{} {
+	switch c.Type().Kind {
+	case KindUUID:
+		var id uuid.UUID
+		copy(id[:], sum)
+		id[6] = id[6]&0x0f | 0x80 // version 8, custom
+		id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+		return id.String()
+	case KindInt16:
+		return int64(binary.BigEndian.Uint16(sum) >> 1)
+	case KindInt32:
+		return int64(binary.BigEndian.Uint32(sum) >> 1)
+	case KindInt64:
+		return int64(binary.BigEndian.Uint64(sum) >> 1)
+	}
+	pseudonym := hex.EncodeToString(sum)
+	if strings.Contains(format, "%s") {
+		pseudonym = strings.ReplaceAll(format, "%s", pseudonym)
+	}
+	if c.MaxLength > 0 && len(pseudonym) > c.MaxLength {
+		pseudonym = pseudonym[:c.MaxLength]
+	}
+	return pseudonym
+}
+
+// extractMaskers compiles the masks, by table and column position.
+func extractMaskers(schemas []GenerationSchema, cfg ExtractConfig) (map[*GenerationTable]map[int]extractMasker, error) {
+	keyEnv := cfg.PseudonymKeyEnv
+	if keyEnv == "" {
+		keyEnv = defaultExtractPseudonymKey
+	}
+	maskers := map[*GenerationTable]map[int]extractMasker{}
+	for _, mask := range cfg.Masks {
+		table, err := extractSeedTable(schemas, mask.Table)
+		if err != nil {
+			return nil, errors.Errorf("Masked table %s is not one of the selected tables", mask.Table)
+		}
+		position := -1
+		for i, c := range table.Columns {
+			if c.Name == mask.Column {
+				position = i
+			}
+		}
+		if position < 0 {
+			return nil, errors.Errorf("Masked column %s not found in table %s.%s", mask.Column, table.Schema, table.Name)
+		}
+
+		var masker extractMasker
+		switch mask.Action {
+		case "null":
+			if !table.Columns[position].Nullable {
+				return nil, errors.Errorf("Masked column %s.%s.%s is not nullable", table.Schema, table.Name, mask.Column)
+			}
+			masker = func(value interface{}) interface{} { return nil }
+		case "constant":
+			constant, err := extractConstant(table.Columns[position], mask.Value)
+			if err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("Invalid constant for masked column %s.%s.%s", table.Schema, table.Name, mask.Column))
+			}
+			masker = func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				return constant
+			}
+		case "redact":
+			pattern, err := regexp.Compile(mask.Pattern)
+			if err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("Invalid pattern for masked column %s.%s.%s", table.Schema, table.Name, mask.Column))
+			}
+			replacement := mask.Value
+			masker = func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				return pattern.ReplaceAllLiteralString(extractText(value), replacement)
+			}
+		case "hash":
+			column := table.Columns[position]
+			if !extractDigestible(column) {
+				return nil, errors.Errorf("Masked column %s.%s.%s is %s, so it can't hold hashes", table.Schema, table.Name, mask.Column, column.PGType)
+			}
+			masker = func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				sum := sha256.Sum256([]byte(extractText(value)))
+				return extractDigest(column, "", sum[:])
+			}
+		case "hmac":
+			key := os.Getenv(keyEnv)
+			if key == "" {
+				return nil, errors.Errorf("Masked column %s.%s.%s is pseudonymized, but %s is not set", table.Schema, table.Name, mask.Column, keyEnv)
+			}
+			column, format := table.Columns[position], mask.Value
+			masker = func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				return extractPseudonym([]byte(key), column, format, value)
+			}
+		case "fake":
+			column := table.Columns[position]
+			kind, err := fakeKind(column, mask.Value)
+			if err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("Invalid fake mask for %s.%s", table.Schema, table.Name))
+			}
+			masker = func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				return fakeValue(column, kind, value)
+			}
+		default:
+			return nil, errors.Errorf("Unknown mask action %q for %s.%s.%s, expected null, constant, redact, hash, hmac, or fake", mask.Action, table.Schema, table.Name, mask.Column)
+		}
+		if maskers[table] == nil {
+			maskers[table] = map[int]extractMasker{}
+		}
+		maskers[table][position] = masker
+	}
+	return maskers, nil
+}
+
+// extractDigestible reports whether extractDigest returns values of the
+// column's type.
+func extractDigestible(c Column) bool {
+	typ := c.Type()
+	if typ.Array {
+		return false
+	}
+	switch typ.Kind {
+	case KindString, KindUUID, KindInt16, KindInt32, KindInt64:
+		return true
+	}
+	return false
+}
+
+// extractConstant parses the value of a constant mask as the column's type.
+// Values of types without a Go form here are kept as text, which Postgres
+// parses when they're loaded.
+func extractConstant(c Column, constant string) (interface{}, error) {
+	typ := c.Type()
+	if typ.Array {
+		return constant, nil
+	}
+	switch typ.Kind {
+	case KindInt16, KindInt32, KindInt64:
+		return strconv.ParseInt(constant, 10, 64)
+	case KindFloat32, KindFloat64:
+		return strconv.ParseFloat(constant, 64)
+	case KindBool:
+		return strconv.ParseBool(constant)
+	case KindUUID:
+		id, err := uuid.Parse(constant)
+		if err != nil {
+			return nil, err
+		}
+		return id.String(), nil
+	}
+	return constant, nil
+}
+
+// extractText returns the text of a value scanned by pgx, as its SQL
+// literal holds it.
+func extractText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	literal := extractLiteral(value)
+	if strings.HasPrefix(literal, "'") {
+		return strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	}
+	return literal
+}
+
+// masked returns a row with the masks of its table applied. The rows are
+// masked as they're added, once their foreign keys have been read, so masked
+// keys are still followed.
+func (e *Extraction) masked(table *GenerationTable, row []interface{}) []interface{} {
+	columns, ok := e.maskers[table]
+	if !ok {
+		return row
+	}
+	masked := append([]interface{}{}, row...)
+	for i, masker := range columns {
+		masked[i] = masker(masked[i])
+	}
+	return masked
+}
+
+// ExtractRelation overrides how a foreign key is followed.
+type ExtractRelation struct {
+	// Column is the referencing column, as table.column, qualified by the
+	// table's schema unless it's in public.
+	Column string `yaml:"column"`
+	// Direction is parents, children, or both, as for ExtractConfig.
+	Direction string `yaml:"direction"`
+	// Follow set to false leaves the foreign key out of the walk entirely.
+	Follow *bool `yaml:"follow"`
+}
+
+// extractDirections are whether a direction follows foreign keys to parents
+// and to children.
+var extractDirections = map[string][2]bool{
+	"parents":  {true, false},
+	"children": {false, true},
+	"both":     {true, true},
+}
+
+// extractEdgeDirections returns whether each foreign key is followed to
+// parents and to children, by position in fks.
+func extractEdgeDirections(fks []extractForeignKey, cfg ExtractConfig) ([][2]bool, error) {
+	direction := cfg.Direction
+	if direction == "" && len(cfg.Samples) > 0 {
+		direction = "parents"
+	} else if direction == "" {
+		direction = "both"
+	}
+	defaults, ok := extractDirections[direction]
+	if !ok {
+		return nil, errors.Errorf("Unknown extract direction %q, expected parents, children, or both", direction)
+	}
+	directions := make([][2]bool, len(fks))
+	for i := range fks {
+		directions[i] = defaults
+	}
+	for _, relation := range cfg.Relations {
+		follow, ok := extractDirections[relation.Direction]
+		switch {
+		case relation.Follow != nil && !*relation.Follow:
+			follow = [2]bool{false, false}
+		case relation.Direction == "" && relation.Follow != nil:
+			follow = defaults
+		case !ok:
+			return nil, errors.Errorf("Unknown direction %q for relation %s, expected parents, children, or both", relation.Direction, relation.Column)
+		}
+		found := false
+		for i, fk := range fks {
+			if extractRelationMatches(fk, relation.Column) {
+				directions[i], found = follow, true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("Relation %s is not a foreign key between the selected tables", relation.Column)
+		}
+	}
+	return directions, nil
+}
+
+// extractRelationMatches reports whether one of a foreign key's referencing
+// columns is the named one.
+func extractRelationMatches(fk extractForeignKey, name string) bool {
+	for _, c := range fk.Columns {
+		column := fk.From.Name + "." + c
+		if name == fk.From.Schema+"."+column || (fk.From.Schema == "public" && name == column) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractSkips returns the tables in skip_tables and the columns in
+// exclude_columns. Columns needed to walk or load the rows can't be excluded.
+func extractSkips(edges []DiagramEdge, schemas []GenerationSchema, cfg ExtractConfig) (map[*GenerationTable]bool, map[*GenerationTable]map[string]bool, error) {
+	keys := map[*GenerationTable]map[string]bool{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		for table, column := range map[*GenerationTable]string{edge.From: edge.Column.Name, edge.To: edge.Column.Relation.Column.Name} {
+			if keys[table] == nil {
+				keys[table] = map[string]bool{}
+			}
+			keys[table][column] = true
+		}
+	}
+
+	skipped := map[*GenerationTable]bool{}
+	omitted := map[*GenerationTable]map[string]bool{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			schemaConfig := cfg.SchemaConfig[table.Schema]
+			if schemaConfig.ShouldSkipTable(table.Name) {
+				skipped[table] = true
+				continue
+			}
+			for _, name := range schemaConfig.GetTableConfig(table.Name).ExcludeColumns {
+				column, ok := table.GetColumn(name)
+				switch {
+				case !ok:
+					return nil, nil, errors.Errorf("Excluded column %s not found in table %s.%s", name, table.Schema, table.Name)
+				case name == table.Config.PrimaryKey || keys[table][name]:
+					return nil, nil, errors.Errorf("Column %s.%s.%s identifies rows, so it can't be excluded", table.Schema, table.Name, name)
+				case !column.Nullable && column.Default == "" && column.Identity == "" && column.GenerationExpression == "":
+					return nil, nil, errors.Errorf("Column %s.%s.%s is not nullable and has no default, so it can't be excluded", table.Schema, table.Name, name)
+				}
+				if omitted[table] == nil {
+					omitted[table] = map[string]bool{}
+				}
+				omitted[table][name] = true
+			}
+		}
+	}
+	return skipped, omitted, nil
+}
+
+// extractQuerier is the part of a connection or pool the extraction uses.
+type extractQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// ExtractedTable holds the rows extracted from a table, in the order they
+// were found.
+type ExtractedTable struct {
+	Table *GenerationTable
+	// Rows hold the values of the table's columns, unless they were
+	// streamed to a sink.
+	Rows [][]interface{}
+	// Omitted are the columns left out by exclude_columns. Their values in
+	// Rows are nil.
+	Omitted map[string]bool
+	// keys are the primary keys of the rows, to skip rows found again.
+	keys map[string]bool
+	// known are the keys of rows written by an earlier run, which are
+	// walked but not written again.
+	known map[string]bool
+	// count is the number of rows found.
+	count int
+}
+
+// Extraction is a set of rows closed over the foreign keys between the
+// selected tables.
+type Extraction struct {
+	// Seed is nil for an extraction from samples.
+	Seed *GenerationTable
+	// SeedFilter describes the seed rows, e.g. id IN ('v1', 'v2'), or the
+	// samples.
+	SeedFilter string
+	Tables     map[*GenerationTable]*ExtractedTable
+	// omitted are the columns left out of each table.
+	omitted map[*GenerationTable]map[string]bool
+	maskers map[*GenerationTable]map[int]extractMasker
+	// sink receives the rows instead of Tables when set.
+	sink       extractSink
+	checkpoint *extractCheckpoint
+	// Truncated lists the relations that weren't followed to the end
+	// because of max_depth, max_rows, or max_rows_per_table, in the order
+	// they were cut off.
+	Truncated []string
+	// Dangling lists the foreign keys referencing rows left out of the
+	// extraction.
+	Dangling []string
+	// references and referenced are the values of each foreign key, by
+	// relation, in the extracted rows and in the rows they reference.
+	references map[string]map[string]bool
+	referenced map[string]map[string]bool
+	// rows is the number of rows extracted.
+	rows int
+}
+
+// extractLookup finds the rows of a table whose column has a value, or
+// that match a predicate.
+type extractLookup struct {
+	Table  *GenerationTable
+	Column string
+	Value  interface{}
+	// Where is a predicate used instead of Column and Value, with Args
+	// bound to its placeholders.
+	Where string
+	Args  []interface{}
+	// Limit and Percent sample the rows matching Where, as for
+	// ExtractSample.
+	Limit   int
+	Percent float64
+	// Cast is set when Value is text to be cast to the column's type, as
+	// for seed values from the config.
+	Cast bool
+	// Required is set for lookups of rows referenced by extracted rows but
+	// left out, which are fetched beyond the limits.
+	Required bool
+	// Depth is the number of foreign keys followed from the seed row.
+	Depth int
+	// Relation describes the foreign key followed, for reporting.
+	Relation string
+}
+
+func (l extractLookup) key() string {
+	var key string
+	switch {
+	case l.Limit > 0 || l.Percent > 0:
+		key = fmt.Sprintf("%s.%s WHERE %s SAMPLE %d %g", l.Table.Schema, l.Table.Name, l.Where, l.Limit, l.Percent)
+	case l.Where != "":
+		args := make([]string, 0, len(l.Args))
+		for _, arg := range l.Args {
+			args = append(args, extractText(arg))
+		}
+		key = fmt.Sprintf("%s.%s WHERE %s %v", l.Table.Schema, l.Table.Name, l.Where, args)
+	default:
+		key = fmt.Sprintf("%s.%s.%s=%s", l.Table.Schema, l.Table.Name, l.Column, extractText(l.Value))
+	}
+	if l.Required {
+		return "required " + key
+	}
+	return key
+}
+
+// extractSeedTable finds the seed table among the selected tables.
+func extractSeedTable(schemas []GenerationSchema, name string) (*GenerationTable, error) {
+	schemaName, tableName, ok := strings.Cut(name, ".")
+	if !ok {
+		schemaName, tableName = "public", name
+	}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Schema == schemaName && schema.Tables[i].Name == tableName {
+				return &schema.Tables[i], nil
+			}
+		}
+	}
+	return nil, errors.Errorf("Seed table %s is not one of the selected tables", name)
+}
+
+// extractLookupSQL returns the query of a lookup selecting a select list,
+// and the arguments bound to it.
+func extractLookupSQL(lookup extractLookup, selected string) (string, []interface{}) {
+	where, args := lookup.Where, lookup.Args
+	if where == "" {
+		parameter := "$1"
+		if lookup.Cast {
+			column, _ := lookup.Table.GetColumn(lookup.Column)
+			parameter = "$1::text::" + column.SQLType()
+		}
+		where, args = pgx.Identifier{lookup.Column}.Sanitize()+" = "+parameter, []interface{}{lookup.Value}
+	}
+	from := pgx.Identifier{lookup.Table.Schema, lookup.Table.Name}.Sanitize()
+	if lookup.Percent > 0 {
+		from += fmt.Sprintf(" TABLESAMPLE BERNOULLI (%g)", lookup.Percent)
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s", selected, from, where)
+	if lookup.Limit > 0 {
+		sql += fmt.Sprintf(" ORDER BY random() LIMIT %d", lookup.Limit)
+	}
+	return sql, args
+}
+
+// extractRows runs a lookup, returning the rows with the table's columns.
+// Omitted columns are selected as NULL, so they aren't read.
+func extractRows(ctx context.Context, q extractQuerier, lookup extractLookup, omitted map[string]bool) ([][]interface{}, error) {
+	columns := make([]string, 0, len(lookup.Table.Columns))
+	for _, c := range lookup.Table.Columns {
+		if omitted[c.Name] {
+			columns = append(columns, "NULL AS "+pgx.Identifier{c.Name}.Sanitize())
+		} else {
+			columns = append(columns, pgx.Identifier{c.Name}.Sanitize())
+		}
+	}
+	sql, args := extractLookupSQL(lookup, strings.Join(columns, ", "))
+
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("Unable to query %s.%s", lookup.Table.Schema, lookup.Table.Name))
+	}
+	defer rows.Close()
+	result := [][]interface{}{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Unable to read row of %s.%s", lookup.Table.Schema, lookup.Table.Name))
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
+
+// add adds a row to the extraction, masked, reporting whether it's new.
+func (e *Extraction) add(table *GenerationTable, row []interface{}) (bool, error) {
+	extracted := e.table(table)
+	key := extractRowKey(table, row)
+	if extracted.keys[key] {
+		return false, nil
+	}
+	extracted.keys[key] = true
+	if extracted.known[key] {
+		return true, nil
+	}
+	extracted.count++
+	e.rows++
+	if e.sink != nil {
+		return true, e.sink.write(extracted, e.masked(table, row))
+	}
+	extracted.Rows = append(extracted.Rows, e.masked(table, row))
+	return true, nil
+}
+
+// source describes the rows the extraction started from, e.g.
+// public.vehicle where id = 'v1'.
+func (e *Extraction) source() string {
+	if e.Seed == nil {
+		return e.SeedFilter
+	}
+	return ddlTableName(&e.Seed.Table) + " where " + e.SeedFilter
+}
+
+// table returns the rows extracted from a table.
+func (e *Extraction) table(table *GenerationTable) *ExtractedTable {
+	extracted, ok := e.Tables[table]
+	if !ok {
+		extracted = &ExtractedTable{Table: table, Omitted: e.omitted[table], keys: map[string]bool{}, known: map[string]bool{}}
+		e.Tables[table] = extracted
+	}
+	return extracted
+}
+
+// extractRowKey identifies a row by the text of its primary key, or by all of
+// its values when the table has none.
+func extractRowKey(table *GenerationTable, row []interface{}) string {
+	key := fmt.Sprint(row)
+	for i, c := range table.Columns {
+		if c.Name == table.Config.PrimaryKey {
+			key = extractText(row[i])
+		}
+	}
+	return key
+}
+
+// full reports whether a table has max_rows_per_table rows, and the row
+// isn't one of them.
+func (e *Extraction) full(table *GenerationTable, row []interface{}, maxRows int) bool {
+	extracted, ok := e.Tables[table]
+	return maxRows > 0 && ok && extracted.count >= maxRows && !extracted.keys[extractRowKey(table, row)]
+}
+
+// truncate records that a relation wasn't followed to the end.
+func (e *Extraction) truncate(relation string, reason string) {
+	truncated := fmt.Sprintf("%s (%s)", relation, reason)
+	for _, existing := range e.Truncated {
+		if existing == truncated {
+			return
+		}
+	}
+	e.Truncated = append(e.Truncated, truncated)
+}
+
+// extractForeignKey is a foreign key between the selected tables, of one
+// column or several.
+type extractForeignKey struct {
+	From *GenerationTable
+	To   *GenerationTable
+	// Columns reference the Referenced columns, in the same order.
+	Columns    []string
+	Referenced []string
+	// Name describes the foreign key, e.g.
+	// public.rental.vehicle_id -> public.vehicle.id, or
+	// public.rental.(region, vehicle_id) -> public.vehicle.(region, id).
+	Name string
+}
+
+// extractForeignKeys groups the edges into foreign keys, joining the columns
+// of composite foreign keys by their constraint.
+func extractForeignKeys(edges []DiagramEdge) []extractForeignKey {
+	fks := []extractForeignKey{}
+	constraints := map[*GenerationTable]map[string]int{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		constraint := edge.Column.Relation.Constraint
+		if i, ok := constraints[edge.From][constraint]; ok && constraint != "" && fks[i].To == edge.To {
+			fks[i].Columns = append(fks[i].Columns, edge.Column.Name)
+			fks[i].Referenced = append(fks[i].Referenced, edge.Column.Relation.Column.Name)
+			continue
+		}
+		if constraints[edge.From] == nil {
+			constraints[edge.From] = map[string]int{}
+		}
+		constraints[edge.From][constraint] = len(fks)
+		fks = append(fks, extractForeignKey{From: edge.From, To: edge.To, Columns: []string{edge.Column.Name}, Referenced: []string{edge.Column.Relation.Column.Name}})
+	}
+	for i, fk := range fks {
+		columns, referenced := fk.Columns[0], fk.Referenced[0]
+		if len(fk.Columns) > 1 {
+			columns, referenced = "("+strings.Join(fk.Columns, ", ")+")", "("+strings.Join(fk.Referenced, ", ")+")"
+		}
+		fks[i].Name = fmt.Sprintf("%s.%s.%s -> %s.%s.%s", fk.From.Schema, fk.From.Name, columns, fk.To.Schema, fk.To.Name, referenced)
+	}
+	return fks
+}
+
+// extractKeyValues returns the values of a row's columns, reporting false if
+// one is NULL, which leaves a foreign key unchecked.
+func extractKeyValues(columns []string, values map[string]interface{}) ([]interface{}, bool) {
+	key := make([]interface{}, 0, len(columns))
+	for _, c := range columns {
+		if values[c] == nil {
+			return nil, false
+		}
+		key = append(key, values[c])
+	}
+	return key, true
+}
+
+// extractKeyLookup returns the lookup of the rows of a table whose columns
+// have values: by Column and Value for one column, and by a Where predicate
+// for several.
+func extractKeyLookup(table *GenerationTable, columns []string, values []interface{}, cast bool) extractLookup {
+	if len(columns) == 1 {
+		return extractLookup{Table: table, Column: columns[0], Value: values[0], Cast: cast}
+	}
+	conditions := make([]string, 0, len(columns))
+	for i, name := range columns {
+		parameter := fmt.Sprintf("$%d", i+1)
+		if cast {
+			column, _ := table.GetColumn(name)
+			parameter += "::text::" + column.SQLType()
+		}
+		conditions = append(conditions, pgx.Identifier{name}.Sanitize()+" = "+parameter)
+	}
+	return extractLookup{Table: table, Where: strings.Join(conditions, " AND "), Args: values}
+}
+
+// extractBatch runs lookups concurrently, returning the rows of each in the
+// order of the lookups.
+func extractBatch(ctx context.Context, q extractQuerier, batch []extractLookup, omitted map[*GenerationTable]map[string]bool) ([][][]interface{}, error) {
+	results := make([][][]interface{}, len(batch))
+	errs := make([]error, len(batch))
+	wg := sync.WaitGroup{}
+	for i, lookup := range batch {
+		wg.Add(1)
+		go func(i int, lookup extractLookup) {
+			defer wg.Done()
+			results[i], errs[i] = extractRows(ctx, q, lookup, omitted[lookup.Table])
+		}(i, lookup)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// extractSeeds returns the lookups finding the seed rows, and a description
+// of them.
+func extractSeeds(seed *GenerationTable, cfg ExtractConfig) ([]extractLookup, string, error) {
+	if cfg.SeedWhere != "" {
+		if cfg.SeedValue != "" || len(cfg.SeedValues) > 0 {
+			return nil, "", errors.New("The extract config must set either seed_where or seed_value and seed_values")
+		}
+		args := make([]interface{}, 0, len(cfg.SeedArgs))
+		literals := make([]string, 0, len(cfg.SeedArgs))
+		for _, arg := range cfg.SeedArgs {
+			args = append(args, arg)
+			literals = append(literals, ddlLiteral(arg))
+		}
+		filter := cfg.SeedWhere
+		if len(literals) > 0 {
+			filter += " with " + strings.Join(literals, ", ")
+		}
+		return []extractLookup{{Table: seed, Where: cfg.SeedWhere, Args: args, Relation: "seed rows"}}, filter, nil
+	}
+
+	seedKey := cfg.SeedKey
+	if seedKey == "" {
+		seedKey = seed.Config.PrimaryKey
+	}
+	if !seed.HasColumn(seedKey) {
+		return nil, "", errors.Errorf("Seed key %s not found in table %s.%s", seedKey, seed.Schema, seed.Name)
+	}
+	values := cfg.SeedValues
+	if cfg.SeedValue != "" {
+		values = append([]string{cfg.SeedValue}, values...)
+	}
+	if len(values) == 0 {
+		return nil, "", errors.New("The extract config must set seed_value, seed_values, or seed_where")
+	}
+	lookups := make([]extractLookup, 0, len(values))
+	literals := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		lookups = append(lookups, extractLookup{Table: seed, Column: seedKey, Value: value, Cast: true, Relation: "seed rows"})
+		literals = append(literals, ddlLiteral(value))
+	}
+	if len(literals) == 1 {
+		return lookups, ddlIdent(seedKey) + " = " + literals[0], nil
+	}
+	return lookups, ddlIdent(seedKey) + " IN (" + strings.Join(literals, ", ") + ")", nil
+}
+
+// extractGraph is what an extraction walks: the lookups of the rows it
+// starts from, and the foreign keys it follows in each direction.
+type extractGraph struct {
+	// seed is nil for an extraction from samples.
+	seed   *GenerationTable
+	start  []extractLookup
+	filter string
+	fks    []extractForeignKey
+	// directions are whether each foreign key is followed to parents and to
+	// children, by position in fks. Foreign keys of skipped tables aren't.
+	directions [][2]bool
+	skipped    map[*GenerationTable]bool
+	omitted    map[*GenerationTable]map[string]bool
+}
+
+// newExtractGraph checks the config, returning the graph it walks.
+func newExtractGraph(schemas []GenerationSchema, cfg ExtractConfig) (*extractGraph, error) {
+	graph := &extractGraph{}
+	var err error
+	switch {
+	case len(cfg.Samples) > 0 && cfg.SeedTable != "":
+		return nil, errors.New("The extract config must set either seed_table or samples")
+	case len(cfg.Samples) > 0:
+		graph.start, graph.filter, err = extractSamples(schemas, cfg.Samples)
+	case cfg.SeedTable == "":
+		return nil, errors.New("The extract config must set seed_table or samples")
+	default:
+		graph.seed, err = extractSeedTable(schemas, cfg.SeedTable)
+		if err == nil {
+			graph.start, graph.filter, err = extractSeeds(graph.seed, cfg)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	edges := diagramEdges(schemas)
+	graph.fks = extractForeignKeys(edges)
+	graph.skipped, graph.omitted, err = extractSkips(edges, schemas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, lookup := range graph.start {
+		if graph.skipped[lookup.Table] {
+			return nil, errors.Errorf("Table %s.%s is skipped, so it can't be extracted from", lookup.Table.Schema, lookup.Table.Name)
+		}
+	}
+	graph.directions, err = extractEdgeDirections(graph.fks, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Dangling != "" && !extractDanglingActions[cfg.Dangling] {
+		return nil, errors.Errorf("Unknown dangling action %q, expected report, fetch, or error", cfg.Dangling)
+	}
+	if err := extractReplaceCheck(schemas, cfg); err != nil {
+		return nil, err
+	}
+	for i, fk := range graph.fks {
+		if graph.skipped[fk.From] || graph.skipped[fk.To] {
+			graph.directions[i] = [2]bool{false, false}
+		}
+	}
+	return graph, nil
+}
+
+// extract walks the foreign keys between the selected tables from the seed
+// rows, in the configured directions: to the rows each row references, so
+// they can be inserted first, and to the rows referencing it. Up to
+// concurrency lookups run at once, but their rows are taken in the order the
+// lookups were queued, so the result doesn't depend on which finishes
+// first. Each row is visited once, however many seeds and paths lead to it,
+// so reference cycles end. Relations beyond max_depth, or reaching tables
+// with max_rows_per_table rows, and everything left once max_rows rows have
+// been extracted, are recorded as truncated. The masks are applied to the
+// rows found. The foreign keys referencing rows left out are then reported,
+// or fetched.
+func extract(ctx context.Context, q extractQuerier, schemas []GenerationSchema, cfg ExtractConfig) (*Extraction, error) {
+	return extractStreaming(ctx, q, schemas, cfg, nil)
+}
+
+// extractStreaming extracts rows like extract, handing them to sink as
+// they're found rather than holding them in the extraction if it's set.
+func extractStreaming(ctx context.Context, q extractQuerier, schemas []GenerationSchema, cfg ExtractConfig, sink extractSink) (*Extraction, error) {
+	graph, err := newExtractGraph(schemas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	maskers, err := extractMaskers(schemas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	seed, queue, filter := graph.seed, graph.start, graph.filter
+	fks, directions, skipped, omitted := graph.fks, graph.directions, graph.skipped, graph.omitted
+	extraction := &Extraction{Seed: seed, SeedFilter: filter, Tables: map[*GenerationTable]*ExtractedTable{}, omitted: omitted, maskers: maskers, sink: sink, references: map[string]map[string]bool{}, referenced: map[string]map[string]bool{}}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultExtractConcurrency
+	}
+	done := map[string]bool{}
+	if cfg.Checkpoint != "" {
+		if sink == nil {
+			return nil, errors.New("A checkpoint only applies to rows written to an output")
+		}
+		extraction.checkpoint, err = loadExtractCheckpoint(cfg.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		queue, err = extraction.checkpoint.resume(schemas, extraction, queue, done)
+		if err != nil {
+			return nil, err
+		}
+	}
+	saved := time.Now()
+	for len(queue) > 0 {
+		batch := []extractLookup{}
+		for len(queue) > 0 && len(batch) < concurrency {
+			lookup := queue[0]
+			queue = queue[1:]
+			if done[lookup.key()] {
+				continue
+			}
+			done[lookup.key()] = true
+			if cfg.MaxRows > 0 && extraction.rows >= cfg.MaxRows && !lookup.Required {
+				extraction.truncate(lookup.Relation, "max_rows")
+				continue
+			}
+			batch = append(batch, lookup)
+		}
+
+		results, err := extractBatch(ctx, q, batch, omitted)
+		if err != nil {
+			return nil, err
+		}
+		for b, lookup := range batch {
+			if cfg.MaxRows > 0 && extraction.rows >= cfg.MaxRows && !lookup.Required {
+				extraction.truncate(lookup.Relation, "max_rows")
+				continue
+			}
+			for _, row := range results[b] {
+				if cfg.MaxRows > 0 && extraction.rows >= cfg.MaxRows && !lookup.Required {
+					extraction.truncate(lookup.Relation, "max_rows")
+					break
+				}
+				if extraction.full(lookup.Table, row, cfg.MaxRowsPerTable) && !lookup.Required {
+					extraction.truncate(lookup.Relation, "max_rows_per_table")
+					break
+				}
+				added, err := extraction.add(lookup.Table, row)
+				if err != nil {
+					return nil, err
+				}
+				if !added {
+					continue
+				}
+				values := map[string]interface{}{}
+				for i, c := range lookup.Table.Columns {
+					values[c.Name] = row[i]
+				}
+				extraction.reference(fks, lookup.Table, values)
+				if lookup.Required {
+					// The rows these reference are fetched once the walk
+					// ends, if they're missing.
+					continue
+				}
+				for f, fk := range fks {
+					next := []extractLookup{}
+					if key, ok := extractKeyValues(fk.Columns, values); ok && fk.From == lookup.Table && directions[f][0] {
+						next = append(next, extractKeyLookup(fk.To, fk.Referenced, key, false))
+					}
+					if key, ok := extractKeyValues(fk.Referenced, values); ok && fk.To == lookup.Table && directions[f][1] {
+						next = append(next, extractKeyLookup(fk.From, fk.Columns, key, false))
+					}
+					for _, n := range next {
+						n.Depth, n.Relation = lookup.Depth+1, fk.Name
+						if cfg.MaxDepth > 0 && n.Depth > cfg.MaxDepth {
+							if !done[n.key()] {
+								extraction.truncate(n.Relation, "max_depth")
+							}
+							continue
+						}
+						queue = append(queue, n)
+					}
+				}
+			}
+		}
+		if len(queue) == 0 && cfg.Dangling == "fetch" {
+			for _, lookup := range extraction.missing(fks, skipped) {
+				if !done[lookup.key()] {
+					queue = append(queue, lookup)
+				}
+			}
+		}
+		if extraction.checkpoint != nil && time.Since(saved) >= extractCheckpointInterval {
+			if err := extraction.checkpoint.save(extraction, queue, done); err != nil {
+				return nil, err
+			}
+			saved = time.Now()
+		}
+	}
+	if err := extraction.checkIntegrity(fks, cfg); err != nil {
+		return nil, err
+	}
+	if extraction.checkpoint != nil {
+		if err := extraction.checkpoint.save(extraction, nil, done); err != nil {
+			return nil, err
+		}
+	}
+	return extraction, nil
+}
+
+// extractChunkSize returns the number of rows per INSERT statement.
+func extractChunkSize(cfg ExtractConfig) int {
+	if cfg.ChunkSize <= 0 {
+		return defaultExtractChunkSize
+	}
+	return cfg.ChunkSize
+}
+
+// extractConnInfo holds the pgtype types values are encoded with.
+var extractConnInfo = pgtype.NewConnInfo()
+
+// extractLiteral renders a value scanned by pgx as a SQL literal.
+func extractLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return ddlLiteral(v)
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case int16, int32, int64, int:
+		return fmt.Sprint(v)
+	case float32:
+		return extractFloatLiteral(float64(v), 32)
+	case float64:
+		return extractFloatLiteral(v, 64)
+	case time.Time:
+		return ddlLiteral(v.Format(time.RFC3339Nano))
+	case [16]uint8:
+		return ddlLiteral(uuid.UUID(v).String())
+	case []byte:
+		return ddlLiteral(`\x` + hex.EncodeToString(v))
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			return ddlLiteral(string(encoded))
+		}
+	}
+	if text, ok := extractPGText(value); ok {
+		if text == nil {
+			return "NULL"
+		}
+		return ddlLiteral(string(text))
+	}
+	return ddlLiteral(fmt.Sprint(value))
+}
+
+// extractFloatLiteral renders a float, quoting NaN and the infinities,
+// which Postgres only reads as strings.
+func extractFloatLiteral(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'"
+	case math.IsInf(f, 1):
+		return "'Infinity'"
+	case math.IsInf(f, -1):
+		return "'-Infinity'"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// extractPGText returns a value in the text format of its Postgres type, or
+// nil for NULL, using pgtype: pgx scans numerics, intervals, arrays, ranges,
+// and geometric types into pgtype values, and pgtype finds the type of Go
+// values such as network addresses. It reports false for values pgtype can't
+// encode.
+func extractPGText(value interface{}) ([]byte, bool) {
+	encoder, ok := value.(pgtype.TextEncoder)
+	if !ok {
+		dt, found := extractConnInfo.DataTypeForValue(value)
+		if !found {
+			return nil, false
+		}
+		v := pgtype.NewValue(dt.Value)
+		if err := v.Set(value); err != nil {
+			return nil, false
+		}
+		if encoder, ok = v.(pgtype.TextEncoder); !ok {
+			return nil, false
+		}
+	}
+	text, err := encoder.EncodeText(extractConnInfo, nil)
+	if err != nil {
+		return nil, false
+	}
+	return text, true
+}
+
+// extractColumnValue prepares a value of a column to be written as SQL or
+// CSV: json values as their JSON text, since pgx decodes them, and dates
+// without a time of day.
+func extractColumnValue(c Column, value interface{}) interface{} {
+	typ := c.Type()
+	if value == nil || typ.Array {
+		return value
+	}
+	switch typ.Kind {
+	case KindJSON:
+		if encoded, err := json.Marshal(value); err == nil {
+			return string(encoded)
+		}
+	case KindDate:
+		if t, ok := value.(time.Time); ok {
+			return t.Format("2006-01-02")
+		}
+	}
+	return value
+}
+
+// extractTableWriter writes the rows of a table in a format, a row at a time.
+type extractTableWriter struct {
+	w         io.Writer
+	extracted *ExtractedTable
+	format    string
+	cfg       ExtractConfig
+	// included are the positions of the columns written.
+	included []int
+	csv      *csv.Writer
+	rows     int
+	// into is the table sql rows are inserted into, when it isn't the
+	// extracted table.
+	into string
+}
+
+// newExtractTableWriter returns a writer of the rows of a table. Omitted
+// columns are left out, and for sql generated columns too, for the database
+// to fill in.
+func newExtractTableWriter(w io.Writer, extracted *ExtractedTable, format string, cfg ExtractConfig) *extractTableWriter {
+	t := &extractTableWriter{w: w, extracted: extracted, format: format, cfg: cfg}
+	for i, c := range extracted.Table.Columns {
+		if extracted.Omitted[c.Name] || (format == "sql" && c.GenerationExpression != "") {
+			continue
+		}
+		t.included = append(t.included, i)
+	}
+	if format == "csv" {
+		t.csv = csv.NewWriter(w)
+	}
+	return t
+}
+
+// write writes a row: for sql a tuple of the table's INSERT statements, a
+// statement per chunk_size rows, for json an element of the table's array,
+// for ndjson a line, and for csv a record, after a header row of the column
+// names.
+func (t *extractTableWriter) write(row []interface{}) error {
+	var err error
+	switch t.format {
+	case "sql":
+		separator := ",\n    "
+		if t.rows == 0 {
+			separator = t.insertHead()
+		} else if t.rows%extractChunkSize(t.cfg) == 0 {
+			separator = ";\n" + t.insertHead()
+		}
+		values := make([]string, 0, len(t.included))
+		for _, i := range t.included {
+			values = append(values, extractLiteral(extractColumnValue(t.extracted.Table.Columns[i], row[i])))
+		}
+		_, err = io.WriteString(t.w, separator+"("+strings.Join(values, ", ")+")")
+	case "json":
+		separator := ",\n    "
+		if t.rows == 0 {
+			separator = "    "
+		}
+		_, err = io.WriteString(t.w, separator+t.jsonRow(row))
+	case "ndjson":
+		_, err = io.WriteString(t.w, t.jsonRow(row)+"\n")
+	case "csv":
+		if t.rows == 0 {
+			header := make([]string, 0, len(t.included))
+			for _, i := range t.included {
+				header = append(header, t.extracted.Table.Columns[i].Name)
+			}
+			if err := t.csv.Write(header); err != nil {
+				return err
+			}
+		}
+		record := make([]string, 0, len(t.included))
+		for _, i := range t.included {
+			if value := extractColumnValue(t.extracted.Table.Columns[i], row[i]); value == nil {
+				record = append(record, t.cfg.CSVNull)
+			} else {
+				record = append(record, extractText(value))
+			}
+		}
+		err = t.csv.Write(record)
+	}
+	t.rows++
+	return err
+}
+
+// flush writes out the rows the writer buffers.
+func (t *extractTableWriter) flush() error {
+	if t.csv == nil {
+		return nil
+	}
+	t.csv.Flush()
+	return t.csv.Error()
+}
+
+// insertHead returns the start of the table's INSERT statement, up to its
+// first tuple. Identity values are kept.
+func (t *extractTableWriter) insertHead() string {
+	if t.into != "" {
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES\n    ", t.into, t.columnList())
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s)%s VALUES\n    ", ddlTableName(&t.extracted.Table.Table), t.columnList(), t.overriding())
+}
+
+// columnList returns the names of the columns written, comma separated.
+func (t *extractTableWriter) columnList() string {
+	columns := make([]string, 0, len(t.included))
+	for _, i := range t.included {
+		columns = append(columns, ddlIdent(t.extracted.Table.Columns[i].Name))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// overriding returns the clause keeping the values of the columns written
+// that are GENERATED ALWAYS AS IDENTITY, including its leading space.
+func (t *extractTableWriter) overriding() string {
+	for _, i := range t.included {
+		if t.extracted.Table.Columns[i].Identity == "ALWAYS" {
+			return " OVERRIDING SYSTEM VALUE"
+		}
+	}
+	return ""
+}
+
+// jsonRow renders a row as a JSON object with the table's columns in order.
+func (t *extractTableWriter) jsonRow(row []interface{}) string {
+	fields := make([]string, 0, len(t.included))
+	for _, i := range t.included {
+		name, _ := json.Marshal(t.extracted.Table.Columns[i].Name)
+		fields = append(fields, string(name)+": "+string(extractJSONValue(row[i])))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// writeExtractedRows writes the rows an extraction holds for a table.
+func writeExtractedRows(w io.Writer, extracted *ExtractedTable, format string, cfg ExtractConfig) error {
+	t := newExtractTableWriter(w, extracted, format, cfg)
+	for _, row := range extracted.Rows {
+		if err := t.write(row); err != nil {
+			return err
+		}
+	}
+	return t.flush()
+}
+
+// writeExtractionDocument writes a sql or json document, with the rows of
+// each table written by rows in the order the tables can be inserted.
+//
+// The sql document holds INSERT statements, a table at a time so that
+// referenced rows are inserted first. The statements run in a transaction
+// with constraints deferred, so rows whose foreign keys form a cycle load as
+// long as the constraints are deferrable, after the replace statements, and
+// are followed by the statements moving sequences past the inserted values.
+// The json document holds the rows of each table by qualified table name.
+func writeExtractionDocument(w io.Writer, format string, schemas []GenerationSchema, extraction *Extraction, cfg ExtractConfig, rows func(w io.Writer, extracted *ExtractedTable) error) error {
+	b := bufio.NewWriter(w)
+	if format == "sql" {
+		fmt.Fprintf(b, "-- Extracted by pginspector from %s.\n", extraction.source())
+		if len(extraction.Truncated) > 0 {
+			b.WriteString("-- Relations not followed to the end:\n")
+			for _, relation := range extraction.Truncated {
+				fmt.Fprintf(b, "--   %s\n", relation)
+			}
+		}
+		if len(extraction.Dangling) > 0 {
+			b.WriteString("-- Foreign keys referencing rows not extracted:\n")
+			for _, relation := range extraction.Dangling {
+				fmt.Fprintf(b, "--   %s\n", relation)
+			}
+		}
+		b.WriteString("\nBEGIN;\n\nSET CONSTRAINTS ALL DEFERRED;\n")
+		if cfg.DisableTriggers {
+			b.WriteString("SET LOCAL session_replication_role = replica;\n")
+		}
+		if statements := extractReplaceStatements(schemas, extraction, cfg); len(statements) > 0 {
+			b.WriteString("\n")
+			for _, statement := range statements {
+				b.WriteString(statement + ";\n")
+			}
+		}
+	} else {
+		b.WriteString("{\n")
+	}
+	written := 0
+	for _, table := range ddlOrderedTables(schemas) {
+		extracted, ok := extraction.Tables[table]
+		if !ok || extracted.count == 0 {
+			continue
+		}
+		if format == "sql" {
+			b.WriteString("\n")
+		} else {
+			if written > 0 {
+				b.WriteString(",\n")
+			}
+			name, _ := json.Marshal(table.Schema + "." + table.Name)
+			fmt.Fprintf(b, "  %s: [\n", name)
+		}
+		if err := rows(b, extracted); err != nil {
+			return err
+		}
+		if format == "sql" {
+			b.WriteString(";\n")
+		} else {
+			b.WriteString("\n  ]")
+		}
+		written++
+	}
+	if format == "sql" {
+		if statements := extractSequenceStatements(schemas, extraction); len(statements) > 0 {
+			b.WriteString("\n")
+			for _, statement := range statements {
+				b.WriteString(statement + ";\n")
+			}
+		}
+		b.WriteString("\nCOMMIT;\n")
+	} else {
+		b.WriteString("\n}\n")
+	}
+	return b.Flush()
+}
+
+// writeExtraction writes the extracted rows as INSERT statements in a
+// transaction; see writeExtractionDocument.
+func writeExtraction(w io.Writer, schemas []GenerationSchema, extraction *Extraction, cfg ExtractConfig) error {
+	return writeExtractionDocument(w, "sql", schemas, extraction, cfg, func(w io.Writer, extracted *ExtractedTable) error {
+		return writeExtractedRows(w, extracted, "sql", cfg)
+	})
+}
+
+// extractTarget is the part of a connection or pool a clone inserts through.
+type extractTarget interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// extractCloneTable is the temporary table a clone stages a table's rows in.
+type extractCloneTable struct {
+	name    string
+	writer  *extractTableWriter
+	pending [][]interface{}
+}
+
+// extractClone inserts the rows of an extraction straight into a target
+// database as they're found, so they needn't be held in memory. Each table's
+// rows are staged in a temporary table, a statement per chunk_size rows, and
+// moved into the table once the extraction is done, in the order the tables
+// can be inserted, after clearing the rows they replace. It's all one
+// transaction, so either all of the rows are cloned or none are.
+type extractClone struct {
+	ctx    context.Context
+	tx     pgx.Tx
+	cfg    ExtractConfig
+	tables map[*GenerationTable]*extractCloneTable
+	// committed is set once the rows have been moved into their tables.
+	committed bool
+}
+
+// newExtractClone begins the transaction cloning rows into target.
+func newExtractClone(ctx context.Context, target extractTarget, cfg ExtractConfig) (*extractClone, error) {
+	if cfg.Checkpoint != "" {
+		return nil, errors.New("A checkpoint only applies to rows written to an output, not cloned in a single transaction")
+	}
+	tx, err := target.Begin(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to begin transaction in the target database")
+	}
+	c := &extractClone{ctx: ctx, tx: tx, cfg: cfg, tables: map[*GenerationTable]*extractCloneTable{}}
+	statements := []string{"SET CONSTRAINTS ALL DEFERRED"}
+	if cfg.DisableTriggers {
+		statements = append(statements, "SET LOCAL session_replication_role = replica")
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement); err != nil {
+			c.close()
+			return nil, errors.WithMessage(err, "Unable to prepare the target database")
+		}
+	}
+	return c, nil
+}
+
+func (c *extractClone) write(extracted *ExtractedTable, row []interface{}) error {
+	staged, ok := c.tables[extracted.Table]
+	if !ok {
+		staged = &extractCloneTable{name: fmt.Sprintf("pginspector_clone_%d", len(c.tables))}
+		staged.writer = newExtractTableWriter(nil, extracted, "sql", c.cfg)
+		sql := fmt.Sprintf("CREATE TEMPORARY TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA", staged.name, staged.writer.columnList(), ddlTableName(&extracted.Table.Table))
+		if _, err := c.tx.Exec(c.ctx, sql); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Unable to stage rows of %s", ddlTableName(&extracted.Table.Table)))
+		}
+		c.tables[extracted.Table] = staged
+	}
+	staged.pending = append(staged.pending, row)
+	if len(staged.pending) < extractChunkSize(c.cfg) {
+		return nil
+	}
+	return c.stage(staged)
+}
+
+// stage inserts the pending rows of a table into its temporary table.
+func (c *extractClone) stage(staged *extractCloneTable) error {
+	if len(staged.pending) == 0 {
+		return nil
+	}
+	b := strings.Builder{}
+	t := newExtractTableWriter(&b, staged.writer.extracted, "sql", ExtractConfig{ChunkSize: len(staged.pending)})
+	t.into = staged.name
+	for _, row := range staged.pending {
+		t.write(row)
+	}
+	staged.pending = nil
+	if _, err := c.tx.Exec(c.ctx, b.String()); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to stage rows of %s", ddlTableName(&staged.writer.extracted.Table.Table)))
+	}
+	return nil
+}
+
+func (c *extractClone) sync() (map[string]int64, error) {
+	return nil, errors.New("Cloned rows can't be checkpointed")
+}
+
+func (c *extractClone) restore(extracted *ExtractedTable, rows int, size int64) error {
+	return errors.New("Cloned rows can't be restored from a checkpoint")
+}
+
+// finish moves the staged rows into their tables, sets the sequences past
+// them, and commits.
+func (c *extractClone) finish(schemas []GenerationSchema, extraction *Extraction) error {
+	tables := ddlOrderedTables(schemas)
+	for _, table := range tables {
+		if staged, ok := c.tables[table]; ok {
+			if err := c.stage(staged); err != nil {
+				return err
+			}
+		}
+	}
+	for _, statement := range extractReplaceStatements(schemas, extraction, c.cfg) {
+		if _, err := c.tx.Exec(c.ctx, statement); err != nil {
+			return errors.WithMessage(err, "Unable to clear the replaced rows")
+		}
+	}
+	for _, table := range tables {
+		staged, ok := c.tables[table]
+		if !ok {
+			continue
+		}
+		columns := staged.writer.columnList()
+		sql := fmt.Sprintf("INSERT INTO %s (%s)%s SELECT %s FROM %s", ddlTableName(&table.Table), columns, staged.writer.overriding(), columns, staged.name)
+		if _, err := c.tx.Exec(c.ctx, sql); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Unable to insert into %s", ddlTableName(&table.Table)))
+		}
+	}
+	for _, statement := range extractSequenceStatements(schemas, extraction) {
+		if _, err := c.tx.Exec(c.ctx, statement); err != nil {
+			return errors.WithMessage(err, "Unable to set the sequences past the cloned rows")
+		}
+	}
+	if err := c.tx.Commit(c.ctx); err != nil {
+		return errors.WithMessage(err, "Unable to commit the cloned rows")
+	}
+	c.committed = true
+	return nil
+}
+
+// close rolls the transaction back unless the rows were committed.
+func (c *extractClone) close() {
+	if !c.committed {
+		c.tx.Rollback(c.ctx)
+	}
+}
+
+// extractJSONValue renders a value scanned by pgx as JSON: UUIDs as their
+// text, and values with no JSON encoding of their own, such as intervals and
+// arrays scanned into pgtype values, as their SQL text.
+func extractJSONValue(value interface{}) json.RawMessage {
+	switch v := value.(type) {
+	case [16]uint8:
+		value = uuid.UUID(v).String()
+	case nil, string, bool, int16, int32, int64, int, float32, float64, time.Time, []byte, map[string]interface{}, []interface{}, json.Marshaler:
+	default:
+		value = extractText(value)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(extractText(value))
+	}
+	return encoded
+}
+
+// writeExtractionJSON writes the extracted rows as a JSON document with the
+// rows of each table, by qualified table name, in the order they can be
+// inserted.
+func writeExtractionJSON(w io.Writer, schemas []GenerationSchema, extraction *Extraction) error {
+	return writeExtractionDocument(w, "json", schemas, extraction, ExtractConfig{}, func(w io.Writer, extracted *ExtractedTable) error {
+		return writeExtractedRows(w, extracted, "json", ExtractConfig{})
+	})
+}
+
+// writeExtractionFiles writes the extracted rows into a directory, with a
+// schema.table.<format> file per table.
+func writeExtractionFiles(dir string, schemas []GenerationSchema, extraction *Extraction, format string, cfg ExtractConfig) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithMessage(err, "Unable to create extract directory")
+	}
+	for _, table := range ddlOrderedTables(schemas) {
+		extracted, ok := extraction.Tables[table]
+		if !ok {
+			continue
+		}
+		b := bytes.Buffer{}
+		if err := writeExtractedRows(&b, extracted, format, cfg); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, table.Schema+"."+table.Name+"."+format)
+		if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Unable to write %s", path))
+		}
+	}
+	return nil
+}
+
+// writeExtractionNDJSON writes the extracted rows into a directory, with a
+// schema.table.ndjson file per table holding a JSON object per row.
+func writeExtractionNDJSON(dir string, schemas []GenerationSchema, extraction *Extraction) error {
+	return writeExtractionFiles(dir, schemas, extraction, "ndjson", ExtractConfig{})
+}
+
+// writeExtractionCSV writes the extracted rows into a directory, with a
+// schema.table.csv file per table holding a header row of the columns not
+// omitted and a line per row. Values are written as their SQL text, and NULL
+// as csv_null, an empty field by default.
+func writeExtractionCSV(dir string, schemas []GenerationSchema, extraction *Extraction, cfg ExtractConfig) error {
+	return writeExtractionFiles(dir, schemas, extraction, "csv", cfg)
+}
+
+// extractSink receives the rows of an extraction as they're found.
+type extractSink interface {
+	write(extracted *ExtractedTable, row []interface{}) error
+	// sync writes out the rows received so far, returning the size of each
+	// table's rows by qualified name, for a checkpoint.
+	sync() (map[string]int64, error)
+	// restore continues the rows of a table from a checkpoint, dropping
+	// those received after it.
+	restore(extracted *ExtractedTable, rows int, size int64) error
+}
+
+// extractSpoolFile is the file a spool writes a table's rows to.
+type extractSpoolFile struct {
+	path   string
+	file   *os.File
+	buffer *bufio.Writer
+	writer *extractTableWriter
+}
+
+// extractSpool writes the rows of an extraction as they're found, so they
+// needn't be held in memory: ndjson and csv rows straight to their files in
+// the output directory, and sql and json rows to a temporary file per table,
+// put together in the order the tables can be inserted once the extraction is
+// done.
+type extractSpool struct {
+	format string
+	cfg    ExtractConfig
+	dir    string
+	temp   bool
+	files  map[*GenerationTable]*extractSpoolFile
+	// finished is set once the rows have been written out.
+	finished bool
+}
+
+// newExtractSpool returns a spool writing into dir for ndjson and csv, or
+// into a temporary directory for sql and json, next to the checkpoint if
+// there is one.
+func newExtractSpool(format string, dir string, cfg ExtractConfig) (*extractSpool, error) {
+	s := &extractSpool{format: format, cfg: cfg, dir: dir, files: map[*GenerationTable]*extractSpoolFile{}}
+	if (format == "sql" || format == "json") && cfg.Checkpoint != "" {
+		s.dir, s.temp = cfg.Checkpoint+".spool", true
+		if err := os.MkdirAll(s.dir, 0755); err != nil {
+			return nil, errors.WithMessage(err, "Unable to create spool directory")
+		}
+	} else if format == "sql" || format == "json" {
+		temp, err := os.MkdirTemp("", "pginspector-extract")
+		if err != nil {
+			return nil, errors.WithMessage(err, "Unable to create temporary directory")
+		}
+		s.dir, s.temp = temp, true
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "Unable to create extract directory")
+	}
+	return s, nil
+}
+
+func (s *extractSpool) write(extracted *ExtractedTable, row []interface{}) error {
+	f, ok := s.files[extracted.Table]
+	if !ok {
+		path := filepath.Join(s.dir, extracted.Table.Schema+"."+extracted.Table.Name+"."+s.format)
+		file, err := os.Create(path)
+		if err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Unable to write %s", path))
+		}
+		buffer := bufio.NewWriter(file)
+		f = &extractSpoolFile{path: path, file: file, buffer: buffer, writer: newExtractTableWriter(buffer, extracted, s.format, s.cfg)}
+		s.files[extracted.Table] = f
+	}
+	return f.writer.write(row)
+}
+
+func (s *extractSpool) sync() (map[string]int64, error) {
+	sizes := map[string]int64{}
+	for table, f := range s.files {
+		if err := f.writer.flush(); err != nil {
+			return nil, err
+		}
+		if err := f.buffer.Flush(); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Unable to write %s", f.path))
+		}
+		if err := f.file.Sync(); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Unable to write %s", f.path))
+		}
+		info, err := f.file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		sizes[table.Schema+"."+table.Name] = info.Size()
+	}
+	return sizes, nil
+}
+
+func (s *extractSpool) restore(extracted *ExtractedTable, rows int, size int64) error {
+	path := filepath.Join(s.dir, extracted.Table.Schema+"."+extracted.Table.Name+"."+s.format)
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to resume %s", path))
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return errors.WithMessage(err, fmt.Sprintf("Unable to resume %s", path))
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return errors.WithMessage(err, fmt.Sprintf("Unable to resume %s", path))
+	}
+	buffer := bufio.NewWriter(file)
+	writer := newExtractTableWriter(buffer, extracted, s.format, s.cfg)
+	writer.rows = rows
+	s.files[extracted.Table] = &extractSpoolFile{path: path, file: file, buffer: buffer, writer: writer}
+	return nil
+}
+
+// finish writes out and closes the files, then for sql and json writes the
+// document to w. The extraction's checkpoint is marked complete once the
+// rows are written.
+func (s *extractSpool) finish(w io.Writer, schemas []GenerationSchema, extraction *Extraction) error {
+	if _, err := s.sync(); err != nil {
+		return err
+	}
+	for _, f := range s.files {
+		if err := f.file.Close(); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("Unable to write %s", f.path))
+		}
+	}
+	if s.temp {
+		err := writeExtractionDocument(w, s.format, schemas, extraction, s.cfg, func(w io.Writer, extracted *ExtractedTable) error {
+			file, err := os.Open(s.files[extracted.Table].path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(w, file)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if extraction.checkpoint != nil {
+		if err := extraction.checkpoint.complete(extraction); err != nil {
+			return err
+		}
+	}
+	s.finished = true
+	return nil
+}
+
+// close closes the files and removes the temporary directory, unless it
+// holds the rows of a checkpoint yet to be finished.
+func (s *extractSpool) close() {
+	for _, f := range s.files {
+		f.file.Close()
+	}
+	if s.temp && (s.cfg.Checkpoint == "" || s.finished) {
+		os.RemoveAll(s.dir)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	fakeFirstNames = []string{"Ada", "Alan", "Barbara", "Carmen", "Dmitri", "Elena", "Farah", "Grace", "Hiro", "Ines", "Jamal", "Keiko", "Liam", "Maya", "Nadia", "Omar", "Priya", "Quinn", "Rosa", "Sven", "Tariq", "Uma", "Victor", "Wen", "Yusuf", "Zoe"}
+	fakeLastNames  = []string{"Abbott", "Baker", "Castillo", "Dubois", "Eriksen", "Fischer", "Garcia", "Hughes", "Ivanova", "Jensen", "Kowalski", "Lopez", "Moreau", "Nakamura", "Okafor", "Patel", "Quint", "Rossi", "Schmidt", "Tanaka", "Underwood", "Varga", "Walsh", "Xu", "Young", "Zimmer"}
+	fakeStreets    = []string{"Maple Avenue", "Oak Street", "Cedar Lane", "Elm Road", "Birch Way", "Willow Drive", "Pine Court", "Harbor Boulevard", "Mill Road", "Station Street"}
+	fakeCities     = []string{"Springfield", "Riverton", "Lakeside", "Fairview", "Greenville", "Millbrook", "Oakridge", "Brookfield", "Ashford", "Kingsport"}
+	fakeCompanies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Vandelay", "Wonka", "Stark", "Wayne", "Tyrell"}
+	fakeSuffixes   = []string{"Inc.", "LLC", "Ltd.", "Group", "Industries", "Labs"}
+)
+
+// fakeKinds generate the fake values of each kind from a source of numbers.
+var fakeKinds = map[string]func(next func(n int) int) string{
+	"first_name": func(next func(n int) int) string { return fakeFirstNames[next(len(fakeFirstNames))] },
+	"last_name":  func(next func(n int) int) string { return fakeLastNames[next(len(fakeLastNames))] },
+	"name": func(next func(n int) int) string {
+		return fakeFirstNames[next(len(fakeFirstNames))] + " " + fakeLastNames[next(len(fakeLastNames))]
+	},
+	"email": func(next func(n int) int) string {
+		// The number keeps the addresses of different values apart.
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(fakeFirstNames[next(len(fakeFirstNames))]), strings.ToLower(fakeLastNames[next(len(fakeLastNames))]), next(100000))
+	},
+	"phone": func(next func(n int) int) string {
+		// 555-01xx numbers are reserved for fiction.
+		return fmt.Sprintf("+1-%03d-555-01%02d", 200+next(800), next(100))
+	},
+	"address": func(next func(n int) int) string {
+		return fmt.Sprintf("%d %s", 1+next(9999), fakeStreets[next(len(fakeStreets))])
+	},
+	"city":        func(next func(n int) int) string { return fakeCities[next(len(fakeCities))] },
+	"postal_code": func(next func(n int) int) string { return fmt.Sprintf("%05d", next(100000)) },
+	"company": func(next func(n int) int) string {
+		return fakeCompanies[next(len(fakeCompanies))] + " " + fakeSuffixes[next(len(fakeSuffixes))]
+	},
+}
+
+// fakeColumnKinds guess the kind of fake value from a column name, in order,
+// by the words the name ends with.
+var fakeColumnKinds = []struct {
+	Suffix string
+	Kind   string
+}{
+	{"email", "email"},
+	{"email_address", "email"},
+	{"phone", "phone"},
+	{"phone_number", "phone"},
+	{"mobile", "phone"},
+	{"first_name", "first_name"},
+	{"given_name", "first_name"},
+	{"last_name", "last_name"},
+	{"family_name", "last_name"},
+	{"surname", "last_name"},
+	{"company", "company"},
+	{"company_name", "company"},
+	{"organization", "company"},
+	{"address", "address"},
+	{"street", "address"},
+	{"city", "city"},
+	{"zip", "postal_code"},
+	{"zip_code", "postal_code"},
+	{"postal_code", "postal_code"},
+	{"postcode", "postal_code"},
+	{"name", "name"},
+}
+
+// fakeKind returns the kind of fake value for a column, as configured or
+// guessed from its name.
+func fakeKind(c Column, kind string) (string, error) {
+	if kind == "" {
+		name := strings.ToLower(c.Name)
+		for _, guess := range fakeColumnKinds {
+			if name == guess.Suffix || strings.HasSuffix(name, "_"+guess.Suffix) {
+				kind = guess.Kind
+				break
+			}
+		}
+		if kind == "" {
+			return "", errors.Errorf("Unable to guess the kind of fake value for column %s, set it as the mask's value", c.Name)
+		}
+	}
+	if _, ok := fakeKinds[kind]; !ok {
+		kinds := make([]string, 0, len(fakeKinds))
+		for name := range fakeKinds {
+			kinds = append(kinds, name)
+		}
+		sort.Strings(kinds)
+		return "", errors.Errorf("Unknown kind of fake value %q for column %s, expected one of %s", kind, c.Name, strings.Join(kinds, ", "))
+	}
+	if c.Type().Kind != KindString || c.Type().Array {
+		return "", errors.Errorf("Column %s is not a text column, so it can't hold fake values", c.Name)
+	}
+	return kind, nil
+}
+
+// fakeValue returns a fake value of a kind standing in for a value. It's
+// derived from the value, so a value is replaced the same way wherever it's
+// found, and cut to the column's length.
+func fakeValue(c Column, kind string, value interface{}) string {
+	sum := sha256.Sum256([]byte(kind + ":" + extractText(value)))
+	position := 0
+	next := func(n int) int {
+		number := binary.BigEndian.Uint32(sum[position:])
+		position = (position + 4) % (len(sum) - 3)
+		return int(number % uint32(n))
+	}
+	fake := fakeKinds[kind](next)
+	if c.MaxLength > 0 && len(fake) > c.MaxLength {
+		fake = fake[:c.MaxLength]
+	}
+	return fake
+}
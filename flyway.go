@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// flywayFilePattern matches Flyway versioned migration names, e.g.
+// V1__baseline.sql.
+var flywayFilePattern = regexp.MustCompile(`^V(\d+)__.+\.sql$`)
+
+// flywayMigrations returns the baseline migrations for the selected tables.
+// Without flyway_split_tables it's a single baseline; with it, one migration
+// creates the schemas and enum types, one per table creates it in dependency
+// order, and a last one adds the foreign keys that close reference cycles.
+// Flyway only runs undo migrations in its paid editions, so there are no down
+// scripts.
+func flywayMigrations(schemas []GenerationSchema, cfg GeneratorConfiguration) []Migration {
+	statements := ddlBuild(schemas)
+	if len(statements.Tables) == 0 {
+		return nil
+	}
+	if !cfg.FlywaySplitTables {
+		return []Migration{{Name: "baseline", Up: statements.Up()}}
+	}
+
+	migrations := []Migration{}
+	if statements.Types != "" {
+		migrations = append(migrations, Migration{Name: "create_types", Up: strings.TrimPrefix(statements.Types, "\n")})
+	}
+	for _, table := range statements.Tables {
+		name := table.Table.Name
+		if table.Table.Schema != "public" {
+			name = table.Table.Schema + "_" + name
+		}
+		migrations = append(migrations, Migration{Name: "create_" + name, Up: strings.TrimPrefix(table.SQL, "\n")})
+	}
+	if statements.ForeignKeys != "" {
+		migrations = append(migrations, Migration{Name: "add_foreign_keys", Up: strings.TrimPrefix(statements.ForeignKeys, "\n")})
+	}
+	return migrations
+}
+
+// writeFlywayMigrations writes the baseline from flywayMigrations into dir as
+// V<version>__<name>.sql files, numbered after the versions already there. It
+// returns the paths written.
+func writeFlywayMigrations(dir string, schemas []GenerationSchema, cfg GeneratorConfiguration) ([]string, error) {
+	migrations := flywayMigrations(schemas, cfg)
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+
+	version, _, err := nextMigrationVersion(dir, flywayFilePattern, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "Unable to create migrations directory")
+	}
+	paths := []string{}
+	for _, migration := range migrations {
+		path := filepath.Join(dir, fmt.Sprintf("V%d__%s.sql", version, migration.Name))
+		if err := os.WriteFile(path, []byte(migration.Up), 0644); err != nil {
+			return nil, errors.WithMessage(err, "Unable to write migration")
+		}
+		paths = append(paths, path)
+		version++
+	}
+	return paths, nil
+}
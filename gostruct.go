@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// goType describes how a column kind is represented in Go, both when the
+// column is NOT NULL and when it is nullable.
+type goType struct {
+	NotNull  string
+	Nullable string
+	Array    string
+}
+
+var goTypes = map[TypeKind]goType{
+	KindInt16:       {NotNull: "int16", Nullable: "pgtype.Int2", Array: "pgtype.Int2Array"},
+	KindInt32:       {NotNull: "int32", Nullable: "pgtype.Int4", Array: "pgtype.Int4Array"},
+	KindInt64:       {NotNull: "int64", Nullable: "pgtype.Int8", Array: "pgtype.Int8Array"},
+	KindFloat32:     {NotNull: "float32", Nullable: "pgtype.Float4", Array: "pgtype.Float4Array"},
+	KindFloat64:     {NotNull: "float64", Nullable: "pgtype.Float8", Array: "pgtype.Float8Array"},
+	KindNumeric:     {NotNull: "pgtype.Numeric", Nullable: "pgtype.Numeric", Array: "pgtype.NumericArray"},
+	KindBool:        {NotNull: "bool", Nullable: "pgtype.Bool", Array: "pgtype.BoolArray"},
+	KindString:      {NotNull: "string", Nullable: "pgtype.Text", Array: "pgtype.TextArray"},
+	KindUUID:        {NotNull: "uuid.UUID", Nullable: "pgtype.UUID", Array: "pgtype.UUIDArray"},
+	KindTimestamp:   {NotNull: "time.Time", Nullable: "pgtype.Timestamp", Array: "pgtype.TimestampArray"},
+	KindTimestamptz: {NotNull: "time.Time", Nullable: "pgtype.Timestamptz", Array: "pgtype.TimestamptzArray"},
+	KindDate:        {NotNull: "time.Time", Nullable: "pgtype.Date", Array: "pgtype.DateArray"},
+	KindTime:        {NotNull: "pgtype.Time", Nullable: "pgtype.Time", Array: "pgtype.GenericText"},
+	KindInterval:    {NotNull: "pgtype.Interval", Nullable: "pgtype.Interval", Array: "pgtype.GenericText"},
+	KindJSON:        {NotNull: "json.RawMessage", Nullable: "pgtype.JSONB", Array: "pgtype.JSONBArray"},
+	KindBytes:       {NotNull: "[]byte", Nullable: "[]byte", Array: "pgtype.ByteaArray"},
+	KindEnum:        {NotNull: "string", Nullable: "pgtype.Text", Array: "pgtype.TextArray"},
+	KindUnknown:     {NotNull: "pgtype.GenericText", Nullable: "pgtype.GenericText", Array: "pgtype.GenericText"},
+}
+
+// goPackageImports maps the package qualifiers used in goTypes to their import
+// paths.
+var goPackageImports = map[string]string{
+	"json":   "encoding/json",
+	"pgtype": "github.com/jackc/pgtype",
+	"time":   "time",
+	"uuid":   "github.com/google/uuid",
+}
+
+// GoType returns the Go type used for the column in generated structs. Enum
+// columns use the enum types generated alongside the structs.
+func (c Column) GoType() string {
+	typ := c.Type()
+	mapping := goTypes[typ.Kind]
+	switch {
+	case typ.Array:
+		return mapping.Array
+	case typ.Kind == KindEnum && c.Nullable:
+		return "Null" + c.EnumTypeName()
+	case typ.Kind == KindEnum:
+		return c.EnumTypeName()
+	case c.Nullable:
+		return mapping.Nullable
+	}
+	return mapping.NotNull
+}
+
+// GoField returns the Go field name for the column.
+func (c Column) GoField() string {
+	return exportedName(c.Name)
+}
+
+// goImports returns the sorted import paths needed by the structs for tables.
+func goImports(schemas []GenerationSchema) []string {
+	seen := map[string]bool{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for _, c := range table.Columns {
+				qualifier, _, ok := strings.Cut(c.GoType(), ".")
+				if !ok {
+					continue
+				}
+				seen[goPackageImports[qualifier]] = true
+			}
+		}
+	}
+	imports := make([]string, 0, len(seen))
+	for path := range seen {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// GoEnum is a Postgres enum rendered as a Go string type.
+type GoEnum struct {
+	Name      string
+	PGName    string
+	Constants []GoEnumConstant
+}
+
+// GoEnumConstant is the constant declared for one enum label.
+type GoEnumConstant struct {
+	Name  string
+	Value string
+}
+
+// goEnumConstantName prefixes the label with the enum type name. Labels that
+// don't make a usable identifier fall back to their position.
+func goEnumConstantName(enum string, label string, position int, seen map[string]bool) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, label)
+	name := enum + exportedName(cleaned)
+	if name == enum || seen[name] {
+		name = fmt.Sprintf("%sValue%d", enum, position)
+	}
+	seen[name] = true
+	return name
+}
+
+// goEnums returns the enum types used by the tables' columns, sorted by name.
+func goEnums(schemas []GenerationSchema) []GoEnum {
+	seen := map[string]bool{}
+	enums := []GoEnum{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for _, c := range table.Columns {
+				name := c.EnumTypeName()
+				if c.Type().Kind != KindEnum || seen[name] {
+					continue
+				}
+				seen[name] = true
+				enum := GoEnum{Name: name, PGName: strings.TrimPrefix(c.UDTName, "_")}
+				constants := map[string]bool{}
+				for i, value := range c.EnumValues {
+					enum.Constants = append(enum.Constants, GoEnumConstant{
+						Name:  goEnumConstantName(name, value, i, constants),
+						Value: value,
+					})
+				}
+				enums = append(enums, enum)
+			}
+		}
+	}
+	sort.Slice(enums, func(i, j int) bool {
+		return enums[i].Name < enums[j].Name
+	})
+	return enums
+}
+
+const goStructsTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+package {{ .Package }}
+{{- with .Imports }}
+
+import (
+{{- range . }}
+	"{{ . }}"
+{{- end }}
+)
+{{- end }}
+{{- range .Enums }}
+{{- $enum := . }}
+
+// {{ .Name }} is the Postgres enum {{ .PGName }}.
+type {{ .Name }} string
+
+const (
+{{- range .Constants }}
+	{{ .Name }} {{ $enum.Name }} = {{ printf "%q" .Value }}
+{{- end }}
+)
+
+// Valid reports whether e is one of the labels of {{ .PGName }}.
+func (e {{ .Name }}) Valid() bool {
+	switch e {
+	case {{ range $i, $c := .Constants }}{{ if $i }}, {{ end }}{{ $c.Name }}{{ end }}:
+		return true
+	}
+	return false
+}
+
+// Scan implements sql.Scanner.
+func (e *{{ .Name }}) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case string:
+		*e = {{ .Name }}(s)
+	case []byte:
+		*e = {{ .Name }}(s)
+	default:
+		return fmt.Errorf("unsupported scan type for {{ .Name }}: %T", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (e {{ .Name }}) Value() (driver.Value, error) {
+	return string(e), nil
+}
+
+// Null{{ .Name }} is a {{ .Name }} that may be NULL.
+type Null{{ .Name }} struct {
+	{{ .Name }} {{ .Name }}
+	Valid bool // Valid is true if {{ .Name }} is not NULL
+}
+
+// Scan implements sql.Scanner.
+func (n *Null{{ .Name }}) Scan(src interface{}) error {
+	if src == nil {
+		n.{{ .Name }}, n.Valid = "", false
+		return nil
+	}
+	n.Valid = true
+	return n.{{ .Name }}.Scan(src)
+}
+
+// Value implements driver.Valuer.
+func (n Null{{ .Name }}) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.{{ .Name }}.Value()
+}
+{{- end }}
+{{- range .Schemas }}
+{{- range .Tables }}
+
+// {{ .TypeName }} is a row of {{ .Schema }}.{{ .Name }}.
+type {{ .TypeName }} struct {
+{{- range .Columns }}
+	{{ .GoField }} {{ .GoType }} ` + "`" + `db:"{{ .Name }}" json:"{{ .Name }}"` + "`" + `
+	{{- with .Relation.Table }} // references {{ .Schema }}.{{ .Name }}{{ end }}
+	{{- with .Relation.Column }}({{ .Name }}){{ end }}
+{{- end }}
+}
+{{- end }}
+{{- end }}
+`
+
+// generateGoStructs writes a Go package with one struct per table, preceded by
+// a string type with sql.Scanner and driver.Valuer implementations for each
+// enum the tables use.
+func generateGoStructs(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	packageName := cfg.GoPackage
+	if packageName == "" {
+		packageName = "models"
+	}
+
+	tmpl, err := template.New("GoStructs").Parse(goStructsTemplate)
+	if err != nil {
+		return err
+	}
+
+	enums := goEnums(schemas)
+	imports := goImports(schemas)
+	if len(enums) > 0 {
+		imports = append(imports, "database/sql/driver", "fmt")
+		sort.Strings(imports)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = tmpl.Execute(buf, map[string]interface{}{
+		"Package": packageName,
+		"Imports": imports,
+		"Enums":   enums,
+		"Schemas": schemas,
+	})
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to format generated Go code:\n%s", buf.String()))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+	AccessNone  = "none"
+)
+
+// accessPrivileges are the table privileges granted for each access level.
+var accessPrivileges = map[string]string{
+	AccessRead:  "SELECT",
+	AccessWrite: "SELECT, INSERT, UPDATE, DELETE",
+	AccessNone:  "",
+}
+
+// grantSequencePattern extracts the sequence from a nextval() column default.
+var grantSequencePattern = regexp.MustCompile(`^nextval\('((?:[^']|'')+)'(?:::regclass)?\)$`)
+
+// grantRoles returns the roles with a default access level or a per-table
+// grant, sorted by name.
+func grantRoles(schemas []GenerationSchema, cfg GeneratorConfiguration) []string {
+	seen := map[string]bool{}
+	for role := range cfg.Roles {
+		seen[role] = true
+	}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for role := range table.Config.Grants {
+				seen[role] = true
+			}
+		}
+	}
+	roles := make([]string, 0, len(seen))
+	for role := range seen {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// grantAccess returns the role's access to the table: the table's grant if it
+// has one, otherwise the role's default, otherwise none.
+func grantAccess(table *GenerationTable, role string, cfg GeneratorConfiguration) (string, error) {
+	access, ok := table.Config.Grants[role]
+	if !ok {
+		access = cfg.Roles[role]
+	}
+	if access == "" {
+		access = AccessNone
+	}
+	if _, ok := accessPrivileges[access]; !ok {
+		return "", errors.Errorf("Unknown access %q for role %s on table %s.%s (expected %s, %s, or %s)", access, role, table.Schema, table.Name, AccessRead, AccessWrite, AccessNone)
+	}
+	return access, nil
+}
+
+// grantMigration grants each configured role its access to the selected
+// tables. Roles are given USAGE on each schema first, then every table's
+// privileges are revoked and regranted in dependency order, so the result
+// matches the config whatever was granted before. Roles with write access
+// can also use the sequences behind the tables' serial columns.
+func grantMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	roles := grantRoles(schemas, cfg)
+	if len(roles) == 0 {
+		return Migration{Name: "grants"}, nil
+	}
+	tables := ddlOrderedTables(schemas)
+
+	up := strings.Builder{}
+	down := strings.Builder{}
+	for _, role := range roles {
+		grantee := ddlIdent(role)
+		fmt.Fprintf(&up, "\n-- %s\n", role)
+		for _, schema := range schemas {
+			if len(schema.Tables) > 0 {
+				fmt.Fprintf(&up, "GRANT USAGE ON SCHEMA %s TO %s;\n", ddlIdent(schema.Name), grantee)
+			}
+		}
+		for _, table := range tables {
+			access, err := grantAccess(table, role, cfg)
+			if err != nil {
+				return Migration{}, err
+			}
+			name := ddlTableName(&table.Table)
+			fmt.Fprintf(&up, "REVOKE ALL ON %s FROM %s;\n", name, grantee)
+			if access == AccessNone {
+				continue
+			}
+			fmt.Fprintf(&up, "GRANT %s ON %s TO %s;\n", accessPrivileges[access], name, grantee)
+			fmt.Fprintf(&down, "REVOKE ALL ON %s FROM %s;\n", name, grantee)
+			if access != AccessWrite {
+				continue
+			}
+			for _, c := range table.Columns {
+				match := grantSequencePattern.FindStringSubmatch(c.Default)
+				if match == nil {
+					continue
+				}
+				// The sequence is already written as SQL identifiers.
+				sequence := strings.ReplaceAll(match[1], "''", "'")
+				fmt.Fprintf(&up, "GRANT USAGE, SELECT ON SEQUENCE %s TO %s;\n", sequence, grantee)
+				fmt.Fprintf(&down, "REVOKE ALL ON SEQUENCE %s FROM %s;\n", sequence, grantee)
+			}
+		}
+		for _, schema := range schemas {
+			if len(schema.Tables) > 0 {
+				fmt.Fprintf(&down, "REVOKE USAGE ON SCHEMA %s FROM %s;\n", ddlIdent(schema.Name), grantee)
+			}
+		}
+	}
+	return Migration{Name: "grants", Up: strings.TrimPrefix(up.String(), "\n"), Down: down.String()}, nil
+}
+
+// generateGrants writes the GRANT and REVOKE statements from grantMigration.
+func generateGrants(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(grantRoles(schemas, cfg)) == 0 {
+		return errors.New("No roles are configured")
+	}
+	return writeMigrationUp(w, grantMigration, schemas, cfg)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+)
+
+// graphqlScalars maps column kinds to GraphQL scalars. Types that aren't
+// built into GraphQL are declared as custom scalars in the generated schema.
+var graphqlScalars = map[TypeKind]string{
+	KindInt16:       "Int",
+	KindInt32:       "Int",
+	KindInt64:       "BigInt",
+	KindFloat32:     "Float",
+	KindFloat64:     "Float",
+	KindNumeric:     "String",
+	KindBool:        "Boolean",
+	KindString:      "String",
+	KindUUID:        "UUID",
+	KindTimestamp:   "DateTime",
+	KindTimestamptz: "DateTime",
+	KindDate:        "Date",
+	KindTime:        "String",
+	KindInterval:    "String",
+	KindJSON:        "JSON",
+	KindBytes:       "String",
+	KindEnum:        "String",
+	KindUnknown:     "String",
+}
+
+var graphqlBuiltinScalars = map[string]bool{
+	"ID":      true,
+	"Int":     true,
+	"Float":   true,
+	"String":  true,
+	"Boolean": true,
+}
+
+// GraphQLType returns the GraphQL type for the column, marking NOT NULL
+// columns as non-null.
+func (c Column) GraphQLType() string {
+	typ := c.Type()
+	name := graphqlScalars[typ.Kind]
+	if typ.Array {
+		name = "[" + name + "!]"
+	}
+	if !c.Nullable {
+		name += "!"
+	}
+	return name
+}
+
+// GraphQLField is a single field of a generated GraphQL type.
+type GraphQLField struct {
+	Name    string
+	Type    string
+	Comment string
+}
+
+// GraphQLObject is a table rendered as a GraphQL object type along with its
+// get/list query fields.
+type GraphQLObject struct {
+	Name      string
+	Table     GenerationTable
+	Fields    []GraphQLField
+	GetField  string
+	GetArg    GraphQLField
+	ListField string
+}
+
+func graphqlObjects(schemas []GenerationSchema) []GraphQLObject {
+	typeNames := map[string]string{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			typeNames[table.Schema+"."+table.Name] = table.TypeName()
+		}
+	}
+
+	objects := []GraphQLObject{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			object := GraphQLObject{
+				Name:      table.TypeName(),
+				Table:     table,
+				ListField: strcase.ToLowerCamel(pluralize(table.TypeName())),
+			}
+			primaryKey := table.PrimaryKeyColumn()
+			for _, c := range table.Columns {
+				field := GraphQLField{Name: strcase.ToLowerCamel(c.Name), Type: c.GraphQLType()}
+				if c.Name == primaryKey.Name && !c.Type().Array {
+					field.Type = "ID!"
+				}
+				object.Fields = append(object.Fields, field)
+
+				if c.Relation.Table == nil {
+					continue
+				}
+				// Only link to types that are part of the generated schema.
+				related, ok := typeNames[c.Relation.Table.Schema+"."+c.Relation.Table.Name]
+				if !ok {
+					continue
+				}
+				relation := GraphQLField{
+					Name:    strcase.ToLowerCamel(c.RelationName()),
+					Type:    related,
+					Comment: "references " + c.Relation.Table.Schema + "." + c.Relation.Table.Name,
+				}
+				if c.Relation.Column != nil {
+					relation.Comment += "(" + c.Relation.Column.Name + ")"
+				}
+				if !c.Nullable {
+					relation.Type += "!"
+				}
+				object.Fields = append(object.Fields, relation)
+			}
+			if table.HasColumn(primaryKey.Name) {
+				object.GetField = strcase.ToLowerCamel(table.TypeName())
+				object.GetArg = GraphQLField{Name: strcase.ToLowerCamel(primaryKey.Name), Type: "ID!"}
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// graphqlCustomScalars returns the sorted non-builtin scalars used by objects.
+func graphqlCustomScalars(objects []GraphQLObject) []string {
+	objectNames := map[string]bool{}
+	for _, object := range objects {
+		objectNames[object.Name] = true
+	}
+	seen := map[string]bool{}
+	for _, object := range objects {
+		for _, field := range object.Fields {
+			name := strings.Trim(field.Type, "[]!")
+			if !graphqlBuiltinScalars[name] && !objectNames[name] {
+				seen[name] = true
+			}
+		}
+	}
+	scalars := make([]string, 0, len(seen))
+	for name := range seen {
+		scalars = append(scalars, name)
+	}
+	sort.Strings(scalars)
+	return scalars
+}
+
+const graphqlTemplate = `# Code generated by pginspector. DO NOT EDIT.
+{{- with .Scalars }}
+{{ range . }}
+scalar {{ . }}
+{{- end }}
+{{- end }}
+{{- range .Objects }}
+
+# {{ .Name }} is a row of {{ .Table.Schema }}.{{ .Table.Name }}.
+type {{ .Name }} {
+{{- range .Fields }}
+  {{ .Name }}: {{ .Type }}{{ with .Comment }} # {{ . }}{{ end }}
+{{- end }}
+}
+{{- end }}
+
+type Query {
+{{- range .Objects }}
+{{- if .GetField }}
+  {{ .GetField }}({{ .GetArg.Name }}: {{ .GetArg.Type }}): {{ .Name }}
+{{- end }}
+  {{ .ListField }}: [{{ .Name }}!]!
+{{- end }}
+}
+`
+
+// generateGraphQL writes a read-only GraphQL schema with one object type per
+// table and get/list fields on Query.
+func generateGraphQL(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	objects := graphqlObjects(schemas)
+
+	tmpl, err := template.New("GraphQL").Parse(graphqlTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"Scalars": graphqlCustomScalars(objects),
+		"Objects": objects,
+	})
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+)
+
+// handlerKeyParsers are the bodies of the functions parsing a path value into
+// a primary key, by key type. Tables with other key types only get a list
+// route.
+var handlerKeyParsers = map[string]string{
+	"string":    "return s, nil",
+	"int16":     "v, err := strconv.ParseInt(s, 10, 16)\n\treturn int16(v), err",
+	"int32":     "v, err := strconv.ParseInt(s, 10, 32)\n\treturn int32(v), err",
+	"int64":     "return strconv.ParseInt(s, 10, 64)",
+	"uuid.UUID": "return uuid.Parse(s)",
+}
+
+// HandlerTable is a table rendered as an HTTP handler over its repository.
+type HandlerTable struct {
+	RepositoryTable
+	Path string
+	// KeyParser is the body of the function parsing the key path value. It's
+	// empty when the table has no key routes.
+	KeyParser string
+	// PatchFields are the columns a PATCH request may set.
+	PatchFields []Column
+}
+
+func handlerTables(schemas []GenerationSchema) []HandlerTable {
+	tables := []HandlerTable{}
+	for _, repo := range repositoryTables(schemas) {
+		table := HandlerTable{
+			RepositoryTable: repo,
+			Path:            "/" + strcase.ToKebab(repo.Table.Name),
+		}
+		if repo.HasKey {
+			table.KeyParser = handlerKeyParsers[repo.KeyType]
+		}
+		if repo.UpdateSQL != "" {
+			table.PatchFields = repo.Table.UpdateColumns()
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// handlerImports returns the imports needed by the handler file.
+func handlerImports(tables []HandlerTable) []string {
+	seen := map[string]bool{
+		"encoding/json":           true,
+		"errors":                  true,
+		"net/http":                true,
+		"strconv":                 true,
+		"github.com/jackc/pgx/v4": true,
+	}
+	for _, table := range tables {
+		if qualifier, _, ok := strings.Cut(table.KeyType, "."); ok && table.KeyParser != "" {
+			seen[goPackageImports[qualifier]] = true
+		}
+	}
+	imports := make([]string, 0, len(seen))
+	for path := range seen {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+const handlerTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+// HTTP handlers for the repositories generated by the repository target.
+// Generate them into the same package as the repositories and the go target's
+// structs. Routes use the method and wildcard patterns of net/http (Go 1.22).
+
+package {{ .Package }}
+
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+)
+
+const (
+	// defaultPageSize is the number of rows listed when no limit is given.
+	defaultPageSize = 50
+	// maxPageSize caps the limit of list requests.
+	maxPageSize = 1000
+)
+
+// listResponse is a page of rows. NextOffset is set when there may be more.
+type listResponse struct {
+	Items      interface{} ` + "`" + `json:"items"` + "`" + `
+	NextOffset *int        ` + "`" + `json:"next_offset,omitempty"` + "`" + `
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeRepositoryError reports missing rows as 404s and hides other errors
+// behind a 500.
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// pageParams reads the limit and offset query parameters.
+func pageParams(r *http.Request) (int, int, error) {
+	limit, offset := defaultPageSize, 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		limit = parsed
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+{{- range .Tables }}
+
+// {{ .TypeName }}Handler serves {{ .Table.Schema }}.{{ .Table.Name }} rows over HTTP.
+type {{ .TypeName }}Handler struct {
+	Repo {{ .TypeName }}Repository
+}
+
+// New{{ .TypeName }}Handler creates a {{ .TypeName }}Handler backed by repo.
+func New{{ .TypeName }}Handler(repo {{ .TypeName }}Repository) *{{ .TypeName }}Handler {
+	return &{{ .TypeName }}Handler{Repo: repo}
+}
+
+// Register adds the handler's routes to mux under {{ .Path }}.
+func (h *{{ .TypeName }}Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET {{ .Path }}", h.List)
+{{- if .KeyParser }}
+	mux.HandleFunc("GET {{ .Path }}/{{ "{" }}{{ .Table.Config.PrimaryKey }}{{ "}" }}", h.Get)
+{{- if .PatchFields }}
+	mux.HandleFunc("PATCH {{ .Path }}/{{ "{" }}{{ .Table.Config.PrimaryKey }}{{ "}" }}", h.Patch)
+{{- end }}
+{{- if .DeleteSQL }}
+	mux.HandleFunc("DELETE {{ .Path }}/{{ "{" }}{{ .Table.Config.PrimaryKey }}{{ "}" }}", h.Delete)
+{{- end }}
+{{- end }}
+}
+
+// List serves a page of rows, selected with the limit and offset query
+// parameters.
+func (h *{{ .TypeName }}Handler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	items, err := h.Repo.ListPage(r.Context(), limit, offset)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	response := listResponse{Items: items}
+	if len(items) == limit {
+		next := offset + limit
+		response.NextOffset = &next
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+{{- if .KeyParser }}
+
+func parse{{ .TypeName }}Key(s string) ({{ .KeyType }}, error) {
+	{{ .KeyParser }}
+}
+
+// Get serves the row with the key in the path.
+func (h *{{ .TypeName }}Handler) Get(w http.ResponseWriter, r *http.Request) {
+	{{ .KeyParam }}, err := parse{{ .TypeName }}Key(r.PathValue("{{ .Table.Config.PrimaryKey }}"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid {{ .Table.Config.PrimaryKey }}")
+		return
+	}
+	item, err := h.Repo.Get(r.Context(), {{ .KeyParam }})
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+{{- if .PatchFields }}
+
+// Patch updates the row with the key in the path. The fields in the JSON
+// request body are the field mask: only they're changed.
+func (h *{{ .TypeName }}Handler) Patch(w http.ResponseWriter, r *http.Request) {
+	{{ .KeyParam }}, err := parse{{ .TypeName }}Key(r.PathValue("{{ .Table.Config.PrimaryKey }}"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid {{ .Table.Config.PrimaryKey }}")
+		return
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
+	item, err := h.Repo.Get(r.Context(), {{ .KeyParam }})
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	for name, value := range fields {
+		switch name {
+{{- range .PatchFields }}
+		case "{{ .Name }}":
+			err = json.Unmarshal(value, &item.{{ .GoField }})
+{{- end }}
+		default:
+			writeError(w, http.StatusBadRequest, "field "+strconv.Quote(name)+" can't be updated")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid value for field "+strconv.Quote(name))
+			return
+		}
+	}
+	item, err = h.Repo.Update(r.Context(), item)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+{{- end }}
+{{- if .DeleteSQL }}
+
+// Delete deletes the row with the key in the path.
+func (h *{{ .TypeName }}Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	{{ .KeyParam }}, err := parse{{ .TypeName }}Key(r.PathValue("{{ .Table.Config.PrimaryKey }}"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid {{ .Table.Config.PrimaryKey }}")
+		return
+	}
+	if err := h.Repo.Delete(r.Context(), {{ .KeyParam }}); err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+// generateHandlers writes net/http handlers per table serving the rows of the
+// repositories from the repository target: list with pagination, get by key,
+// PATCH with the request body as the field mask, and delete.
+func generateHandlers(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	packageName := cfg.GoPackage
+	if packageName == "" {
+		packageName = "models"
+	}
+	tables := handlerTables(schemas)
+
+	tmpl, err := template.New("Handlers").Parse(handlerTemplate)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer([]byte{})
+	err = tmpl.Execute(buf, map[string]interface{}{
+		"Package": packageName,
+		"Imports": handlerImports(tables),
+		"Tables":  tables,
+	})
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to format generated Go code:\n%s", buf.String()))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
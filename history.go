@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const historyTriggerName = "record_history"
+
+// historyTables returns the tables with generate_history set.
+func historyTables(schemas []GenerationSchema) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Config.GenerateHistory {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+	}
+	return tables
+}
+
+// historyTableName returns the name of the table's history table.
+func historyTableName(t *Table) string {
+	return ddlIdent(t.Schema) + "." + ddlIdent(t.Name+"_history")
+}
+
+// historyFunctionName returns the name of the trigger function recording the
+// table's history.
+func historyFunctionName(t *Table) string {
+	return ddlIdent(t.Schema) + "." + ddlIdent(t.Name+"_record_history")
+}
+
+// historyMigration creates a <table>_history table for each table with
+// generate_history set, and a trigger copying the old row into it on UPDATE
+// and DELETE. History tables mirror the table's columns without defaults or
+// constraints, plus valid_from and valid_to (the period the old row was
+// current) and the operation that replaced it. valid_from is the valid_to of
+// the previous version with the same primary key, or NULL for the first
+// recorded version.
+func historyMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	tables := historyTables(schemas)
+	if len(tables) == 0 {
+		return Migration{Name: "history"}, nil
+	}
+
+	up := strings.Builder{}
+	down := strings.Builder{}
+	for _, table := range tables {
+		name := ddlTableName(&table.Table)
+		history := historyTableName(&table.Table)
+		function := historyFunctionName(&table.Table)
+
+		columns := make([]Column, len(table.Columns))
+		copy(columns, table.Columns)
+		sort.SliceStable(columns, func(i, j int) bool {
+			return columns[i].Position < columns[j].Position
+		})
+		lines := []string{}
+		names := []string{}
+		values := []string{}
+		for _, c := range columns {
+			line := ddlIdent(c.Name) + " " + ddlType(c)
+			if !c.Nullable {
+				line += " NOT NULL"
+			}
+			lines = append(lines, line)
+			names = append(names, ddlIdent(c.Name))
+			values = append(values, "OLD."+ddlIdent(c.Name))
+		}
+		lines = append(lines,
+			"valid_from timestamp with time zone",
+			"valid_to timestamp with time zone NOT NULL DEFAULT now()",
+			"operation text NOT NULL",
+		)
+		names = append(names, "valid_from", "valid_to", "operation")
+
+		validFrom := "NULL"
+		if table.HasColumn(table.Config.PrimaryKey) {
+			pk := ddlIdent(table.Config.PrimaryKey)
+			validFrom = fmt.Sprintf("(SELECT max(h.valid_to) FROM %s h WHERE h.%s = OLD.%s)", history, pk, pk)
+		}
+		values = append(values, validFrom, "now()", "TG_OP")
+
+		fmt.Fprintf(&up, "\nCREATE TABLE IF NOT EXISTS %s (\n    %s\n);\n", history, strings.Join(lines, ",\n    "))
+		if table.HasColumn(table.Config.PrimaryKey) {
+			pk := ddlIdent(table.Config.PrimaryKey)
+			fmt.Fprintf(&up, "CREATE INDEX IF NOT EXISTS %s ON %s (%s, valid_to);\n", ddlIdent(table.Name+"_history_"+table.Config.PrimaryKey+"_idx"), history, pk)
+		}
+		fmt.Fprintf(&up, `
+CREATE OR REPLACE FUNCTION %s() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO %s (%s)
+    VALUES (%s);
+    RETURN NULL;
+END;
+$$;
+`, function, history, strings.Join(names, ", "), strings.Join(values, ", "))
+		fmt.Fprintf(&up, "\nDROP TRIGGER IF EXISTS %s ON %s;\n", historyTriggerName, name)
+		fmt.Fprintf(&up, "CREATE TRIGGER %s\n    AFTER UPDATE OR DELETE ON %s\n    FOR EACH ROW EXECUTE FUNCTION %s();\n", historyTriggerName, name, function)
+
+		fmt.Fprintf(&down, "DROP TRIGGER IF EXISTS %s ON %s;\n", historyTriggerName, name)
+		fmt.Fprintf(&down, "DROP FUNCTION IF EXISTS %s();\n", function)
+		fmt.Fprintf(&down, "DROP TABLE IF EXISTS %s;\n", history)
+	}
+	return Migration{Name: "history", Up: strings.TrimPrefix(up.String(), "\n"), Down: down.String()}, nil
+}
+
+// generateHistory writes the history tables, trigger functions, and triggers
+// from historyMigration.
+func generateHistory(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(historyTables(schemas)) == 0 {
+		return errors.New("No tables have generate_history set")
+	}
+	return writeMigrationUp(w, historyMigration, schemas, cfg)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// configSources maps the nodes of a config to the files they're read from,
+// so problems are reported in the file that has them.
+type configSources map[*yaml.Node]string
+
+// readConfigDocument parses a config, expanding environment variables, and
+// merges in the files it includes, returning its top level mapping node.
+// Files listed in include (paths or globs, relative to the including file,
+// or the working directory for configs without a path) are merged in order,
+// each overriding the ones before, and the including file overrides them
+// all: mappings are merged key by key, and other values, including lists,
+// are replaced.
+func readConfigDocument(path string, source []byte, sources configSources) (*yaml.Node, error) {
+	return readConfigIncluding(path, source, sources, nil)
+}
+
+// readConfigIncluding reads a config that the files in including include.
+func readConfigIncluding(path string, source []byte, sources configSources, including []string) (*yaml.Node, error) {
+	name := path
+	if name == "" {
+		name = "config"
+	}
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, errors.WithMessagef(err, "Unable to parse %s", name)
+	}
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		document = root.Content[0]
+	}
+	if document.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("Unable to parse %s: expected a mapping", name)
+	}
+	if err := expandConfigNode(name, document); err != nil {
+		return nil, err
+	}
+	configSourceNodes(sources, path, document)
+
+	includeKey, includeNode := configNode(document, "include")
+	if includeNode == nil {
+		return document, nil
+	}
+	patterns := []*yaml.Node{includeNode}
+	if includeNode.Kind == yaml.SequenceNode {
+		patterns = includeNode.Content
+	}
+	merged := &yaml.Node{Kind: yaml.MappingNode}
+	for _, pattern := range patterns {
+		if pattern.Kind != yaml.ScalarNode || pattern.Value == "" {
+			return nil, errors.Errorf("Invalid include at %s line %d, expected a path or a list of paths", name, pattern.Line)
+		}
+		includePath := pattern.Value
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), includePath)
+		}
+		includePaths := []string{includePath}
+		if strings.ContainsAny(includePath, "*?[") {
+			matches, err := filepath.Glob(includePath)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "Invalid include pattern at %s line %d", name, pattern.Line)
+			}
+			if len(matches) == 0 {
+				return nil, errors.Errorf("Include %s at %s line %d matches no files", pattern.Value, name, pattern.Line)
+			}
+			includePaths = matches
+		}
+		for _, includePath := range includePaths {
+			for _, p := range append(including, path) {
+				if filepath.Clean(p) == filepath.Clean(includePath) {
+					return nil, errors.Errorf("Config %s is included again by %s", includePath, name)
+				}
+			}
+			includeSource, err := os.ReadFile(includePath)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "Unable to read include at %s line %d", name, pattern.Line)
+			}
+			included, err := readConfigIncluding(includePath, includeSource, sources, append(including, path))
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeConfigNodes(merged, included)
+		}
+	}
+
+	own := &yaml.Node{Kind: yaml.MappingNode, Line: document.Line, Column: document.Column}
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		if document.Content[i] != includeKey {
+			own.Content = append(own.Content, document.Content[i], document.Content[i+1])
+		}
+	}
+	return mergeConfigNodes(merged, own), nil
+}
+
+// mergeConfigNodes merges override into base, key by key where both are
+// mappings, returning the merged node. Other values are replaced.
+func mergeConfigNodes(base *yaml.Node, override *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		merged := false
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value == key.Value {
+				base.Content[j], base.Content[j+1] = key, mergeConfigNodes(base.Content[j+1], value)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+	return base
+}
+
+// configSourceNodes records the file of a node and the nodes under it.
+func configSourceNodes(sources configSources, path string, node *yaml.Node) {
+	if sources == nil || node == nil {
+		return
+	}
+	sources[node] = path
+	for _, child := range node.Content {
+		configSourceNodes(sources, path, child)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// InspectModel is the inspected schemas as inspect -format json writes them,
+// for other tooling. Unlike a schema snapshot, its fields are named for
+// readers rather than by the Go types, and everything is sorted: schemas,
+// tables, indexes, constraints, and triggers by name, and columns by
+// position. Optional fields are left out when they're empty.
+type InspectModel struct {
+	Schemas []InspectSchema `json:"schemas"`
+}
+
+type InspectSchema struct {
+	Name   string         `json:"name"`
+	Tables []InspectTable `json:"tables"`
+}
+
+type InspectTable struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+	// PrimaryKey are the columns of the primary key, if there is one.
+	PrimaryKey   []string             `json:"primary_key,omitempty"`
+	Columns      []InspectColumn      `json:"columns"`
+	ForeignKeys  []InspectForeignKey  `json:"foreign_keys,omitempty"`
+	Indexes      []InspectIndex       `json:"indexes,omitempty"`
+	Constraints  []InspectConstraint  `json:"constraints,omitempty"`
+	Triggers     []InspectTrigger     `json:"triggers,omitempty"`
+	Partitioning *InspectPartitioning `json:"partitioning,omitempty"`
+}
+
+type InspectColumn struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	// Type is the data_type of information_schema, e.g. ARRAY or
+	// USER-DEFINED, and SQLType the type to cast to, e.g. text[] or an enum.
+	Type                 string   `json:"type"`
+	SQLType              string   `json:"sql_type"`
+	UDTName              string   `json:"udt_name"`
+	UDTSchema            string   `json:"udt_schema,omitempty"`
+	Nullable             bool     `json:"nullable"`
+	Default              string   `json:"default,omitempty"`
+	Identity             string   `json:"identity,omitempty"`
+	Generated            bool     `json:"generated,omitempty"`
+	GenerationExpression string   `json:"generation_expression,omitempty"`
+	MaxLength            int      `json:"max_length,omitempty"`
+	NumericPrecision     int      `json:"numeric_precision,omitempty"`
+	NumericScale         int      `json:"numeric_scale,omitempty"`
+	EnumValues           []string `json:"enum_values,omitempty"`
+	Comment              string   `json:"comment,omitempty"`
+}
+
+type InspectForeignKey struct {
+	Name              string   `json:"name,omitempty"`
+	Columns           []string `json:"columns"`
+	ReferencedSchema  string   `json:"referenced_schema"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+type InspectIndex struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	Unique     bool     `json:"unique"`
+	Primary    bool     `json:"primary"`
+	Definition string   `json:"definition"`
+}
+
+type InspectConstraint struct {
+	Name string `json:"name"`
+	// Type is check, foreign_key, primary_key, unique, or exclusion.
+	Type       string `json:"type"`
+	Definition string `json:"definition"`
+}
+
+type InspectTrigger struct {
+	Name       string `json:"name"`
+	Function   string `json:"function"`
+	Definition string `json:"definition"`
+}
+
+type InspectPartitioning struct {
+	// Strategy is hash, list, or range.
+	Strategy string   `json:"strategy"`
+	Columns  []string `json:"columns"`
+}
+
+// inspectConstraintNames name the constraint types of pg_constraint.
+var inspectConstraintNames = map[string]string{
+	ConstraintCheck:      "check",
+	ConstraintForeignKey: "foreign_key",
+	ConstraintPrimaryKey: "primary_key",
+	ConstraintUnique:     "unique",
+	ConstraintExclusion:  "exclusion",
+}
+
+// inspectPartitionNames name the strategies of pg_partitioned_table.
+var inspectPartitionNames = map[string]string{
+	PartitionHash:  "hash",
+	PartitionList:  "list",
+	PartitionRange: "range",
+}
+
+// newInspectModel returns the model of inspected schemas.
+func newInspectModel(schemas map[string]Schema) InspectModel {
+	model := InspectModel{Schemas: []InspectSchema{}}
+	schemaNames := make([]string, 0, len(schemas))
+	for schemaName := range schemas {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	for _, schemaName := range schemaNames {
+		schema := schemas[schemaName]
+		tableNames := make([]string, 0, len(schema.Tables))
+		for tableName := range schema.Tables {
+			tableNames = append(tableNames, tableName)
+		}
+		sort.Strings(tableNames)
+		inspected := InspectSchema{Name: schemaName, Tables: make([]InspectTable, 0, len(tableNames))}
+		for _, tableName := range tableNames {
+			inspected.Tables = append(inspected.Tables, newInspectTable(schema.Tables[tableName]))
+		}
+		model.Schemas = append(model.Schemas, inspected)
+	}
+	return model
+}
+
+func newInspectTable(table Table) InspectTable {
+	inspected := InspectTable{Name: table.Name, Comment: table.Comment, Columns: []InspectColumn{}}
+
+	columns := append([]Column{}, table.Columns...)
+	sort.SliceStable(columns, func(i, j int) bool {
+		return columns[i].Position < columns[j].Position
+	})
+	foreignKeys := map[string]int{}
+	for _, c := range columns {
+		inspected.Columns = append(inspected.Columns, InspectColumn{
+			Name:                 c.Name,
+			Position:             c.Position,
+			Type:                 c.PGType,
+			SQLType:              c.SQLType(),
+			UDTName:              c.UDTName,
+			UDTSchema:            c.UDTSchema,
+			Nullable:             c.Nullable,
+			Default:              c.Default,
+			Identity:             c.Identity,
+			Generated:            c.Generated,
+			GenerationExpression: c.GenerationExpression,
+			MaxLength:            c.MaxLength,
+			NumericPrecision:     c.NumericPrecision,
+			NumericScale:         c.NumericScale,
+			EnumValues:           c.EnumValues,
+			Comment:              c.Comment,
+		})
+		if c.Relation.Table == nil || c.Relation.Column == nil {
+			continue
+		}
+		// The columns of a composite foreign key share its constraint name.
+		i, ok := foreignKeys[c.Relation.Constraint]
+		if !ok || c.Relation.Constraint == "" {
+			i = len(inspected.ForeignKeys)
+			foreignKeys[c.Relation.Constraint] = i
+			inspected.ForeignKeys = append(inspected.ForeignKeys, InspectForeignKey{
+				Name:             c.Relation.Constraint,
+				ReferencedSchema: c.Relation.Table.Schema,
+				ReferencedTable:  c.Relation.Table.Name,
+			})
+		}
+		inspected.ForeignKeys[i].Columns = append(inspected.ForeignKeys[i].Columns, c.Name)
+		inspected.ForeignKeys[i].ReferencedColumns = append(inspected.ForeignKeys[i].ReferencedColumns, c.Relation.Column.Name)
+	}
+	sort.SliceStable(inspected.ForeignKeys, func(i, j int) bool {
+		return inspected.ForeignKeys[i].Name < inspected.ForeignKeys[j].Name
+	})
+
+	for _, index := range table.Indexes {
+		if index.Primary {
+			inspected.PrimaryKey = index.Columns
+		}
+		inspected.Indexes = append(inspected.Indexes, InspectIndex(index))
+	}
+	sort.SliceStable(inspected.Indexes, func(i, j int) bool {
+		return inspected.Indexes[i].Name < inspected.Indexes[j].Name
+	})
+	for _, constraint := range table.Constraints {
+		constraintType, ok := inspectConstraintNames[constraint.Type]
+		if !ok {
+			constraintType = constraint.Type
+		}
+		inspected.Constraints = append(inspected.Constraints, InspectConstraint{Name: constraint.Name, Type: constraintType, Definition: constraint.Definition})
+	}
+	sort.SliceStable(inspected.Constraints, func(i, j int) bool {
+		return inspected.Constraints[i].Name < inspected.Constraints[j].Name
+	})
+	for _, trigger := range table.Triggers {
+		inspected.Triggers = append(inspected.Triggers, InspectTrigger{Name: trigger.Name, Function: trigger.Function, Definition: trigger.Definition})
+	}
+	sort.SliceStable(inspected.Triggers, func(i, j int) bool {
+		return inspected.Triggers[i].Name < inspected.Triggers[j].Name
+	})
+	if table.Partitioning != nil {
+		strategy, ok := inspectPartitionNames[table.Partitioning.Strategy]
+		if !ok {
+			strategy = table.Partitioning.Strategy
+		}
+		inspected.Partitioning = &InspectPartitioning{Strategy: strategy, Columns: table.Partitioning.Columns}
+	}
+	return inspected
+}
+
+// writeInspectJSON writes the model of inspected schemas as indented JSON.
+func writeInspectJSON(w io.Writer, schemas map[string]Schema) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newInspectModel(schemas))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// extractDanglingActions are what can be done about foreign keys referencing
+// rows left out of an extraction.
+var extractDanglingActions = map[string]bool{
+	"report": true,
+	"fetch":  true,
+	"error":  true,
+}
+
+// reference records the values of the foreign keys of an extracted row, both
+// those it references and those it's referenced by, whether or not they're
+// followed, so references to rows left out can be found.
+func (e *Extraction) reference(fks []extractForeignKey, table *GenerationTable, values map[string]interface{}) {
+	for _, fk := range fks {
+		if key, ok := extractKeyValues(fk.Columns, values); ok && fk.From == table {
+			extractRecord(e.references, fk.Name, extractKeyText(key))
+		}
+		if key, ok := extractKeyValues(fk.Referenced, values); ok && fk.To == table {
+			extractRecord(e.referenced, fk.Name, extractKeyText(key))
+		}
+	}
+}
+
+// extractKeyText returns the text of a key: the text of its value, or a
+// JSON array of the text of its values for a composite key.
+func extractKeyText(key []interface{}) string {
+	if len(key) == 1 {
+		return extractText(key[0])
+	}
+	texts := make([]string, 0, len(key))
+	for _, value := range key {
+		texts = append(texts, extractText(value))
+	}
+	encoded, _ := json.Marshal(texts)
+	return string(encoded)
+}
+
+// extractRecord adds a value to the set of a relation.
+func extractRecord(sets map[string]map[string]bool, relation string, value string) {
+	if sets[relation] == nil {
+		sets[relation] = map[string]bool{}
+	}
+	sets[relation][value] = true
+}
+
+// missing returns the lookups of the rows referenced by extracted rows but
+// not extracted, leaving out those of skipped tables, which can't be.
+func (e *Extraction) missing(fks []extractForeignKey, skipped map[*GenerationTable]bool) []extractLookup {
+	lookups := []extractLookup{}
+	for _, fk := range fks {
+		if skipped[fk.To] {
+			continue
+		}
+		for _, value := range extractCheckpointKeys(e.references[fk.Name]) {
+			if e.referenced[fk.Name][value] {
+				continue
+			}
+			key := []interface{}{value}
+			if len(fk.Columns) > 1 {
+				texts := []string{}
+				if err := json.Unmarshal([]byte(value), &texts); err != nil || len(texts) != len(fk.Columns) {
+					continue
+				}
+				key = key[:0]
+				for _, text := range texts {
+					key = append(key, text)
+				}
+			}
+			lookup := extractKeyLookup(fk.To, fk.Referenced, key, true)
+			lookup.Required, lookup.Relation = true, fk.Name
+			lookups = append(lookups, lookup)
+		}
+	}
+	return lookups
+}
+
+// dangle records the foreign keys referencing rows left out of the
+// extraction, with how many rows are missing, which make the rows fail to
+// load unless their constraints are left out too.
+func (e *Extraction) dangle(fks []extractForeignKey) {
+	e.Dangling = nil
+	for _, fk := range fks {
+		missing := 0
+		for value := range e.references[fk.Name] {
+			if !e.referenced[fk.Name][value] {
+				missing++
+			}
+		}
+		if missing > 0 {
+			e.Dangling = append(e.Dangling, fmt.Sprintf("%s (%d missing)", fk.Name, missing))
+		}
+	}
+	sort.Strings(e.Dangling)
+}
+
+// checkIntegrity finds the foreign keys referencing rows left out of the
+// extraction, failing if the config says to.
+func (e *Extraction) checkIntegrity(fks []extractForeignKey, cfg ExtractConfig) error {
+	e.dangle(fks)
+	if cfg.Dangling == "error" && len(e.Dangling) > 0 {
+		return errors.Errorf("Extracted rows reference rows that weren't extracted: %s", strings.Join(e.Dangling, ", "))
+	}
+	return nil
+}
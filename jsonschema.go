@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema (draft 2020-12) that is generated.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	MaxLength            int                    `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              json.Number            `json:"minimum,omitempty"`
+	Maximum              json.Number            `json:"maximum,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+var integerBounds = map[TypeKind][2]json.Number{
+	KindInt16: {"-32768", "32767"},
+	KindInt32: {"-2147483648", "2147483647"},
+	KindInt64: {"-9223372036854775808", "9223372036854775807"},
+}
+
+// numericPattern returns a pattern matching decimal strings that fit in
+// numeric(precision, scale).
+func numericPattern(precision int, scale int) string {
+	if scale == 0 {
+		return fmt.Sprintf(`^-?\d{1,%d}$`, precision)
+	}
+	integer := `0`
+	if precision > scale {
+		integer = fmt.Sprintf(`\d{1,%d}`, precision-scale)
+	}
+	return fmt.Sprintf(`^-?%s(\.\d{1,%d})?$`, integer, scale)
+}
+
+// elementJSONSchema describes a single (non-array) value of the column.
+func (c Column) elementJSONSchema() *JSONSchema {
+	typ := c.Type()
+	mapping := jsonTypes[typ.Kind]
+	schema := &JSONSchema{Format: mapping.Format}
+	if mapping.Type != "" {
+		schema.Type = mapping.Type
+	}
+	if bounds, ok := integerBounds[typ.Kind]; ok {
+		schema.Minimum, schema.Maximum = bounds[0], bounds[1]
+	}
+	switch typ.Kind {
+	case KindString:
+		schema.MaxLength = c.MaxLength
+	case KindNumeric:
+		if c.NumericPrecision > 0 {
+			schema.Pattern = numericPattern(c.NumericPrecision, c.NumericScale)
+		}
+	case KindEnum:
+		for _, value := range c.EnumValues {
+			schema.Enum = append(schema.Enum, value)
+		}
+	}
+	return schema
+}
+
+// JSONSchema returns the schema for the column's values, allowing null for
+// nullable columns.
+func (c Column) JSONSchema() *JSONSchema {
+	schema := c.elementJSONSchema()
+	if c.Type().Array {
+		schema = &JSONSchema{Type: "array", Items: schema}
+	}
+	if c.Nullable {
+		if typ, ok := schema.Type.(string); ok {
+			schema.Type = []string{typ, "null"}
+		}
+		if schema.Enum != nil {
+			schema.Enum = append(schema.Enum, nil)
+		}
+	}
+	if c.Relation.Table != nil {
+		schema.Description = fmt.Sprintf("References %s.%s", c.Relation.Table.Schema, c.Relation.Table.Name)
+		if c.Relation.Column != nil {
+			schema.Description += fmt.Sprintf("(%s)", c.Relation.Column.Name)
+		}
+	}
+	return schema
+}
+
+// tableJSONSchema describes a row of the table. Every column is required
+// since rows always carry all of them, with nullable columns allowing null.
+func tableJSONSchema(t *Table) *JSONSchema {
+	additional := false
+	schema := &JSONSchema{
+		Title:                t.TypeName(),
+		Description:          fmt.Sprintf("A row of %s.%s.", t.Schema, t.Name),
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		Required:             []string{},
+		AdditionalProperties: &additional,
+	}
+	for _, c := range t.Columns {
+		schema.Properties[c.Name] = c.JSONSchema()
+		schema.Required = append(schema.Required, c.Name)
+	}
+	return schema
+}
+
+// generateJSONSchema writes a JSON Schema document with one definition per
+// table under $defs, keyed on the table's type name.
+func generateJSONSchema(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	doc := &JSONSchema{
+		Schema: jsonSchemaDialect,
+		Defs:   map[string]*JSONSchema{},
+	}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i].Table
+			doc.Defs[table.TypeName()] = tableJSONSchema(table)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var typescriptIdentPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// typescriptPropertyName quotes names that aren't valid identifiers.
+func typescriptPropertyName(name string) string {
+	if typescriptIdentPattern.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// KyselyColumn is a column of a Kysely table interface.
+type KyselyColumn struct {
+	Name string
+	Type string
+}
+
+// KyselyTable is a table rendered as a Kysely table interface.
+type KyselyTable struct {
+	*GenerationTable
+	// Key is the table's name in the Database interface, qualified by its
+	// schema outside of public.
+	Key     string
+	Columns []KyselyColumn
+}
+
+// kyselyColumnType wraps the column's type for Kysely: columns the database
+// fills in are Generated, so inserts may leave them out, and columns it always
+// computes are GeneratedAlways, so they can't be written at all.
+func kyselyColumnType(c Column, timestampType string) string {
+	typ := c.TypeScriptType(timestampType)
+	switch {
+	case c.Identity == "ALWAYS" || c.GenerationExpression != "":
+		return "GeneratedAlways<" + typ + ">"
+	case c.Identity != "" || c.Default != "":
+		return "Generated<" + typ + ">"
+	}
+	return typ
+}
+
+func kyselyTables(schemas []GenerationSchema, timestampType string) []KyselyTable {
+	tables := []KyselyTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := KyselyTable{GenerationTable: &schema.Tables[i], Key: schema.Tables[i].Name}
+			if table.Schema != "public" {
+				table.Key = table.Schema + "." + table.Name
+			}
+			table.Key = typescriptPropertyName(table.Key)
+			for _, c := range table.GenerationTable.Columns {
+				table.Columns = append(table.Columns, KyselyColumn{
+					Name: typescriptPropertyName(c.Name),
+					Type: kyselyColumnType(c, timestampType),
+				})
+			}
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// kyselyImports returns the types imported from kysely.
+func kyselyImports(tables []KyselyTable) string {
+	imports := []string{}
+	for _, name := range []string{"Generated", "GeneratedAlways"} {
+		used := false
+		for _, table := range tables {
+			for _, c := range table.Columns {
+				used = used || strings.HasPrefix(c.Type, name+"<")
+			}
+		}
+		if used {
+			imports = append(imports, name)
+		}
+	}
+	imports = append(imports, "Insertable", "Selectable", "Updateable")
+	return strings.Join(imports, ", ")
+}
+
+const kyselyTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+import type { {{ .Imports }} } from "kysely";
+{{- range .Enums }}
+
+export type {{ .Name }} = {{ .Union }};
+{{- end }}
+{{- range .Tables }}
+
+/** The {{ .Schema }}.{{ .Name }} table. */
+export interface {{ .TypeName }}Table {
+{{- range .Columns }}
+  {{ .Name }}: {{ .Type }};
+{{- end }}
+}
+
+export type {{ .TypeName }} = Selectable<{{ .TypeName }}Table>;
+export type New{{ .TypeName }} = Insertable<{{ .TypeName }}Table>;
+export type {{ .TypeName }}Update = Updateable<{{ .TypeName }}Table>;
+{{- end }}
+
+export interface Database {
+{{- range .Tables }}
+  {{ .Key }}: {{ .TypeName }}Table;
+{{- end }}
+}
+`
+
+// generateKysely writes a TypeScript module for Kysely: a table interface per
+// table with its selectable, insertable, and updateable row types, and the
+// Database interface listing the tables. Column types match the typescript
+// action, including typescript_timestamp_type.
+func generateKysely(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	timestampType, err := typescriptTimestampType(cfg)
+	if err != nil {
+		return err
+	}
+	tables := kyselyTables(schemas, timestampType)
+
+	tmpl, err := template.New("Kysely").Parse(kyselyTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"Imports": kyselyImports(tables),
+		"Enums":   typescriptEnums(schemas),
+		"Tables":  tables,
+	})
+}
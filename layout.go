@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Output layouts of the sql target, set with output_layout. Single writes
+// one file; the others write a directory of files with an index.
+const (
+	OutputLayoutSingle    = "single"
+	OutputLayoutPerSchema = "per_schema"
+	OutputLayoutPerTable  = "per_table"
+)
+
+// outputIndexName is the index written with split output.
+const outputIndexName = "README.md"
+
+// outputFile is a file of split output, by its path in the output directory.
+type outputFile struct {
+	path    string
+	content []byte
+}
+
+// isSplitOutputLayout reports whether a layout writes a directory of files,
+// returning an error for unknown layouts.
+func isSplitOutputLayout(layout string) (bool, error) {
+	switch layout {
+	case "", OutputLayoutSingle:
+		return false, nil
+	case OutputLayoutPerSchema, OutputLayoutPerTable:
+		return true, nil
+	}
+	return false, errors.Errorf("Unknown output_layout %s, expected %s, %s, or %s", layout, OutputLayoutSingle, OutputLayoutPerSchema, OutputLayoutPerTable)
+}
+
+// splitSQL generates the queries of each schema, or each table, as a file of
+// their own, <schema>.sql or <schema>/<table>.sql, followed by an index
+// listing the files with their tables and queries.
+func splitSQL(ctx context.Context, schemas []GenerationSchema, cfg GeneratorConfiguration) ([]outputFile, error) {
+	if err := ValidateDialect(cfg.Dialect); err != nil {
+		return nil, err
+	}
+	parts := []GenerationSchema{}
+	paths := []string{}
+	for _, schema := range schemas {
+		if cfg.OutputLayout == OutputLayoutPerSchema {
+			parts = append(parts, schema)
+			paths = append(paths, schema.Name+".sql")
+			continue
+		}
+		for _, table := range schema.Tables {
+			parts = append(parts, GenerationSchema{Name: schema.Name, Tables: []GenerationTable{table}})
+			paths = append(paths, filepath.Join(schema.Name, table.Name+".sql"))
+		}
+	}
+
+	files := make([]outputFile, 0, len(parts)+1)
+	index := strings.Builder{}
+	index.WriteString("<!-- File generated by pginspector. DO NOT EDIT. -->\n\n")
+	index.WriteString("# Generated queries\n")
+	for i, part := range parts {
+		b := bytes.Buffer{}
+		if err := generateSQL(ctx, &b, []GenerationSchema{part}, cfg); err != nil {
+			return nil, err
+		}
+		files = append(files, outputFile{path: paths[i], content: b.Bytes()})
+
+		tableNames := make([]string, 0, len(part.Tables))
+		for _, table := range part.Tables {
+			tableNames = append(tableNames, part.Name+"."+table.Name)
+		}
+		link := filepath.ToSlash(paths[i])
+		fmt.Fprintf(&index, "\n## [%s](%s)\n\n", link, link)
+		fmt.Fprintf(&index, "Tables: %s\n\n", strings.Join(tableNames, ", "))
+		for _, line := range strings.Split(b.String(), "\n") {
+			if fields := strings.Fields(strings.TrimPrefix(line, "-- name: ")); strings.HasPrefix(line, "-- name: ") && len(fields) > 0 {
+				fmt.Fprintf(&index, "- %s\n", fields[0])
+			}
+		}
+	}
+	files = append(files, outputFile{path: outputIndexName, content: []byte(index.String())})
+	return files, nil
+}
+
+// staleOutputFiles returns the generated SQL files in an output directory
+// that aren't among the files written to it, e.g. of a table dropped since.
+// Files without the generated header are never stale.
+func staleOutputFiles(dir string, files []outputFile) ([]string, error) {
+	written := map[string]bool{}
+	for _, file := range files {
+		written[filepath.Clean(file.path)] = true
+	}
+	stale := []string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".sql" {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || written[rel] {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(string(content), generatedSQLHeader) {
+			stale = append(stale, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to list output directory")
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// writeOutputFiles writes split output to a directory, removing the stale
+// files of a previous run, and returns the paths written and removed.
+func writeOutputFiles(dir string, files []outputFile) ([]string, []string, error) {
+	stale, err := staleOutputFiles(dir, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	written := make([]string, 0, len(files))
+	for _, file := range files {
+		path := filepath.Join(dir, file.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, nil, errors.WithMessage(err, "Unable to create output directory")
+		}
+		if err := os.WriteFile(path, file.content, 0644); err != nil {
+			return nil, nil, errors.WithMessage(err, "Unable to write output to file")
+		}
+		written = append(written, path)
+	}
+	removed := make([]string, 0, len(stale))
+	for _, rel := range stale {
+		path := filepath.Join(dir, rel)
+		if err := os.Remove(path); err != nil {
+			return nil, nil, errors.WithMessage(err, "Unable to remove stale output file")
+		}
+		removed = append(removed, path)
+	}
+	return written, removed, nil
+}
+
+// diffOutputFiles returns a unified diff turning the files in an output
+// directory into split output, including removing stale files, or "" if
+// they're up to date.
+func diffOutputFiles(dir string, files []outputFile) (string, error) {
+	stale, err := staleOutputFiles(dir, files)
+	if err != nil {
+		return "", err
+	}
+	b := strings.Builder{}
+	for _, file := range files {
+		path := filepath.Join(dir, file.path)
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", errors.WithMessage(err, "Unable to read output file")
+		}
+		b.WriteString(driftDiff(path, path+" (regenerated)", string(current), string(file.content)))
+	}
+	for _, rel := range stale {
+		path := filepath.Join(dir, rel)
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return "", errors.WithMessage(err, "Unable to read output file")
+		}
+		b.WriteString(driftDiff(path, path+" (removed)", string(current), ""))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LintProblem is a problem lint found in a table's schema.
+type LintProblem struct {
+	Table string
+	// Rule names the check that found it, e.g. no_primary_key.
+	Rule    string
+	Message string
+}
+
+// lintSchemas checks the selected tables for problems the generated code
+// runs into: tables without a primary key, which get no get, update, or
+// delete queries, and foreign keys without an index starting with their
+// columns, which the list by foreign key queries and cascading deletes scan
+// the table for.
+func lintSchemas(schemas []GenerationSchema) []LintProblem {
+	problems := []LintProblem{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			name := schema.Name + "." + table.Name
+			inspected := newInspectTable(table.Table)
+			if len(inspected.PrimaryKey) == 0 {
+				problems = append(problems, LintProblem{Table: name, Rule: "no_primary_key", Message: "has no primary key"})
+			}
+			for _, fk := range inspected.ForeignKeys {
+				if lintIndexed(inspected.Indexes, fk.Columns) {
+					continue
+				}
+				label := "foreign key"
+				if fk.Name != "" {
+					label += " " + fk.Name
+				}
+				problems = append(problems, LintProblem{
+					Table:   name,
+					Rule:    "unindexed_foreign_key",
+					Message: fmt.Sprintf("%s (%s) has no index starting with its columns", label, strings.Join(fk.Columns, ", ")),
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// lintIndexed reports whether an index starts with the columns, in any order.
+func lintIndexed(indexes []InspectIndex, columns []string) bool {
+	for _, index := range indexes {
+		if len(index.Columns) < len(columns) {
+			continue
+		}
+		leading := map[string]bool{}
+		for _, column := range index.Columns[:len(columns)] {
+			leading[column] = true
+		}
+		indexed := true
+		for _, column := range columns {
+			indexed = indexed && leading[column]
+		}
+		if indexed {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLintProblems writes a problem per line.
+func writeLintProblems(w io.Writer, problems []LintProblem) error {
+	for _, problem := range problems {
+		_, err := fmt.Fprintf(w, "%s: %s [%s]\n", problem.Table, problem.Message, problem.Rule)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultLiquibaseAuthor = "pginspector"
+
+type liquibaseChangelog struct {
+	DatabaseChangeLog []liquibaseEntry `yaml:"databaseChangeLog"`
+}
+
+type liquibaseEntry struct {
+	ChangeSet liquibaseChangeSet `yaml:"changeSet"`
+}
+
+type liquibaseChangeSet struct {
+	ID      string                   `yaml:"id"`
+	Author  string                   `yaml:"author"`
+	Changes []map[string]interface{} `yaml:"changes"`
+}
+
+type liquibaseCreateTable struct {
+	SchemaName string                  `yaml:"schemaName"`
+	TableName  string                  `yaml:"tableName"`
+	Remarks    string                  `yaml:"remarks,omitempty"`
+	Columns    []liquibaseColumnChange `yaml:"columns"`
+}
+
+type liquibaseColumnChange struct {
+	Column liquibaseColumn `yaml:"column"`
+}
+
+type liquibaseColumn struct {
+	Name                 string               `yaml:"name"`
+	Type                 string               `yaml:"type"`
+	AutoIncrement        bool                 `yaml:"autoIncrement,omitempty"`
+	DefaultValueComputed string               `yaml:"defaultValueComputed,omitempty"`
+	Remarks              string               `yaml:"remarks,omitempty"`
+	Constraints          liquibaseConstraints `yaml:"constraints"`
+}
+
+type liquibaseConstraints struct {
+	Nullable       bool   `yaml:"nullable"`
+	PrimaryKey     bool   `yaml:"primaryKey,omitempty"`
+	PrimaryKeyName string `yaml:"primaryKeyName,omitempty"`
+}
+
+type liquibaseAddPrimaryKey struct {
+	SchemaName     string `yaml:"schemaName"`
+	TableName      string `yaml:"tableName"`
+	ColumnNames    string `yaml:"columnNames"`
+	ConstraintName string `yaml:"constraintName"`
+}
+
+type liquibaseAddUniqueConstraint struct {
+	SchemaName     string `yaml:"schemaName"`
+	TableName      string `yaml:"tableName"`
+	ColumnNames    string `yaml:"columnNames"`
+	ConstraintName string `yaml:"constraintName"`
+}
+
+type liquibaseSQL struct {
+	SQL string `yaml:"sql"`
+}
+
+type liquibaseAddForeignKey struct {
+	BaseTableSchemaName       string `yaml:"baseTableSchemaName"`
+	BaseTableName             string `yaml:"baseTableName"`
+	BaseColumnNames           string `yaml:"baseColumnNames"`
+	ConstraintName            string `yaml:"constraintName"`
+	ReferencedTableSchemaName string `yaml:"referencedTableSchemaName"`
+	ReferencedTableName       string `yaml:"referencedTableName"`
+	ReferencedColumnNames     string `yaml:"referencedColumnNames"`
+}
+
+// liquibaseTableChanges returns the changes creating a table: createTable with
+// its columns, then its primary key (when it spans columns), unique and check
+// constraints, and indexes.
+func liquibaseTableChanges(t *GenerationTable) []map[string]interface{} {
+	primaryKey := Index{}
+	constraints := map[string]bool{}
+	for _, constraint := range t.Constraints {
+		constraints[constraint.Name] = true
+	}
+	for _, index := range t.Indexes {
+		if index.Primary {
+			primaryKey = index
+		}
+	}
+
+	create := liquibaseCreateTable{SchemaName: t.Schema, TableName: t.Name, Remarks: t.Comment}
+	for _, c := range t.Columns {
+		column := liquibaseColumn{
+			Name:        c.Name,
+			Type:        ddlType(c),
+			Remarks:     c.Comment,
+			Constraints: liquibaseConstraints{Nullable: c.Nullable},
+		}
+		switch {
+		case c.Identity != "" || strings.HasPrefix(c.Default, "nextval("):
+			column.AutoIncrement = true
+		case c.GenerationExpression != "":
+			// Generated columns are added with SQL after the table.
+			continue
+		case c.Default != "":
+			column.DefaultValueComputed = c.Default
+		}
+		if len(primaryKey.Columns) == 1 && primaryKey.Columns[0] == c.Name {
+			column.Constraints.PrimaryKey = true
+			column.Constraints.PrimaryKeyName = primaryKey.Name
+		}
+		create.Columns = append(create.Columns, liquibaseColumnChange{Column: column})
+	}
+	changes := []map[string]interface{}{{"createTable": create}}
+
+	name := ddlTableName(&t.Table)
+	for _, c := range t.Columns {
+		if c.GenerationExpression != "" {
+			changes = append(changes, map[string]interface{}{"sql": liquibaseSQL{
+				SQL: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", name, ddlColumn(c)),
+			}})
+		}
+	}
+	if len(primaryKey.Columns) > 1 {
+		changes = append(changes, map[string]interface{}{"addPrimaryKey": liquibaseAddPrimaryKey{
+			SchemaName:     t.Schema,
+			TableName:      t.Name,
+			ColumnNames:    strings.Join(primaryKey.Columns, ", "),
+			ConstraintName: primaryKey.Name,
+		}})
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.Type != ConstraintCheck && constraint.Type != ConstraintExclusion {
+			continue
+		}
+		// Liquibase has no core change for these constraints.
+		changes = append(changes, map[string]interface{}{"sql": liquibaseSQL{
+			SQL: fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", name, ddlIdent(constraint.Name), constraint.Definition),
+		}})
+	}
+	for _, index := range t.Indexes {
+		switch {
+		case index.Primary:
+		case index.Unique && constraints[index.Name]:
+			changes = append(changes, map[string]interface{}{"addUniqueConstraint": liquibaseAddUniqueConstraint{
+				SchemaName:     t.Schema,
+				TableName:      t.Name,
+				ColumnNames:    strings.Join(index.Columns, ", "),
+				ConstraintName: index.Name,
+			}})
+		case constraints[index.Name] || index.Definition == "":
+			// Exclusion constraints create their own index.
+		default:
+			changes = append(changes, map[string]interface{}{"sql": liquibaseSQL{SQL: index.Definition}})
+		}
+	}
+	return changes
+}
+
+// liquibaseForeignKeyName returns the name of the inspected foreign key
+// constraint on the column, or the name Postgres would give it.
+func liquibaseForeignKeyName(t *GenerationTable, column string) string {
+	prefix := "FOREIGN KEY (" + ddlIdent(column) + ")"
+	for _, constraint := range t.Constraints {
+		if constraint.Type == ConstraintForeignKey && strings.HasPrefix(constraint.Definition, prefix) {
+			return constraint.Name
+		}
+	}
+	return t.Name + "_" + column + "_fkey"
+}
+
+// generateLiquibase writes a Liquibase YAML changelog reconstructing the
+// selected tables: changeSets creating the schemas and enum types, one per
+// table in dependency order, and one adding the foreign keys between selected
+// tables. Changes Liquibase has no core change type for (schemas, enums,
+// check constraints, generated columns, and indexes, which may use
+// expressions or methods) are written as SQL. The changeSet author is
+// liquibase_author, pginspector by default.
+func generateLiquibase(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	author := cfg.LiquibaseAuthor
+	if author == "" {
+		author = defaultLiquibaseAuthor
+	}
+	tables := ddlOrderedTables(schemas)
+	changelog := liquibaseChangelog{}
+	addChangeSet := func(id string, changes []map[string]interface{}) {
+		changelog.DatabaseChangeLog = append(changelog.DatabaseChangeLog, liquibaseEntry{
+			ChangeSet: liquibaseChangeSet{ID: id, Author: author, Changes: changes},
+		})
+	}
+
+	schemaChanges := []map[string]interface{}{}
+	for _, schema := range schemas {
+		if schema.Name != "public" && len(schema.Tables) > 0 {
+			schemaChanges = append(schemaChanges, map[string]interface{}{"sql": liquibaseSQL{SQL: "CREATE SCHEMA IF NOT EXISTS " + ddlIdent(schema.Name)}})
+		}
+	}
+	if len(schemaChanges) > 0 {
+		addChangeSet("create-schemas", schemaChanges)
+	}
+	if enums := ddlEnums(tables); len(enums) > 0 {
+		changes := []map[string]interface{}{}
+		for _, enum := range enums {
+			changes = append(changes, map[string]interface{}{"sql": liquibaseSQL{SQL: strings.TrimSuffix(enum.Statement, ";\n")}})
+		}
+		addChangeSet("create-enum-types", changes)
+	}
+	for _, table := range tables {
+		addChangeSet("create-table-"+table.Schema+"."+table.Name, liquibaseTableChanges(table))
+	}
+
+	foreignKeys := []map[string]interface{}{}
+	for _, edge := range diagramEdges(schemas) {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		foreignKeys = append(foreignKeys, map[string]interface{}{"addForeignKeyConstraint": liquibaseAddForeignKey{
+			BaseTableSchemaName:       edge.From.Schema,
+			BaseTableName:             edge.From.Name,
+			BaseColumnNames:           edge.Column.Name,
+			ConstraintName:            liquibaseForeignKeyName(edge.From, edge.Column.Name),
+			ReferencedTableSchemaName: edge.To.Schema,
+			ReferencedTableName:       edge.To.Name,
+			ReferencedColumnNames:     edge.Column.Relation.Column.Name,
+		}})
+	}
+	if len(foreignKeys) > 0 {
+		addChangeSet("add-foreign-keys", foreignKeys)
+	}
+
+	if _, err := io.WriteString(w, "# Code generated by pginspector. DO NOT EDIT.\n\n"); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(changelog); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
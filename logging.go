@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// newLogger returns a logger writing to w at a level (debug, info, warn, or
+// error) in a format (text or json).
+func newLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{}
+	switch level {
+	case "debug":
+		options.Level = slog.LevelDebug
+	case "", "info":
+		options.Level = slog.LevelInfo
+	case "warn":
+		options.Level = slog.LevelWarn
+	case "error":
+		options.Level = slog.LevelError
+	default:
+		return nil, errors.Errorf("Unknown log level %s, expected debug, info, warn, or error", level)
+	}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, errors.Errorf("Unknown log format %s, expected text or json", format)
+}
+
+// fatalf logs a message at error level and exits non-zero.
+func fatalf(format string, args ...interface{}) {
+	slog.Error(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+	os.Exit(1)
+}
+
+// pgxLogger logs what pgx logs about connections and queries with slog, at
+// debug level unless it's a warning or an error.
+type pgxLogger struct {
+	logger *slog.Logger
+}
+
+func (l pgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	slogLevel := slog.LevelDebug
+	switch level {
+	case pgx.LogLevelError:
+		slogLevel = slog.LevelError
+	case pgx.LogLevelWarn:
+		slogLevel = slog.LevelWarn
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys)+1)
+	attrs = append(attrs, slog.String("component", "pgx"))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, data[key]))
+	}
+	l.logger.LogAttrs(ctx, slogLevel, msg, attrs...)
+}
@@ -3,14 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
-	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v4"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sort"
+	"strings"
 	"syscall"
 	"text/template"
 
@@ -22,26 +22,174 @@ import (
 )
 
 type TableConfig struct {
-	ProtoName               string `yaml:"proto_name"`
-	PrimaryKey              string `yaml:"primary_key"`
-	GenerateFieldMaskUpdate bool   `yaml:"generate_field_mask_update"`
+	ProtoName                  string            `yaml:"proto_name"`
+	PrimaryKey                 string            `yaml:"primary_key"`
+	GenerateFieldMaskUpdate    bool              `yaml:"generate_field_mask_update"`
+	DisableDelete              bool              `yaml:"disable_delete"`
+	GenerateDeleteByForeignKey bool              `yaml:"generate_delete_by_foreign_key"`
+	GenerateUpsert             bool              `yaml:"generate_upsert"`
+	UpsertConflictColumns      []string          `yaml:"upsert_conflict_columns"`
+	SoftDeleteColumn           string            `yaml:"soft_delete_column"`
+	GeneratePaginatedList      bool              `yaml:"generate_paginated_list"`
+	ListByForeignKeys          []string          `yaml:"list_by_foreign_keys"`
+	GenerateCount              bool              `yaml:"generate_count"`
+	GenerateBulkInsert         bool              `yaml:"generate_bulk_insert"`
+	BulkUpdateColumns          []string          `yaml:"bulk_update_columns"`
+	PreserveInputOrder         bool              `yaml:"preserve_input_order"`
+	Aggregates                 []AggregateConfig `yaml:"aggregates"`
+	FilterColumns              []string          `yaml:"filter_columns"`
+	GenerateForUpdate          bool              `yaml:"generate_for_update"`
+	GenerateClaim              bool              `yaml:"generate_claim"`
+	ClaimCondition             string            `yaml:"claim_condition"`
+	ClaimOrderBy               string            `yaml:"claim_order_by"`
+	EagerLoad                  []string          `yaml:"eager_load"`
+	OrderBy                    []string          `yaml:"order_by"`
+	IncludeColumns             []string          `yaml:"include_columns"`
+	ExcludeColumns             []string          `yaml:"exclude_columns"`
+	UpdateManagedColumns       []string          `yaml:"update_managed_columns"`
+	Returning                  ReturningConfig   `yaml:"returning"`
+	Category                   string            `yaml:"category"`
+	GenerateAudit              bool              `yaml:"generate_audit"`
+	GenerateHistory            bool              `yaml:"generate_history"`
+	GenerateOutbox             bool              `yaml:"generate_outbox"`
+	RLSColumn                  string            `yaml:"rls_column"`
+	Grants                     map[string]string `yaml:"grants"`
+	Description                string            `yaml:"description"`
+	ColumnDescriptions         map[string]string `yaml:"column_descriptions"`
+	Partitions                 PartitionConfig   `yaml:"partitions"`
+}
+
+// PartitionConfig controls the maintenance of a table partitioned by range
+// on a time column. Partitions are created Premake intervals ahead and
+// dropped once they're more than Retention intervals old (never if zero).
+// CronSchedule schedules the maintenance as a pg_cron job.
+type PartitionConfig struct {
+	Interval     string `yaml:"interval"`
+	Premake      int    `yaml:"premake"`
+	Retention    int    `yaml:"retention"`
+	CronSchedule string `yaml:"cron_schedule"`
+}
+
+// ReturningConfig controls the RETURNING clause of generated mutations. In
+// YAML it is either "*" (the default), "primary_key", "none", or a list of
+// column names.
+type ReturningConfig struct {
+	Mode    string
+	Columns []string
+}
+
+const (
+	ReturningAll        = "*"
+	ReturningPrimaryKey = "primary_key"
+	ReturningNone       = "none"
+	ReturningColumns    = "columns"
+)
+
+func (r *ReturningConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		r.Mode = ReturningColumns
+		return value.Decode(&r.Columns)
+	}
+	var mode string
+	if err := value.Decode(&mode); err != nil {
+		return err
+	}
+	switch mode {
+	case "", ReturningAll, ReturningPrimaryKey, ReturningNone:
+		r.Mode = mode
+		return nil
+	}
+	return errors.Errorf("Invalid returning value %q (expected \"*\", \"primary_key\", \"none\", or a list of columns)", mode)
+}
+
+// AggregateConfig declares a simple aggregate query over a table, e.g. a count
+// of rows grouped by a status column.
+type AggregateConfig struct {
+	Name     string   `yaml:"name"`
+	Function string   `yaml:"function"`
+	Column   string   `yaml:"column"`
+	GroupBy  []string `yaml:"group_by"`
+}
+
+var aggregateFunctions = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// QueryName returns the configured query name, or derives one from the
+// function, column, and grouping, e.g. SumRentalPriceByVehicle.
+func (a AggregateConfig) QueryName(tableName string) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	name := strcase.ToCamel(a.Function) + strcase.ToCamel(tableName) + strcase.ToCamel(a.Column)
+	if len(a.GroupBy) > 0 {
+		name += "By"
+		for _, g := range a.GroupBy {
+			name += strcase.ToCamel(g)
+		}
+	}
+	return name
+}
+
+// Expression returns the aggregate SQL expression, e.g. sum(price).
+func (a AggregateConfig) Expression() string {
+	if a.Column == "" {
+		return a.Function + "(*)"
+	}
+	return fmt.Sprintf("%s(%s)", a.Function, a.Column)
+}
+
+// Alias returns the output column name for the aggregate expression.
+func (a AggregateConfig) Alias() string {
+	if a.Column == "" {
+		return a.Function
+	}
+	return a.Function + "_" + a.Column
 }
 
 type SchemaConfig struct {
 	TableConfig             map[string]TableConfig `yaml:"table_config"`
 	DefaultPrimaryKeyColumn string                 `yaml:"default_primary_key_name"`
 	SkipTables              []string               `yaml:"skip_tables"`
+	// IncludeTables, if set, lists the only tables generated, by name or
+	// pattern as in SkipTables, which still leaves out tables it matches.
+	IncludeTables    []string `yaml:"include_tables"`
+	SoftDeleteColumn string   `yaml:"soft_delete_column"`
 }
 
 func (s *SchemaConfig) ShouldSkipTable(tableName string) bool {
-	for _, t := range s.SkipTables {
-		if t == tableName {
+	return s.SkippedBy(tableName) != "" || !s.IncludesTable(tableName)
+}
+
+// IncludesTable reports whether a table is matched by include_tables, or
+// include_tables isn't set.
+func (s *SchemaConfig) IncludesTable(tableName string) bool {
+	if len(s.IncludeTables) == 0 {
+		return true
+	}
+	for _, t := range s.IncludeTables {
+		if matchTablePattern(t, tableName) {
 			return true
 		}
 	}
 	return false
 }
 
+// SkippedBy returns the skip_tables entry matching a table, a name or a
+// pattern, or "" if it isn't skipped.
+func (s *SchemaConfig) SkippedBy(tableName string) string {
+	for _, t := range s.SkipTables {
+		if matchTablePattern(t, tableName) {
+			return t
+		}
+	}
+	return ""
+}
+
 func (s *SchemaConfig) GetTableConfig(tableName string) TableConfig {
 	if s.TableConfig != nil {
 		if cfg, ok := s.TableConfig[tableName]; ok {
@@ -56,7 +204,70 @@ func (s *SchemaConfig) GetTableConfig(tableName string) TableConfig {
 }
 
 type GeneratorConfiguration struct {
-	SchemaConfig map[string]SchemaConfig `yaml:"schema_config"`
+	SchemaConfig            map[string]SchemaConfig `yaml:"schema_config"`
+	Dialect                 string                  `yaml:"dialect"`
+	OutputLayout            string                  `yaml:"output_layout"`
+	GoPackage               string                  `yaml:"go_package"`
+	ProtoPackage            string                  `yaml:"proto_package"`
+	ProtoServices           bool                    `yaml:"proto_services"`
+	TypeScriptTimestampType string                  `yaml:"typescript_timestamp_type"`
+	DiagramFormat           string                  `yaml:"diagram_format"`
+	DiagramClusterBySchema  bool                    `yaml:"diagram_cluster_by_schema"`
+	DiagramCategoryColors   map[string]string       `yaml:"diagram_category_colors"`
+	RepositoryMocks         bool                    `yaml:"repository_mocks"`
+	AuditSchema             string                  `yaml:"audit_schema"`
+	AuditActorSetting       string                  `yaml:"audit_actor_setting"`
+	UpdatedAtColumn         string                  `yaml:"updated_at_column"`
+	OutboxSchema            string                  `yaml:"outbox_schema"`
+	RLSStyle                string                  `yaml:"rls_style"`
+	RLSSetting              string                  `yaml:"rls_setting"`
+	Roles                   map[string]string       `yaml:"roles"`
+	PostgRESTSchema         string                  `yaml:"postgrest_schema"`
+	PostgRESTAnonRole       string                  `yaml:"postgrest_anon_role"`
+	PostgRESTRoles          map[string]string       `yaml:"postgrest_roles"`
+	LiquibaseAuthor         string                  `yaml:"liquibase_author"`
+	FlywaySplitTables       bool                    `yaml:"flyway_split_tables"`
+	AvroNamespace           string                  `yaml:"avro_namespace"`
+	DebeziumName            string                  `yaml:"debezium_name"`
+	DebeziumConfig          map[string]string       `yaml:"debezium_config"`
+	Extract                 ExtractConfig           `yaml:"extract"`
+}
+
+// targetGenerators render the inspected schemas as something other than SQL
+// queries. They are selected as the target of the generate command.
+var targetGenerators = map[string]func(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error{
+	"go":         generateGoStructs,
+	"proto":      generateProto,
+	"graphql":    generateGraphQL,
+	"openapi":    generateOpenAPI,
+	"typescript": generateTypeScript,
+	"kysely":     generateKysely,
+	"jsonschema": generateJSONSchema,
+	"avro":       generateAvro,
+	"debezium":   generateDebezium,
+	"zod":        generateZod,
+	"diagram":    generateDiagram,
+	"dbml":       generateDBML,
+	"report":     generateReport,
+	"ent":        generateEnt,
+	"sqlalchemy": generateSQLAlchemy,
+	"django":     generateDjango,
+	"prisma":     generatePrisma,
+	"repository": generateRepositories,
+	"handlers":   generateHandlers,
+	"validate":   generateValidation,
+	"ddl":        generateDDL,
+	"liquibase":  generateLiquibase,
+	"audit":      generateAudit,
+	"updated_at": generateUpdatedAt,
+	"history":    generateHistory,
+	"outbox":     generateOutbox,
+	"rls":        generateRLS,
+	"grants":     generateGrants,
+	"comments":   generateComments,
+	"pgtap":      generatePgTAP,
+	"partitions": generatePartitions,
+	"postgrest":  generatePostgREST,
 }
 
 const exampleConfig = `
@@ -85,12 +296,38 @@ schema_config:
         generate_field_mask_update: true
 `
 
+// ReadConfig reads a config, merging in the files it includes relative to
+// the working directory.
 func ReadConfig(reader io.Reader) (GeneratorConfiguration, error) {
+	source, err := io.ReadAll(reader)
+	if err != nil {
+		return GeneratorConfiguration{}, errors.WithMessage(err, "Unable to read config file")
+	}
+	return decodeConfig("", source)
+}
+
+// ReadConfigFile reads a config file, merging in the files it includes
+// relative to it.
+func ReadConfigFile(path string) (GeneratorConfiguration, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return GeneratorConfiguration{}, errors.WithMessage(err, "Unable to open config file")
+	}
+	return decodeConfig(path, source)
+}
+
+func decodeConfig(path string, source []byte) (GeneratorConfiguration, error) {
 	cfg := GeneratorConfiguration{}
-	err := yaml.NewDecoder(reader).Decode(&cfg)
+	document, err := readConfigDocument(path, source, nil)
 	if err != nil {
+		return cfg, err
+	}
+	if err := document.Decode(&cfg); err != nil {
 		return cfg, errors.WithMessage(err, "Unable to parse config file")
 	}
+	if err := checkTablePatterns(cfg); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
@@ -98,370 +335,1560 @@ type Relation struct {
 	Forward bool
 	Table   *Table
 	Column  *Column
+	// Constraint names the foreign key. The columns of a composite foreign
+	// key each reference their column of the key, and share its name.
+	Constraint string
 }
 
 type Column struct {
-	Name     string
-	PGType   string
-	Nullable bool
-	Default  string
-	Relation Relation
+	Name      string
+	PGType    string
+	Nullable  bool
+	Default   string
+	Generated bool
+	UDTName   string
+	Relation  Relation
+	// MaxLength is the declared length of character types (0 if unbounded).
+	MaxLength int
+	// NumericPrecision and NumericScale are the declared precision and scale
+	// of numeric columns (0 if unconstrained).
+	NumericPrecision int
+	NumericScale     int
+	// Comment is the column's COMMENT ON text, if any.
+	Comment string
+	// EnumValues holds the labels of the column's enum type, in sort order.
+	// It's empty for columns that aren't enums (or arrays of enums).
+	EnumValues []string
+	// UDTSchema is the schema of the column's type.
+	UDTSchema string
+	// Position is the column's ordinal position in the table.
+	Position int
+	// Identity is ALWAYS or BY DEFAULT for identity columns.
+	Identity string
+	// GenerationExpression is the expression of a stored generated column.
+	GenerationExpression string
 }
 
-type Table struct {
-	Schema  string
-	Name    string
-	Columns []Column
+// SQLType returns a type name for the column that can be used in casts.
+// information_schema reports enums and arrays generically, so the underlying
+// udt_name is used for those.
+func (c Column) SQLType() string {
+	switch c.PGType {
+	case "USER-DEFINED":
+		return c.UDTName
+	case "ARRAY":
+		return strings.TrimPrefix(c.UDTName, "_") + "[]"
+	}
+	return c.PGType
 }
 
-type GenerationTable struct {
-	Table
-	Config TableConfig
+// BulkArrayType returns the type of the array argument a bulk query passes
+// the column's values in. Postgres arrays can't hold arrays, and unnest
+// flattens multi-dimensional ones, so array values are passed as their text,
+// e.g. {1,2}, and cast back row by row.
+func (c Column) BulkArrayType() string {
+	if c.PGType == "ARRAY" {
+		return "text[]"
+	}
+	return c.SQLType() + "[]"
 }
 
-func (t *Table) PrettyPrint() {
-	fmt.Printf("Table: %s.%s\n", t.Schema, t.Name)
-	for _, c := range t.Columns {
-		fmt.Printf("\t%s: %s (default=%s) (nullable=%t) (relation:=%+v)\n", c.Name, c.PGType, c.Default, c.Nullable, c.Relation)
+// PluralName returns the column name pluralized, for naming array arguments.
+func (c Column) PluralName() string {
+	return pluralize(c.Name)
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
 	}
+	return name + "s"
 }
 
-type Schema struct {
-	Tables map[string]Table
+type Table struct {
+	Schema      string
+	Name        string
+	Columns     []Column
+	Comment     string
+	Indexes     []Index
+	Constraints []Constraint
+	Triggers    []Trigger
+	// Partitioning is set for partitioned tables.
+	Partitioning *Partitioning
 }
 
-func Unwrap[T any](p *T) T {
-	if p == nil {
-		v := new(T)
-		return *v
-	}
-	return *p
+// Index is an index on a table's columns. Expression indexes are not
+// inspected.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+	// Definition is the CREATE INDEX statement from pg_get_indexdef.
+	Definition string
 }
 
-func (s *Schema) ProcessRow(schemaName string, tableName string, col Column) {
-	if _, ok := s.Tables[tableName]; !ok {
-		s.Tables[tableName] = Table{
-			Schema:  schemaName,
-			Name:    tableName,
-			Columns: []Column{},
-		}
-	}
+// Constraint types as stored in pg_constraint.contype.
+const (
+	ConstraintCheck      = "c"
+	ConstraintForeignKey = "f"
+	ConstraintPrimaryKey = "p"
+	ConstraintUnique     = "u"
+	ConstraintExclusion  = "x"
+)
 
-	t := s.Tables[tableName]
-	t.Columns = append(t.Columns, col)
-	s.Tables[tableName] = t
+// Constraint is a table constraint with its definition as rendered by
+// pg_get_constraintdef, e.g. "CHECK ((price > 0))".
+type Constraint struct {
+	Name       string
+	Type       string
+	Definition string
 }
 
-var (
-	flagDatabaseURL = flag.String("database-url", os.Getenv("DATABASE_URL"), "Database URL to connect to")
-	flagConfigPath  = flag.String("config", "pginspector.yaml", "Path to config file")
-	flagOutputPath  = flag.String("output", "generated.sql", "Path to output file")
-	flagAction      = flag.String("action", "generate", "Action to perform (generate, inspect, or help)")
-	flagDebug       = flag.Bool("debug", false, "Enable debug logging")
+// Partition strategies as stored in pg_partitioned_table.partstrat.
+const (
+	PartitionHash  = "h"
+	PartitionList  = "l"
+	PartitionRange = "r"
 )
 
-func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+// Partitioning is how a partitioned table is split. Expressions in the
+// partition key are not inspected.
+type Partitioning struct {
+	Strategy string
+	Columns  []string
+}
 
-	flag.Parse()
+// Trigger is a user-defined trigger on a table.
+type Trigger struct {
+	Name string
+	// Function is the schema-qualified name of the trigger function.
+	Function       string
+	FunctionSource string
+	// Definition is the CREATE TRIGGER statement from pg_get_triggerdef.
+	Definition string
+}
 
-	databaseURL := *flagDatabaseURL
-	configPath := *flagConfigPath
-	outputPath := *flagOutputPath
-	action := *flagAction
-	debug := *flagDebug
+type GenerationTable struct {
+	Table
+	Config TableConfig
+}
 
-	if databaseURL == "" {
-		log.Fatalf("-database-url (or DATABASE_URL environment variable) must be set (no default assumed)")
-	}
-	if configPath == "" {
-		log.Fatalf("-config must not be empty if set (defaults to pginspector.yaml when not set)")
+// IsUnique reports whether the column alone is the primary key or has a
+// unique index.
+func (g *GenerationTable) IsUnique(column string) bool {
+	if column == g.Config.PrimaryKey {
+		return true
 	}
-	if outputPath == "" {
-		log.Fatalf("-output must not be empty if set (defaults to generated.sql when not set)")
+	for _, index := range g.Indexes {
+		if index.Unique && len(index.Columns) == 1 && index.Columns[0] == column {
+			return true
+		}
 	}
+	return false
+}
 
-	if action == "help" {
-		fmt.Println("Usage: pginspector -database-url <database-url> -config <config-path> -output <output-path> -action <action>")
-		fmt.Println("Actions:")
-		fmt.Println("  generate: Generate SQL from a configuration file")
-		fmt.Println("  inspect: Inspect a schema and print it to stdout (outputs in configuration file format). Pass the schema name as the first argument.")
-		fmt.Println("  help: Print this help message")
-		return
+// ListByForeignKeyColumns returns the foreign key columns that get a
+// Select<Table>By<Parent>ID query. All foreign keys are used unless
+// list_by_foreign_keys restricts them.
+func (g *GenerationTable) ListByForeignKeyColumns() []Column {
+	if len(g.Config.ListByForeignKeys) == 0 {
+		return g.ForeignKeyColumns()
 	}
-
-	if action == "inspect" {
-		schemaName := flag.Arg(0)
-		if schemaName == "" {
-			log.Printf("Assuming schema \"public\" since no schema name was provided (pass the schema name as the first argument to override)\n")
-			schemaName = "public"
-		}
-		schema, err := inspectTablesInSchema(ctx, databaseURL, schemaName, []string{}, debug)
-		if err != nil {
-			log.Fatalf("Unable to inspect schema: %v\n", err)
+	cols := []Column{}
+	for _, c := range g.ForeignKeyColumns() {
+		for _, name := range g.Config.ListByForeignKeys {
+			if c.Name == name {
+				cols = append(cols, c)
+				break
+			}
 		}
+	}
+	return cols
+}
 
-		if len(schema.Tables) == 0 {
-			log.Fatalf("No tables found in schema %s\n", schemaName)
+// PrimaryKeyColumn returns the configured primary key column. Only the name is
+// set if the table doesn't have such a column.
+func (g *GenerationTable) PrimaryKeyColumn() Column {
+	for _, c := range g.Columns {
+		if c.Name == g.Config.PrimaryKey {
+			return c
 		}
+	}
+	return Column{Name: g.Config.PrimaryKey}
+}
 
-		tableConfig := map[string]TableConfig{}
-		for tableName := range schema.Tables {
-			tableConfig[tableName] = TableConfig{
-				ProtoName:               fmt.Sprintf("foo.v1.%s", strcase.ToCamel(tableName)),
-				PrimaryKey:              "id",
-				GenerateFieldMaskUpdate: true,
+// EagerLoadColumns returns the foreign key columns whose parent rows are
+// joined into Select<Table>WithRelations queries.
+func (g *GenerationTable) EagerLoadColumns() []Column {
+	cols := []Column{}
+	for _, name := range g.Config.EagerLoad {
+		for _, c := range g.ForeignKeyColumns() {
+			if c.Name == name {
+				cols = append(cols, c)
 			}
 		}
+	}
+	return cols
+}
+
+// OrderByTerm is a single column of an ORDER BY clause.
+type OrderByTerm struct {
+	Column    string
+	Direction string
+}
 
-		outputConfig := GeneratorConfiguration{
-			SchemaConfig: map[string]SchemaConfig{
-				schemaName: {
-					SkipTables: []string{
-						"\"add tables to skip here (likely a migrations table)\"",
-					},
-					DefaultPrimaryKeyColumn: "id",
-					TableConfig:             tableConfig,
-				},
-			},
+// OrderByTerms parses order_by. Entries are either "column", "column DIRECTION",
+// or a bare direction that applies to the preceding column, so both
+// [created_at, desc] and ["created_at DESC", id] are accepted.
+func (c TableConfig) OrderByTerms() ([]OrderByTerm, error) {
+	terms := []OrderByTerm{}
+	for _, entry := range c.OrderBy {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.Errorf("Invalid order_by entry %q", entry)
+		}
+		direction := ""
+		if len(fields) == 2 {
+			direction = strings.ToUpper(fields[1])
+		}
+		if len(fields) == 1 {
+			upper := strings.ToUpper(fields[0])
+			if upper == "ASC" || upper == "DESC" {
+				if len(terms) == 0 || terms[len(terms)-1].Direction != "" {
+					return nil, errors.Errorf("order_by direction %q must follow a column", entry)
+				}
+				terms[len(terms)-1].Direction = upper
+				continue
+			}
+		}
+		if direction != "" && direction != "ASC" && direction != "DESC" {
+			return nil, errors.Errorf("Invalid order_by direction in %q", entry)
 		}
+		terms = append(terms, OrderByTerm{Column: fields[0], Direction: direction})
+	}
+	return terms, nil
+}
 
-		_, err = fmt.Fprintf(os.Stdout, "# And example configuration for the provided database follows.\n# You may need to edit this to suit your needs.\n\n")
-		if err != nil {
-			log.Fatalf("Unable to write output to stdout: %v\n", err)
+// OrderByClause renders order_by for use after ORDER BY, qualifying columns
+// with qualifier when it is set. Invalid configuration is rejected before
+// generation, so errors are ignored here.
+func (g *GenerationTable) OrderByClause(qualifier string) string {
+	terms, _ := g.Config.OrderByTerms()
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part := term.Column
+		if qualifier != "" {
+			part = qualifier + "." + part
 		}
-		err = yaml.NewEncoder(os.Stdout).Encode(outputConfig)
-		if err != nil {
-			log.Fatalf("Unable to encode schema: %v\n", err)
+		if term.Direction != "" {
+			part += " " + term.Direction
 		}
-		return
+		parts = append(parts, part)
 	}
+	return strings.Join(parts, ", ")
+}
 
-	cfgReader, err := os.Open(configPath)
-	if err != nil {
-		log.Fatalf("Unable to open config file: %v\n", err)
+// ClaimWhere returns the conditions a row must meet to be claimed.
+func (g *GenerationTable) ClaimWhere() []string {
+	conditions := []string{}
+	if g.Config.ClaimCondition != "" {
+		conditions = append(conditions, g.Config.ClaimCondition)
 	}
-	cfg, err := ReadConfig(cfgReader)
-	if err != nil {
-		log.Fatalf("Unable to read config file: %v\n", err)
+	if g.Config.SoftDeleteColumn != "" {
+		conditions = append(conditions, g.Config.SoftDeleteColumn+" IS NULL")
 	}
+	return conditions
+}
 
-	outputBuffer := bytes.NewBuffer([]byte{})
+// ClaimOrderBy returns the column claimed rows are ordered by, defaulting to
+// the primary key.
+func (g *GenerationTable) ClaimOrderBy() string {
+	if g.Config.ClaimOrderBy != "" {
+		return g.Config.ClaimOrderBy
+	}
+	return g.Config.PrimaryKey
+}
 
-	err = generate(ctx, databaseURL, cfg, outputBuffer, debug)
-	if err != nil {
-		log.Fatalf("Unable to generate SQL: %v\n", err)
+// FilterColumns returns the columns configured as optional filters, in the
+// configured order.
+func (g *GenerationTable) FilterColumns() []Column {
+	cols := []Column{}
+	for _, name := range g.Config.FilterColumns {
+		for _, c := range g.Columns {
+			if c.Name == name {
+				cols = append(cols, c)
+			}
+		}
 	}
+	return cols
+}
 
-	if outputPath == "-" {
-		_, err = io.Copy(os.Stdout, outputBuffer)
-		if err != nil {
-			log.Fatalf("Unable to write output to stdout: %v\n", err)
+// PrimaryKeyPluralName names the array argument used to match many rows by
+// primary key.
+func (g *GenerationTable) PrimaryKeyPluralName() string {
+	return pluralize(g.Config.PrimaryKey)
+}
+
+// managedDefaults are the starts of the column defaults filled in by the
+// database when a row is written, as information_schema renders them.
+var managedDefaults = []string{
+	"now()",
+	"CURRENT_TIMESTAMP",
+	"nextval(",
+	"gen_random_uuid()",
+	"uuid_generate_v4()",
+}
+
+// IsManagedColumn reports whether the database maintains a column's value:
+// the primary key, generated columns, and columns defaulting to a sequence,
+// the current time, or a random UUID. Other defaults, such as a literal cast
+// like 'draft'::text, only fill in missing values.
+func (g *GenerationTable) IsManagedColumn(c Column) bool {
+	if c.Name == g.Config.PrimaryKey || c.Generated {
+		return true
+	}
+	for _, prefix := range managedDefaults {
+		if strings.HasPrefix(c.Default, prefix) {
+			return true
 		}
-	} else {
-		err = os.WriteFile(outputPath, outputBuffer.Bytes(), 0644)
-		if err != nil {
-			log.Fatalf("Unable to write output to file: %v\n", err)
+	}
+	return false
+}
+
+// UpdateColumns returns the columns set by Update<Table> queries. Managed
+// columns are left out unless listed in update_managed_columns.
+func (g *GenerationTable) UpdateColumns() []Column {
+	cols := make([]Column, 0, len(g.Columns))
+	for _, c := range g.Columns {
+		if g.IsManagedColumn(c) {
+			optedIn := false
+			for _, name := range g.Config.UpdateManagedColumns {
+				if name == c.Name {
+					optedIn = true
+				}
+			}
+			if !optedIn {
+				continue
+			}
 		}
+		cols = append(cols, c)
 	}
+	return cols
 }
 
-func generate(ctx context.Context, databaseURL string, cfg GeneratorConfiguration, outputBuffer io.Writer, debug bool) error {
-	_, err := fmt.Fprintf(outputBuffer, "-- File generated by pginspector. DO NOT EDIT.\n\n")
-	if err != nil {
-		return errors.WithMessage(err, "Unable to write output to file")
+// ReturningClause renders the RETURNING clause for generated mutations,
+// including its leading space.
+func (g *GenerationTable) ReturningClause() string {
+	switch g.Config.Returning.Mode {
+	case ReturningNone:
+		return ""
+	case ReturningPrimaryKey:
+		return " RETURNING " + g.Config.PrimaryKey
+	case ReturningColumns:
+		return " RETURNING " + strings.Join(g.Config.Returning.Columns, ", ")
 	}
+	return " RETURNING *"
+}
 
-	sortedSchemaNames := make([]string, 0, len(cfg.SchemaConfig))
-	for schemaName := range cfg.SchemaConfig {
-		sortedSchemaNames = append(sortedSchemaNames, schemaName)
+// MutationAnnotation returns the pggen command (and proto type, when full rows
+// are returned) for a mutation that would otherwise use kind.
+func (g *GenerationTable) MutationAnnotation(kind string) string {
+	switch g.Config.Returning.Mode {
+	case ReturningNone:
+		return ":exec"
+	case ReturningPrimaryKey, ReturningColumns:
+		return kind
 	}
-	sort.Strings(sortedSchemaNames)
+	if g.Config.ProtoName != "" {
+		return kind + " proto-type=" + g.Config.ProtoName
+	}
+	return kind
+}
 
-	for _, schemaName := range sortedSchemaNames {
-		schemaConfig := cfg.SchemaConfig[schemaName]
+// ConflictColumns returns the columns an upsert conflicts on, which is the
+// primary key unless upsert_conflict_columns names a unique constraint.
+func (g *GenerationTable) ConflictColumns() []string {
+	if len(g.Config.UpsertConflictColumns) > 0 {
+		return g.Config.UpsertConflictColumns
+	}
+	return []string{g.Config.PrimaryKey}
+}
 
-		inspectedSchema, err := inspectTablesInSchema(ctx, databaseURL, schemaName, schemaConfig.SkipTables, debug)
-		if err != nil {
-			return errors.WithMessage(err, "Unable to inspect schema")
+func (g *GenerationTable) isConflictColumn(name string) bool {
+	for _, c := range g.ConflictColumns() {
+		if c == name {
+			return true
 		}
-		tableConfigs := make([]GenerationTable, 0, len(schemaConfig.TableConfig))
+	}
+	return false
+}
 
-		sortedTableNames := make([]string, 0, len(inspectedSchema.Tables))
-		for tableName := range inspectedSchema.Tables {
-			sortedTableNames = append(sortedTableNames, tableName)
+// UpsertColumns returns the columns supplied to an upsert. Conflict columns
+// are always included, even when generated, so existing rows can be matched.
+func (g *GenerationTable) UpsertColumns() []Column {
+	cols := make([]Column, 0, len(g.Columns))
+	for _, c := range g.Columns {
+		if c.Generated && !g.isConflictColumn(c.Name) {
+			continue
 		}
-		sort.Strings(sortedTableNames)
+		cols = append(cols, c)
+	}
+	return cols
+}
 
-		for _, tableName := range sortedTableNames {
-			tableConfig := schemaConfig.GetTableConfig(tableName)
+// UpsertUpdateColumns returns the columns overwritten when an upsert hits an
+// existing row. When empty, the conflict column is assigned to itself instead
+// of using DO NOTHING so that RETURNING still yields the existing row.
+func (g *GenerationTable) UpsertUpdateColumns() []Column {
+	cols := []Column{}
+	for _, c := range g.UpsertColumns() {
+		if g.isConflictColumn(c.Name) {
+			continue
+		}
+		cols = append(cols, c)
+	}
+	return cols
+}
 
-			if schemaConfig.ShouldSkipTable(tableName) {
-				continue
-			}
-			inspectedTable, ok := inspectedSchema.Tables[tableName]
-			if !ok {
-				return errors.Errorf("Unable to find table %s.%s\n", schemaName, tableName)
-			}
-			if tableConfig.PrimaryKey == "" {
-				tableConfig.PrimaryKey = schemaConfig.DefaultPrimaryKeyColumn
-			}
-			if tableConfig.PrimaryKey == "" {
-				return errors.Errorf("No primary key specified for table %s.%s and no default primary key set\n", schemaName, tableName)
-			}
-			tableConfigs = append(tableConfigs, GenerationTable{
-				Table:  inspectedTable,
-				Config: tableConfig,
-			})
+// GetColumn returns the column with the given name.
+func (t *Table) GetColumn(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
 		}
+	}
+	return Column{}, false
+}
 
-		err = generateGetAndListQueries(ctx, outputBuffer, tableConfigs)
-		if err != nil {
-			return errors.WithMessage(err, "Unable to generate get and list queries")
+// HasColumn reports whether the table has a column with the given name.
+func (t *Table) HasColumn(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return true
 		}
+	}
+	return false
+}
 
-		err = generateUpdateQueries(ctx, outputBuffer, tableConfigs)
-		if err != nil {
-			return errors.WithMessage(err, "Unable to generate update queries")
+// IsUniqueKey reports whether the columns, in any order, are exactly the
+// primary key or those of a unique index, so ON CONFLICT can name them.
+// Partial indexes are left out since they'd need their predicate repeated.
+func (t *Table) IsUniqueKey(columns []string, primaryKey string) bool {
+	if len(columns) == 1 && columns[0] == primaryKey {
+		return true
+	}
+	for _, index := range t.Indexes {
+		if !index.Unique && !index.Primary || len(index.Columns) != len(columns) || strings.Contains(index.Definition, " WHERE ") {
+			continue
+		}
+		matches := true
+		for _, column := range columns {
+			found := false
+			for _, indexed := range index.Columns {
+				found = found || indexed == column
+			}
+			matches = matches && found
+		}
+		if matches {
+			return true
 		}
 	}
+	return false
+}
 
-	return nil
+// InsertColumns returns the columns that should be supplied when inserting a
+// row, i.e. everything except generated columns.
+func (t *Table) InsertColumns() []Column {
+	cols := make([]Column, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		if c.Generated {
+			continue
+		}
+		cols = append(cols, c)
+	}
+	return cols
 }
 
-type logger struct{}
+// RelationName returns the camel-cased name used for queries keyed on this
+// column, without any trailing _id.
+func (c Column) RelationName() string {
+	return strcase.ToCamel(strings.TrimSuffix(c.Name, "_id"))
+}
 
-func (l *logger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
-	if data != nil {
-		log.Printf("%s: %s: %v", level, msg, data)
-		return
-	}
-	log.Printf("%s: %s", level, msg)
+// RelationAlias returns the alias used when joining the table this column
+// references. Joined columns are prefixed with the alias and a double
+// underscore so they can't collide with the table's own columns.
+func (c Column) RelationAlias() string {
+	return strings.TrimSuffix(c.Name, "_id")
 }
 
-func inspectTablesInSchema(ctx context.Context, dbConnectionString string, schemaName string, excludedTableNames []string, debug bool) (Schema, error) {
-	pgxConfig, err := pgxpool.ParseConfig(dbConnectionString)
-	if err != nil {
-		log.Fatalf("Unable to parse database connection string: %v\n", err)
-	}
-	if debug {
-		pgxConfig.ConnConfig.Logger = &logger{}
-	}
-	pool, err := pgxpool.ConnectConfig(ctx, pgxConfig)
-	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v\n", err)
+// ForeignKeyColumns returns the columns that reference another table.
+func (t *Table) ForeignKeyColumns() []Column {
+	cols := []Column{}
+	for _, c := range t.Columns {
+		if c.Relation.Table != nil {
+			cols = append(cols, c)
+		}
 	}
+	return cols
+}
 
-	querier := models.NewQuerier(pool)
+type Schema struct {
+	Tables map[string]Table
+}
 
-	tablesAndColumns, err := querier.ListTableColumnsInSchema(ctx, schemaName)
-	if err != nil {
-		log.Fatal(err)
+func Unwrap[T any](p *T) T {
+	if p == nil {
+		v := new(T)
+		return *v
 	}
+	return *p
+}
 
-	sch := Schema{
-		Tables: map[string]Table{},
+// applyColumnSelection narrows a table's columns according to include_columns
+// and exclude_columns. The primary key is always kept since generated queries
+// are keyed on it.
+func applyColumnSelection(t Table, cfg TableConfig) (Table, error) {
+	if len(cfg.IncludeColumns) > 0 && len(cfg.ExcludeColumns) > 0 {
+		return t, errors.Errorf("Only one of include_columns and exclude_columns may be set for table %s.%s\n", t.Schema, t.Name)
+	}
+	for _, name := range append(append([]string{}, cfg.IncludeColumns...), cfg.ExcludeColumns...) {
+		if !t.HasColumn(name) {
+			return t, errors.Errorf("Column %s not found in table %s.%s\n", name, t.Schema, t.Name)
+		}
+	}
+	if len(cfg.IncludeColumns) == 0 && len(cfg.ExcludeColumns) == 0 {
+		return t, nil
 	}
 
-	for _, col := range tablesAndColumns {
-		for _, excludedTableName := range excludedTableNames {
-			if col.TableName == excludedTableName {
-				continue
+	listed := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
 			}
 		}
-		sch.ProcessRow(schemaName, col.TableName, Column{
-			Name:     col.ColumnName,
-			PGType:   Unwrap(col.DataType),
-			Nullable: Unwrap(col.IsNullable) == "YES",
-			Default:  Unwrap(col.ColumnDefault),
-		})
+		return false
 	}
-
-	if debug {
-		for _, table := range sch.Tables {
-			table.PrettyPrint()
+	columns := make([]Column, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		keep := !listed(cfg.ExcludeColumns, c.Name)
+		if len(cfg.IncludeColumns) > 0 {
+			keep = listed(cfg.IncludeColumns, c.Name)
+		}
+		if keep || c.Name == cfg.PrimaryKey {
+			columns = append(columns, c)
 		}
 	}
+	t.Columns = columns
+	return t, nil
+}
 
-	return sch, nil
+// IsGeneratedColumn reports whether Postgres produces the value for a column on
+// insert (serial, identity, and GENERATED ALWAYS columns).
+func IsGeneratedColumn(row models.ListTableColumnsInSchemaRow) bool {
+	if Unwrap(row.IsIdentity) == "YES" || Unwrap(row.IsGenerated) == "ALWAYS" {
+		return true
+	}
+	return strings.HasPrefix(Unwrap(row.ColumnDefault), "nextval(")
 }
 
-func generateGetAndListQueries(ctx context.Context, w io.Writer, tables []GenerationTable) error {
-	tmpl, err := template.New("SQLGetAndListQueries").Funcs(template.FuncMap{
-		"ToCamel": strcase.ToCamel,
-	}).Parse(`{{- define "SQLGetAndListQueries" -}}
+func (s *Schema) ProcessRow(schemaName string, tableName string, col Column) {
+	if _, ok := s.Tables[tableName]; !ok {
+		s.Tables[tableName] = Table{
+			Schema:  schemaName,
+			Name:    tableName,
+			Columns: []Column{},
+		}
+	}
+
+	t := s.Tables[tableName]
+	t.Columns = append(t.Columns, col)
+	s.Tables[tableName] = t
+}
+
+// ProcessRelation attaches a foreign key relation to an already processed column.
+func (s *Schema) ProcessRelation(tableName string, columnName string, rel Relation) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	for i := range t.Columns {
+		if t.Columns[i].Name == columnName {
+			t.Columns[i].Relation = rel
+		}
+	}
+}
+
+// ProcessComment attaches a comment to a table, or to one of its columns when
+// columnName is set.
+func (s *Schema) ProcessComment(tableName string, columnName string, comment string) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	if columnName == "" {
+		t.Comment = comment
+		s.Tables[tableName] = t
+		return
+	}
+	for i := range t.Columns {
+		if t.Columns[i].Name == columnName {
+			t.Columns[i].Comment = comment
+		}
+	}
+}
+
+// ProcessIndex adds an index to an already processed table.
+func (s *Schema) ProcessIndex(tableName string, index Index) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	t.Indexes = append(t.Indexes, index)
+	s.Tables[tableName] = t
+}
+
+// ProcessConstraint adds a constraint to an already processed table.
+func (s *Schema) ProcessConstraint(tableName string, constraint Constraint) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	t.Constraints = append(t.Constraints, constraint)
+	s.Tables[tableName] = t
+}
+
+// ProcessTrigger adds a trigger to an already processed table.
+func (s *Schema) ProcessTrigger(tableName string, trigger Trigger) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	t.Triggers = append(t.Triggers, trigger)
+	s.Tables[tableName] = t
+}
+
+// ProcessPartitioning marks an already processed table as partitioned.
+func (s *Schema) ProcessPartitioning(tableName string, partitioning Partitioning) {
+	t, ok := s.Tables[tableName]
+	if !ok {
+		return
+	}
+	t.Partitioning = &partitioning
+	s.Tables[tableName] = t
+}
+
+// ResolveRelations replaces relation placeholders that point at tables in the
+// same schema with the inspected tables, so their columns are available.
+func (s *Schema) ResolveRelations(schemaName string) {
+	for _, t := range s.Tables {
+		for i := range t.Columns {
+			rel := t.Columns[i].Relation
+			if rel.Table == nil || rel.Table.Schema != schemaName {
+				continue
+			}
+			parent, ok := s.Tables[rel.Table.Name]
+			if !ok {
+				continue
+			}
+			t.Columns[i].Relation.Table = &parent
+			for j := range parent.Columns {
+				if parent.Columns[j].Name == rel.Column.Name {
+					t.Columns[i].Relation.Column = &parent.Columns[j]
+				}
+			}
+		}
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) < 2 {
+		writeUsage(os.Stderr)
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	help := name == "help" || name == "-h" || name == "-help" || name == "--help"
+	if help && len(args) == 0 {
+		writeUsage(os.Stdout)
+		return
+	}
+	if help {
+		name, args = args[0], []string{"-h"}
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", name)
+		writeUsage(os.Stderr)
+		os.Exit(2)
+	}
+	fs := newCommandFlags(cmd)
+	if help {
+		// help <command> prints the command's usage to stdout, like help.
+		fs.SetOutput(os.Stdout)
+	}
+	cmd.flags(fs)(ctx, args)
+}
+
+// generatedSQLHeader starts every generated SQL file.
+const generatedSQLHeader = "-- File generated by pginspector. DO NOT EDIT.\n\n"
+
+func generate(ctx context.Context, databaseURL string, cfg GeneratorConfiguration, w io.Writer, debug bool) error {
+	err := ValidateDialect(cfg.Dialect)
+	if err != nil {
+		return err
+	}
+
+	schemas, err := loadGenerationSchemas(ctx, databaseURL, cfg, debug)
+	if err != nil {
+		return err
+	}
+	return generateSQL(ctx, w, schemas, cfg)
+}
+
+// generateSQL writes the queries of the tables of schemas.
+func generateSQL(ctx context.Context, w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	outputBuffer := bytes.NewBuffer([]byte{})
+	_, err := io.WriteString(outputBuffer, generatedSQLHeader)
+	if err != nil {
+		return errors.WithMessage(err, "Unable to write output to file")
+	}
+
+	for _, schema := range schemas {
+		tableNames := make([]string, 0, len(schema.Tables))
+		for _, table := range schema.Tables {
+			tableNames = append(tableNames, table.Name)
+		}
+		slog.DebugContext(ctx, "Generating queries", "schema", schema.Name, "tables", tableNames, "dialect", cfg.Dialect)
+
+		err = generateGetAndListQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate get and list queries")
+		}
+
+		err = generateAggregateQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate aggregate queries")
+		}
+
+		err = generateInsertQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate insert queries")
+		}
+
+		err = generateUpsertQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate upsert queries")
+		}
+
+		err = generateUpdateQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate update queries")
+		}
+
+		err = generateDeleteQueries(ctx, outputBuffer, schema.Tables, cfg.Dialect)
+		if err != nil {
+			return errors.WithMessage(err, "Unable to generate delete queries")
+		}
+	}
+
+	_, err = io.WriteString(w, outputBuffer.String())
+	if err != nil {
+		return errors.WithMessage(err, "Unable to write output to file")
+	}
+	return nil
+}
+
+// GenerationSchema is the set of tables to generate for a single schema.
+type GenerationSchema struct {
+	Name   string
+	Tables []GenerationTable
+}
+
+// loadGenerationSchemas inspects every configured schema and resolves and
+// validates the configuration of each table that isn't skipped. Schemas and
+// tables are sorted by name.
+func loadGenerationSchemas(ctx context.Context, databaseURL string, cfg GeneratorConfiguration, debug bool) ([]GenerationSchema, error) {
+	return buildGenerationSchemas(cfg, func(schemaName string, excludedTableNames []string) (Schema, error) {
+		return inspectTablesInSchema(ctx, databaseURL, schemaName, excludedTableNames, debug)
+	})
+}
+
+// buildGenerationSchemas resolves the configuration of the schemas returned
+// by inspect, like loadGenerationSchemas.
+func buildGenerationSchemas(cfg GeneratorConfiguration, inspect func(schemaName string, excludedTableNames []string) (Schema, error)) ([]GenerationSchema, error) {
+	sortedSchemaNames := make([]string, 0, len(cfg.SchemaConfig))
+	for schemaName := range cfg.SchemaConfig {
+		sortedSchemaNames = append(sortedSchemaNames, schemaName)
+	}
+	sort.Strings(sortedSchemaNames)
+
+	schemas := make([]GenerationSchema, 0, len(sortedSchemaNames))
+
+	for _, schemaName := range sortedSchemaNames {
+		schemaConfig := cfg.SchemaConfig[schemaName]
+
+		inspectedSchema, err := inspect(schemaName, schemaConfig.SkipTables)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Unable to inspect schema")
+		}
+		tableConfigs := make([]GenerationTable, 0, len(schemaConfig.TableConfig))
+
+		sortedTableNames := make([]string, 0, len(inspectedSchema.Tables))
+		for tableName := range inspectedSchema.Tables {
+			sortedTableNames = append(sortedTableNames, tableName)
+		}
+		sort.Strings(sortedTableNames)
+
+		for _, tableName := range sortedTableNames {
+			tableConfig := schemaConfig.GetTableConfig(tableName)
+
+			if schemaConfig.ShouldSkipTable(tableName) {
+				continue
+			}
+			inspectedTable, ok := inspectedSchema.Tables[tableName]
+			if !ok {
+				return nil, errors.Errorf("Unable to find table %s.%s\n", schemaName, tableName)
+			}
+			if tableConfig.PrimaryKey == "" {
+				tableConfig.PrimaryKey = schemaConfig.DefaultPrimaryKeyColumn
+			}
+			if tableConfig.PrimaryKey == "" {
+				return nil, errors.Errorf("No primary key specified for table %s.%s and no default primary key set\n", schemaName, tableName)
+			}
+			inspectedTable, err = applyColumnSelection(inspectedTable, tableConfig)
+			if err != nil {
+				return nil, err
+			}
+			if tableConfig.SoftDeleteColumn == "" && inspectedTable.HasColumn(schemaConfig.SoftDeleteColumn) {
+				tableConfig.SoftDeleteColumn = schemaConfig.SoftDeleteColumn
+			}
+			if tableConfig.SoftDeleteColumn != "" && !inspectedTable.HasColumn(tableConfig.SoftDeleteColumn) {
+				return nil, errors.Errorf("Soft delete column %s not found in table %s.%s\n", tableConfig.SoftDeleteColumn, schemaName, tableName)
+			}
+			for _, columnName := range tableConfig.FilterColumns {
+				if !inspectedTable.HasColumn(columnName) {
+					return nil, errors.Errorf("Filter column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+				}
+			}
+			for _, columnName := range tableConfig.UpdateManagedColumns {
+				col, ok := inspectedTable.GetColumn(columnName)
+				if !ok {
+					return nil, errors.Errorf("Update managed column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+				}
+				// Update<Table> matches rows by primary key, and generated
+				// columns can't be written.
+				if columnName == tableConfig.PrimaryKey {
+					return nil, errors.Errorf("Update managed column %s is the primary key of table %s.%s\n", columnName, schemaName, tableName)
+				}
+				if col.Generated {
+					return nil, errors.Errorf("Update managed column %s is generated in table %s.%s\n", columnName, schemaName, tableName)
+				}
+			}
+			for _, columnName := range tableConfig.UpsertConflictColumns {
+				if !inspectedTable.HasColumn(columnName) {
+					return nil, errors.Errorf("Upsert conflict column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+				}
+			}
+			if len(tableConfig.UpsertConflictColumns) > 0 && !inspectedTable.IsUniqueKey(tableConfig.UpsertConflictColumns, tableConfig.PrimaryKey) {
+				return nil, errors.Errorf("Upsert conflict columns (%s) of table %s.%s are not the primary key or a unique constraint\n", strings.Join(tableConfig.UpsertConflictColumns, ", "), schemaName, tableName)
+			}
+			for _, columnName := range tableConfig.BulkUpdateColumns {
+				col, ok := inspectedTable.GetColumn(columnName)
+				if !ok {
+					return nil, errors.Errorf("Bulk update column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+				}
+				// Update<Table>Many matches rows by primary key, so it can't set it.
+				if columnName == tableConfig.PrimaryKey {
+					return nil, errors.Errorf("Bulk update column %s is the primary key of table %s.%s\n", columnName, schemaName, tableName)
+				}
+				if col.Generated {
+					return nil, errors.Errorf("Bulk update column %s is generated in table %s.%s\n", columnName, schemaName, tableName)
+				}
+			}
+			for _, columnName := range tableConfig.EagerLoad {
+				col, ok := inspectedTable.GetColumn(columnName)
+				if !ok || col.Relation.Table == nil {
+					return nil, errors.Errorf("Eager load column %s is not a foreign key of table %s.%s\n", columnName, schemaName, tableName)
+				}
+				if len(col.Relation.Table.Columns) == 0 {
+					return nil, errors.Errorf("Unable to eager load %s.%s from table %s.%s (only tables in the same schema are supported)\n", col.Relation.Table.Schema, col.Relation.Table.Name, schemaName, tableName)
+				}
+			}
+			for _, columnName := range tableConfig.Returning.Columns {
+				if !inspectedTable.HasColumn(columnName) {
+					return nil, errors.Errorf("Returning column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+				}
+			}
+			orderByTerms, err := tableConfig.OrderByTerms()
+			if err != nil {
+				return nil, errors.WithMessagef(err, "Invalid order_by for table %s.%s", schemaName, tableName)
+			}
+			for _, term := range orderByTerms {
+				if !inspectedTable.HasColumn(term.Column) {
+					return nil, errors.Errorf("Order by column %s not found in table %s.%s\n", term.Column, schemaName, tableName)
+				}
+			}
+			for _, aggregate := range tableConfig.Aggregates {
+				if !aggregateFunctions[aggregate.Function] {
+					return nil, errors.Errorf("Unsupported aggregate function %q for table %s.%s\n", aggregate.Function, schemaName, tableName)
+				}
+				for _, columnName := range append([]string{aggregate.Column}, aggregate.GroupBy...) {
+					if columnName != "" && !inspectedTable.HasColumn(columnName) {
+						return nil, errors.Errorf("Aggregate column %s not found in table %s.%s\n", columnName, schemaName, tableName)
+					}
+				}
+			}
+			tableConfigs = append(tableConfigs, GenerationTable{
+				Table:  inspectedTable,
+				Config: tableConfig,
+			})
+		}
+		schemas = append(schemas, GenerationSchema{
+			Name:   schemaName,
+			Tables: tableConfigs,
+		})
+	}
+
+	return schemas, nil
+}
+
+func connect(ctx context.Context, dbConnectionString string, debug bool) (*pgxpool.Pool, error) {
+	pgxConfig, err := pgxpool.ParseConfig(dbConnectionString)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to parse database connection string")
+	}
+	pgxConfig.ConnConfig.Logger = pgxLogger{logger: slog.Default()}
+	pgxConfig.ConnConfig.LogLevel = pgx.LogLevelWarn
+	if debug {
+		pgxConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+	pool, err := pgxpool.ConnectConfig(ctx, pgxConfig)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to create connection pool")
+	}
+	return pool, nil
+}
+
+// listSchemas returns the schemas with tables, leaving out the system
+// schemas.
+func listSchemas(ctx context.Context, dbConnectionString string, debug bool) ([]string, error) {
+	pool, err := connect(ctx, dbConnectionString, debug)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	schemaNames, err := models.NewQuerier(pool).ListSchemas(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to list schemas")
+	}
+	return schemaNames, nil
+}
+
+// listTables returns the tables outside the system schemas, qualified by
+// their schema unless it's public, as seed_table names them.
+func listTables(ctx context.Context, dbConnectionString string, debug bool) ([]string, error) {
+	pool, err := connect(ctx, dbConnectionString, debug)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	tableNames, err := models.NewQuerier(pool).ListTables(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to list tables")
+	}
+	return tableNames, nil
+}
+
+func inspectTablesInSchema(ctx context.Context, dbConnectionString string, schemaName string, excludedTableNames []string, debug bool) (Schema, error) {
+	pool, err := connect(ctx, dbConnectionString, debug)
+	if err != nil {
+		return Schema{}, err
+	}
+	defer pool.Close()
+
+	querier := models.NewQuerier(pool)
+
+	tablesAndColumns, err := querier.ListTableColumnsInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list table columns")
+	}
+
+	enumRows, err := querier.ListEnumValues(ctx)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list enum values")
+	}
+	enums := map[string][]string{}
+	for _, row := range enumRows {
+		enums[row.EnumName] = append(enums[row.EnumName], row.EnumValue)
+	}
+
+	sch := Schema{
+		Tables: map[string]Table{},
+	}
+
+	for _, col := range tablesAndColumns {
+		for _, excludedTableName := range excludedTableNames {
+			if col.TableName == excludedTableName {
+				continue
+			}
+		}
+		column := Column{
+			Name:                 col.ColumnName,
+			PGType:               Unwrap(col.DataType),
+			Nullable:             Unwrap(col.IsNullable) == "YES",
+			Default:              Unwrap(col.ColumnDefault),
+			Generated:            IsGeneratedColumn(col),
+			UDTName:              col.UdtName,
+			EnumValues:           enums[strings.TrimPrefix(col.UdtName, "_")],
+			MaxLength:            int(Unwrap(col.CharacterMaximumLength)),
+			UDTSchema:            col.UdtSchema,
+			Position:             int(Unwrap(col.OrdinalPosition)),
+			Identity:             Unwrap(col.IdentityGeneration),
+			GenerationExpression: Unwrap(col.GenerationExpression),
+		}
+		if column.PGType == "numeric" {
+			column.NumericPrecision = int(Unwrap(col.NumericPrecision))
+			column.NumericScale = int(Unwrap(col.NumericScale))
+		}
+		sch.ProcessRow(schemaName, col.TableName, column)
+	}
+
+	foreignKeys, err := querier.ListForeignKeysInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list foreign keys")
+	}
+	for _, fk := range foreignKeys {
+		sch.ProcessRelation(fk.TableName, fk.ColumnName, Relation{
+			Forward:    true,
+			Table:      &Table{Schema: fk.ForeignTableSchema, Name: fk.ForeignTableName},
+			Column:     &Column{Name: fk.ForeignColumnName},
+			Constraint: fk.ConstraintName,
+		})
+	}
+
+	comments, err := querier.ListCommentsInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list comments")
+	}
+	for _, comment := range comments {
+		sch.ProcessComment(comment.TableName, Unwrap(comment.ColumnName), Unwrap(comment.Description))
+	}
+
+	indexes, err := querier.ListIndexesInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list indexes")
+	}
+	for _, index := range indexes {
+		sch.ProcessIndex(index.TableName, Index{
+			Name:       index.IndexName,
+			Columns:    index.ColumnNames,
+			Unique:     index.IsUnique,
+			Primary:    index.IsPrimary,
+			Definition: index.Definition,
+		})
+	}
+
+	constraints, err := querier.ListConstraintsInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list constraints")
+	}
+	for _, constraint := range constraints {
+		sch.ProcessConstraint(constraint.TableName, Constraint{
+			Name:       constraint.ConstraintName,
+			Type:       constraint.ConstraintType,
+			Definition: constraint.Definition,
+		})
+	}
+
+	triggers, err := querier.ListTriggersInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list triggers")
+	}
+	for _, trigger := range triggers {
+		sch.ProcessTrigger(trigger.TableName, Trigger{
+			Name:           trigger.TriggerName,
+			Function:       trigger.FunctionSchema + "." + trigger.FunctionName,
+			FunctionSource: trigger.FunctionSource,
+			Definition:     trigger.Definition,
+		})
+	}
+
+	partitionedTables, err := querier.ListPartitionedTablesInSchema(ctx, schemaName)
+	if err != nil {
+		return Schema{}, errors.WithMessage(err, "Unable to list partitioned tables")
+	}
+	for _, partitioned := range partitionedTables {
+		sch.ProcessPartitioning(partitioned.TableName, Partitioning{
+			Strategy: partitioned.Strategy,
+			Columns:  partitioned.ColumnNames,
+		})
+	}
+	sch.ResolveRelations(schemaName)
+
+	if debug {
+		for _, table := range sch.Tables {
+			columns := make([]string, 0, len(table.Columns))
+			for _, c := range table.Columns {
+				columns = append(columns, c.Name+" "+c.SQLType())
+			}
+			slog.DebugContext(ctx, "Inspected table", "schema", schemaName, "table", table.Name, "columns", columns)
+		}
+	}
+
+	return sch, nil
+}
+
+func generateGetAndListQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLGetAndListQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLGetAndListQueries" -}}
 {{- range . }}
 
--- name: Select{{ ToCamel .Name }}ByID :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}
+-- name: Select{{ ToCamel .Name }}ByID :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
 SELECT
         {{- range $index, $col := .Columns }}
         {{- if $index}},{{ end }}
         {{ $col.Name }}
         {{- end }}
 FROM {{ .Schema }}.{{ .Name }}
-WHERE {{ .Config.PrimaryKey }} = pggen.arg('{{ .Config.PrimaryKey }}');
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }}
+{{- if .Config.SoftDeleteColumn }} AND {{ .Config.SoftDeleteColumn }} IS NULL{{ end }};
+
+-- name: Select{{ ToCamel .Name }}ByIDs :many {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
+{{- if .Config.PreserveInputOrder }}
+{{- $table := . }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $table.Name }}.{{ $col.Name }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+JOIN unnest({{ Arg .PrimaryKeyPluralName }}::{{ .PrimaryKeyColumn.SQLType }}[]) WITH ORDINALITY AS input(key, ordinality)
+        ON {{ .Name }}.{{ .Config.PrimaryKey }} = input.key
+{{- if .Config.SoftDeleteColumn }}
+WHERE {{ .Name }}.{{ .Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+ORDER BY input.ordinality;
+{{- else }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+WHERE {{ .Config.PrimaryKey }} = ANY({{ Arg .PrimaryKeyPluralName }})
+{{- if .Config.SoftDeleteColumn }} AND {{ .Config.SoftDeleteColumn }} IS NULL{{ end }};
+{{- end }}
+
+-- name: Select{{ ToCamel .Name }}List :many {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+{{- if .Config.SoftDeleteColumn }}
+WHERE {{ .Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+{{- with .OrderByClause "" }}
+ORDER BY {{ . }}
+{{- end }};
+{{- $table := . }}
+{{- range $fk := .ListByForeignKeyColumns }}
+
+-- name: Select{{ ToCamel $table.Name }}By{{ $fk.RelationName }}ID :many {{- if $table.Config.ProtoName }} proto-type={{ $table.Config.ProtoName }} {{- end }}{{ QueryArgs }}
+SELECT
+        {{- range $index, $col := $table.Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ $table.Schema }}.{{ $table.Name }}
+WHERE {{ $fk.Name }} = {{ Arg $fk.Name }}
+{{- if $table.Config.SoftDeleteColumn }} AND {{ $table.Config.SoftDeleteColumn }} IS NULL{{ end }}
+{{- with $table.OrderByClause "" }}
+ORDER BY {{ . }}
+{{- end }};
+{{- end }}
+
+{{- with .EagerLoadColumns }}
+
+-- name: Select{{ ToCamel $table.Name }}WithRelations :one{{ QueryArgs }}
+{{- template "SQLWithRelationsSelect" $table }}
+WHERE {{ $table.Name }}.{{ $table.Config.PrimaryKey }} = {{ Arg $table.Config.PrimaryKey }}
+{{- if $table.Config.SoftDeleteColumn }} AND {{ $table.Name }}.{{ $table.Config.SoftDeleteColumn }} IS NULL{{ end }};
+
+-- name: Select{{ ToCamel $table.Name }}ListWithRelations :many{{ QueryArgs }}
+{{- template "SQLWithRelationsSelect" $table }}
+{{- if $table.Config.SoftDeleteColumn }}
+WHERE {{ $table.Name }}.{{ $table.Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+{{- with $table.OrderByClause $table.Name }}
+ORDER BY {{ . }}
+{{- end }};
+{{- end }}
+
+{{- if .Config.GenerateForUpdate }}
+
+-- name: Select{{ ToCamel .Name }}ByIDForUpdate :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }}
+{{- if .Config.SoftDeleteColumn }} AND {{ .Config.SoftDeleteColumn }} IS NULL{{ end }}
+FOR UPDATE;
+{{- end }}
+
+{{- if .Config.GenerateClaim }}
+
+-- name: Claim{{ ToCamel .Name }} :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+{{- with .ClaimWhere }}
+WHERE {{ range $index, $cond := . }}{{ if $index }} AND {{ end }}{{ $cond }}{{ end }}
+{{- end }}
+ORDER BY {{ .ClaimOrderBy }}
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+{{- end }}
+
+{{- with .FilterColumns }}
+
+-- name: Filter{{ ToCamel $table.Name }} :many {{- if $table.Config.ProtoName }} proto-type={{ $table.Config.ProtoName }} {{- end }}{{ QueryArgs }}
+SELECT
+        {{- range $index, $col := $table.Columns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+FROM {{ $table.Schema }}.{{ $table.Name }}
+WHERE
+        {{- range $index, $col := . }}
+        {{- if $index}} AND{{ end }}
+        ({{ Arg $col.Name }}::{{ $col.SQLType }} IS NULL OR {{ $col.Name }} = {{ Arg $col.Name }})
+        {{- end }}
+{{- if $table.Config.SoftDeleteColumn }}
+        AND {{ $table.Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+{{- with $table.OrderByClause "" }}
+ORDER BY {{ . }}
+{{- end }};
+{{- end }}
+
+{{- if .Config.GeneratePaginatedList }}
 
--- name: Select{{ ToCamel .Name }}List :many {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}
+-- name: Select{{ ToCamel .Name }}ListPaginated :many {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}{{ QueryArgs }}
 SELECT
         {{- range $index, $col := .Columns }}
         {{- if $index}},{{ end }}
         {{ $col.Name }}
         {{- end }}
-FROM {{ .Schema }}.{{ .Name }};
+FROM {{ .Schema }}.{{ .Name }}
+{{- if .Config.SoftDeleteColumn }}
+WHERE {{ .Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+ORDER BY {{ or (.OrderByClause "") .Config.PrimaryKey }}
+LIMIT {{ Arg "limit" }} OFFSET {{ Arg "offset" }};
+{{- end }}
+
+{{- if or .Config.GenerateCount .Config.GeneratePaginatedList }}
+
+-- name: Count{{ ToCamel .Name }} :one{{ QueryArgs }}
+SELECT count(*) AS total
+FROM {{ .Schema }}.{{ .Name }}
+{{- if .Config.SoftDeleteColumn }}
+WHERE {{ .Config.SoftDeleteColumn }} IS NULL
+{{- end }};
+{{- end }}
+
+{{- if .Config.GenerateCount }}
+{{- range $fk := .ListByForeignKeyColumns }}
+
+-- name: Count{{ ToCamel $table.Name }}By{{ $fk.RelationName }}ID :one{{ QueryArgs }}
+SELECT count(*) AS total
+FROM {{ $table.Schema }}.{{ $table.Name }}
+WHERE {{ $fk.Name }} = {{ Arg $fk.Name }}
+{{- if $table.Config.SoftDeleteColumn }} AND {{ $table.Config.SoftDeleteColumn }} IS NULL{{ end }};
+{{- end }}
+{{- end }}
+
+{{- end }}
+{{- end }}
 
+{{- define "SQLWithRelationsSelect" }}
+{{- $table := . }}
+SELECT
+        {{- range $index, $col := .Columns }}
+        {{- if $index}},{{ end }}
+        {{ $table.Name }}.{{ $col.Name }}
+        {{- end }}
+        {{- range $fk := .EagerLoadColumns }}
+        {{- range $col := $fk.Relation.Table.Columns }},
+        {{ $fk.RelationAlias }}.{{ $col.Name }} AS {{ $fk.RelationAlias }}__{{ $col.Name }}
+        {{- end }}
+        {{- end }}
+FROM {{ .Schema }}.{{ .Name }}
+{{- range $fk := .EagerLoadColumns }}
+LEFT JOIN {{ $fk.Relation.Table.Schema }}.{{ $fk.Relation.Table.Name }} AS {{ $fk.RelationAlias }} ON {{ $fk.RelationAlias }}.{{ $fk.Relation.Column.Name }} = {{ $table.Name }}.{{ $fk.Name }}
 {{- end }}
 {{- end }}
 `)
 	if err != nil {
 		return err
 	}
-	return tmpl.Execute(w, tables)
+	return args.execute(w, tmpl, tables)
 }
 
-func generateUpdateQueries(ctx context.Context, w io.Writer, tables []GenerationTable) error {
-	tmpl, err := template.New("SQLUpdateQueries").Funcs(template.FuncMap{
-		"ToCamel": strcase.ToCamel,
-	}).Parse(`{{- define "SQLUpdateQueries" -}}
+func generateAggregateQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLAggregateQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLAggregateQueries" -}}
 {{- range . }}
+{{- $table := . }}
+{{- range $agg := .Config.Aggregates }}
 
--- name: Update{{ ToCamel .Name }} :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}
+-- name: {{ $agg.QueryName $table.Name }} :many{{ QueryArgs }}
+SELECT
+        {{- range $index, $name := $agg.GroupBy }}
+        {{ $name }},
+        {{- end }}
+        {{ $agg.Expression }} AS {{ $agg.Alias }}
+FROM {{ $table.Schema }}.{{ $table.Name }}
+{{- if $table.Config.SoftDeleteColumn }}
+WHERE {{ $table.Config.SoftDeleteColumn }} IS NULL
+{{- end }}
+{{- if $agg.GroupBy }}
+GROUP BY {{ range $index, $name := $agg.GroupBy }}{{ if $index }}, {{ end }}{{ $name }}{{ end }}
+ORDER BY {{ range $index, $name := $agg.GroupBy }}{{ if $index }}, {{ end }}{{ $name }}{{ end }}
+{{- end }};
+{{- end }}
+
+{{- end }}
+{{- end }}`)
+	if err != nil {
+		return err
+	}
+	return args.execute(w, tmpl, tables)
+}
+
+func generateInsertQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLInsertQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLInsertQueries" -}}
+{{- range . }}
+
+-- name: Insert{{ ToCamel .Name }} {{ .MutationAnnotation ":one" }}{{ QueryArgs }}
+INSERT INTO {{ .Schema }}.{{ .Name }} (
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+) VALUES (
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ Arg $col.Name }}
+        {{- end }}
+){{ .ReturningClause }};
+
+{{- if .Config.GenerateBulkInsert }}
+
+-- name: BulkInsert{{ ToCamel .Name }} {{ .MutationAnnotation ":many" }}{{ QueryArgs }}
+INSERT INTO {{ .Schema }}.{{ .Name }} (
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+)
+SELECT
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}{{ if eq $col.PGType "ARRAY" }}::{{ $col.SQLType }}{{ end }}
+        {{- end }}
+FROM unnest(
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ Arg $col.PluralName }}::{{ $col.BulkArrayType }}
+        {{- end }}
+) AS input(
+{{- range $index, $col := .InsertColumns }}
+        {{- if $index}}, {{ end }}
+        {{- $col.Name }}
+        {{- end -}}
+){{ .ReturningClause }};
+{{- end }}
+
+{{- end }}
+{{- end }}`)
+	if err != nil {
+		return err
+	}
+	return args.execute(w, tmpl, tables)
+}
+
+func generateUpsertQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLUpsertQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLUpsertQueries" -}}
+{{- range . }}
+{{- if .Config.GenerateUpsert }}
+
+-- name: Upsert{{ ToCamel .Name }} {{ .MutationAnnotation ":one" }}{{ QueryArgs }}
+INSERT INTO {{ .Schema }}.{{ .Name }} (
+{{- range $index, $col := .UpsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }}
+        {{- end }}
+) VALUES (
+{{- range $index, $col := .UpsertColumns }}
+        {{- if $index}},{{ end }}
+        {{ Arg $col.Name }}
+        {{- end }}
+) ON CONFLICT (
+{{- range $index, $name := .ConflictColumns }}
+        {{- if $index}}, {{ end }}
+        {{- $name }}
+        {{- end -}}
+)
+{{- with .UpsertUpdateColumns }} DO UPDATE SET
+{{- range $index, $col := . }}
+        {{- if $index}},{{ end }}
+        {{ $col.Name }} = EXCLUDED.{{ $col.Name }}
+        {{- end }}
+{{- else }} DO UPDATE SET {{ index .ConflictColumns 0 }} = EXCLUDED.{{ index .ConflictColumns 0 }}
+{{- end }}{{ .ReturningClause }};
+{{- end }}
+
+{{- end }}
+{{- end }}`)
+	if err != nil {
+		return err
+	}
+	return args.execute(w, tmpl, tables)
+}
+
+func generateUpdateQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLUpdateQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLUpdateQueries" -}}
+{{- range . }}
+{{- if .UpdateColumns }}
+
+-- name: Update{{ ToCamel .Name }} {{ .MutationAnnotation ":one" }}{{ QueryArgs }}
 UPDATE {{ .Schema }}.{{ .Name }}
 SET (
-{{- range $index, $col := .Columns }}
+{{- range $index, $col := .UpdateColumns }}
         {{- if $index}},{{ end }}
         {{ $col.Name }}
         {{- end }}
-) = (
-{{- range $index, $col := .Columns }}
+) = ROW(
+{{- range $index, $col := .UpdateColumns }}
+        {{- if $index}},{{ end }}
+        {{ Arg $col.Name }}
+        {{- end }}
+) WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }}{{ .ReturningClause }};
+{{- end }}
+
+{{- $table := . }}
+{{- with .Config.BulkUpdateColumns }}
+
+-- name: Update{{ ToCamel $table.Name }}Many {{ $table.MutationAnnotation ":many" }}{{ QueryArgs }}
+UPDATE {{ $table.Schema }}.{{ $table.Name }}
+SET
+{{- range $index, $name := . }}
         {{- if $index}},{{ end }}
-        pggen.arg('{{ $col.Name }}')
+        {{ $name }} = {{ Arg $name }}
         {{- end }}
-) WHERE {{ .Config.PrimaryKey }} = pggen.arg('{{ .Config.PrimaryKey }}') RETURNING *;
+WHERE {{ $table.Config.PrimaryKey }} = ANY({{ Arg $table.PrimaryKeyPluralName }}){{ $table.ReturningClause }};
+{{- end }}
 
-{{- if .Config.GenerateFieldMaskUpdate }}
--- name: Update{{ ToCamel .Name }}FieldMask :one {{- if .Config.ProtoName }} proto-type={{ .Config.ProtoName }} {{- end }}
+{{- if and .Config.GenerateFieldMaskUpdate .UpdateColumns }}
+-- name: Update{{ ToCamel .Name }}FieldMask {{ .MutationAnnotation ":one" }}{{ QueryArgs }}
 UPDATE {{ .Schema }}.{{ .Name }}
 SET (
-{{- range $index, $col := .Columns }}
+{{- range $index, $col := .UpdateColumns }}
         {{- if $index}},{{ end }}
         {{ $col.Name }}
         {{- end }}
-) = (
-{{- range $index, $col := .Columns }}
+) = ROW(
+{{- range $index, $col := .UpdateColumns }}
         {{- if $index}},{{ end }}
         CASE
-        	WHEN '{{ $col.Name }}' = ANY(pggen.arg('_field_mask')::text[]) THEN pggen.arg('{{ $col.Name }}')
+        	WHEN '{{ $col.Name }}' = ANY({{ Arg "_field_mask" }}::text[]) THEN {{ Arg $col.Name }}
         	ELSE {{ $col.Name }}
         END
         {{- end }}
-) WHERE {{ .Config.PrimaryKey }} = pggen.arg('{{ .Config.PrimaryKey }}') RETURNING *;
+) WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }}{{ .ReturningClause }};
+{{- end }}
+
 {{- end }}
+{{- end }}`)
+	if err != nil {
+		return err
+	}
+	return args.execute(w, tmpl, tables)
+}
+
+func generateDeleteQueries(ctx context.Context, w io.Writer, tables []GenerationTable, dialect string) error {
+	args := newSQLArgs(dialect)
+	tmpl, err := template.New("SQLDeleteQueries").Funcs(templateFuncs(args)).Parse(`{{- define "SQLDeleteQueries" -}}
+{{- range . }}
+{{- if not .Config.DisableDelete }}
+{{- $table := . }}
+{{- if .Config.SoftDeleteColumn }}
 
+-- name: Delete{{ ToCamel .Name }}ByID :exec{{ QueryArgs }}
+UPDATE {{ .Schema }}.{{ .Name }}
+SET {{ .Config.SoftDeleteColumn }} = now()
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }} AND {{ .Config.SoftDeleteColumn }} IS NULL;
+
+-- name: HardDelete{{ ToCamel .Name }}ByID :exec{{ QueryArgs }}
+DELETE FROM {{ .Schema }}.{{ .Name }}
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }};
+
+-- name: Restore{{ ToCamel .Name }}ByID :exec{{ QueryArgs }}
+UPDATE {{ .Schema }}.{{ .Name }}
+SET {{ .Config.SoftDeleteColumn }} = NULL
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }};
+{{- else }}
+
+-- name: Delete{{ ToCamel .Name }}ByID :exec{{ QueryArgs }}
+DELETE FROM {{ .Schema }}.{{ .Name }}
+WHERE {{ .Config.PrimaryKey }} = {{ Arg .Config.PrimaryKey }};
+{{- end }}
+
+{{- if .Config.GenerateDeleteByForeignKey }}
+{{- range $fk := .ForeignKeyColumns }}
+
+-- name: Delete{{ ToCamel $table.Name }}By{{ $fk.RelationName }}ID :exec{{ QueryArgs }}
+{{- if $table.Config.SoftDeleteColumn }}
+UPDATE {{ $table.Schema }}.{{ $table.Name }}
+SET {{ $table.Config.SoftDeleteColumn }} = now()
+WHERE {{ $fk.Name }} = {{ Arg $fk.Name }} AND {{ $table.Config.SoftDeleteColumn }} IS NULL;
+{{- else }}
+DELETE FROM {{ $table.Schema }}.{{ $table.Name }}
+WHERE {{ $fk.Name }} = {{ Arg $fk.Name }};
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- end }}
 {{- end }}
 {{- end }}`)
 	if err != nil {
 		return err
 	}
-	return tmpl.Execute(w, tables)
+	return args.execute(w, tmpl, tables)
 }
@@ -3,12 +3,26 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func red(s string) string {
@@ -120,7 +134,7 @@ CREATE TABLE public.person (
 
 	outputBuf := &bytes.Buffer{}
 
-	err = generate(context.TODO(), connectionString, configuration, outputBuf)
+	err = generate(context.TODO(), connectionString, configuration, outputBuf, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -136,23 +150,4622 @@ SELECT
 FROM public.person
 WHERE id = pggen.arg('id');
 
+-- name: SelectPersonByIDs :many
+SELECT
+        id,
+        name
+FROM public.person
+WHERE id = ANY(pggen.arg('ids'));
+
 -- name: SelectPersonList :many
 SELECT
         id,
         name
 FROM public.person;
 
+-- name: InsertPerson :one
+INSERT INTO public.person (
+        name
+) VALUES (
+        pggen.arg('name')
+) RETURNING *;
+
 -- name: UpdatePerson :one
 UPDATE public.person
 SET (
+        name
+) = ROW(
+        pggen.arg('name')
+) WHERE id = pggen.arg('id') RETURNING *;
+
+-- name: DeletePersonByID :exec
+DELETE FROM public.person
+WHERE id = pggen.arg('id');`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateInsertQueriesSkipsGeneratedColumns(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "id", PGType: "integer", Default: "nextval('person_id_seq'::regclass)", Generated: true},
+					{Name: "name", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", ProtoName: "v1.Person"},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateInsertQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: InsertPerson :one proto-type=v1.Person
+INSERT INTO public.person (
+        name
+) VALUES (
+        pggen.arg('name')
+) RETURNING *;`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestCompareQueries(t *testing.T) {
+	baseline := splitNamedQueries(`-- File generated by pginspector. DO NOT EDIT.
+
+-- name: SelectPersonByID :one
+SELECT id FROM public.person WHERE id = pggen.arg('id');
+
+-- name: InsertPerson :one
+INSERT INTO public.person (name) VALUES (pggen.arg('name')) RETURNING *;`)
+	target := splitNamedQueries(`-- name: SelectPersonByID :one
+SELECT id, name FROM public.person WHERE id = pggen.arg('id');
+
+-- name: UpdatePerson :one
+UPDATE public.person SET name = pggen.arg('name') WHERE id = pggen.arg('id') RETURNING *;`)
+
+	diff := compareQueries(baseline, target)
+	if diff.Identical {
+		t.Fatal("expected differences")
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0] != "SelectPersonByID" {
+		t.Fatalf("expected SelectPersonByID to be changed, got %v", diff.Changed)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0] != "InsertPerson" {
+		t.Fatalf("expected InsertPerson to be missing, got %v", diff.Missing)
+	}
+	if len(diff.Extra) != 1 || diff.Extra[0] != "UpdatePerson" {
+		t.Fatalf("expected UpdatePerson to be extra, got %v", diff.Extra)
+	}
+}
+
+// fakeCompatTx fails to prepare the queries containing a string.
+type fakeCompatTx struct {
+	failing  string
+	executed []string
+}
+
+func (tx *fakeCompatTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tx.executed = append(tx.executed, sql)
+	return nil, nil
+}
+
+func (tx *fakeCompatTx) Prepare(ctx context.Context, name string, sql string) (*pgconn.StatementDescription, error) {
+	if strings.Contains(sql, tx.failing) {
+		return nil, errors.New(`ERROR: syntax error at or near "MERGE"`)
+	}
+	return &pgconn.StatementDescription{Name: name, SQL: sql}, nil
+}
+
+func TestPrepareQueries(t *testing.T) {
+	tx := &fakeCompatTx{failing: "MERGE"}
+	failures, err := prepareQueries(context.Background(), tx, map[string]string{
+		"SelectPersonByID": "SELECT id FROM public.person WHERE id = $1;",
+		"UpsertPerson":     "MERGE INTO public.person USING (SELECT $1::uuid AS id) AS input ON person.id = input.id WHEN NOT MATCHED THEN INSERT (id) VALUES (input.id);",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures["UpsertPerson"] == "" {
+		t.Fatalf("expected UpsertPerson to fail to prepare, got %v", failures)
+	}
+	expectedExecuted := []string{"SAVEPOINT pginspector_compat", "SAVEPOINT pginspector_compat", "ROLLBACK TO SAVEPOINT pginspector_compat"}
+	if !reflect.DeepEqual(tx.executed, expectedExecuted) {
+		t.Fatalf("expected the failure to be rolled back to its savepoint, got %v", tx.executed)
+	}
+	details := compatFailureDetails(CompatTarget{Label: "localhost:5432/app", Version: "12.17", Failures: failures})
+	expectedDetail := `localhost:5432/app (12.17): UpsertPerson fails: ERROR: syntax error at or near "MERGE"`
+	if len(details) != 1 || details[0] != expectedDetail {
+		t.Fatalf("expected details:\n%s\nbut got:\n%s", green(expectedDetail), red(strings.Join(details, "\n")))
+	}
+}
+
+func TestGenerateDeleteQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "audit_log",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", DisableDelete: true},
+		},
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "rental",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "vehicle", PGType: "uuid", Relation: Relation{
+						Forward: true,
+						Table:   &Table{Schema: "public", Name: "vehicle"},
+						Column:  &Column{Name: "id"},
+					}},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateDeleteByForeignKey: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDeleteQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: DeleteRentalByID :exec
+DELETE FROM public.rental
+WHERE id = pggen.arg('id');
+
+-- name: DeleteRentalByVehicleID :exec
+DELETE FROM public.rental
+WHERE vehicle = pggen.arg('vehicle');`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateUpsertQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "email", PGType: "text"},
+					{Name: "id", PGType: "integer", Generated: true},
+					{Name: "name", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateUpsert: true},
+		},
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "vehicle",
+				Columns: []Column{
+					{Name: "id", PGType: "integer", Generated: true},
+					{Name: "vin", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateUpsert: true, UpsertConflictColumns: []string{"vin"}},
+		},
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person_tag",
+				Columns: []Column{
+					{Name: "person_id", PGType: "integer"},
+					{Name: "tag", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "person_id", GenerateUpsert: true, UpsertConflictColumns: []string{"person_id", "tag"}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateUpsertQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: UpsertPerson :one
+INSERT INTO public.person (
+        email,
         id,
         name
-) = (
+) VALUES (
+        pggen.arg('email'),
         pggen.arg('id'),
         pggen.arg('name')
-) WHERE id = pggen.arg('id') RETURNING *;`
+) ON CONFLICT (id) DO UPDATE SET
+        email = EXCLUDED.email,
+        name = EXCLUDED.name RETURNING *;
+
+-- name: UpsertVehicle :one
+INSERT INTO public.vehicle (
+        vin
+) VALUES (
+        pggen.arg('vin')
+) ON CONFLICT (vin) DO UPDATE SET vin = EXCLUDED.vin RETURNING *;
+
+-- name: UpsertPersonTag :one
+INSERT INTO public.person_tag (
+        person_id,
+        tag
+) VALUES (
+        pggen.arg('person_id'),
+        pggen.arg('tag')
+) ON CONFLICT (person_id, tag) DO UPDATE SET person_id = EXCLUDED.person_id RETURNING *;`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateSoftDeleteQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "deleted_at", PGType: "timestamp without time zone", Nullable: true},
+					{Name: "id", PGType: "uuid"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", SoftDeleteColumn: "deleted_at"},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = generateDeleteQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: SelectPersonByID :one
+SELECT
+        deleted_at,
+        id
+FROM public.person
+WHERE id = pggen.arg('id') AND deleted_at IS NULL;
+
+-- name: SelectPersonByIDs :many
+SELECT
+        deleted_at,
+        id
+FROM public.person
+WHERE id = ANY(pggen.arg('ids')) AND deleted_at IS NULL;
+
+-- name: SelectPersonList :many
+SELECT
+        deleted_at,
+        id
+FROM public.person
+WHERE deleted_at IS NULL;
+
+-- name: DeletePersonByID :exec
+UPDATE public.person
+SET deleted_at = now()
+WHERE id = pggen.arg('id') AND deleted_at IS NULL;
+
+-- name: HardDeletePersonByID :exec
+DELETE FROM public.person
+WHERE id = pggen.arg('id');
+
+-- name: RestorePersonByID :exec
+UPDATE public.person
+SET deleted_at = NULL
+WHERE id = pggen.arg('id');`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGeneratePaginatedListQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "name", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GeneratePaginatedList: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: SelectPersonListPaginated :many
+SELECT
+        id,
+        name
+FROM public.person
+ORDER BY id
+LIMIT pggen.arg('limit') OFFSET pggen.arg('offset');
+
+-- name: CountPerson :one
+SELECT count(*) AS total
+FROM public.person;`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateListByForeignKeyQueries(t *testing.T) {
+	vehicleRelation := Relation{
+		Forward: true,
+		Table:   &Table{Schema: "public", Name: "vehicle"},
+		Column:  &Column{Name: "id"},
+	}
+	personRelation := Relation{
+		Forward: true,
+		Table:   &Table{Schema: "public", Name: "person"},
+		Column:  &Column{Name: "id"},
+	}
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "ownership",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "person_id", PGType: "uuid", Relation: personRelation},
+					{Name: "vehicle", PGType: "uuid", Relation: vehicleRelation},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", ListByForeignKeys: []string{"person_id"}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: SelectOwnershipByPersonID :many
+SELECT
+        id,
+        person_id,
+        vehicle
+FROM public.ownership
+WHERE person_id = pggen.arg('person_id');`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+	if strings.Contains(outputBuf.String(), "SelectOwnershipByVehicleID") {
+		t.Fatal("expected vehicle to be excluded by list_by_foreign_keys")
+	}
+}
+
+func TestGenerateCountQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "rental",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "vehicle", PGType: "uuid", Relation: Relation{
+						Forward: true,
+						Table:   &Table{Schema: "public", Name: "vehicle"},
+						Column:  &Column{Name: "id"},
+					}},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateCount: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: CountRental :one
+SELECT count(*) AS total
+FROM public.rental;
+
+-- name: CountRentalByVehicleID :one
+SELECT count(*) AS total
+FROM public.rental
+WHERE vehicle = pggen.arg('vehicle');`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateBulkInsertQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "category", PGType: "USER-DEFINED", UDTName: "person_category"},
+					{Name: "id", PGType: "uuid"},
+					{Name: "name", PGType: "character varying"},
+					{Name: "serial", PGType: "integer", Generated: true},
+					{Name: "shift", PGType: "ARRAY", UDTName: "_int4"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateBulkInsert: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateInsertQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: BulkInsertPerson :many
+INSERT INTO public.person (
+        category,
+        id,
+        name,
+        shift
+)
+SELECT
+        category,
+        id,
+        name,
+        shift::int4[]
+FROM unnest(
+        pggen.arg('categories')::person_category[],
+        pggen.arg('ids')::uuid[],
+        pggen.arg('names')::character varying[],
+        pggen.arg('shifts')::text[]
+) AS input(category, id, name, shift) RETURNING *;`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateBulkUpdateQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "task",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "status", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", BulkUpdateColumns: []string{"status"}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateUpdateQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: UpdateTaskMany :many
+UPDATE public.task
+SET
+        status = pggen.arg('status')
+WHERE id = ANY(pggen.arg('ids')) RETURNING *;`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateSelectByIDsPreservingInputOrder(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "name", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", PreserveInputOrder: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `-- name: SelectPersonByIDs :many
+SELECT
+        person.id,
+        person.name
+FROM public.person
+JOIN unnest(pggen.arg('ids')::uuid[]) WITH ORDINALITY AS input(key, ordinality)
+        ON person.id = input.key
+ORDER BY input.ordinality;`
+
+	if !strings.Contains(outputBuf.String(), expected) {
+		t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateAggregateQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "rental",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "price", PGType: "numeric"},
+					{Name: "status", PGType: "text"},
+					{Name: "vehicle", PGType: "uuid"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", Aggregates: []AggregateConfig{
+				{Function: "count", GroupBy: []string{"status"}},
+				{Name: "RevenueByVehicle", Function: "sum", Column: "price", GroupBy: []string{"vehicle"}},
+			}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateAggregateQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: CountRentalByStatus :many
+SELECT
+        status,
+        count(*) AS count
+FROM public.rental
+GROUP BY status
+ORDER BY status;
+
+-- name: RevenueByVehicle :many
+SELECT
+        vehicle,
+        sum(price) AS sum_price
+FROM public.rental
+GROUP BY vehicle
+ORDER BY vehicle;`
 
 	if outputBuf.String() != expectedOutput {
 		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
 	}
 }
+
+func TestGenerateFilterQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "vehicle",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "make", PGType: "uuid"},
+					{Name: "year", PGType: "integer"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", FilterColumns: []string{"year", "make"}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: FilterVehicle :many
+SELECT
+        id,
+        make,
+        year
+FROM public.vehicle
+WHERE
+        (pggen.arg('year')::integer IS NULL OR year = pggen.arg('year')) AND
+        (pggen.arg('make')::uuid IS NULL OR make = pggen.arg('make'));`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateRowLockingQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "job",
+				Columns: []Column{
+					{Name: "created_at", PGType: "timestamp without time zone"},
+					{Name: "id", PGType: "uuid"},
+					{Name: "status", PGType: "text"},
+				},
+			},
+			Config: TableConfig{
+				PrimaryKey:        "id",
+				GenerateForUpdate: true,
+				GenerateClaim:     true,
+				ClaimCondition:    "status = 'pending'",
+				ClaimOrderBy:      "created_at",
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: SelectJobByIDForUpdate :one
+SELECT
+        created_at,
+        id,
+        status
+FROM public.job
+WHERE id = pggen.arg('id')
+FOR UPDATE;
+
+-- name: ClaimJob :one
+SELECT
+        created_at,
+        id,
+        status
+FROM public.job
+WHERE status = 'pending'
+ORDER BY created_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateWithRelationsQueries(t *testing.T) {
+	vehicle := Table{
+		Schema: "public",
+		Name:   "vehicle",
+		Columns: []Column{
+			{Name: "id", PGType: "uuid"},
+			{Name: "vin", PGType: "character varying"},
+		},
+	}
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "rental",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+						Forward: true,
+						Table:   &vehicle,
+						Column:  &vehicle.Columns[0],
+					}},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", EagerLoad: []string{"vehicle_id"}},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSuffix := `
+
+-- name: SelectRentalWithRelations :one
+SELECT
+        rental.id,
+        rental.vehicle_id,
+        vehicle.id AS vehicle__id,
+        vehicle.vin AS vehicle__vin
+FROM public.rental
+LEFT JOIN public.vehicle AS vehicle ON vehicle.id = rental.vehicle_id
+WHERE rental.id = pggen.arg('id');
+
+-- name: SelectRentalListWithRelations :many
+SELECT
+        rental.id,
+        rental.vehicle_id,
+        vehicle.id AS vehicle__id,
+        vehicle.vin AS vehicle__vin
+FROM public.rental
+LEFT JOIN public.vehicle AS vehicle ON vehicle.id = rental.vehicle_id;`
+
+	if !strings.HasSuffix(outputBuf.String(), expectedSuffix) {
+		t.Fatalf("expected output to end with:\n%s\nbut got:\n%s", green(expectedSuffix), red(outputBuf.String()))
+	}
+}
+
+func TestOrderByTerms(t *testing.T) {
+	cfg := TableConfig{OrderBy: []string{"created_at", "desc", "name asc", "id"}}
+	terms, err := cfg.OrderByTerms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []OrderByTerm{
+		{Column: "created_at", Direction: "DESC"},
+		{Column: "name", Direction: "ASC"},
+		{Column: "id"},
+	}
+	if fmt.Sprint(terms) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, terms)
+	}
+
+	_, err = TableConfig{OrderBy: []string{"desc"}}.OrderByTerms()
+	if err == nil {
+		t.Fatal("expected an error for a direction without a column")
+	}
+}
+
+func TestGenerateOrderedListQueries(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "created_at", PGType: "timestamp without time zone"},
+					{Name: "id", PGType: "uuid"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", OrderBy: []string{"created_at", "desc"}, GeneratePaginatedList: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGetAndListQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"FROM public.person\nORDER BY created_at DESC;",
+		"FROM public.person\nORDER BY created_at DESC\nLIMIT pggen.arg('limit') OFFSET pggen.arg('offset');",
+	} {
+		if !strings.Contains(outputBuf.String(), expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+		}
+	}
+}
+
+func TestApplyColumnSelection(t *testing.T) {
+	table := Table{
+		Schema: "public",
+		Name:   "account",
+		Columns: []Column{
+			{Name: "email"},
+			{Name: "id"},
+			{Name: "password_hash"},
+		},
+	}
+
+	excluded, err := applyColumnSelection(table, TableConfig{PrimaryKey: "id", ExcludeColumns: []string{"password_hash"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(excluded.Columns) != 2 || excluded.HasColumn("password_hash") {
+		t.Fatalf("expected password_hash to be excluded, got %+v", excluded.Columns)
+	}
+
+	included, err := applyColumnSelection(table, TableConfig{PrimaryKey: "id", IncludeColumns: []string{"email"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(included.Columns) != 2 || !included.HasColumn("id") || !included.HasColumn("email") {
+		t.Fatalf("expected email and the primary key to be included, got %+v", included.Columns)
+	}
+
+	_, err = applyColumnSelection(table, TableConfig{PrimaryKey: "id", ExcludeColumns: []string{"missing"}})
+	if err == nil {
+		t.Fatal("expected an error for an unknown column")
+	}
+}
+
+func TestGenerateUpdateQueriesSkipManagedColumns(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "created_at", PGType: "timestamp without time zone", Default: "now()"},
+					{Name: "id", PGType: "uuid", Default: "uuid_generate_v4()"},
+					{Name: "name", PGType: "character varying"},
+					{Name: "settings", PGType: "jsonb", Default: "jsonb_build_object()"},
+					{Name: "status", PGType: "text", Default: "'active'::text"},
+					{Name: "updated_at", PGType: "timestamp without time zone", Default: "now()"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", UpdateManagedColumns: []string{"updated_at"}},
+		},
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "visit",
+				Columns: []Column{
+					{Name: "id", PGType: "bigint", Default: "nextval('visit_id_seq'::regclass)"},
+					{Name: "created_at", PGType: "timestamp with time zone", Default: "CURRENT_TIMESTAMP"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", GenerateFieldMaskUpdate: true},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateUpdateQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `
+
+-- name: UpdatePerson :one
+UPDATE public.person
+SET (
+        name,
+        settings,
+        status,
+        updated_at
+) = ROW(
+        pggen.arg('name'),
+        pggen.arg('settings'),
+        pggen.arg('status'),
+        pggen.arg('updated_at')
+) WHERE id = pggen.arg('id') RETURNING *;`
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestReturningConfig(t *testing.T) {
+	const cfgFile = `schema_config:
+  public:
+    table_config:
+      person:
+        returning: none
+      vehicle:
+        returning: primary_key
+      rental:
+        returning: [id, vehicle]
+`
+	configuration, err := ReadConfig(strings.NewReader(cfgFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableConfig := configuration.SchemaConfig["public"].TableConfig
+
+	person := GenerationTable{Config: tableConfig["person"]}
+	person.Config.ProtoName = "v1.Person"
+	if person.MutationAnnotation(":one") != ":exec" || person.ReturningClause() != "" {
+		t.Fatalf("expected no RETURNING for person, got %q %q", person.MutationAnnotation(":one"), person.ReturningClause())
+	}
+
+	vehicle := GenerationTable{Config: tableConfig["vehicle"]}
+	vehicle.Config.PrimaryKey = "id"
+	if vehicle.ReturningClause() != " RETURNING id" {
+		t.Fatalf("expected RETURNING id for vehicle, got %q", vehicle.ReturningClause())
+	}
+
+	rental := GenerationTable{Config: tableConfig["rental"]}
+	if rental.ReturningClause() != " RETURNING id, vehicle" {
+		t.Fatalf("expected RETURNING id, vehicle for rental, got %q", rental.ReturningClause())
+	}
+
+	_, err = ReadConfig(strings.NewReader("schema_config:\n  public:\n    table_config:\n      person:\n        returning: everything\n"))
+	if err == nil {
+		t.Fatal("expected an error for an invalid returning value")
+	}
+}
+
+func TestPositionalDialect(t *testing.T) {
+	tables := []GenerationTable{
+		{
+			Table: Table{
+				Schema: "public",
+				Name:   "person",
+				Columns: []Column{
+					{Name: "id", PGType: "uuid"},
+					{Name: "name", PGType: "text"},
+				},
+			},
+			Config: TableConfig{PrimaryKey: "id", BulkUpdateColumns: []string{"name"}},
+		},
+	}
+	outputBuf := &bytes.Buffer{}
+	err := generateUpdateQueries(context.TODO(), outputBuf, tables, DialectPositional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `
+
+-- name: UpdatePerson :one
+-- $1: name
+-- $2: id
+UPDATE public.person
+SET (
+        name
+) = ROW(
+        $1
+) WHERE id = $2 RETURNING *;
+
+-- name: UpdatePersonMany :many
+-- $1: name
+-- $2: ids
+UPDATE public.person
+SET
+        name = $1
+WHERE id = ANY($2) RETURNING *;`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	outputBuf.Reset()
+	err = generateUpdateQueries(context.TODO(), outputBuf, tables, DialectPggen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(outputBuf.String(), "$1") || !strings.Contains(outputBuf.String(), "-- name: UpdatePerson :one\nUPDATE public.person") || !strings.Contains(outputBuf.String(), "WHERE id = pggen.arg('id')") {
+		t.Fatalf("expected named arguments without a comment, got:\n%s", red(outputBuf.String()))
+	}
+}
+
+func TestGenerateGoStructs(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "end_date", PGType: "timestamp without time zone", Nullable: true},
+							{Name: "id", PGType: "uuid"},
+							{Name: "price", PGType: "integer"},
+							{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &Table{Schema: "public", Name: "vehicle"},
+								Column:  &Column{Name: "id"},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGoStructs(outputBuf, schemas, GeneratorConfiguration{GoPackage: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := "// Code generated by pginspector. DO NOT EDIT.\n\n" +
+		"package db\n\n" +
+		"import (\n" +
+		"\t\"github.com/google/uuid\"\n" +
+		"\t\"github.com/jackc/pgtype\"\n" +
+		")\n\n" +
+		"// Rental is a row of public.rental.\n" +
+		"type Rental struct {\n" +
+		"\tEndDate   pgtype.Timestamp `db:\"end_date\" json:\"end_date\"`\n" +
+		"\tID        uuid.UUID        `db:\"id\" json:\"id\"`\n" +
+		"\tPrice     int32            `db:\"price\" json:\"price\"`\n" +
+		"\tVehicleID uuid.UUID        `db:\"vehicle_id\" json:\"vehicle_id\"` // references public.vehicle(id)\n" +
+		"}\n"
+
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateProto(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "end_date", PGType: "timestamp without time zone", Nullable: true},
+							{Name: "id", PGType: "uuid"},
+							{Name: "notes", PGType: "text", Nullable: true},
+							{Name: "price", PGType: "integer"},
+							{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &Table{Schema: "public", Name: "vehicle"},
+								Column:  &Column{Name: "id"},
+							}},
+						},
+					},
+					Config: TableConfig{ProtoName: "rentals.v1.Rental"},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateProto(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `// Code generated by pginspector. DO NOT EDIT.
+
+syntax = "proto3";
+
+package rentals.v1;
+
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+// Rental is a row of public.rental.
+message Rental {
+  google.protobuf.Timestamp end_date = 1;
+  string id = 2;
+  google.protobuf.StringValue notes = 3;
+  int32 price = 4;
+  string vehicle_id = 5; // references public.vehicle(id)
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	err = generateProto(&bytes.Buffer{}, schemas, GeneratorConfiguration{ProtoPackage: "other.v1"})
+	if err == nil {
+		t.Fatal("expected an error when proto_name is outside proto_package")
+	}
+}
+
+func TestGenerateGraphQL(t *testing.T) {
+	vehicle := Table{
+		Schema: "public",
+		Name:   "vehicle",
+		Columns: []Column{
+			{Name: "id", PGType: "uuid"},
+			{Name: "model_year", PGType: "smallint", Nullable: true},
+		},
+	}
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "end_date", PGType: "timestamp without time zone", Nullable: true},
+							{Name: "id", PGType: "uuid"},
+							{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &vehicle,
+								Column:  &Column{Name: "id"},
+							}},
+						},
+					},
+					Config: TableConfig{PrimaryKey: "id"},
+				},
+				{Table: vehicle, Config: TableConfig{PrimaryKey: "id"}},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGraphQL(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `# Code generated by pginspector. DO NOT EDIT.
+
+scalar DateTime
+scalar UUID
+
+# Rental is a row of public.rental.
+type Rental {
+  endDate: DateTime
+  id: ID!
+  vehicleId: UUID!
+  vehicle: Vehicle! # references public.vehicle(id)
+}
+
+# Vehicle is a row of public.vehicle.
+type Vehicle {
+  id: ID!
+  modelYear: Int
+}
+
+type Query {
+  rental(id: ID!): Rental
+  rentals: [Rental!]!
+  vehicle(id: ID!): Vehicle
+  vehicles: [Vehicle!]!
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "id", PGType: "uuid", Default: "gen_random_uuid()"},
+							{Name: "notes", PGType: "text", Nullable: true},
+						},
+					},
+					Config: TableConfig{PrimaryKey: "id", DisableDelete: true},
+				},
+			},
+		},
+	}
+
+	doc := openAPIDocument(schemas)
+	collection, ok := doc.Paths["/rental"]
+	if !ok || collection.Get.OperationID != "SelectRentalList" || collection.Post.OperationID != "InsertRental" {
+		t.Fatalf("unexpected collection path: %+v", collection)
+	}
+	item, ok := doc.Paths["/rental/{id}"]
+	if !ok || item.Get.OperationID != "SelectRentalByID" || item.Put.OperationID != "UpdateRental" {
+		t.Fatalf("unexpected item path: %+v", item)
+	}
+	if item.Delete != nil {
+		t.Fatal("expected no delete operation when disable_delete is set")
+	}
+
+	row := doc.Components.Schemas["Rental"]
+	if len(row.Required) != 1 || row.Required[0] != "id" || !row.Properties["notes"].Nullable {
+		t.Fatalf("unexpected row schema: %+v", row)
+	}
+	if insert := doc.Components.Schemas["RentalInsert"]; len(insert.Required) != 0 {
+		t.Fatalf("expected columns with defaults to be optional on insert, got %v", insert.Required)
+	}
+	if _, ok := doc.Components.Schemas["RentalUpdate"].Properties["id"]; ok {
+		t.Fatal("expected the primary key to be left out of the update schema")
+	}
+
+	outputBuf := &bytes.Buffer{}
+	if err := generateOpenAPI(outputBuf, schemas, GeneratorConfiguration{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(outputBuf.String(), "openapi: 3.0.3\n") {
+		t.Fatalf("expected an OpenAPI document, got:\n%s", red(outputBuf.String()))
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "end_date", PGType: "timestamp without time zone", Nullable: true},
+							{Name: "id", PGType: "uuid"},
+							{Name: "price", PGType: "integer"},
+							{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed"}},
+							{Name: "tags", PGType: "ARRAY", UDTName: "_text", Nullable: true},
+							{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &Table{Schema: "public", Name: "vehicle"},
+								Column:  &Column{Name: "id"},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateTypeScript(outputBuf, schemas, GeneratorConfiguration{TypeScriptTimestampType: "Date"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `// Code generated by pginspector. DO NOT EDIT.
+
+export type RentalStatus = "open" | "closed";
+
+/** A row of public.rental. */
+export interface Rental {
+  end_date: Date | null;
+  id: string;
+  price: number;
+  status: RentalStatus;
+  tags: string[] | null;
+  vehicle_id: string; // references public.vehicle(id)
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	err = generateTypeScript(&bytes.Buffer{}, schemas, GeneratorConfiguration{TypeScriptTimestampType: "number"})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported typescript_timestamp_type")
+	}
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "code", PGType: "character varying", MaxLength: 12},
+							{Name: "price", PGType: "numeric", NumericPrecision: 8, NumericScale: 2},
+							{Name: "quantity", PGType: "smallint"},
+							{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", Nullable: true, EnumValues: []string{"open", "closed"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateJSONSchema(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$defs": {
+    "Rental": {
+      "title": "Rental",
+      "description": "A row of public.rental.",
+      "type": "object",
+      "properties": {
+        "code": {
+          "type": "string",
+          "maxLength": 12
+        },
+        "price": {
+          "type": "string",
+          "format": "decimal",
+          "pattern": "^-?\\d{1,6}(\\.\\d{1,2})?$"
+        },
+        "quantity": {
+          "type": "integer",
+          "format": "int32",
+          "minimum": -32768,
+          "maximum": 32767
+        },
+        "status": {
+          "type": [
+            "string",
+            "null"
+          ],
+          "enum": [
+            "open",
+            "closed",
+            null
+          ]
+        }
+      },
+      "required": [
+        "code",
+        "price",
+        "quantity",
+        "status"
+      ],
+      "additionalProperties": false
+    }
+  }
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateZod(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "code", PGType: "character varying", MaxLength: 12},
+							{Name: "id", PGType: "uuid"},
+							{Name: "started_at", PGType: "timestamp with time zone", Nullable: true},
+							{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed"}},
+							{Name: "tags", PGType: "ARRAY", UDTName: "_text"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateZod(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `// Code generated by pginspector. DO NOT EDIT.
+
+import { z } from "zod";
+
+export const RentalStatusSchema = z.enum(["open", "closed"]);
+export type RentalStatus = z.infer<typeof RentalStatusSchema>;
+
+/** A row of public.rental. */
+export const RentalSchema = z.object({
+  code: z.string().max(12),
+  id: z.string().uuid(),
+  started_at: z.string().datetime({ offset: true }).nullable(),
+  status: RentalStatusSchema,
+  tags: z.array(z.string()),
+});
+export type Rental = z.infer<typeof RentalSchema>;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func diagramTestSchemas() []GenerationSchema {
+	vehicle := Table{
+		Schema: "public",
+		Name:   "vehicle",
+		Columns: []Column{
+			{Name: "id", PGType: "uuid"},
+			{Name: "model", PGType: "text", Nullable: true},
+		},
+	}
+	return []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "end_date", PGType: "timestamp without time zone", Nullable: true},
+							{Name: "id", PGType: "uuid"},
+							{Name: "owner_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &Table{Schema: "public", Name: "owner"},
+								Column:  &Column{Name: "id"},
+							}},
+							{Name: "vehicle_id", PGType: "uuid", Relation: Relation{
+								Forward: true,
+								Table:   &vehicle,
+								Column:  &Column{Name: "id"},
+							}},
+						},
+					},
+					Config: TableConfig{PrimaryKey: "id"},
+				},
+				{Table: vehicle, Config: TableConfig{PrimaryKey: "id"}},
+			},
+		},
+	}
+}
+
+func TestGenerateMermaidDiagram(t *testing.T) {
+	outputBuf := &bytes.Buffer{}
+	err := generateDiagram(outputBuf, diagramTestSchemas(), GeneratorConfiguration{DiagramFormat: "mermaid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// owner is skipped, so its relation isn't drawn.
+	expectedOutput := `erDiagram
+    rental {
+        timestamp_without_time_zone end_date "nullable"
+        uuid id PK
+        uuid owner_id FK
+        uuid vehicle_id FK
+    }
+    vehicle {
+        uuid id PK
+        text model "nullable"
+    }
+    vehicle ||--o{ rental : "vehicle_id"
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	err = generateDiagram(&bytes.Buffer{}, diagramTestSchemas(), GeneratorConfiguration{DiagramFormat: "visio"})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported diagram format")
+	}
+}
+
+func TestGenerateDOTDiagram(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Config.Category = "fleet"
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDiagram(outputBuf, schemas, GeneratorConfiguration{
+		DiagramFormat:          "dot",
+		DiagramClusterBySchema: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `digraph schema {
+  rankdir=LR;
+  node [shape=box, style=filled, fillcolor="white"];
+  subgraph "cluster_public" {
+    label="public";
+    "public.rental" [label="rental"];
+    "public.vehicle" [label="vehicle", fillcolor="lightblue"];
+  }
+  "public.rental" -> "public.vehicle" [label="vehicle_id"];
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	colors := diagramCategoryColors(schemas, GeneratorConfiguration{DiagramCategoryColors: map[string]string{"fleet": "gold"}})
+	if colors["fleet"] != "gold" {
+		t.Fatalf("expected configured category color to be used, got %q", colors["fleet"])
+	}
+}
+
+func TestGeneratePlantUMLDiagram(t *testing.T) {
+	outputBuf := &bytes.Buffer{}
+	err := generateDiagram(outputBuf, diagramTestSchemas(), GeneratorConfiguration{DiagramFormat: "plantuml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `@startuml
+hide circle
+skinparam linetype ortho
+
+entity "public.rental" as rental {
+  * id : uuid <<PK>>
+  --
+    end_date : timestamp without time zone
+  * owner_id : uuid <<FK>>
+  * vehicle_id : uuid <<FK>>
+}
+
+entity "public.vehicle" as vehicle {
+  * id : uuid <<PK>>
+  --
+    model : text
+}
+
+rental }o--|| vehicle : vehicle_id
+@enduml
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateDBML(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Comment = "A vehicle rented to an owner."
+	schemas[0].Tables[0].Columns[0].Comment = "Null while the rental is open"
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDBML(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOutput := `Table rental {
+  end_date "timestamp without time zone" [null, note: 'Null while the rental is open']
+  id uuid [pk, not null]
+  owner_id uuid [not null]
+  vehicle_id uuid [not null]
+
+  Note: 'A vehicle rented to an owner.'
+}
+
+Table vehicle {
+  id uuid [pk, not null]
+  model text [null]
+}
+
+Ref: rental.vehicle_id > vehicle.id
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output to be:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestGenerateReport(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Comment = "Rentals <& returns>"
+
+	outputBuf := &bytes.Buffer{}
+	err := generateReport(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		`<li data-name="public.rental"><a href="#table-public-rental">public.rental</a></li>`,
+		`<a href="#table-public-vehicle">public.vehicle(id)</a>`,
+		`Referenced by: <a href="#table-public-rental">public.rental.vehicle_id</a>`,
+		`<p>Rentals &lt;&amp; returns&gt;</p>`,
+		`<line x1="106" y1="32" x2="346" y2="32"/>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected report to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateEnt(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Indexes = []Index{
+		{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+		{Name: "rental_vehicle_id_idx", Columns: []string{"vehicle_id"}},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateEnt(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"package schema\n",
+		"\t\"entgo.io/ent/schema/edge\"\n",
+		"type Rental struct {\n\tent.Schema\n}\n",
+		"\t\tentsql.Annotation{Table: \"rental\"},\n",
+		"\t\tfield.Time(\"end_date\").\n\t\t\tOptional().\n\t\t\tNillable(),\n",
+		"\t\tfield.UUID(\"id\", uuid.UUID{}),\n",
+		"\t\tedge.From(\"vehicle\", Vehicle.Type).\n\t\t\tRef(\"rentals\").\n\t\t\tField(\"vehicle_id\").\n\t\t\tUnique().\n\t\t\tRequired(),\n",
+		"\t\tindex.Fields(\"vehicle_id\").\n\t\t\tStorageKey(\"rental_vehicle_id_idx\"),\n",
+		"\t\tedge.To(\"rentals\", Rental.Type),\n",
+		"\t\tfield.Text(\"model\").\n\t\t\tOptional().\n\t\t\tNillable(),\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected ent schema to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, `edge.From("owner"`) {
+		t.Fatalf("expected no edge to the skipped owner table, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateRepositories(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Config.SoftDeleteColumn = "end_date"
+	schemas[0].Tables[1].Config.DisableDelete = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateRepositories(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"package models\n",
+		"type RentalRepository interface {\n\tGet(ctx context.Context, id uuid.UUID) (Rental, error)\n",
+		"func NewRentalRepository(db DBTX) *PgxRentalRepository {\n",
+		"err := row.Scan(&item.EndDate, &item.ID, &item.OwnerID, &item.VehicleID)\n",
+		"const selectRentalListSQL = `SELECT end_date, id, owner_id, vehicle_id FROM public.rental WHERE end_date IS NULL`\n",
+		"const deleteRentalByIDSQL = `UPDATE public.rental SET end_date = now() WHERE id = $1 AND end_date IS NULL`\n",
+		"return Vehicle{}, fmt.Errorf(\"query SelectVehicleByID: %w\", err)\n",
+		"func InTx(ctx context.Context, db TxStarter, fn func(tx pgx.Tx) error) error {\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected repositories to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "deleteVehicleByIDSQL") {
+		t.Fatalf("expected no delete for vehicle with disable_delete, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateRepositoryMocks(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Config.DisableDelete = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateRepositories(outputBuf, schemas, GeneratorConfiguration{RepositoryMocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"\t\"errors\"\n",
+		"var ErrNotMocked = errors.New(\"repository method not mocked\")\n",
+		"var _ RentalRepository = (*MockRentalRepository)(nil)\n",
+		"\tDeleteFunc   func(ctx context.Context, id uuid.UUID) error\n",
+		"\t\treturn Vehicle{}, fmt.Errorf(\"MockVehicleRepository.Get: %w\", ErrNotMocked)\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected repository mocks to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "func (m *MockVehicleRepository) Delete(") {
+		t.Fatalf("expected no Delete mock for vehicle with disable_delete, got:\n%s", red(output))
+	}
+
+	outputBuf.Reset()
+	if err := generateRepositories(outputBuf, schemas, GeneratorConfiguration{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(outputBuf.String(), "Mock") {
+		t.Fatalf("expected no mocks without repository_mocks, got:\n%s", red(outputBuf.String()))
+	}
+}
+
+func TestGenerateGoEnums(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "id", PGType: "uuid"},
+							{Name: "previous_status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed", "on-hold"}, Nullable: true},
+							{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed", "on-hold"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGoStructs(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"\t\"database/sql/driver\"\n\t\"fmt\"\n",
+		"// RentalStatus is the Postgres enum rental_status.\ntype RentalStatus string\n",
+		"\tRentalStatusOnHold RentalStatus = \"on-hold\"\n",
+		"\tcase RentalStatusOpen, RentalStatusClosed, RentalStatusOnHold:\n",
+		"func (e *RentalStatus) Scan(src interface{}) error {\n",
+		"func (n NullRentalStatus) Value() (driver.Value, error) {\n",
+		"\tPreviousStatus NullRentalStatus `db:\"previous_status\" json:\"previous_status\"`\n",
+		"\tStatus         RentalStatus     `db:\"status\" json:\"status\"`\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected Go enums to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Count(output, "type RentalStatus string") != 1 {
+		t.Fatalf("expected the enum type to be declared once, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateValidation(t *testing.T) {
+	schemas := []GenerationSchema{
+		{
+			Name: "public",
+			Tables: []GenerationTable{
+				{
+					Table: Table{
+						Schema: "public",
+						Name:   "rental",
+						Columns: []Column{
+							{Name: "code", PGType: "character varying", MaxLength: 12},
+							{Name: "discount", PGType: "integer", Nullable: true},
+							{Name: "end_date", PGType: "date", Nullable: true},
+							{Name: "kind", PGType: "character varying", MaxLength: 10},
+							{Name: "notes", PGType: "character varying", MaxLength: 200, Nullable: true},
+							{Name: "price", PGType: "numeric", NumericPrecision: 10, NumericScale: 2},
+							{Name: "quantity", PGType: "integer"},
+							{Name: "start_date", PGType: "date"},
+							{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed"}},
+						},
+						Constraints: []Constraint{
+							{Name: "rental_code_check", Type: ConstraintCheck, Definition: "CHECK ((char_length((code)::text) >= 3))"},
+							{Name: "rental_dates_check", Type: ConstraintCheck, Definition: "CHECK ((end_date > start_date))"},
+							{Name: "rental_discount_check", Type: ConstraintCheck, Definition: "CHECK (((discount >= 0) AND (discount <= 100)))"},
+							{Name: "rental_kind_check", Type: ConstraintCheck, Definition: "CHECK (((kind)::text = ANY ((ARRAY['daily'::character varying, 'weekly'::character varying])::text[])))"},
+							{Name: "rental_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (code)"},
+							{Name: "rental_quantity_check", Type: ConstraintCheck, Definition: "CHECK ((quantity > 0))"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateValidation(outputBuf, schemas, GeneratorConfiguration{GoPackage: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"package db\n",
+		"\t\"github.com/jackc/pgtype\"\n",
+		"\t\"unicode/utf8\"\n",
+		"func (r Rental) Validate() error {\n",
+		"\tif utf8.RuneCountInString(r.Code) > 12 {\n\t\terrs = append(errs, ValidationError{Column: \"code\", Message: \"must be at most 12 characters\"})\n",
+		"\tif r.Notes.Status == pgtype.Present && utf8.RuneCountInString(r.Notes.String) > 200 {\n",
+		"\tif r.Price.Status != pgtype.Present {\n\t\terrs = append(errs, ValidationError{Column: \"price\", Message: \"must not be null\"})\n",
+		"\tif !r.Status.Valid() {\n\t\terrs = append(errs, ValidationError{Column: \"status\", Message: \"must be one of open, closed\"})\n",
+		"\tif utf8.RuneCountInString(r.Code) < 3 {\n",
+		"\tif r.Discount.Status == pgtype.Present && ((r.Discount.Int < 0) || (r.Discount.Int > 100)) {\n",
+		"\tif r.Kind != \"daily\" && r.Kind != \"weekly\" {\n",
+		"\tif r.Quantity <= 0 {\n\t\terrs = append(errs, ValidationError{Constraint: \"rental_quantity_check\", Message: \"violates check constraint rental_quantity_check (quantity > 0)\"})\n",
+		"\t// Not validated: rental_dates_check CHECK ((end_date > start_date))\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected validation to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "rental_pkey") {
+		t.Fatalf("expected only CHECK constraints to be validated, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateDDL(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Columns = append(rental.Columns,
+		Column{Name: "number", PGType: "integer", Default: "nextval('rental_number_seq'::regclass)", Position: 5},
+		Column{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", UDTSchema: "public", EnumValues: []string{"open", "it's closed"}, Position: 6},
+	)
+	rental.Comment = "Vehicle rentals"
+	rental.Constraints = []Constraint{
+		{Name: "rental_owner_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (owner_id) REFERENCES owner(id)"},
+		{Name: "rental_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"},
+		{Name: "rental_vehicle_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (vehicle_id) REFERENCES vehicle(id)"},
+	}
+	rental.Indexes = []Index{
+		{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Definition: "CREATE UNIQUE INDEX rental_pkey ON public.rental USING btree (id)"},
+		{Name: "rental_vehicle_id_idx", Columns: []string{"vehicle_id"}, Definition: "CREATE INDEX rental_vehicle_id_idx ON public.rental USING btree (vehicle_id)"},
+	}
+	vehicle := &schemas[0].Tables[1]
+	vehicle.Columns[1].MaxLength = 80
+	vehicle.Columns[1].PGType = "character varying"
+	vehicle.Constraints = []Constraint{
+		{Name: "vehicle_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDDL(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE TYPE public.rental_status AS ENUM ('open', 'it''s closed');\n",
+		"CREATE TABLE public.vehicle (\n    id uuid NOT NULL,\n    model character varying(80),\n    CONSTRAINT vehicle_pkey PRIMARY KEY (id)\n);\n",
+		"    number serial NOT NULL,\n",
+		"    status public.rental_status NOT NULL,\n",
+		"    CONSTRAINT rental_vehicle_id_fkey FOREIGN KEY (vehicle_id) REFERENCES vehicle(id)\n);\n",
+		"CREATE INDEX rental_vehicle_id_idx ON public.rental USING btree (vehicle_id);\n",
+		"COMMENT ON TABLE public.rental IS 'Vehicle rentals';\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected DDL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Index(output, "CREATE TABLE public.vehicle") > strings.Index(output, "CREATE TABLE public.rental") {
+		t.Fatalf("expected vehicle to be created before the rental table that references it, got:\n%s", red(output))
+	}
+	if strings.Contains(output, "CREATE UNIQUE INDEX rental_pkey") {
+		t.Fatalf("expected no separate index for the primary key, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateDDLReferenceCycle(t *testing.T) {
+	schemas := diagramTestSchemas()
+	vehicle := &schemas[0].Tables[1]
+	vehicle.Columns = append(vehicle.Columns, Column{Name: "current_rental_id", PGType: "uuid", Nullable: true, Relation: Relation{
+		Forward: true,
+		Table:   &schemas[0].Tables[0].Table,
+		Column:  &Column{Name: "id"},
+	}})
+	vehicle.Constraints = []Constraint{
+		{Name: "vehicle_current_rental_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (current_rental_id) REFERENCES rental(id)"},
+	}
+	schemas[0].Tables[0].Constraints = []Constraint{
+		{Name: "rental_vehicle_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (vehicle_id) REFERENCES vehicle(id)"},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDDL(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	expected := "\nALTER TABLE public.rental ADD CONSTRAINT rental_vehicle_id_fkey FOREIGN KEY (vehicle_id) REFERENCES vehicle(id);\n"
+	if !strings.HasSuffix(output, expected) {
+		t.Fatalf("expected the foreign key closing the cycle to be added last:\n%s\nbut got:\n%s", green(expected), red(output))
+	}
+}
+
+func TestWriteMigrations(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "0007_init.up.sql"), []byte("SELECT 1;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Constraints = []Constraint{
+		{Name: "rental_vehicle_id_fkey", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (vehicle_id) REFERENCES vehicle(id)"},
+	}
+	paths, err := writeMigrations(dir, []string{"ddl"}, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{filepath.Join(dir, "0008_schema.up.sql"), filepath.Join(dir, "0008_schema.down.sql")}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("expected migration files %v, got %v", expectedPaths, paths)
+	}
+
+	up, err := os.ReadFile(expectedPaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(up), "CREATE TABLE public.vehicle (\n") {
+		t.Fatalf("expected the up migration to create tables, got:\n%s", red(string(up)))
+	}
+	down, err := os.ReadFile(expectedPaths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDown := "DROP TABLE IF EXISTS public.rental;\nDROP TABLE IF EXISTS public.vehicle;\n"
+	if string(down) != expectedDown {
+		t.Fatalf("expected down migration:\n%s\nbut got:\n%s", green(expectedDown), red(string(down)))
+	}
+
+	_, err = writeMigrations(dir, []string{"nope"}, schemas, GeneratorConfiguration{})
+	if err == nil || !strings.Contains(err.Error(), `Unknown migration source "nope"`) {
+		t.Fatalf("expected an unknown source error, got %v", err)
+	}
+}
+
+func TestWriteFlywayMigrations(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "V3__init.sql"), []byte("SELECT 1;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := diagramTestSchemas()
+	paths, err := writeFlywayMigrations(dir, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{filepath.Join(dir, "V4__baseline.sql")}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("expected migration files %v, got %v", expectedPaths, paths)
+	}
+
+	paths, err = writeFlywayMigrations(dir, schemas, GeneratorConfiguration{FlywaySplitTables: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths = []string{filepath.Join(dir, "V5__create_vehicle.sql"), filepath.Join(dir, "V6__create_rental.sql")}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("expected a migration per table in dependency order %v, got %v", expectedPaths, paths)
+	}
+	rental, err := os.ReadFile(expectedPaths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(rental), "CREATE TABLE public.rental (\n") {
+		t.Fatalf("expected the migration to create the table, got:\n%s", red(string(rental)))
+	}
+}
+
+func TestGenerateAudit(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Config.GenerateAudit = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateAudit(outputBuf, schemas, GeneratorConfiguration{AuditActorSetting: "request.jwt.claim.sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE TABLE IF NOT EXISTS audit.record_version (\n",
+		"CREATE OR REPLACE FUNCTION audit.record_change() RETURNS trigger\n",
+		"coalesce(nullif(current_setting('request.jwt.claim.sub', true), ''), current_user)",
+		"CREATE TRIGGER audit_record_change\n    AFTER INSERT OR UPDATE OR DELETE ON public.rental\n    FOR EACH ROW EXECUTE FUNCTION audit.record_change('id');\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected audit SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "public.vehicle") {
+		t.Fatalf("expected no trigger on vehicle without generate_audit, got:\n%s", red(output))
+	}
+
+	migration, err := auditMigration(schemas, GeneratorConfiguration{AuditSchema: "history"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDown := "DROP TRIGGER IF EXISTS audit_record_change ON public.rental;\n" +
+		"DROP FUNCTION IF EXISTS history.record_change();\n" +
+		"DROP TABLE IF EXISTS history.record_version;\n"
+	if migration.Down != expectedDown {
+		t.Fatalf("expected down migration:\n%s\nbut got:\n%s", green(expectedDown), red(migration.Down))
+	}
+
+	if err := generateAudit(&bytes.Buffer{}, diagramTestSchemas(), GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error when no table has generate_audit set")
+	}
+}
+
+func TestGenerateUpdatedAt(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Columns = append(rental.Columns, Column{Name: "modified_at", PGType: "timestamp with time zone"})
+	vehicle := &schemas[0].Tables[1]
+	vehicle.Columns = append(vehicle.Columns, Column{Name: "modified_at", PGType: "timestamp with time zone"})
+	vehicle.Triggers = []Trigger{{
+		Name:           "touch_vehicle",
+		Function:       "public.touch",
+		FunctionSource: "\nBEGIN\n  NEW.modified_at = clock_timestamp();\n  RETURN NEW;\nEND;\n",
+		Definition:     "CREATE TRIGGER touch_vehicle BEFORE INSERT OR UPDATE ON public.vehicle FOR EACH ROW EXECUTE FUNCTION touch()",
+	}}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateUpdatedAt(outputBuf, schemas, GeneratorConfiguration{UpdatedAtColumn: "modified_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE OR REPLACE FUNCTION public.set_updated_at() RETURNS trigger\n",
+		"    NEW.modified_at := now();\n",
+		"CREATE TRIGGER set_updated_at\n    BEFORE UPDATE ON public.rental\n    FOR EACH ROW EXECUTE FUNCTION public.set_updated_at();\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected updated_at SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "public.vehicle") {
+		t.Fatalf("expected vehicle to be skipped since it already maintains modified_at, got:\n%s", red(output))
+	}
+
+	// An AFTER trigger doesn't maintain the column.
+	vehicle.Triggers[0].Definition = "CREATE TRIGGER touch_vehicle AFTER UPDATE ON public.vehicle FOR EACH ROW EXECUTE FUNCTION touch()"
+	if hasUpdatedAtTrigger(&vehicle.Table, "modified_at") {
+		t.Fatal("expected an AFTER UPDATE trigger not to count as an updated_at trigger")
+	}
+
+	err = generateUpdatedAt(&bytes.Buffer{}, schemas, GeneratorConfiguration{})
+	if err == nil {
+		t.Fatal("expected an error when no table has an updated_at column")
+	}
+}
+
+func TestGenerateHistory(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Config.GenerateHistory = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateHistory(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE TABLE IF NOT EXISTS public.vehicle_history (\n    id uuid NOT NULL,\n    model text,\n    valid_from timestamp with time zone,\n    valid_to timestamp with time zone NOT NULL DEFAULT now(),\n    operation text NOT NULL\n);\n",
+		"CREATE INDEX IF NOT EXISTS vehicle_history_id_idx ON public.vehicle_history (id, valid_to);\n",
+		"    INSERT INTO public.vehicle_history (id, model, valid_from, valid_to, operation)\n" +
+			"    VALUES (OLD.id, OLD.model, (SELECT max(h.valid_to) FROM public.vehicle_history h WHERE h.id = OLD.id), now(), TG_OP);\n",
+		"CREATE TRIGGER record_history\n    AFTER UPDATE OR DELETE ON public.vehicle\n    FOR EACH ROW EXECUTE FUNCTION public.vehicle_record_history();\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected history SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "public.rental") {
+		t.Fatalf("expected no history for rental without generate_history, got:\n%s", red(output))
+	}
+
+	if err := generateHistory(&bytes.Buffer{}, diagramTestSchemas(), GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error when no table has generate_history set")
+	}
+}
+
+func TestGenerateOutbox(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Config.GenerateOutbox = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateOutbox(outputBuf, schemas, GeneratorConfiguration{OutboxSchema: "events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE SCHEMA IF NOT EXISTS events;\n",
+		"CREATE TABLE IF NOT EXISTS events.outbox (\n",
+		"CREATE OR REPLACE FUNCTION events.outbox_record_event() RETURNS trigger\n",
+		"CREATE TRIGGER outbox_record_event\n    AFTER INSERT OR UPDATE OR DELETE ON public.rental\n    FOR EACH ROW EXECUTE FUNCTION events.outbox_record_event('id', 'owner_id', 'owner', 'vehicle_id', 'vehicle');\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected outbox SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "ON public.vehicle") {
+		t.Fatalf("expected no trigger on vehicle without generate_outbox, got:\n%s", red(output))
+	}
+
+	if err := generateOutbox(&bytes.Buffer{}, diagramTestSchemas(), GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error when no table has generate_outbox set")
+	}
+}
+
+func TestGenerateRLS(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Config.RLSColumn = "owner_id"
+
+	outputBuf := &bytes.Buffer{}
+	err := generateRLS(outputBuf, schemas, GeneratorConfiguration{RLSSetting: "app.user_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"ALTER TABLE public.rental ENABLE ROW LEVEL SECURITY;\n",
+		"CREATE POLICY rental_select ON public.rental FOR SELECT\n    USING (owner_id = current_setting('app.user_id', true)::uuid);\n",
+		"CREATE POLICY rental_insert ON public.rental FOR INSERT\n    WITH CHECK (owner_id = current_setting('app.user_id', true)::uuid);\n",
+		"CREATE POLICY rental_update ON public.rental FOR UPDATE\n    USING (owner_id = current_setting('app.user_id', true)::uuid)\n    WITH CHECK (owner_id = current_setting('app.user_id', true)::uuid);\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected RLS SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+
+	outputBuf.Reset()
+	if err := generateRLS(outputBuf, schemas, GeneratorConfiguration{RLSStyle: RLSStyleSupabase}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "CREATE POLICY rental_delete ON public.rental FOR DELETE\n    USING (owner_id = auth.uid());\n"
+	if !strings.Contains(outputBuf.String(), expected) {
+		t.Fatalf("expected RLS SQL to contain:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+	}
+
+	schemas[0].Tables[0].Config.RLSColumn = "tenant_id"
+	if err := generateRLS(&bytes.Buffer{}, schemas, GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error for a missing rls_column")
+	}
+}
+
+func TestGenerateGrants(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Columns[0].Default = "nextval('vehicle_id_seq'::regclass)"
+	schemas[0].Tables[1].Config.Grants = map[string]string{"app": AccessWrite, "reporting": AccessNone}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateGrants(outputBuf, schemas, GeneratorConfiguration{Roles: map[string]string{"reporting": AccessRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Vehicle is referenced by rental, so it's granted first.
+	expectedOutput := `-- Code generated by pginspector. DO NOT EDIT.
+
+-- app
+GRANT USAGE ON SCHEMA public TO app;
+REVOKE ALL ON public.vehicle FROM app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON public.vehicle TO app;
+GRANT USAGE, SELECT ON SEQUENCE vehicle_id_seq TO app;
+REVOKE ALL ON public.rental FROM app;
+
+-- reporting
+GRANT USAGE ON SCHEMA public TO reporting;
+REVOKE ALL ON public.vehicle FROM reporting;
+REVOKE ALL ON public.rental FROM reporting;
+GRANT SELECT ON public.rental TO reporting;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected grants:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	err = generateGrants(&bytes.Buffer{}, schemas, GeneratorConfiguration{Roles: map[string]string{"reporting": "admin"}})
+	if err == nil {
+		t.Fatal("expected an error for an unknown access level")
+	}
+}
+
+func TestGenerateComments(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Comment = "Rentals"
+	rental.Config.Description = "A vehicle rented by an owner"
+	rental.Config.ColumnDescriptions = map[string]string{"end_date": "When the vehicle is returned, if it's known"}
+
+	migration, err := commentMigration(schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUp := "COMMENT ON TABLE public.rental IS 'A vehicle rented by an owner';\n" +
+		"COMMENT ON COLUMN public.rental.end_date IS 'When the vehicle is returned, if it''s known';\n"
+	if migration.Up != expectedUp {
+		t.Fatalf("expected up migration:\n%s\nbut got:\n%s", green(expectedUp), red(migration.Up))
+	}
+	expectedDown := "COMMENT ON TABLE public.rental IS 'Rentals';\n" +
+		"COMMENT ON COLUMN public.rental.end_date IS NULL;\n"
+	if migration.Down != expectedDown {
+		t.Fatalf("expected down migration:\n%s\nbut got:\n%s", green(expectedDown), red(migration.Down))
+	}
+
+	rental.Config.ColumnDescriptions["start_date"] = "When the rental begins"
+	if err := generateComments(&bytes.Buffer{}, schemas, GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error for a description of a missing column")
+	}
+	if err := generateComments(&bytes.Buffer{}, diagramTestSchemas(), GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error when no descriptions are configured")
+	}
+}
+
+func TestGeneratePgTAP(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables = schemas[0].Tables[:1]
+	rental := &schemas[0].Tables[0]
+	rental.Indexes = []Index{
+		{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+		{Name: "rental_vehicle_id_end_date_key", Columns: []string{"vehicle_id", "end_date"}, Unique: true},
+	}
+	rental.Constraints = []Constraint{{Name: "rental_vehicle_id_end_date_key", Type: ConstraintUnique, Definition: "UNIQUE (vehicle_id, end_date)"}}
+
+	outputBuf := &bytes.Buffer{}
+	err := generatePgTAP(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"BEGIN;\nSELECT plan(17);\n\n",
+		"SELECT has_table('public', 'rental', 'Table public.rental should exist');\n",
+		"SELECT col_type_is('public', 'rental', 'end_date', 'timestamp without time zone', 'Column public.rental.end_date should be type timestamp without time zone');\n",
+		"SELECT col_is_null('public', 'rental', 'end_date', 'Column public.rental.end_date should allow NULL');\n",
+		"SELECT col_not_null('public', 'rental', 'id', 'Column public.rental.id should be NOT NULL');\n",
+		"SELECT col_is_pk('public', 'rental', ARRAY['id'], 'Table public.rental should have primary key (id)');\n",
+		"SELECT col_is_unique('public', 'rental', ARRAY['vehicle_id', 'end_date'], 'Table public.rental should have unique constraint (vehicle_id, end_date)');\n",
+		"SELECT fk_ok('public', 'rental', 'vehicle_id', 'public', 'vehicle', 'id', 'Column public.rental.vehicle_id should reference public.vehicle(id)');\n",
+		"\nSELECT * FROM finish();\nROLLBACK;\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected pgTAP tests to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGeneratePartitions(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Config.Partitions = PartitionConfig{Interval: "month", Retention: 12, CronSchedule: "0 3 * * *"}
+
+	if err := generatePartitions(&bytes.Buffer{}, schemas, GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error for a table that isn't partitioned")
+	}
+
+	rental.Partitioning = &Partitioning{Strategy: PartitionRange, Columns: []string{"end_date"}}
+	outputBuf := &bytes.Buffer{}
+	err := generatePartitions(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"CREATE OR REPLACE FUNCTION public.rental_maintain_partitions() RETURNS void\n",
+		"    FOR i IN 0 .. 3 LOOP\n        period_start := date_trunc('month', now()) + i * interval '1 month';\n",
+		"        EXECUTE format('CREATE TABLE IF NOT EXISTS %I.%I PARTITION OF %I.%I FOR VALUES FROM (%L) TO (%L)',\n" +
+			"            'public', partition_name, 'public', 'rental', period_start, period_start + interval '1 month');\n",
+		"            AND c.relname ~ '^rental_p[0-9]{8}$'\n" +
+			"            AND to_date(right(c.relname, 8), 'YYYYMMDD') < date_trunc('month', now()) - 12 * interval '1 month'\n",
+		"SELECT cron.schedule('public.rental_maintain_partitions', '0 3 * * *', 'SELECT public.rental_maintain_partitions()');\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected partition SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+
+	rental.Config.Partitions.Interval = "quarter"
+	if err := generatePartitions(&bytes.Buffer{}, schemas, GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error for an unknown interval")
+	}
+}
+
+func TestGenerateHandlers(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Config.DisableDelete = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateHandlers(outputBuf, schemas, GeneratorConfiguration{GoPackage: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"package api\n",
+		"\t\"github.com/google/uuid\"\n",
+		"\tmux.HandleFunc(\"GET /rental\", h.List)\n",
+		"\tmux.HandleFunc(\"PATCH /rental/{id}\", h.Patch)\n",
+		"\tmux.HandleFunc(\"DELETE /rental/{id}\", h.Delete)\n",
+		"\titems, err := h.Repo.ListPage(r.Context(), limit, offset)\n",
+		"func parseRentalKey(s string) (uuid.UUID, error) {\n\treturn uuid.Parse(s)\n}\n",
+		"\t\tcase \"vehicle_id\":\n\t\t\terr = json.Unmarshal(value, &item.VehicleID)\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected handlers to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "DELETE /vehicle/{id}") {
+		t.Fatalf("expected no delete route for vehicle with disable_delete, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateProtoServices(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[1].Config.DisableDelete = true
+
+	outputBuf := &bytes.Buffer{}
+	err := generateProto(outputBuf, schemas, GeneratorConfiguration{ProtoPackage: "rentals.v1", ProtoServices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"import \"google/protobuf/empty.proto\";\nimport \"google/protobuf/field_mask.proto\";\n",
+		"service RentalService {\n" +
+			"  rpc GetRental(GetRentalRequest) returns (Rental);\n" +
+			"  rpc ListRentals(ListRentalsRequest) returns (ListRentalsResponse);\n" +
+			"  rpc UpdateRental(UpdateRentalRequest) returns (Rental);\n" +
+			"  rpc DeleteRental(DeleteRentalRequest) returns (google.protobuf.Empty);\n" +
+			"}\n",
+		"message GetRentalRequest {\n  string id = 1;\n}\n",
+		"message ListRentalsResponse {\n  repeated Rental rentals = 1;\n",
+		"message UpdateRentalRequest {\n  // The row to update, identified by its id.\n  Rental rental = 1;\n",
+		"  google.protobuf.FieldMask update_mask = 2;\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected proto services to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "DeleteVehicle") {
+		t.Fatalf("expected no DeleteVehicle RPC with disable_delete, got:\n%s", red(output))
+	}
+}
+
+func TestGeneratePostgREST(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Config.SoftDeleteColumn = "end_date"
+
+	outputBuf := &bytes.Buffer{}
+	err := generatePostgREST(outputBuf, schemas, GeneratorConfiguration{
+		PostgRESTRoles: map[string]string{"web_anon": AccessRead, "web_user": AccessWrite},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"-- PostgREST configuration:\n--   db-schemas = \"api\"\n--   db-anon-role = \"web_anon\"\n",
+		"CREATE SCHEMA IF NOT EXISTS api;\n",
+		"CREATE OR REPLACE VIEW api.rental WITH (security_invoker = true) AS\n    SELECT end_date, id, owner_id, vehicle_id\n    FROM public.rental\n    WHERE end_date IS NULL;\n",
+		"CREATE OR REPLACE VIEW api.vehicle WITH (security_invoker = true) AS\n    SELECT id, model\n    FROM public.vehicle;\n",
+		"GRANT SELECT ON api.rental TO web_anon;\n",
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON api.vehicle TO web_user;\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected PostgREST SQL to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+
+	schemas = append(schemas, GenerationSchema{Name: "archive", Tables: []GenerationTable{
+		{Table: Table{Schema: "archive", Name: "rental", Columns: []Column{{Name: "id", PGType: "uuid"}}}},
+	}})
+	if err := generatePostgREST(&bytes.Buffer{}, schemas, GeneratorConfiguration{}); err == nil {
+		t.Fatal("expected an error for tables exposed under the same view name")
+	}
+}
+
+func TestGeneratePrisma(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Columns = append(rental.Columns, Column{
+		Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", UDTSchema: "public",
+		EnumValues: []string{"active", "in-review"}, Default: "'active'::rental_status",
+	})
+	schemas[0].Tables[1].Columns[0].Default = "gen_random_uuid()"
+
+	outputBuf := &bytes.Buffer{}
+	err := generatePrisma(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	// owner isn't selected, so owner_id stays a plain field.
+	for _, expected := range []string{
+		"model Rental {\n" +
+			"  end_date   DateTime?    @db.Timestamp(6)\n" +
+			"  id         String       @id @db.Uuid\n" +
+			"  owner_id   String       @db.Uuid\n" +
+			"  vehicle_id String       @db.Uuid\n" +
+			"  status     RentalStatus @default(active)\n" +
+			"  vehicle    Vehicle      @relation(fields: [vehicle_id], references: [id])\n" +
+			"\n  @@map(\"rental\")\n}\n",
+		"  id      String   @id @default(dbgenerated(\"gen_random_uuid()\")) @db.Uuid\n",
+		"  rentals Rental[]\n",
+		"enum RentalStatus {\n  active\n  in_review @map(\"in-review\")\n\n  @@map(\"rental_status\")\n}\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected Prisma schema to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+	if strings.Contains(output, "multiSchema") {
+		t.Fatalf("expected no multiSchema preview feature for the public schema, got:\n%s", red(output))
+	}
+}
+
+func TestGenerateLiquibase(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Indexes = []Index{{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true}}
+	rental.Constraints = []Constraint{
+		{Name: "rental_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"},
+		{Name: "rental_vehicle_fk", Type: ConstraintForeignKey, Definition: "FOREIGN KEY (vehicle_id) REFERENCES vehicle(id)"},
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateLiquibase(outputBuf, schemas, GeneratorConfiguration{LiquibaseAuthor: "dba"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	// vehicle is referenced by rental, so it's created first.
+	vehicle := strings.Index(output, "id: create-table-public.vehicle\n      author: dba\n")
+	if vehicle < 0 || vehicle > strings.Index(output, "id: create-table-public.rental\n") {
+		t.Fatalf("expected vehicle to be created before rental, got:\n%s", red(output))
+	}
+	for _, expected := range []string{
+		"              - column:\n" +
+			"                  name: id\n" +
+			"                  type: uuid\n" +
+			"                  constraints:\n" +
+			"                    nullable: false\n" +
+			"                    primaryKey: true\n" +
+			"                    primaryKeyName: rental_pkey\n",
+		"        - addForeignKeyConstraint:\n" +
+			"            baseTableSchemaName: public\n" +
+			"            baseTableName: rental\n" +
+			"            baseColumnNames: vehicle_id\n" +
+			"            constraintName: rental_vehicle_fk\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected Liquibase changelog to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateAvro(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Columns = append(schemas[0].Tables[0].Columns,
+		Column{Name: "price", PGType: "numeric", NumericPrecision: 10, NumericScale: 2},
+		Column{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "closed"}},
+	)
+
+	outputBuf := &bytes.Buffer{}
+	err := generateAvro(outputBuf, schemas, GeneratorConfiguration{AvroNamespace: "com.example.rentals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		`"name": "Rental",
+    "namespace": "com.example.rentals",`,
+		`"name": "end_date",
+        "type": [
+          "null",
+          {
+            "type": "long",
+            "logicalType": "local-timestamp-micros"
+          }
+        ],
+        "default": null`,
+		`"type": {
+          "type": "string",
+          "logicalType": "uuid"
+        }`,
+		`"type": {
+          "type": "bytes",
+          "logicalType": "decimal",
+          "precision": 10,
+          "scale": 2
+        }`,
+		`"type": {
+          "type": "enum",
+          "name": "RentalStatus",
+          "symbols": [
+            "open",
+            "closed"
+          ]
+        }`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateDebezium(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Columns = append(schemas[0].Tables[0].Columns, Column{Name: "price", PGType: "numeric"})
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDebezium(outputBuf, schemas, GeneratorConfiguration{
+		DebeziumName:   "rentals",
+		DebeziumConfig: map[string]string{"snapshot.mode": "never"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		`"name": "rentals",`,
+		`"table.include.list": "public\\.rental,public\\.vehicle",`,
+		`"message.key.columns": "public\\.rental:id;public\\.vehicle:id",`,
+		`"column.propagate.source.type": "public\\.rental\\.price",`,
+		`"snapshot.mode": "never",`,
+		`"topic.prefix": "rentals"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateSQLAlchemy(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Columns = append(schemas[0].Tables[0].Columns,
+		Column{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "in use"}},
+		Column{Name: "class", PGType: "numeric", NumericPrecision: 10, NumericScale: 2},
+	)
+
+	outputBuf := &bytes.Buffer{}
+	err := generateSQLAlchemy(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"import uuid\nfrom typing import List, Optional\n\nfrom sqlalchemy import ",
+		"class RentalStatus(enum.Enum):\n    open = \"open\"\n    in_use = \"in use\"\n",
+		"    end_date: Mapped[Optional[datetime.datetime]] = mapped_column(DateTime)\n",
+		"    id: Mapped[uuid.UUID] = mapped_column(Uuid, primary_key=True)\n",
+		"    vehicle_id: Mapped[uuid.UUID] = mapped_column(Uuid, ForeignKey(\"public.vehicle.id\"))\n",
+		"    _class: Mapped[decimal.Decimal] = mapped_column(\"class\", Numeric(10, 2))\n",
+		"    vehicle: Mapped[Vehicle] = relationship(back_populates=\"rentals\")\n",
+		"    rentals: Mapped[List[Rental]] = relationship(back_populates=\"vehicle\")\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateKysely(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Columns[1].Default = "gen_random_uuid()"
+	schemas[0].Tables[0].Columns = append(schemas[0].Tables[0].Columns,
+		Column{Name: "days", PGType: "integer", GenerationExpression: "1"},
+	)
+	schemas = append(schemas, GenerationSchema{
+		Name:   "billing",
+		Tables: []GenerationTable{{Table: Table{Schema: "billing", Name: "invoice", Columns: []Column{{Name: "id", PGType: "bigint", Identity: "BY DEFAULT"}}}}},
+	})
+
+	outputBuf := &bytes.Buffer{}
+	err := generateKysely(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		`import type { Generated, GeneratedAlways, Insertable, Selectable, Updateable } from "kysely";`,
+		"export interface RentalTable {\n  end_date: string | null;\n  id: Generated<string>;\n",
+		"  days: GeneratedAlways<number>;\n",
+		"export type NewRental = Insertable<RentalTable>;\n",
+		"export interface Database {\n  rental: RentalTable;\n  vehicle: VehicleTable;\n  \"billing.invoice\": BillingInvoiceTable;\n}\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+func TestGenerateDjango(t *testing.T) {
+	schemas := diagramTestSchemas()
+	schemas[0].Tables[0].Columns = append(schemas[0].Tables[0].Columns,
+		Column{Name: "status", PGType: "USER-DEFINED", UDTName: "rental_status", EnumValues: []string{"open", "in use"}},
+	)
+	schemas[0].Tables[0].Indexes = []Index{{Name: "rental_vehicle_id_end_date_key", Columns: []string{"vehicle_id", "end_date"}, Unique: true}}
+	schemas[0].Tables[0].Constraints = []Constraint{{Name: "rental_vehicle_id_end_date_key", Type: ConstraintUnique}}
+
+	outputBuf := &bytes.Buffer{}
+	err := generateDjango(outputBuf, schemas, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+
+	for _, expected := range []string{
+		"class RentalStatus(models.TextChoices):\n    OPEN = \"open\"\n    IN_USE = \"in use\"\n",
+		"    end_date = models.DateTimeField(blank=True, null=True)\n",
+		"    id = models.UUIDField(primary_key=True)\n",
+		"    vehicle = models.ForeignKey(\"Vehicle\", models.DO_NOTHING, related_name=\"rentals\")\n",
+		"    status = models.CharField(max_length=6, choices=RentalStatus.choices)\n",
+		"        managed = False\n        db_table = \"rental\"\n        unique_together = ((\"vehicle\", \"end_date\"),)\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(output))
+		}
+	}
+}
+
+// fakeRows serves canned rows. Methods the extraction doesn't use panic.
+type fakeRows struct {
+	pgx.Rows
+	rows [][]interface{}
+	row  []interface{}
+}
+
+func (r *fakeRows) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	r.row, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *fakeRows) Values() ([]interface{}, error) { return r.row, nil }
+func (r *fakeRows) Err() error                     { return nil }
+func (r *fakeRows) Close()                         {}
+
+var fakeLookupPattern = regexp.MustCompile(`FROM "([^"]+)"\."([^"]+)" WHERE "([^"]+)" = \$1`)
+
+// fakeConditionPattern matches the columns compared by a lookup.
+var fakeConditionPattern = regexp.MustCompile(`"([^"]+)" = \$\d+`)
+
+// fakeSamplePattern matches the lookups of samples without a predicate.
+var fakeSamplePattern = regexp.MustCompile(`FROM "([^"]+)"\."([^"]+)"(?: TABLESAMPLE BERNOULLI \(([\d.]+)\))? WHERE TRUE(?: ORDER BY random\(\) LIMIT (\d+))?$`)
+
+// fakePlanPattern matches the subqueries of an extraction plan.
+var fakePlanPattern = regexp.MustCompile(`"plan_\d+" AS \(SELECT \* FROM "([^"]+)"\."([^"]+)"`)
+
+// fakeExtractQuerier answers extraction lookups from rows per table, given
+// in the order of the table's columns.
+type fakeExtractQuerier struct {
+	schemas []GenerationSchema
+	rows    map[string][][]interface{}
+	mu      sync.Mutex
+	queries []string
+	// failAfter fails the lookups after that many when set.
+	failAfter int
+}
+
+func (q *fakeExtractQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	q.mu.Lock()
+	q.queries = append(q.queries, sql)
+	failed := q.failAfter > 0 && len(q.queries) > q.failAfter
+	q.mu.Unlock()
+	if failed {
+		return nil, errors.New("connection lost")
+	}
+	if strings.HasPrefix(sql, "WITH ") {
+		// Plans count every row of the tables they select from.
+		row := []interface{}{}
+		for _, subquery := range fakePlanPattern.FindAllStringSubmatch(sql, -1) {
+			row = append(row, int64(len(q.rows[subquery[1]+"."+subquery[2]])))
+		}
+		return &fakeRows{rows: [][]interface{}{row}}, nil
+	}
+	if sample := fakeSamplePattern.FindStringSubmatch(sql); sample != nil {
+		// Sampling takes the first rows, so the samples are predictable.
+		rows := q.rows[sample[1]+"."+sample[2]]
+		if percent, err := strconv.ParseFloat(sample[3], 64); err == nil {
+			rows = rows[:int(float64(len(rows))*percent/100)]
+		}
+		if limit, err := strconv.Atoi(sample[4]); err == nil && limit < len(rows) {
+			rows = rows[:limit]
+		}
+		return &fakeRows{rows: rows}, nil
+	}
+	match := fakeLookupPattern.FindStringSubmatch(sql)
+	table, err := extractSeedTable(q.schemas, match[1]+"."+match[2])
+	if err != nil {
+		return nil, err
+	}
+	// Lookups by several columns compare each with its argument.
+	conditions := fakeConditionPattern.FindAllStringSubmatch(sql[strings.Index(sql, " WHERE "):], -1)
+	columns := make([]int, len(conditions))
+	for j, condition := range conditions {
+		for i, c := range table.Columns {
+			if c.Name == condition[1] {
+				columns[j] = i
+			}
+		}
+	}
+	result := &fakeRows{}
+	for _, row := range q.rows[match[1]+"."+match[2]] {
+		matches := true
+		for j, column := range columns {
+			matches = matches && fmt.Sprint(row[column]) == fmt.Sprint(args[j])
+		}
+		if matches {
+			result.rows = append(result.rows, row)
+		}
+	}
+	return result, nil
+}
+
+func TestExtract(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}, {"v2", "Model A"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+			{nil, "r3", "o1", "v2"},
+		},
+	}}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(q.queries[0], `WHERE "id" = $1::text::uuid`) {
+		t.Fatalf("expected the seed value to be bound as text, got %s", q.queries[0])
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `-- Extracted by pginspector from public.vehicle where id = 'v1'.
+
+BEGIN;
+
+SET CONSTRAINTS ALL DEFERRED;
+
+INSERT INTO public.vehicle (id, model) VALUES
+    ('v1', 'Model T');
+
+INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r1', 'o1', 'v1'),
+    (NULL, 'r2', 'o1', 'v1');
+
+COMMIT;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	outputBuf.Reset()
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{DisableTriggers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(outputBuf.String(), "SET CONSTRAINTS ALL DEFERRED;\nSET LOCAL session_replication_role = replica;\n") {
+		t.Fatalf("expected triggers to be disabled, got:\n%s", red(outputBuf.String()))
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", MaxRows: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `-- Extracted by pginspector from public.vehicle where id = 'v1'.
+-- Relations not followed to the end:
+--   public.rental.vehicle_id -> public.vehicle.id (max_rows)
+
+BEGIN;
+
+SET CONSTRAINTS ALL DEFERRED;
+
+INSERT INTO public.vehicle (id, model) VALUES
+    ('v1', 'Model T');
+
+INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r1', 'o1', 'v1');
+
+COMMIT;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	q.queries = nil
+	extraction, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extraction.Tables[extraction.Seed].Rows) != 1 || len(q.queries) != 2 {
+		t.Fatalf("expected the cycle back to the seed row to end, got %d queries", len(q.queries))
+	}
+}
+
+func TestExtractSeeds(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}, {"v2", "Model T"}, {"v3", "Model A"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r3", "o1", "v2"},
+		},
+	}}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", SeedValues: []string{"v2", "v1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `-- Extracted by pginspector from public.vehicle where id IN ('v1', 'v2').
+
+BEGIN;
+
+SET CONSTRAINTS ALL DEFERRED;
+
+INSERT INTO public.vehicle (id, model) VALUES
+    ('v1', 'Model T'),
+    ('v2', 'Model T');
+
+INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r1', 'o1', 'v1'),
+    (NULL, 'r3', 'o1', 'v2');
+
+COMMIT;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	q.queries = nil
+	extraction, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedWhere: `"model" = $1`, SeedArgs: []string{"Model T"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(q.queries[0], `FROM "public"."vehicle" WHERE "model" = $1`) {
+		t.Fatalf("expected the seed predicate to be used as is, got %s", q.queries[0])
+	}
+	if len(extraction.Tables[extraction.Seed].Rows) != 2 || extraction.SeedFilter != `"model" = $1 with 'Model T'` {
+		t.Fatalf("expected both Model T rows, got %v", extraction.Tables[extraction.Seed].Rows)
+	}
+
+	_, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", SeedWhere: "true"})
+	if err == nil {
+		t.Fatal("expected an error when seeding by both value and predicate")
+	}
+}
+
+func TestExtractMasks(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental":  {{"2020-01-01", "r1", "o1", "v1"}},
+	}}
+	masks := []ExtractMask{
+		{Table: "vehicle", Column: "model", Action: "redact", Pattern: `\w+$`, Value: "X"},
+		{Table: "public.rental", Column: "end_date", Action: "null"},
+		{Table: "rental", Column: "owner_id", Action: "hash"},
+		{Table: "rental", Column: "vehicle_id", Action: "constant", Value: "00000000-0000-0000-0000-000000000000"},
+	}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Masks: masks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `-- Extracted by pginspector from public.vehicle where id = 'v1'.
+
+BEGIN;
+
+SET CONSTRAINTS ALL DEFERRED;
+
+INSERT INTO public.vehicle (id, model) VALUES
+    ('v1', 'Model X');
+
+INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r1', '2352da72-80f1-8ecc-bacf-1ba84eb945c9', '00000000-0000-0000-0000-000000000000');
+
+COMMIT;
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	for _, mask := range []ExtractMask{
+		{Table: "vehicle", Column: "id", Action: "null"},
+		{Table: "vehicle", Column: "model", Action: "shuffle"},
+		{Table: "vehicle", Column: "color", Action: "null"},
+		{Table: "rental", Column: "vehicle_id", Action: "constant", Value: "v0"},
+		{Table: "rental", Column: "end_date", Action: "hash"},
+	} {
+		_, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Masks: []ExtractMask{mask}})
+		if err == nil {
+			t.Fatalf("expected an error for mask %+v", mask)
+		}
+	}
+}
+
+func TestExtractPseudonyms(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental":  {{nil, "r1", "o1", "v1"}},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", PseudonymKeyEnv: "TEST_PSEUDONYM_KEY", Masks: []ExtractMask{
+		{Table: "vehicle", Column: "id", Action: "hmac"},
+		{Table: "vehicle", Column: "model", Action: "hmac", Value: "model-%s"},
+		{Table: "rental", Column: "vehicle_id", Action: "hmac"},
+	}}
+
+	_, err := extract(context.Background(), q, schemas, cfg)
+	if err == nil {
+		t.Fatal("expected an error without a pseudonym key")
+	}
+
+	t.Setenv("TEST_PSEUDONYM_KEY", "secret")
+	extraction, err := extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle := extraction.Tables[extraction.Seed].Rows[0]
+	for _, rental := range extraction.Tables {
+		if rental.Table.Name == "rental" && rental.Rows[0][3] != vehicle[0] {
+			t.Fatalf("expected the pseudonymized key %v to match, got %v", vehicle[0], rental.Rows[0][3])
+		}
+	}
+	if _, err := uuid.Parse(vehicle[0].(string)); err != nil {
+		t.Fatalf("expected a UUID pseudonym, got %v", vehicle[0])
+	}
+	if model := vehicle[1].(string); !strings.HasPrefix(model, "model-") || len(model) != len("model-")+64 {
+		t.Fatalf("expected a formatted pseudonym, got %v", model)
+	}
+}
+
+func TestFakeValues(t *testing.T) {
+	for name, expected := range map[string]string{
+		"email":             "email",
+		"contact_email":     "email",
+		"billing_address":   "address",
+		"mobile":            "phone",
+		"customer_surname":  "last_name",
+		"display_name":      "name",
+		"shipping_zip_code": "postal_code",
+	} {
+		kind, err := fakeKind(Column{Name: name, PGType: "text"}, "")
+		if err != nil || kind != expected {
+			t.Fatalf("expected %s to be guessed as %s, got %s (%v)", name, expected, kind, err)
+		}
+	}
+	if _, err := fakeKind(Column{Name: "notes", PGType: "text"}, ""); err == nil {
+		t.Fatal("expected an error for a column with no guessable kind")
+	}
+	if _, err := fakeKind(Column{Name: "email", PGType: "integer"}, ""); err == nil {
+		t.Fatal("expected an error for a column that isn't text")
+	}
+
+	email := fakeValue(Column{Name: "email", PGType: "text"}, "email", "ada@lovelace.dev")
+	if email != fakeValue(Column{Name: "email", PGType: "text"}, "email", "ada@lovelace.dev") || !strings.HasSuffix(email, "@example.com") {
+		t.Fatalf("expected a repeatable fake email, got %s", email)
+	}
+	if phone := fakeValue(Column{Name: "phone", PGType: "character varying", MaxLength: 8}, "phone", "555"); len(phone) != 8 {
+		t.Fatalf("expected the fake value to be cut to the column's length, got %s", phone)
+	}
+
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+	}}
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Masks: []ExtractMask{
+		{Table: "vehicle", Column: "model", Action: "fake", Value: "company"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model := extraction.Tables[extraction.Seed].Rows[0][1]; model == "Model T" || model == "" {
+		t.Fatalf("expected a fake company, got %v", model)
+	}
+}
+
+func TestWriteExtractionJSON(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err = writeExtractionJSON(outputBuf, schemas, extraction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `{
+  "public.vehicle": [
+    {"id": "v1", "model": "Model T"}
+  ],
+  "public.rental": [
+    {"end_date": "2020-01-02T00:00:00Z", "id": "r1", "owner_id": "o1", "vehicle_id": "v1"},
+    {"end_date": null, "id": "r2", "owner_id": "o1", "vehicle_id": "v1"}
+  ]
+}
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+	if !json.Valid(outputBuf.Bytes()) {
+		t.Fatal("expected valid JSON")
+	}
+
+	dir := t.TempDir()
+	err = writeExtractionNDJSON(dir, schemas, extraction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rentals, err := os.ReadFile(filepath.Join(dir, "public.rental.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(rentals)), "\n"); len(lines) != 2 || !json.Valid([]byte(lines[1])) {
+		t.Fatalf("expected a JSON row per line, got:\n%s", red(string(rentals)))
+	}
+
+	dir = t.TempDir()
+	err = writeExtractionCSV(dir, schemas, extraction, ExtractConfig{CSVNull: `\N`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rentals, err = os.ReadFile(filepath.Join(dir, "public.rental.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCSV := "end_date,id,owner_id,vehicle_id\n2020-01-02T00:00:00Z,r1,o1,v1\n\\N,r2,o1,v1\n"
+	if string(rentals) != expectedCSV {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedCSV), red(string(rentals)))
+	}
+
+	if _, _, err := extractOutput(ExtractConfig{Format: "xml"}); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	if format, output, _ := extractOutput(ExtractConfig{Format: "ndjson"}); format != "ndjson" || output != "extract" {
+		t.Fatalf("expected the ndjson default output, got %s %s", format, output)
+	}
+}
+
+func TestExtractDirections(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	count := func(extraction *Extraction, name string) int {
+		for table, extracted := range extraction.Tables {
+			if table.Name == name {
+				return len(extracted.Rows)
+			}
+		}
+		return 0
+	}
+
+	for _, test := range []struct {
+		cfg      ExtractConfig
+		vehicles int
+		rentals  int
+	}{
+		{ExtractConfig{SeedTable: "rental", SeedValue: "r1"}, 1, 2},
+		{ExtractConfig{SeedTable: "rental", SeedValue: "r1", Direction: "parents"}, 1, 1},
+		{ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Direction: "parents"}, 1, 0},
+		{ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Direction: "children"}, 1, 2},
+		{ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Relations: []ExtractRelation{{Column: "public.rental.vehicle_id", Direction: "parents"}}}, 1, 0},
+	} {
+		extraction, err := extract(context.Background(), q, schemas, test.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count(extraction, "vehicle") != test.vehicles || count(extraction, "rental") != test.rentals {
+			t.Fatalf("expected %d vehicles and %d rentals for %+v, got %d and %d", test.vehicles, test.rentals, test.cfg, count(extraction, "vehicle"), count(extraction, "rental"))
+		}
+	}
+
+	for _, cfg := range []ExtractConfig{
+		{SeedTable: "vehicle", SeedValue: "v1", Direction: "up"},
+		{SeedTable: "vehicle", SeedValue: "v1", Relations: []ExtractRelation{{Column: "rental.model", Direction: "both"}}},
+	} {
+		if _, err := extract(context.Background(), q, schemas, cfg); err == nil {
+			t.Fatalf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestExtractRelationFilters(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+
+	follow := false
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Relations: []ExtractRelation{{Column: "rental.vehicle_id", Follow: &follow}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extraction.Tables) != 1 || len(q.queries) != 1 {
+		t.Fatalf("expected rental.vehicle_id not to be followed, got %d queries", len(q.queries))
+	}
+
+	extraction, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", MaxRowsPerTable: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, extracted := range extraction.Tables {
+		if len(extracted.Rows) != 1 {
+			t.Fatalf("expected a row of %s, got %d", table.Name, len(extracted.Rows))
+		}
+	}
+	expected := []string{"public.rental.vehicle_id -> public.vehicle.id (max_rows_per_table)"}
+	if !reflect.DeepEqual(extraction.Truncated, expected) {
+		t.Fatalf("expected %v to be truncated, got %v", expected, extraction.Truncated)
+	}
+}
+
+func TestExtractSkips(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", nil}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+		},
+	}}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "rental", SeedValue: "r1", SchemaConfig: map[string]SchemaConfig{
+		"public": {TableConfig: map[string]TableConfig{"vehicle": {ExcludeColumns: []string{"model"}}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(q.queries[1], `SELECT "id", NULL AS "model" FROM`) {
+		t.Fatalf("expected model not to be read, got %s", q.queries[1])
+	}
+	outputBuf := &bytes.Buffer{}
+	err = writeExtraction(outputBuf, schemas, extraction, ExtractConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(outputBuf.String(), "INSERT INTO public.vehicle (id) VALUES\n    ('v1');") {
+		t.Fatalf("expected model to be left out, got:\n%s", red(outputBuf.String()))
+	}
+
+	q.queries = nil
+	extraction, err = extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "rental", SeedValue: "r1", SchemaConfig: map[string]SchemaConfig{
+		"public": {SkipTables: []string{"vehicle"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extraction.Tables) != 1 || len(q.queries) != 1 {
+		t.Fatalf("expected vehicle not to be queried, got %d queries", len(q.queries))
+	}
+
+	for _, cfg := range []ExtractConfig{
+		{SeedTable: "vehicle", SeedValue: "v1", SchemaConfig: map[string]SchemaConfig{"public": {SkipTables: []string{"vehicle"}}}},
+		{SeedTable: "vehicle", SeedValue: "v1", SchemaConfig: map[string]SchemaConfig{"public": {TableConfig: map[string]TableConfig{"rental": {ExcludeColumns: []string{"vehicle_id"}}}}}},
+		{SeedTable: "vehicle", SeedValue: "v1", SchemaConfig: map[string]SchemaConfig{"public": {TableConfig: map[string]TableConfig{"rental": {ExcludeColumns: []string{"color"}}}}}},
+	} {
+		if _, err := extract(context.Background(), q, schemas, cfg); err == nil {
+			t.Fatalf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+// fakeExtractTx records the statements run in it. Methods the clone doesn't
+// use panic.
+type fakeExtractTx struct {
+	pgx.Tx
+	statements []string
+	committed  bool
+}
+
+func (tx *fakeExtractTx) Begin(ctx context.Context) (pgx.Tx, error) { return tx, nil }
+
+func (tx *fakeExtractTx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	tx.statements = append(tx.statements, sql)
+	return nil, nil
+}
+
+func (tx *fakeExtractTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeExtractTx) Rollback(ctx context.Context) error { return nil }
+
+func TestCloneExtraction(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", DisableTriggers: true, ChunkSize: 1}
+
+	tx := &fakeExtractTx{}
+	clone, err := newExtractClone(context.Background(), tx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.close()
+	extraction, err := extractStreaming(context.Background(), q, schemas, cfg, clone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, extracted := range extraction.Tables {
+		if len(extracted.Rows) > 0 {
+			t.Fatalf("expected the rows to be staged rather than held, got %v", extracted.Rows)
+		}
+	}
+	// The rows are staged as they're found, before the extraction ends.
+	staged := []string{
+		"SET CONSTRAINTS ALL DEFERRED",
+		"SET LOCAL session_replication_role = replica",
+		"CREATE TEMPORARY TABLE pginspector_clone_0 ON COMMIT DROP AS SELECT id, model FROM public.vehicle WITH NO DATA",
+		"INSERT INTO pginspector_clone_0 (id, model) VALUES\n    ('v1', 'Model T')",
+		"CREATE TEMPORARY TABLE pginspector_clone_1 ON COMMIT DROP AS SELECT end_date, id, owner_id, vehicle_id FROM public.rental WITH NO DATA",
+		"INSERT INTO pginspector_clone_1 (end_date, id, owner_id, vehicle_id) VALUES\n    (NULL, 'r1', 'o1', 'v1')",
+		"INSERT INTO pginspector_clone_1 (end_date, id, owner_id, vehicle_id) VALUES\n    (NULL, 'r2', 'o1', 'v1')",
+	}
+	if !reflect.DeepEqual(tx.statements, staged) {
+		t.Fatalf("expected %q to be staged, got %q", staged, tx.statements)
+	}
+
+	if err := clone.finish(schemas, extraction); err != nil {
+		t.Fatal(err)
+	}
+	expected := append(staged,
+		"INSERT INTO public.vehicle (id, model) SELECT id, model FROM pginspector_clone_0",
+		"INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) SELECT end_date, id, owner_id, vehicle_id FROM pginspector_clone_1",
+	)
+	if !reflect.DeepEqual(tx.statements, expected) || !tx.committed {
+		t.Fatalf("expected %q to be committed, got %q", expected, tx.statements)
+	}
+
+	if _, err := newExtractClone(context.Background(), &fakeExtractTx{}, ExtractConfig{Checkpoint: "extract.checkpoint"}); err == nil {
+		t.Fatal("expected an error for a checkpointed clone")
+	}
+}
+
+func TestExtractStreaming(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Masks: []ExtractMask{{Table: "vehicle", Column: "model", Action: "constant", Value: "Model X"}}}
+	extraction, err := extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"sql", "json", "csv"} {
+		expectedBuf := &bytes.Buffer{}
+		dir := t.TempDir()
+		switch format {
+		case "sql":
+			err = writeExtraction(expectedBuf, schemas, extraction, cfg)
+		case "json":
+			err = writeExtractionJSON(expectedBuf, schemas, extraction)
+		case "csv":
+			err = writeExtractionCSV(dir, schemas, extraction, cfg)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		streamed := filepath.Join(t.TempDir(), "streamed")
+		spool, err := newExtractSpool(format, streamed, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer spool.close()
+		streamedExtraction, err := extractStreaming(context.Background(), q, schemas, cfg, spool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, extracted := range streamedExtraction.Tables {
+			if len(extracted.Rows) != 0 {
+				t.Fatalf("expected the %s rows not to be held, got %d", extracted.Table.Name, len(extracted.Rows))
+			}
+		}
+		outputBuf := &bytes.Buffer{}
+		err = spool.finish(outputBuf, schemas, streamedExtraction)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if format == "csv" {
+			expected, _ := os.ReadFile(filepath.Join(dir, "public.rental.csv"))
+			expectedBuf.Write(expected)
+			streamedRentals, _ := os.ReadFile(filepath.Join(streamed, "public.rental.csv"))
+			outputBuf.Write(streamedRentals)
+		}
+		if outputBuf.String() != expectedBuf.String() {
+			t.Fatalf("expected streamed %s output:\n%s\nbut got:\n%s", format, green(expectedBuf.String()), red(outputBuf.String()))
+		}
+	}
+}
+
+func TestExtractConcurrency(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{}}
+	seeds := []string{}
+	for v := 0; v < 10; v++ {
+		vehicle := fmt.Sprintf("v%d", v)
+		seeds = append(seeds, vehicle)
+		q.rows["public.vehicle"] = append(q.rows["public.vehicle"], []interface{}{vehicle, "Model T"})
+		for r := 0; r < 3; r++ {
+			q.rows["public.rental"] = append(q.rows["public.rental"], []interface{}{nil, fmt.Sprintf("r%d-%d", v, r), "o1", vehicle})
+		}
+	}
+
+	expected := ""
+	for _, concurrency := range []int{1, 2, 8, 8, 8} {
+		extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValues: seeds, MaxRows: 25, Concurrency: concurrency})
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputBuf := &bytes.Buffer{}
+		if err := writeExtraction(outputBuf, schemas, extraction, ExtractConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if expected == "" {
+			expected = outputBuf.String()
+		}
+		if outputBuf.String() != expected {
+			t.Fatalf("expected the same output with a concurrency of %d:\n%s\nbut got:\n%s", concurrency, green(expected), red(outputBuf.String()))
+		}
+	}
+}
+
+func TestExtractCheckpoint(t *testing.T) {
+	interval := extractCheckpointInterval
+	extractCheckpointInterval = 0
+	defer func() { extractCheckpointInterval = interval }()
+
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Concurrency: 1, Checkpoint: filepath.Join(t.TempDir(), "checkpoint.json")}
+	run := func() (string, error) {
+		spool, err := newExtractSpool("sql", "", cfg)
+		if err != nil {
+			return "", err
+		}
+		defer spool.close()
+		extraction, err := extractStreaming(context.Background(), q, schemas, cfg, spool)
+		if err != nil {
+			return "", err
+		}
+		outputBuf := &bytes.Buffer{}
+		err = spool.finish(outputBuf, schemas, extraction)
+		return outputBuf.String(), err
+	}
+
+	q.failAfter = 1
+	if _, err := run(); err == nil {
+		t.Fatal("expected the extraction to be interrupted")
+	}
+	q.failAfter, q.queries = 0, nil
+	output, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.queries) != 1 {
+		t.Fatalf("expected the extraction to resume after the seed row, got %d queries", len(q.queries))
+	}
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBuf := &bytes.Buffer{}
+	if err := writeExtraction(expectedBuf, schemas, extraction, ExtractConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if output != expectedBuf.String() {
+		t.Fatalf("expected resumed output:\n%s\nbut got:\n%s", green(expectedBuf.String()), red(output))
+	}
+
+	q.rows["public.rental"] = append(q.rows["public.rental"], []interface{}{nil, "r3", "o1", "v1"})
+	output, err = run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "'r3'") || strings.Contains(output, "'r1'") || strings.Contains(output, "INSERT INTO public.vehicle") {
+		t.Fatalf("expected only the new rental, got:\n%s", red(output))
+	}
+
+	if _, err := extract(context.Background(), q, schemas, cfg); err == nil {
+		t.Fatal("expected an error for a checkpoint without streamed rows")
+	}
+}
+
+func TestExtractLiterals(t *testing.T) {
+	numeric := pgtype.Numeric{}
+	if err := numeric.Set("12.50"); err != nil {
+		t.Fatal(err)
+	}
+	array := pgtype.Int4Array{}
+	if err := array.Set([]int32{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		column   Column
+		value    interface{}
+		expected string
+	}{
+		{Column{PGType: "numeric"}, numeric, "'1250e-2'"},
+		{Column{PGType: "numeric"}, pgtype.Numeric{Status: pgtype.Null}, "NULL"},
+		{Column{PGType: "ARRAY", UDTName: "_int4"}, array, "'{1,2}'"},
+		{Column{PGType: "double precision"}, math.NaN(), "'NaN'"},
+		{Column{PGType: "real"}, float32(1.5), "1.5"},
+		{Column{PGType: "boolean"}, true, "TRUE"},
+		{Column{PGType: "jsonb"}, "text", `'"text"'`},
+		{Column{PGType: "jsonb"}, map[string]interface{}{"a": 1}, `'{"a":1}'`},
+		{Column{PGType: "date"}, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "'2020-01-02'"},
+		{Column{PGType: "USER-DEFINED", UDTName: "mood", EnumValues: []string{"happy"}}, "happy", "'happy'"},
+	} {
+		if literal := extractLiteral(extractColumnValue(test.column, test.value)); literal != test.expected {
+			t.Fatalf("expected %v as %s, got %s", test.value, test.expected, literal)
+		}
+	}
+
+	if encoded := string(extractJSONValue(array)); encoded != `"{1,2}"` {
+		t.Fatalf("expected the array as its SQL text, got %s", encoded)
+	}
+}
+
+func TestExtractManifest(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Masks: []ExtractMask{{Table: "vehicle", Column: "model", Action: "null"}}}
+	extraction, err := extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := extractManifest(schemas, extraction, cfg, "sql", "-")
+	if manifest.Seed.Table != "public.vehicle" || manifest.Seed.Key != "id" || len(manifest.Seed.Values) != 1 {
+		t.Fatalf("expected the seed parameters, got %+v", manifest.Seed)
+	}
+	if manifest.Rows != 3 || len(manifest.Tables) != 2 || manifest.Tables[0].Table != "public.vehicle" || manifest.Tables[1].Rows != 2 {
+		t.Fatalf("expected the vehicle and its two rentals, got %+v", manifest.Tables)
+	}
+	if len(manifest.Masks) != 1 || manifest.Masks[0].Table != "public.vehicle" {
+		t.Fatalf("expected the vehicle model mask, got %+v", manifest.Masks)
+	}
+
+	manifestBuf := &bytes.Buffer{}
+	if err := writeExtractManifest(manifestBuf, manifest); err != nil {
+		t.Fatal(err)
+	}
+	decoded := ExtractManifest{}
+	if err := json.Unmarshal(manifestBuf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Rows != manifest.Rows || decoded.Seed.Filter != manifest.Seed.Filter {
+		t.Fatalf("expected the manifest to round trip, got %+v", decoded)
+	}
+
+	summary := extractSummary(manifest)
+	for _, expected := range []string{"Extracted 3 rows from public.vehicle", "into stdout", "public.rental         2 rows", "Masked public.vehicle.model (null)"} {
+		if !strings.Contains(summary, expected) {
+			t.Fatalf("expected the summary to contain %q, got:\n%s", expected, red(summary))
+		}
+	}
+}
+
+func TestExtractSamples(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}, {"v2", "Model A"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+			{nil, "r3", "o1", "v2"},
+			{nil, "r4", "o1", "v2"},
+		},
+	}}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{Samples: []ExtractSample{{Table: "rental", Rows: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(q.queries[0], "WHERE TRUE ORDER BY random() LIMIT 2") {
+		t.Fatalf("expected two rentals sampled at random, got %s", q.queries[0])
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtraction(outputBuf, schemas, extraction, ExtractConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	output := outputBuf.String()
+	if !strings.HasPrefix(output, "-- Extracted by pginspector from a sample of 2 rows of public.rental.") {
+		t.Fatalf("expected the sample to be described, got:\n%s", red(output))
+	}
+	// Only the parents of the sampled rentals are followed, not their
+	// vehicle's other rentals.
+	if !strings.Contains(output, "'r2'") || !strings.Contains(output, "('v1', 'Model T')") || strings.Contains(output, "'v2'") {
+		t.Fatalf("expected the sampled rentals and their vehicle, got:\n%s", red(output))
+	}
+
+	q.queries = nil
+	extraction, err = extract(context.Background(), q, schemas, ExtractConfig{Samples: []ExtractSample{{Table: "rental", Percent: 50}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(q.queries[0], `"rental" TABLESAMPLE BERNOULLI (50) WHERE TRUE`) {
+		t.Fatalf("expected half the rentals sampled, got %s", q.queries[0])
+	}
+	if extraction.rows != 3 {
+		t.Fatalf("expected two rentals and their vehicle, got %d rows", extraction.rows)
+	}
+
+	for _, cfg := range []ExtractConfig{
+		{SeedTable: "vehicle", SeedValue: "v1", Samples: []ExtractSample{{Table: "rental", Rows: 1}}},
+		{Samples: []ExtractSample{{Table: "rental", Percent: 150}}},
+		{Samples: []ExtractSample{{Table: "missing", Rows: 1}}},
+	} {
+		if _, err := extract(context.Background(), q, schemas, cfg); err == nil {
+			t.Fatalf("expected an error for %+v", cfg.Samples)
+		}
+	}
+}
+
+func TestExtractIntegrity(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "rental", SeedValue: "r1", Direction: "children"}
+
+	extraction, err := extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"public.rental.vehicle_id -> public.vehicle.id (1 missing)"}
+	if !reflect.DeepEqual(extraction.Dangling, expected) {
+		t.Fatalf("expected the rental's vehicle to be reported missing, got %v", extraction.Dangling)
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtraction(outputBuf, schemas, extraction, ExtractConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(outputBuf.String(), "-- Foreign keys referencing rows not extracted:\n--   "+expected[0]) {
+		t.Fatalf("expected the dangling foreign key in the header, got:\n%s", red(outputBuf.String()))
+	}
+
+	cfg.Dangling = "fetch"
+	extraction, err = extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extraction.Dangling) != 0 || extraction.rows != 2 {
+		t.Fatalf("expected the rental's vehicle to be fetched but not its other rental, got %d rows and %v", extraction.rows, extraction.Dangling)
+	}
+
+	cfg.Dangling = "error"
+	if _, err := extract(context.Background(), q, schemas, cfg); err == nil {
+		t.Fatal("expected an error for the missing vehicle")
+	}
+}
+
+func TestExtractCompositeForeignKeys(t *testing.T) {
+	vehicle := Table{
+		Schema: "public",
+		Name:   "vehicle",
+		Columns: []Column{
+			{Name: "region", PGType: "text"},
+			{Name: "id", PGType: "integer"},
+			{Name: "model", PGType: "text"},
+		},
+	}
+	reference := func(column string) Relation {
+		return Relation{Forward: true, Table: &vehicle, Column: &Column{Name: column}, Constraint: "rental_vehicle_fkey"}
+	}
+	schemas := []GenerationSchema{{Name: "public", Tables: []GenerationTable{
+		{Table: Table{Schema: "public", Name: "rental", Columns: []Column{
+			{Name: "id", PGType: "text"},
+			{Name: "region", PGType: "text", Relation: reference("region")},
+			{Name: "vehicle_id", PGType: "integer", Relation: reference("id")},
+		}}, Config: TableConfig{PrimaryKey: "id"}},
+		{Table: vehicle},
+	}}}
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"eu", int32(1), "Model T"}, {"us", int32(1), "Model A"}},
+		"public.rental":  {{"r1", "eu", int32(1)}, {"r2", "us", int32(1)}},
+	}}
+
+	fks := extractForeignKeys(diagramEdges(schemas))
+	if len(fks) != 1 || fks[0].Name != "public.rental.(region, vehicle_id) -> public.vehicle.(region, id)" {
+		t.Fatalf("expected one composite foreign key, got %+v", fks)
+	}
+
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "rental", SeedValue: "r1", Dangling: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(q.queries[1], `FROM "public"."vehicle" WHERE "region" = $1 AND "id" = $2`) {
+		t.Fatalf("expected the vehicle to be looked up by both columns, got %s", q.queries[1])
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtraction(outputBuf, schemas, extraction, ExtractConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if output := outputBuf.String(); !strings.Contains(output, "('eu', 1, 'Model T')") || strings.Contains(output, "'Model A'") || strings.Contains(output, "'r2'") {
+		t.Fatalf("expected only the rental's vehicle, got:\n%s", red(output))
+	}
+}
+
+func TestSchemaSnapshot(t *testing.T) {
+	schema := Schema{Tables: map[string]Table{
+		"employee": {Schema: "public", Name: "employee", Columns: []Column{
+			{Name: "id", PGType: "uuid"},
+			{Name: "manager_id", PGType: "uuid", Nullable: true, Relation: Relation{Forward: true, Table: &Table{Schema: "public", Name: "employee"}, Column: &Column{Name: "id"}, Constraint: "employee_manager_id_fkey"}},
+		}},
+	}}
+	// Resolving makes the relation reference its own table.
+	schema.ResolveRelations("public")
+	snapshotBuf := &bytes.Buffer{}
+	if err := writeSchemaSnapshot(snapshotBuf, SchemaSnapshot{Schemas: map[string]Schema{"public": schema}}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := readSchemaSnapshot(snapshotBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := GeneratorConfiguration{SchemaConfig: map[string]SchemaConfig{"public": {DefaultPrimaryKeyColumn: "id"}}}
+	schemas, err := buildGenerationSchemas(cfg, snapshot.inspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, _ := schemas[0].Tables[0].GetColumn("manager_id")
+	if manager.Relation.Table == nil || len(manager.Relation.Table.Columns) != 2 || manager.Relation.Constraint != "employee_manager_id_fkey" {
+		t.Fatalf("expected the relation to be resolved, got %+v", manager.Relation)
+	}
+	if len(diagramEdges(schemas)) != 1 {
+		t.Fatalf("expected the snapshot's foreign key to be an edge")
+	}
+
+	cfg.SchemaConfig["sales"] = SchemaConfig{DefaultPrimaryKeyColumn: "id"}
+	if _, err := buildGenerationSchemas(cfg, snapshot.inspect); err == nil {
+		t.Fatal("expected an error for a schema missing from the snapshot")
+	}
+}
+
+func TestBuildGenerationSchemasValidation(t *testing.T) {
+	snapshot := SchemaSnapshot{Schemas: map[string]Schema{"public": {Tables: map[string]Table{
+		"vehicle": {Schema: "public", Name: "vehicle", Columns: []Column{
+			{Name: "id", PGType: "uuid", Default: "gen_random_uuid()"},
+			{Name: "vin", PGType: "text"},
+			{Name: "status", PGType: "text"},
+			{Name: "search", PGType: "tsvector", Generated: true},
+		}, Indexes: []Index{
+			{Name: "vehicle_vin_key", Columns: []string{"vin"}, Unique: true},
+			{Name: "vehicle_status_idx", Columns: []string{"status"}, Unique: true, Definition: "CREATE UNIQUE INDEX vehicle_status_idx ON public.vehicle USING btree (status) WHERE (status = 'active'::text)"},
+		}},
+	}}}}
+	for _, test := range []struct {
+		config TableConfig
+		err    string
+	}{
+		{TableConfig{BulkUpdateColumns: []string{"status"}}, ""},
+		{TableConfig{BulkUpdateColumns: []string{"colour"}}, "Bulk update column colour not found"},
+		{TableConfig{BulkUpdateColumns: []string{"id"}}, "Bulk update column id is the primary key"},
+		{TableConfig{BulkUpdateColumns: []string{"search"}}, "Bulk update column search is generated"},
+		{TableConfig{UpdateManagedColumns: []string{"status"}}, ""},
+		{TableConfig{UpdateManagedColumns: []string{"colour"}}, "Update managed column colour not found"},
+		{TableConfig{UpdateManagedColumns: []string{"id"}}, "Update managed column id is the primary key"},
+		{TableConfig{UpdateManagedColumns: []string{"search"}}, "Update managed column search is generated"},
+		{TableConfig{UpsertConflictColumns: []string{"vin"}}, ""},
+		{TableConfig{UpsertConflictColumns: []string{"id"}}, ""},
+		{TableConfig{UpsertConflictColumns: []string{"colour"}}, "Upsert conflict column colour not found"},
+		{TableConfig{UpsertConflictColumns: []string{"vin", "status"}}, "Upsert conflict columns (vin, status) of table public.vehicle are not the primary key or a unique constraint"},
+		{TableConfig{UpsertConflictColumns: []string{"status"}}, "are not the primary key or a unique constraint"},
+	} {
+		cfg := GeneratorConfiguration{SchemaConfig: map[string]SchemaConfig{"public": {
+			DefaultPrimaryKeyColumn: "id",
+			TableConfig:             map[string]TableConfig{"vehicle": test.config},
+		}}}
+		_, err := buildGenerationSchemas(cfg, snapshot.inspect)
+		if test.err == "" && err != nil {
+			t.Errorf("expected %+v to be valid, got %v", test.config, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("expected %+v to fail with %q, got %v", test.config, test.err, err)
+		}
+	}
+}
+
+func TestExtractReplace(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	for _, test := range []struct {
+		replace  string
+		expected string
+	}{
+		{"truncate", "SET CONSTRAINTS ALL DEFERRED;\n\nTRUNCATE public.vehicle, public.rental CASCADE;\n\nINSERT INTO public.vehicle"},
+		{"delete", "SET CONSTRAINTS ALL DEFERRED;\n\nDELETE FROM public.rental WHERE id IN ('r1', 'r2');\nDELETE FROM public.vehicle WHERE id IN ('v1');\n\nINSERT INTO public.vehicle"},
+	} {
+		cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Replace: test.replace}
+		extraction, err := extract(context.Background(), q, schemas, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputBuf := &bytes.Buffer{}
+		if err := writeExtraction(outputBuf, schemas, extraction, cfg); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(outputBuf.String(), test.expected) {
+			t.Fatalf("expected the %s preamble:\n%s\nbut got:\n%s", test.replace, green(test.expected), red(outputBuf.String()))
+		}
+	}
+
+	if _, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Replace: "drop"}); err == nil {
+		t.Fatal("expected an error for an unknown replace action")
+	}
+}
+
+func TestExtractSequences(t *testing.T) {
+	schemas := []GenerationSchema{{Name: "public", Tables: []GenerationTable{
+		{Table: Table{Schema: "public", Name: "invoice", Columns: []Column{
+			{Name: "id", PGType: "bigint", Default: "nextval('invoice_id_seq'::regclass)"},
+			{Name: "number", PGType: "integer", Identity: "ALWAYS"},
+		}}, Config: TableConfig{PrimaryKey: "id"}},
+	}}}
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.invoice": {{int64(7), int32(1)}},
+	}}
+	extraction, err := extract(context.Background(), q, schemas, ExtractConfig{SeedTable: "invoice", SeedValue: "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtraction(outputBuf, schemas, extraction, ExtractConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+SELECT setval('invoice_id_seq'::regclass, max(id)) FROM public.invoice;
+SELECT setval(pg_get_serial_sequence('public.invoice', 'number'), max(number)) FROM public.invoice;
+
+COMMIT;
+`
+	if !strings.HasSuffix(outputBuf.String(), expected) {
+		t.Fatalf("expected the sequences to be set after the inserts:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+	}
+}
+
+func TestExtractChunks(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+			{nil, "r3", "o1", "v1"},
+		},
+	}}
+	cfg := ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", ChunkSize: 2}
+	extraction, err := extract(context.Background(), q, schemas, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtraction(outputBuf, schemas, extraction, cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := `INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r1', 'o1', 'v1'),
+    (NULL, 'r2', 'o1', 'v1');
+INSERT INTO public.rental (end_date, id, owner_id, vehicle_id) VALUES
+    (NULL, 'r3', 'o1', 'v1');
+`
+	if !strings.Contains(outputBuf.String(), expected) {
+		t.Fatalf("expected the rentals in chunks of two:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+	}
+}
+
+func TestExtractPlan(t *testing.T) {
+	schemas := diagramTestSchemas()
+	q := &fakeExtractQuerier{schemas: schemas, rows: map[string][][]interface{}{
+		"public.vehicle": {{"v1", "Model T"}},
+		"public.rental": {
+			{nil, "r1", "o1", "v1"},
+			{nil, "r2", "o1", "v1"},
+		},
+	}}
+	plan, err := extractPlan(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedQuery := `WITH "plan_0" AS (SELECT * FROM "public"."vehicle" WHERE "id" = 'v1'::text::uuid), "plan_1" AS (SELECT * FROM "public"."rental" WHERE ("vehicle_id") IN (SELECT "id" FROM "plan_0")) SELECT (SELECT count(*) FROM "plan_0"), (SELECT count(*) FROM "plan_1")`
+	if len(q.queries) != 1 || q.queries[0] != expectedQuery {
+		t.Fatalf("expected a single count query:\n%s\nbut got:\n%s", green(expectedQuery), red(strings.Join(q.queries, "\n")))
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeExtractPlan(outputBuf, plan); err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `Would extract about 3 rows from public.vehicle where id = 'v1'
+  public.vehicle        1 rows  depth 0  seed rows
+  public.rental         2 rows  depth 1  via public.rental.vehicle_id -> public.vehicle.id
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+
+	plan, err = extractPlan(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", Direction: "parents"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Tables) != 1 || plan.Rows != 1 || strings.Join(plan.Excluded, "\n") != "public.rental.vehicle_id -> public.vehicle.id (not followed to children)" {
+		t.Fatalf("expected the rentals to be left out, got %+v", plan)
+	}
+
+	plan, err = extractPlan(context.Background(), q, schemas, ExtractConfig{SeedTable: "vehicle", SeedValue: "v1", MaxRowsPerTable: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Rows != 2 || !plan.Tables[1].Capped {
+		t.Fatalf("expected the rentals to be capped at max_rows_per_table, got %+v", plan)
+	}
+}
+
+func TestLintSchemas(t *testing.T) {
+	schemas := diagramTestSchemas()
+	rental := &schemas[0].Tables[0]
+	rental.Indexes = []Index{
+		{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+		{Name: "rental_vehicle_id_end_date_idx", Columns: []string{"vehicle_id", "end_date"}},
+	}
+	problems := lintSchemas(schemas)
+	outputBuf := &bytes.Buffer{}
+	if err := writeLintProblems(outputBuf, problems); err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `public.rental: foreign key (owner_id) has no index starting with its columns [unindexed_foreign_key]
+public.vehicle: has no primary key [no_primary_key]
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestSchemaDiff(t *testing.T) {
+	diff, err := schemaDiff("schema-snapshot.json", diagramTestSchemas(), diagramTestSchemas(), GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Fatalf("expected no diff for the same schemas, got:\n%s", red(diff))
+	}
+	changed := diagramTestSchemas()
+	changed[0].Tables[1].Columns = append(changed[0].Tables[1].Columns, Column{Name: "color", PGType: "text", Nullable: true})
+	diff, err = schemaDiff("schema-snapshot.json", diagramTestSchemas(), changed, GeneratorConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "--- schema-snapshot.json") || !strings.Contains(diff, "+++ database") || !strings.Contains(diff, "color") {
+		t.Fatalf("expected a diff adding the color column, got:\n%s", red(diff))
+	}
+}
+
+func TestGenerateTargets(t *testing.T) {
+	described := map[string]bool{}
+	for _, target := range generateTargets {
+		if _, ok := targetGenerators[target[0]]; !ok {
+			t.Fatalf("expected a generator for target %s", target[0])
+		}
+		described[target[0]] = true
+	}
+	for name := range targetGenerators {
+		if !described[name] {
+			t.Fatalf("expected target %s to be described in the usage of generate", name)
+		}
+	}
+	if _, ok := findCommand("extract"); !ok {
+		t.Fatal("expected an extract command")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	source := []byte(`schema_config:
+  public:
+    default_primary_key_name: id
+    skip_tables:
+      - migrations
+      - vehicle
+    table_config:
+      rental:
+        proto_name: v1.Rental
+        primary_key: rental_id
+      person:
+        proto_name: v1.Person
+  billing:
+    table_config:
+      invoice:
+        proto_name: v1.Rental
+`)
+	schemas := map[string]Schema{
+		"public": {Tables: map[string]Table{
+			"rental":  {Schema: "public", Name: "rental", Columns: []Column{{Name: "id"}}},
+			"vehicle": {Schema: "public", Name: "vehicle", Columns: []Column{{Name: "id"}}},
+			"owner":   {Schema: "public", Name: "owner", Columns: []Column{{Name: "owner_id"}}},
+		}},
+		"billing": {Tables: map[string]Table{
+			"invoice": {Schema: "billing", Name: "invoice", Columns: []Column{{Name: "id"}}},
+		}},
+	}
+	diagnostics, err := validateConfig("pginspector.yaml", source, func(schemaName string, excludedTableNames []string) (Schema, error) {
+		return schemas[schemaName], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeConfigDiagnostics(outputBuf, diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `pginspector.yaml:3:5: Default primary key id is not a column of public.owner
+pginspector.yaml:5:9: Skipped table migrations matches no table in schema public
+pginspector.yaml:9:21: Proto name v1.Rental of public.rental is also used by billing.invoice
+pginspector.yaml:10:22: Primary key rental_id is not a column of public.rental
+pginspector.yaml:11:7: Table public.person in table_config does not exist
+pginspector.yaml:13:3: No primary key for table billing.invoice: set default_primary_key_name or table_config.invoice.primary_key
+`
+	if outputBuf.String() != expectedOutput {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", green(expectedOutput), red(outputBuf.String()))
+	}
+}
+
+func TestConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"shared.yaml": `go_package: models
+schema_config:
+  public:
+    default_primary_key_name: id
+    skip_tables: [migrations]
+`,
+		"domains/billing.yaml": `schema_config:
+  public:
+    table_config:
+      invoice:
+        proto_name: v1.Invoice
+`,
+		"domains/rental.yaml": `schema_config:
+  public:
+    table_config:
+      rental:
+        primary_key: rental_id
+        generate_upsert: true
+`,
+		"pginspector.yaml": `include:
+  - shared.yaml
+  - domains/*.yaml
+schema_config:
+  public:
+    skip_tables: [schema_migrations]
+    table_config:
+      rental:
+        generate_upsert: false
+`,
+		"loop.yaml": "include: loop.yaml\n",
+	}
+	if err := os.Mkdir(filepath.Join(dir, "domains"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "pginspector.yaml")
+	cfg, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig := cfg.SchemaConfig["public"]
+	rental := schemaConfig.GetTableConfig("rental")
+	if cfg.GoPackage != "models" || schemaConfig.DefaultPrimaryKeyColumn != "id" || !reflect.DeepEqual(schemaConfig.SkipTables, []string{"schema_migrations"}) {
+		t.Fatalf("expected the shared defaults with the config's skip_tables, got %+v", cfg)
+	}
+	if schemaConfig.GetTableConfig("invoice").ProtoName != "v1.Invoice" || rental.PrimaryKey != "rental_id" || rental.GenerateUpsert {
+		t.Fatalf("expected the table configs merged with the config's overrides, got %+v", schemaConfig.TableConfig)
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := Schema{Tables: map[string]Table{
+		"invoice":           {Schema: "public", Name: "invoice", Columns: []Column{{Name: "id"}}},
+		"rental":            {Schema: "public", Name: "rental", Columns: []Column{{Name: "id"}}},
+		"schema_migrations": {Schema: "public", Name: "schema_migrations", Columns: []Column{{Name: "version"}}},
+	}}
+	diagnostics, err := validateConfig(path, source, func(schemaName string, excludedTableNames []string) (Schema, error) {
+		return schema, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ConfigDiagnostic{{Path: filepath.Join(dir, "domains/rental.yaml"), Line: 5, Column: 22, Message: "Primary key rental_id is not a column of public.rental"}}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Fatalf("expected diagnostics %+v, got %+v", expected, diagnostics)
+	}
+
+	if _, err := ReadConfigFile(filepath.Join(dir, "loop.yaml")); err == nil || !strings.Contains(err.Error(), "is included again") {
+		t.Fatalf("expected an error for a config including itself, got %v", err)
+	}
+}
+
+func TestExpandConfig(t *testing.T) {
+	t.Setenv("PGINSPECTOR_TEST_PACKAGE", "models")
+	t.Setenv("PGINSPECTOR_TEST_DEPTH", "2")
+	t.Setenv("PGINSPECTOR_TEST_EMPTY", "")
+	cfg, err := ReadConfig(strings.NewReader(`go_package: ${PGINSPECTOR_TEST_PACKAGE}
+proto_package: ${PGINSPECTOR_TEST_UNSET:-foo.v1}
+extract:
+  max_depth: ${PGINSPECTOR_TEST_DEPTH}
+schema_config:
+  ${PGINSPECTOR_TEST_SCHEMA:-public}:
+    soft_delete_column: ${PGINSPECTOR_TEST_EMPTY:-deleted_at}
+    table_config:
+      rental:
+        claim_condition: "price = '$${PGINSPECTOR_TEST_PACKAGE}' AND id = $1"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig := cfg.SchemaConfig["public"]
+	if cfg.GoPackage != "models" || cfg.ProtoPackage != "foo.v1" || cfg.Extract.MaxDepth != 2 || schemaConfig.SoftDeleteColumn != "deleted_at" {
+		t.Fatalf("expected the variables expanded, got %+v", cfg)
+	}
+	if condition := schemaConfig.GetTableConfig("rental").ClaimCondition; condition != "price = '${PGINSPECTOR_TEST_PACKAGE}' AND id = $1" {
+		t.Fatalf("expected $${ to be a literal ${, got %s", condition)
+	}
+	_, err = ReadConfig(strings.NewReader("go_package: x\ndialect: ${PGINSPECTOR_TEST_UNSET}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2: Environment variable PGINSPECTOR_TEST_UNSET is not set") {
+		t.Fatalf("expected an error for an unset variable, got %v", err)
+	}
+}
+
+func TestSkipTablePatterns(t *testing.T) {
+	schemaConfig := SchemaConfig{SkipTables: []string{"migrations", "django_*", "/^audit_/"}}
+	for tableName, expected := range map[string]string{
+		"migrations":         "migrations",
+		"schema_migrations":  "",
+		"django_session":     "django_*",
+		"audit_rental":       "/^audit_/",
+		"rental_audit":       "",
+		"rental":             "",
+		"django_admin_log_x": "django_*",
+	} {
+		if skippedBy := schemaConfig.SkippedBy(tableName); skippedBy != expected {
+			t.Fatalf("expected %s to be skipped by %q, got %q", tableName, expected, skippedBy)
+		}
+	}
+	_, err := ReadConfig(strings.NewReader("schema_config:\n  public:\n    skip_tables: [\"/(/\"]\n"))
+	if err == nil || !strings.Contains(err.Error(), "Invalid skip_tables entry in schema public: Invalid regular expression /(/") {
+		t.Fatalf("expected an error for an invalid regular expression, got %v", err)
+	}
+}
+
+func TestIncludeTables(t *testing.T) {
+	source := `schema_config:
+  public:
+    include_tables:
+      - rental # the first table generated
+      - payment_*
+    skip_tables: [payment_log]
+`
+	cfg, err := ReadConfig(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig := cfg.SchemaConfig["public"]
+	for tableName, expected := range map[string]bool{"rental": false, "payment_card": false, "payment_log": true, "vehicle": true} {
+		if skipped := schemaConfig.ShouldSkipTable(tableName); skipped != expected {
+			t.Fatalf("expected %s to be skipped: %v, got %v", tableName, expected, skipped)
+		}
+	}
+
+	schema := Schema{Tables: map[string]Table{}}
+	for _, tableName := range []string{"rental", "payment_card", "payment_log", "vehicle"} {
+		schema.Tables[tableName] = Table{Schema: "public", Name: tableName, Columns: []Column{{Name: "id"}}}
+	}
+	editor := newTableEditor("public", schema, schemaConfig)
+	write, err := editor.run(strings.NewReader("1,3-4\nw\n"), &bytes.Buffer{})
+	if err != nil || !write {
+		t.Fatalf("expected w to write the config, got %v", err)
+	}
+	root := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(source), &root); err != nil {
+		t.Fatal(err)
+	}
+	editor.update(&root)
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = ReadConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig = cfg.SchemaConfig["public"]
+	if !reflect.DeepEqual(schemaConfig.IncludeTables, []string{"payment_*", "vehicle"}) || !reflect.DeepEqual(schemaConfig.SkipTables, []string{"payment_log", "payment_card"}) {
+		t.Fatalf("expected the selection written to include_tables and skip_tables, got:\n%s", red(string(out)))
+	}
+}
+
+func TestSplitSQL(t *testing.T) {
+	files, err := splitSQL(context.TODO(), diagramTestSchemas(), GeneratorConfiguration{OutputLayout: OutputLayoutPerTable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, file := range files {
+		paths = append(paths, filepath.ToSlash(file.path))
+	}
+	if !reflect.DeepEqual(paths, []string{"public/rental.sql", "public/vehicle.sql", "README.md"}) {
+		t.Fatalf("expected a file per table and an index, got %v", paths)
+	}
+	rental := string(files[0].content)
+	if !strings.HasPrefix(rental, generatedSQLHeader) || !strings.Contains(rental, "-- name: SelectRentalByID :one") || strings.Contains(rental, "SelectVehicleByID") {
+		t.Fatalf("expected only the rental queries in its file, got:\n%s", red(rental))
+	}
+	index := string(files[2].content)
+	if !strings.Contains(index, "## [public/rental.sql](public/rental.sql)\n\nTables: public.rental\n\n- SelectRentalByID\n") {
+		t.Fatalf("expected the index to list the rental queries, got:\n%s", red(index))
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "public", "owner.sql")
+	notes := filepath.Join(dir, "notes.sql")
+	if err := os.WriteFile(stale, []byte(generatedSQLHeader), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(notes, []byte("-- Written by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := diffOutputFiles(dir, files); err != nil || !strings.Contains(diff, "+++ "+stale+" (removed)") {
+		t.Fatalf("expected a diff removing the stale file, got %v:\n%s", err, diff)
+	}
+	_, removed, err := writeOutputFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{stale}) {
+		t.Fatalf("expected only the stale generated file to be removed, got %v", removed)
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Fatalf("expected files written by hand to be kept, got %v", err)
+	}
+	if diff, err := diffOutputFiles(dir, files); err != nil || diff != "" {
+		t.Fatalf("expected the written files to be up to date, got %v:\n%s", err, diff)
+	}
+}
+
+func TestInspectSchemaConfig(t *testing.T) {
+	schemaConfig := inspectSchemaConfig(Schema{Tables: map[string]Table{
+		"rental_log": {Schema: "audit", Name: "rental_log", Comment: "Rental changes", Columns: []Column{{Name: "id"}, {Name: "at", Comment: "Changed at"}}},
+	}})
+	tableConfig := schemaConfig.GetTableConfig("rental_log")
+	if schemaConfig.DefaultPrimaryKeyColumn != "id" || tableConfig.ProtoName != "foo.v1.RentalLog" || tableConfig.Description != "Rental changes" || tableConfig.ColumnDescriptions["at"] != "Changed at" {
+		t.Fatalf("expected an example config for the table, got %+v", schemaConfig)
+	}
+}
+
+func TestInspectJSON(t *testing.T) {
+	vehicle := Table{Schema: "public", Name: "vehicle"}
+	schemas := map[string]Schema{
+		"public": {Tables: map[string]Table{
+			"vehicle": vehicle,
+			"rental": {
+				Schema:  "public",
+				Name:    "rental",
+				Comment: "Vehicle rentals",
+				Columns: []Column{
+					{Name: "vehicle_id", Position: 2, PGType: "uuid", UDTName: "uuid", Relation: Relation{Forward: true, Table: &vehicle, Column: &Column{Name: "id"}, Constraint: "rental_vehicle_id_fkey"}},
+					{Name: "id", Position: 1, PGType: "uuid", UDTName: "uuid", Default: "gen_random_uuid()"},
+					{Name: "status", Position: 3, PGType: "USER-DEFINED", UDTName: "rental_status", Nullable: true, EnumValues: []string{"open", "closed"}},
+				},
+				Indexes:     []Index{{Name: "rental_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Definition: "CREATE UNIQUE INDEX rental_pkey ON public.rental USING btree (id)"}},
+				Constraints: []Constraint{{Name: "rental_pkey", Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"}},
+			},
+		}},
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeInspectJSON(outputBuf, schemas); err != nil {
+		t.Fatal(err)
+	}
+	model := InspectModel{}
+	if err := json.Unmarshal(outputBuf.Bytes(), &model); err != nil {
+		t.Fatal(err)
+	}
+	tables := model.Schemas[0].Tables
+	if len(tables) != 2 || tables[0].Name != "rental" || tables[1].Name != "vehicle" {
+		t.Fatalf("expected the tables sorted by name, got %+v", tables)
+	}
+	rental := tables[0]
+	if rental.Columns[0].Name != "id" || rental.Columns[2].SQLType != "rental_status" || !reflect.DeepEqual(rental.PrimaryKey, []string{"id"}) || rental.Constraints[0].Type != "primary_key" {
+		t.Fatalf("expected the columns by position with their types and the primary key, got %+v", rental)
+	}
+	expectedForeignKeys := []InspectForeignKey{{Name: "rental_vehicle_id_fkey", Columns: []string{"vehicle_id"}, ReferencedSchema: "public", ReferencedTable: "vehicle", ReferencedColumns: []string{"id"}}}
+	if !reflect.DeepEqual(rental.ForeignKeys, expectedForeignKeys) {
+		t.Fatalf("expected foreign keys %+v, got %+v", expectedForeignKeys, rental.ForeignKeys)
+	}
+	for _, expected := range []string{`"default": "gen_random_uuid()"`, `"enum_values": [`, `"comment": "Vehicle rentals"`} {
+		if !strings.Contains(outputBuf.String(), expected) {
+			t.Fatalf("expected output to contain %s, got:\n%s", green(expected), red(outputBuf.String()))
+		}
+	}
+	if strings.Contains(outputBuf.String(), `"foreign_keys": null`) || strings.Contains(outputBuf.String(), `"identity"`) {
+		t.Fatalf("expected empty optional fields to be left out, got:\n%s", red(outputBuf.String()))
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	outputBuf := &bytes.Buffer{}
+	logger, err := newLogger(outputBuf, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgxLogger{logger: logger}.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "select 1"})
+	pgxLogger{logger: logger}.Log(context.Background(), pgx.LogLevelError, "Query", map[string]interface{}{"sql": "select x", "err": "column x does not exist"})
+	lines := strings.Split(strings.TrimSpace(outputBuf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the error to be logged at warn level, got:\n%s", red(outputBuf.String()))
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "ERROR" || entry["msg"] != "Query" || entry["component"] != "pgx" || entry["sql"] != "select x" {
+		t.Fatalf("expected the pgx error with its data, got %+v", entry)
+	}
+
+	outputBuf.Reset()
+	logger, err = newLogger(outputBuf, "debug", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("Generating queries", "schema", "public")
+	if expected := `level=DEBUG msg="Generating queries" schema=public`; !strings.Contains(outputBuf.String(), expected) {
+		t.Fatalf("expected output to contain %s, got:\n%s", green(expected), red(outputBuf.String()))
+	}
+
+	if _, err := newLogger(outputBuf, "verbose", "text"); err == nil {
+		t.Fatal("expected an unknown log level to be an error")
+	}
+	if _, err := newLogger(outputBuf, "info", "xml"); err == nil {
+		t.Fatal("expected an unknown log format to be an error")
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	listNames := func(ctx context.Context, databaseURL string, kind string) ([]string, error) {
+		if databaseURL != "postgres://localhost/rentals" {
+			return nil, errors.Errorf("unexpected database URL %s", databaseURL)
+		}
+		if kind == completeTables {
+			return []string{"rental", "vehicle", "rentals.invoice"}, nil
+		}
+		return []string{"public", "rentals"}, nil
+	}
+	t.Setenv("DATABASE_URL", "")
+	testCases := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"ins"}, []string{"inspect"}},
+		{[]string{"help", "gen"}, []string{"generate"}},
+		{[]string{"generate", "-ch"}, []string{"-check"}},
+		{[]string{"generate", "--log-"}, []string{"--log-format", "--log-level"}},
+		{[]string{"generate", "-log-level", "w"}, []string{"warn"}},
+		{[]string{"generate", "-log-level", "=", "e"}, []string{"error"}},
+		{[]string{"inspect", "-format=j"}, []string{"-format=json"}},
+		{[]string{"generate", "-format", ""}, []string{"mermaid", "dot", "plantuml"}},
+		{[]string{"generate", "-check", "pr"}, []string{"proto", "prisma"}},
+		{[]string{"inspect", "-schema", ""}, []string{}},
+		{[]string{"inspect", "-database-url", "postgres://localhost/rentals", "-schema", "r"}, []string{"rentals"}},
+		{[]string{"edit", "-database-url=postgres://localhost/rentals", ""}, []string{"public", "rentals"}},
+		{[]string{"extract", "-database-url", "postgres://localhost/rentals", "-seed-table", "re"}, []string{"rental", "rentals.invoice"}},
+		{[]string{"extract", "-seed-t"}, []string{"-seed-table"}},
+		{[]string{"generate", "-config", ""}, []string{}},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+	}
+	for _, tc := range testCases {
+		completions := completeWords(context.Background(), tc.words, listNames)
+		if !reflect.DeepEqual(completions, tc.expected) {
+			t.Fatalf("expected %v to complete to %s, got %s", tc.words, green(fmt.Sprint(tc.expected)), red(fmt.Sprint(completions)))
+		}
+	}
+
+	for shell, script := range completionScripts {
+		if !strings.Contains(script, "pginspector completion -complete --") {
+			t.Fatalf("expected the %s script to complete with pginspector completion -complete, got:\n%s", shell, red(script))
+		}
+	}
+}
+
+func TestScaffoldConfig(t *testing.T) {
+	schemas := map[string]Schema{
+		"public": {Tables: map[string]Table{
+			"vehicle": {
+				Schema:  "public",
+				Name:    "vehicle",
+				Comment: "Vehicles for rent",
+				Columns: []Column{{Name: "id"}, {Name: "model", Comment: "Make and model"}},
+				Indexes: []Index{{Name: "vehicle_pkey", Columns: []string{"id"}, Unique: true, Primary: true}},
+			},
+			"rental_log": {
+				Schema:  "public",
+				Name:    "rental_log",
+				Columns: []Column{{Name: "rental_id"}, {Name: "at"}},
+			},
+		}},
+	}
+	outputBuf := &bytes.Buffer{}
+	if err := writeScaffoldConfig(outputBuf, schemas); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"    default_primary_key_name: id\n",
+		"      rental_log:\n        # No single column primary key was found, so id is assumed.\n",
+		"      vehicle:\n        primary_key: id\n        # proto_name: foo.v1.Vehicle\n",
+		"        description: Vehicles for rent\n        column_descriptions:\n          model: Make and model\n",
+	} {
+		if !strings.Contains(outputBuf.String(), expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+		}
+	}
+	cfg, err := ReadConfig(bytes.NewReader(outputBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig := cfg.SchemaConfig["public"]
+	if schemaConfig.GetTableConfig("vehicle").PrimaryKey != "id" || schemaConfig.GetTableConfig("vehicle").Description != "Vehicles for rent" {
+		t.Fatalf("expected the config to read back, got %+v", cfg)
+	}
+}
+
+func TestDriftDiff(t *testing.T) {
+	if diff := driftDiff("generated.sql", "generated.sql (regenerated)", "a\nb\n", "a\nb\n"); diff != "" {
+		t.Fatalf("expected no diff for the same output, got:\n%s", red(diff))
+	}
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	to := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16"
+	expected := `--- generated.sql
++++ generated.sql (regenerated)
+@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-4
++four
+ 5
+ 6
+ 7
+@@ -13,3 +13,4 @@
+ 13
+ 14
+ 15
++16
+\ No newline at end of file
+`
+	if diff := driftDiff("generated.sql", "generated.sql (regenerated)", from, to); diff != expected {
+		t.Fatalf("expected diff:\n%s\nbut got:\n%s", green(expected), red(diff))
+	}
+}
+
+func TestTableEditor(t *testing.T) {
+	schema := Schema{Tables: map[string]Table{
+		"rental": {
+			Schema:  "public",
+			Name:    "rental",
+			Columns: []Column{{Name: "id"}, {Name: "deleted_at"}},
+		},
+		"vehicle": {
+			Schema:  "public",
+			Name:    "vehicle",
+			Columns: []Column{{Name: "id"}},
+		},
+	}}
+	source := `# Kept as it is
+schema_config:
+  public:
+    default_primary_key_name: id
+    skip_tables: []
+    table_config:
+      vehicle:
+        generate_upsert: true
+`
+	root := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(source), &root); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ReadConfig(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor := newTableEditor("public", schema, cfg.SchemaConfig["public"])
+	outputBuf := &bytes.Buffer{}
+	write, err := editor.run(strings.NewReader("2\nu 1\ns 9\ns 1\nw\n"), outputBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !write {
+		t.Fatalf("expected w to write the config")
+	}
+	for _, expected := range []string{
+		"   2 [x] vehicle  upsert\n",
+		"Invalid table number 9, expected 1 to 2\n",
+		"   1 [x] rental  upsert, soft delete deleted_at\n   2 [ ] vehicle  upsert\n",
+	} {
+		if !strings.Contains(outputBuf.String(), expected) {
+			t.Fatalf("expected output to contain:\n%s\nbut got:\n%s", green(expected), red(outputBuf.String()))
+		}
+	}
+
+	editor.update(&root)
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "# Kept as it is\n") {
+		t.Fatalf("expected the comment to be kept, got:\n%s", red(string(out)))
+	}
+	cfg, err = ReadConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaConfig := cfg.SchemaConfig["public"]
+	rental := schemaConfig.GetTableConfig("rental")
+	if !reflect.DeepEqual(schemaConfig.SkipTables, []string{"vehicle"}) || !rental.GenerateUpsert || rental.SoftDeleteColumn != "deleted_at" || !schemaConfig.GetTableConfig("vehicle").GenerateUpsert {
+		t.Fatalf("expected the choices to be written, got:\n%s", red(string(out)))
+	}
+}
+
+func TestTableEditorScreen(t *testing.T) {
+	schema := Schema{Tables: map[string]Table{
+		"rental": {
+			Schema:  "public",
+			Name:    "rental",
+			Columns: []Column{{Name: "id"}, {Name: "removed_at"}},
+		},
+		"vehicle": {
+			Schema:  "public",
+			Name:    "vehicle",
+			Columns: []Column{{Name: "id"}},
+		},
+	}}
+	editor := newTableEditor("public", schema, SchemaConfig{TableConfig: map[string]TableConfig{"vehicle": {GenerateUpsert: true}}})
+	outputBuf := &bytes.Buffer{}
+	// Down, unselect vehicle, up, toggle upsert, set a soft delete column
+	// (the default deleted_at first, which rental lacks), filter, and write.
+	keys := "j \x1b[Au" + "s\r" + "s\x7f\x7f\x7f\x7f\x7f\x7f\x7f\x7f\x7f\x7fremoved_at\r" + "/veh\r" + "w"
+	write, err := editor.runScreen(strings.NewReader(keys), outputBuf, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !write {
+		t.Fatalf("expected w to write the config")
+	}
+	for _, expected := range []string{
+		"\r\n> [ ] vehicle  upsert\r\n",
+		"Table rental has no deleted_at column",
+		"Soft delete column: removed_at",
+		"public: 1 of 2 tables selected, listing those matching \"veh\"\r\n> [ ] vehicle  upsert\r\n",
+	} {
+		if !strings.Contains(outputBuf.String(), expected) {
+			t.Fatalf("expected output to contain:\n%q\nbut got:\n%q", expected, outputBuf.String())
+		}
+	}
+	rental, vehicle := editor.tables[0], editor.tables[1]
+	if !rental.selected || !rental.upsert || rental.softDelete != "removed_at" || vehicle.selected || !vehicle.upsert {
+		t.Fatalf("expected the keys to be applied, got %+v", editor.tables)
+	}
+
+	write, err = editor.runScreen(strings.NewReader("q"), outputBuf, 24)
+	if err != nil || write {
+		t.Fatalf("expected q to quit without writing, got %v, %v", write, err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExtractManifest records what an extraction copied, for auditing what left
+// the database.
+type ExtractManifest struct {
+	ExtractedAt time.Time           `json:"extracted_at"`
+	Seed        ExtractManifestSeed `json:"seed"`
+	// Format is the format written, or clone for rows inserted into a target
+	// database.
+	Format string `json:"format"`
+	// Output is where the rows were written. It's empty for a clone, so the
+	// target's URL isn't recorded.
+	Output string `json:"output,omitempty"`
+	// Rows is the number of rows written.
+	Rows   int                    `json:"rows"`
+	Tables []ExtractManifestTable `json:"tables"`
+	// Truncated lists the relations that weren't followed to the end.
+	Truncated []string `json:"truncated"`
+	// Dangling lists the foreign keys referencing rows not extracted.
+	Dangling []string              `json:"dangling"`
+	Masks    []ExtractManifestMask `json:"masks"`
+}
+
+// ExtractManifestSeed holds the seed parameters of an extraction. Table is
+// empty for an extraction from samples, which Filter describes.
+type ExtractManifestSeed struct {
+	Table  string   `json:"table,omitempty"`
+	Key    string   `json:"key,omitempty"`
+	Values []string `json:"values,omitempty"`
+	Where  string   `json:"where,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	// Filter describes the seed rows, e.g. id IN ('v1', 'v2'), or the
+	// samples.
+	Filter string `json:"filter"`
+}
+
+// ExtractManifestTable holds the number of rows written from a table.
+type ExtractManifestTable struct {
+	Table   string   `json:"table"`
+	Rows    int      `json:"rows"`
+	Omitted []string `json:"omitted_columns,omitempty"`
+}
+
+// ExtractManifestMask is a masked column and how it was masked.
+type ExtractManifestMask struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Action string `json:"action"`
+}
+
+// extractManifest describes an extraction, with its tables in the order
+// they can be inserted.
+func extractManifest(schemas []GenerationSchema, extraction *Extraction, cfg ExtractConfig, format string, output string) ExtractManifest {
+	values := cfg.SeedValues
+	if cfg.SeedValue != "" {
+		values = append([]string{cfg.SeedValue}, values...)
+	}
+	seed := ExtractManifestSeed{Filter: extraction.SeedFilter}
+	if extraction.Seed != nil {
+		seed.Table, seed.Values, seed.Where, seed.Args = extraction.Seed.Schema+"."+extraction.Seed.Name, values, cfg.SeedWhere, cfg.SeedArgs
+		if cfg.SeedWhere == "" {
+			seed.Key = cfg.SeedKey
+			if seed.Key == "" {
+				seed.Key = extraction.Seed.Config.PrimaryKey
+			}
+		}
+	}
+	manifest := ExtractManifest{
+		ExtractedAt: time.Now().UTC(),
+		Seed:        seed,
+		Format:      format,
+		Output:      output,
+		Rows:        extraction.rows,
+		Tables:      []ExtractManifestTable{},
+		Truncated:   append([]string{}, extraction.Truncated...),
+		Dangling:    append([]string{}, extraction.Dangling...),
+		Masks:       []ExtractManifestMask{},
+	}
+	for _, table := range ddlOrderedTables(schemas) {
+		extracted, ok := extraction.Tables[table]
+		if !ok || extracted.count == 0 {
+			continue
+		}
+		manifestTable := ExtractManifestTable{Table: table.Schema + "." + table.Name, Rows: extracted.count}
+		for _, c := range table.Columns {
+			if extracted.Omitted[c.Name] {
+				manifestTable.Omitted = append(manifestTable.Omitted, c.Name)
+			}
+		}
+		manifest.Tables = append(manifest.Tables, manifestTable)
+	}
+	for _, mask := range cfg.Masks {
+		table, err := extractSeedTable(schemas, mask.Table)
+		if err != nil {
+			continue
+		}
+		manifest.Masks = append(manifest.Masks, ExtractManifestMask{Table: table.Schema + "." + table.Name, Column: mask.Column, Action: mask.Action})
+	}
+	return manifest
+}
+
+// writeExtractManifest writes the manifest as an indented JSON document.
+func writeExtractManifest(w io.Writer, manifest ExtractManifest) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// extractSummary describes the manifest for people: the rows written from
+// each table, then what was truncated, left dangling, and masked.
+func extractSummary(manifest ExtractManifest) string {
+	b := strings.Builder{}
+	destination := manifest.Output
+	if manifest.Format == "clone" {
+		destination = "the target database"
+	} else if destination == "-" {
+		destination = "stdout"
+	}
+	source := manifest.Seed.Filter
+	if manifest.Seed.Table != "" {
+		source = manifest.Seed.Table + " where " + source
+	}
+	fmt.Fprintf(&b, "Extracted %d rows from %s into %s\n", manifest.Rows, source, destination)
+	width := 0
+	for _, table := range manifest.Tables {
+		width = max(width, len(table.Table))
+	}
+	for _, table := range manifest.Tables {
+		fmt.Fprintf(&b, "  %-*s %8d rows", width, table.Table, table.Rows)
+		if len(table.Omitted) > 0 {
+			fmt.Fprintf(&b, " (omitting %s)", strings.Join(table.Omitted, ", "))
+		}
+		b.WriteString("\n")
+	}
+	for _, relation := range manifest.Truncated {
+		fmt.Fprintf(&b, "Truncated %s\n", relation)
+	}
+	for _, relation := range manifest.Dangling {
+		fmt.Fprintf(&b, "Dangling %s\n", relation)
+	}
+	for _, mask := range manifest.Masks {
+		fmt.Fprintf(&b, "Masked %s.%s (%s)\n", mask.Table, mask.Column, mask.Action)
+	}
+	return b.String()
+}
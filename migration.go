@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Migration is a named pair of up and down SQL scripts.
+type Migration struct {
+	Name string
+	Up   string
+	Down string
+}
+
+// MigrationSource builds a migration from the inspected schemas. Sources that
+// have nothing to migrate return a Migration with an empty Up script.
+type MigrationSource func(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error)
+
+// migrationSources are selected by -migrations for the migration command.
+var migrationSources = map[string]MigrationSource{
+	"ddl":        ddlMigration,
+	"audit":      auditMigration,
+	"updated_at": updatedAtMigration,
+	"history":    historyMigration,
+	"outbox":     outboxMigration,
+	"rls":        rlsMigration,
+	"grants":     grantMigration,
+	"comments":   commentMigration,
+	"partitions": partitionMigration,
+	"postgrest":  postgrestMigration,
+}
+
+// writeMigrationUp writes a migration's up script as generated SQL, for the
+// targets that print what the migration command would write to a file.
+func writeMigrationUp(w io.Writer, source MigrationSource, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	migration, err := source(schemas, cfg)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "-- Code generated by pginspector. DO NOT EDIT.\n\n"+migration.Up)
+	return err
+}
+
+// migrationFilePattern matches golang-migrate file names, e.g.
+// 001_schema.up.sql.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_.+\.(up|down)\.sql$`)
+
+// nextMigrationVersion returns the version following the highest migration
+// in dir whose name matches pattern (with the version as its first group),
+// and the number of digits used to write it (at least minWidth, or as many as
+// the existing files use).
+func nextMigrationVersion(dir string, pattern *regexp.Regexp, minWidth int) (int, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, errors.WithMessage(err, "Unable to read migrations directory")
+	}
+	version, width := 0, minWidth
+	for _, entry := range entries {
+		match := pattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		existing, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		version = max(version, existing)
+		width = max(width, len(match[1]))
+	}
+	return version + 1, width, nil
+}
+
+// writeMigrations writes an up and down file per source into dir, numbered
+// after the migrations already there. It returns the paths written.
+func writeMigrations(dir string, sources []string, schemas []GenerationSchema, cfg GeneratorConfiguration) ([]string, error) {
+	migrations := []Migration{}
+	for _, name := range sources {
+		source, ok := migrationSources[name]
+		if !ok {
+			available := make([]string, 0, len(migrationSources))
+			for source := range migrationSources {
+				available = append(available, source)
+			}
+			sort.Strings(available)
+			return nil, errors.Errorf("Unknown migration source %q (expected one of %s)", name, strings.Join(available, ", "))
+		}
+		migration, err := source(schemas, cfg)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("Unable to build %s migration", name))
+		}
+		if migration.Up != "" {
+			migrations = append(migrations, migration)
+		}
+	}
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+
+	version, width, err := nextMigrationVersion(dir, migrationFilePattern, 3)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithMessage(err, "Unable to create migrations directory")
+	}
+	paths := []string{}
+	for _, migration := range migrations {
+		prefix := filepath.Join(dir, fmt.Sprintf("%0*d_%s", width, version, migration.Name))
+		for _, file := range []struct{ path, contents string }{
+			{prefix + ".up.sql", migration.Up},
+			{prefix + ".down.sql", migration.Down},
+		} {
+			if err := os.WriteFile(file.path, []byte(file.contents), 0644); err != nil {
+				return nil, errors.WithMessage(err, "Unable to write migration")
+			}
+			paths = append(paths, file.path)
+		}
+		version++
+	}
+	return paths, nil
+}
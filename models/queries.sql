@@ -5,9 +5,168 @@ SELECT
     data_type,
     column_default,
     is_nullable,
-    table_name
+    table_name,
+    is_identity,
+    is_generated,
+    udt_name,
+    character_maximum_length,
+    numeric_precision,
+    numeric_scale,
+    ordinal_position,
+    identity_generation,
+    generation_expression,
+    udt_schema
 FROM
     information_schema.columns
 WHERE
     table_schema = pggen.arg('schema_name')
 ORDER BY column_name;
+
+-- name: GetServerVersion :one
+SELECT current_setting('server_version') AS server_version;
+
+-- name: ListForeignKeysInSchema :many
+SELECT
+    kcu.table_name,
+    kcu.column_name,
+    rcu.table_schema AS foreign_table_schema,
+    rcu.table_name AS foreign_table_name,
+    rcu.column_name AS foreign_column_name,
+    tc.constraint_name
+FROM
+    information_schema.table_constraints AS tc
+    JOIN information_schema.key_column_usage AS kcu
+        ON tc.constraint_name = kcu.constraint_name
+        AND tc.table_schema = kcu.table_schema
+    JOIN information_schema.referential_constraints AS rc
+        ON rc.constraint_name = tc.constraint_name
+        AND rc.constraint_schema = tc.constraint_schema
+    JOIN information_schema.key_column_usage AS rcu
+        ON rcu.constraint_name = rc.unique_constraint_name
+        AND rcu.constraint_schema = rc.unique_constraint_schema
+        AND rcu.ordinal_position = kcu.position_in_unique_constraint
+WHERE
+    tc.constraint_type = 'FOREIGN KEY'
+    AND tc.table_schema = pggen.arg('schema_name')
+ORDER BY kcu.table_name, kcu.column_name;
+
+-- name: ListEnumValues :many
+SELECT
+    n.nspname AS enum_schema,
+    t.typname AS enum_name,
+    e.enumlabel AS enum_value
+FROM
+    pg_type AS t
+    JOIN pg_enum AS e ON e.enumtypid = t.oid
+    JOIN pg_namespace AS n ON n.oid = t.typnamespace
+ORDER BY n.nspname, t.typname, e.enumsortorder;
+
+-- name: ListCommentsInSchema :many
+SELECT
+    c.relname AS table_name,
+    COALESCE(a.attname, '') AS column_name,
+    d.description
+FROM
+    pg_description AS d
+    JOIN pg_class AS c ON c.oid = d.objoid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    LEFT JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum = d.objsubid AND d.objsubid > 0
+WHERE
+    d.classoid = 'pg_class'::regclass
+    AND n.nspname = pggen.arg('schema_name')
+ORDER BY c.relname, column_name;
+
+-- name: ListIndexesInSchema :many
+SELECT
+    t.relname AS table_name,
+    i.relname AS index_name,
+    ix.indisunique AS is_unique,
+    ix.indisprimary AS is_primary,
+    array_agg(a.attname ORDER BY k.ordinality)::text[] AS column_names,
+    pg_get_indexdef(ix.indexrelid) AS definition
+FROM
+    pg_index AS ix
+    JOIN pg_class AS t ON t.oid = ix.indrelid
+    JOIN pg_class AS i ON i.oid = ix.indexrelid
+    JOIN pg_namespace AS n ON n.oid = t.relnamespace
+    CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ordinality)
+    JOIN pg_attribute AS a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE
+    n.nspname = pggen.arg('schema_name')
+GROUP BY t.relname, i.relname, ix.indexrelid, ix.indisunique, ix.indisprimary
+ORDER BY t.relname, i.relname;
+
+-- name: ListConstraintsInSchema :many
+SELECT
+    t.relname AS table_name,
+    c.conname AS constraint_name,
+    c.contype::text AS constraint_type,
+    pg_get_constraintdef(c.oid) AS definition
+FROM
+    pg_constraint AS c
+    JOIN pg_class AS t ON t.oid = c.conrelid
+    JOIN pg_namespace AS n ON n.oid = t.relnamespace
+WHERE
+    n.nspname = pggen.arg('schema_name')
+ORDER BY t.relname, c.conname;
+
+-- name: ListTriggersInSchema :many
+SELECT
+    c.relname AS table_name,
+    t.tgname AS trigger_name,
+    pn.nspname AS function_schema,
+    p.proname AS function_name,
+    p.prosrc AS function_source,
+    pg_get_triggerdef(t.oid) AS definition
+FROM
+    pg_trigger AS t
+    JOIN pg_class AS c ON c.oid = t.tgrelid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    JOIN pg_proc AS p ON p.oid = t.tgfoid
+    JOIN pg_namespace AS pn ON pn.oid = p.pronamespace
+WHERE
+    NOT t.tgisinternal
+    AND n.nspname = pggen.arg('schema_name')
+ORDER BY c.relname, t.tgname;
+
+-- name: ListPartitionedTablesInSchema :many
+SELECT
+    c.relname AS table_name,
+    pt.partstrat::text AS strategy,
+    array_agg(a.attname ORDER BY k.ordinality)::text[] AS column_names
+FROM
+    pg_partitioned_table AS pt
+    JOIN pg_class AS c ON c.oid = pt.partrelid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    CROSS JOIN LATERAL unnest(pt.partattrs) WITH ORDINALITY AS k(attnum, ordinality)
+    JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum = k.attnum
+WHERE
+    n.nspname = pggen.arg('schema_name')
+GROUP BY c.relname, pt.partstrat
+ORDER BY c.relname;
+
+-- name: ListSchemas :many
+SELECT n.nspname::text AS schema_name
+FROM pg_namespace AS n
+WHERE
+    n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+    AND n.nspname NOT LIKE 'pg\_temp\_%'
+    AND n.nspname NOT LIKE 'pg\_toast\_temp\_%'
+    AND EXISTS (
+        SELECT 1
+        FROM pg_class AS c
+        WHERE c.relnamespace = n.oid AND c.relkind IN ('r', 'p')
+    )
+ORDER BY n.nspname;
+
+-- name: ListTables :many
+SELECT (CASE WHEN n.nspname = 'public' THEN c.relname::text ELSE n.nspname || '.' || c.relname END)::text AS table_name
+FROM pg_class AS c
+JOIN pg_namespace AS n ON n.oid = c.relnamespace
+WHERE
+    c.relkind IN ('r', 'p')
+    AND NOT c.relispartition
+    AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+    AND n.nspname NOT LIKE 'pg\_temp\_%'
+    AND n.nspname NOT LIKE 'pg\_toast\_temp\_%'
+ORDER BY n.nspname, c.relname;
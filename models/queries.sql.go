@@ -23,6 +23,76 @@ type Querier interface {
 	ListTableColumnsInSchemaBatch(batch genericBatch, schemaName string)
 	// ListTableColumnsInSchemaScan scans the result of an executed ListTableColumnsInSchemaBatch query.
 	ListTableColumnsInSchemaScan(results pgx.BatchResults) ([]ListTableColumnsInSchemaRow, error)
+
+	GetServerVersion(ctx context.Context) (*string, error)
+	// GetServerVersionBatch enqueues a GetServerVersion query into batch to be executed
+	// later by the batch.
+	GetServerVersionBatch(batch genericBatch)
+	// GetServerVersionScan scans the result of an executed GetServerVersionBatch query.
+	GetServerVersionScan(results pgx.BatchResults) (*string, error)
+
+	ListForeignKeysInSchema(ctx context.Context, schemaName string) ([]ListForeignKeysInSchemaRow, error)
+	// ListForeignKeysInSchemaBatch enqueues a ListForeignKeysInSchema query into batch to be executed
+	// later by the batch.
+	ListForeignKeysInSchemaBatch(batch genericBatch, schemaName string)
+	// ListForeignKeysInSchemaScan scans the result of an executed ListForeignKeysInSchemaBatch query.
+	ListForeignKeysInSchemaScan(results pgx.BatchResults) ([]ListForeignKeysInSchemaRow, error)
+
+	ListEnumValues(ctx context.Context) ([]ListEnumValuesRow, error)
+	// ListEnumValuesBatch enqueues a ListEnumValues query into batch to be executed
+	// later by the batch.
+	ListEnumValuesBatch(batch genericBatch)
+	// ListEnumValuesScan scans the result of an executed ListEnumValuesBatch query.
+	ListEnumValuesScan(results pgx.BatchResults) ([]ListEnumValuesRow, error)
+
+	ListCommentsInSchema(ctx context.Context, schemaName string) ([]ListCommentsInSchemaRow, error)
+	// ListCommentsInSchemaBatch enqueues a ListCommentsInSchema query into batch to be executed
+	// later by the batch.
+	ListCommentsInSchemaBatch(batch genericBatch, schemaName string)
+	// ListCommentsInSchemaScan scans the result of an executed ListCommentsInSchemaBatch query.
+	ListCommentsInSchemaScan(results pgx.BatchResults) ([]ListCommentsInSchemaRow, error)
+
+	ListIndexesInSchema(ctx context.Context, schemaName string) ([]ListIndexesInSchemaRow, error)
+	// ListIndexesInSchemaBatch enqueues a ListIndexesInSchema query into batch to be executed
+	// later by the batch.
+	ListIndexesInSchemaBatch(batch genericBatch, schemaName string)
+	// ListIndexesInSchemaScan scans the result of an executed ListIndexesInSchemaBatch query.
+	ListIndexesInSchemaScan(results pgx.BatchResults) ([]ListIndexesInSchemaRow, error)
+
+	ListConstraintsInSchema(ctx context.Context, schemaName string) ([]ListConstraintsInSchemaRow, error)
+	// ListConstraintsInSchemaBatch enqueues a ListConstraintsInSchema query into batch to be executed
+	// later by the batch.
+	ListConstraintsInSchemaBatch(batch genericBatch, schemaName string)
+	// ListConstraintsInSchemaScan scans the result of an executed ListConstraintsInSchemaBatch query.
+	ListConstraintsInSchemaScan(results pgx.BatchResults) ([]ListConstraintsInSchemaRow, error)
+
+	ListTriggersInSchema(ctx context.Context, schemaName string) ([]ListTriggersInSchemaRow, error)
+	// ListTriggersInSchemaBatch enqueues a ListTriggersInSchema query into batch to be executed
+	// later by the batch.
+	ListTriggersInSchemaBatch(batch genericBatch, schemaName string)
+	// ListTriggersInSchemaScan scans the result of an executed ListTriggersInSchemaBatch query.
+	ListTriggersInSchemaScan(results pgx.BatchResults) ([]ListTriggersInSchemaRow, error)
+
+	ListPartitionedTablesInSchema(ctx context.Context, schemaName string) ([]ListPartitionedTablesInSchemaRow, error)
+	// ListPartitionedTablesInSchemaBatch enqueues a ListPartitionedTablesInSchema query into batch to be executed
+	// later by the batch.
+	ListPartitionedTablesInSchemaBatch(batch genericBatch, schemaName string)
+	// ListPartitionedTablesInSchemaScan scans the result of an executed ListPartitionedTablesInSchemaBatch query.
+	ListPartitionedTablesInSchemaScan(results pgx.BatchResults) ([]ListPartitionedTablesInSchemaRow, error)
+
+	ListSchemas(ctx context.Context) ([]string, error)
+	// ListSchemasBatch enqueues a ListSchemas query into batch to be executed
+	// later by the batch.
+	ListSchemasBatch(batch genericBatch)
+	// ListSchemasScan scans the result of an executed ListSchemasBatch query.
+	ListSchemasScan(results pgx.BatchResults) ([]string, error)
+
+	ListTables(ctx context.Context) ([]string, error)
+	// ListTablesBatch enqueues a ListTables query into batch to be executed
+	// later by the batch.
+	ListTablesBatch(batch genericBatch)
+	// ListTablesScan scans the result of an executed ListTablesBatch query.
+	ListTablesScan(results pgx.BatchResults) ([]string, error)
 }
 
 type DBQuerier struct {
@@ -103,6 +173,36 @@ func PrepareAllQueries(ctx context.Context, p preparer) error {
 	if _, err := p.Prepare(ctx, listTableColumnsInSchemaSQL, listTableColumnsInSchemaSQL); err != nil {
 		return fmt.Errorf("prepare query 'ListTableColumnsInSchema': %w", err)
 	}
+	if _, err := p.Prepare(ctx, getServerVersionSQL, getServerVersionSQL); err != nil {
+		return fmt.Errorf("prepare query 'GetServerVersion': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listForeignKeysInSchemaSQL, listForeignKeysInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListForeignKeysInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listEnumValuesSQL, listEnumValuesSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListEnumValues': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listCommentsInSchemaSQL, listCommentsInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListCommentsInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listIndexesInSchemaSQL, listIndexesInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListIndexesInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listConstraintsInSchemaSQL, listConstraintsInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListConstraintsInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listTriggersInSchemaSQL, listTriggersInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListTriggersInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listPartitionedTablesInSchemaSQL, listPartitionedTablesInSchemaSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListPartitionedTablesInSchema': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listSchemasSQL, listSchemasSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListSchemas': %w", err)
+	}
+	if _, err := p.Prepare(ctx, listTablesSQL, listTablesSQL); err != nil {
+		return fmt.Errorf("prepare query 'ListTables': %w", err)
+	}
 	return nil
 }
 
@@ -146,7 +246,17 @@ const listTableColumnsInSchemaSQL = `SELECT
     data_type,
     column_default,
     is_nullable,
-    table_name
+    table_name,
+    is_identity,
+    is_generated,
+    udt_name,
+    character_maximum_length,
+    numeric_precision,
+    numeric_scale,
+    ordinal_position,
+    identity_generation,
+    generation_expression,
+    udt_schema
 FROM
     information_schema.columns
 WHERE
@@ -154,11 +264,21 @@ WHERE
 ORDER BY column_name;`
 
 type ListTableColumnsInSchemaRow struct {
-	ColumnName    string  `json:"column_name"`
-	DataType      *string `json:"data_type"`
-	ColumnDefault *string `json:"column_default"`
-	IsNullable    *string `json:"is_nullable"`
-	TableName     string  `json:"table_name"`
+	ColumnName             string  `json:"column_name"`
+	DataType               *string `json:"data_type"`
+	ColumnDefault          *string `json:"column_default"`
+	IsNullable             *string `json:"is_nullable"`
+	TableName              string  `json:"table_name"`
+	IsIdentity             *string `json:"is_identity"`
+	IsGenerated            *string `json:"is_generated"`
+	UdtName                string  `json:"udt_name"`
+	CharacterMaximumLength *int32  `json:"character_maximum_length"`
+	NumericPrecision       *int32  `json:"numeric_precision"`
+	NumericScale           *int32  `json:"numeric_scale"`
+	OrdinalPosition        *int32  `json:"ordinal_position"`
+	IdentityGeneration     *string `json:"identity_generation"`
+	GenerationExpression   *string `json:"generation_expression"`
+	UdtSchema              string  `json:"udt_schema"`
 }
 
 // ListTableColumnsInSchema implements Querier.ListTableColumnsInSchema.
@@ -172,7 +292,7 @@ func (q *DBQuerier) ListTableColumnsInSchema(ctx context.Context, schemaName str
 	items := []ListTableColumnsInSchemaRow{}
 	for rows.Next() {
 		var item ListTableColumnsInSchemaRow
-		if err := rows.Scan(&item.ColumnName, &item.DataType, &item.ColumnDefault, &item.IsNullable, &item.TableName); err != nil {
+		if err := rows.Scan(&item.ColumnName, &item.DataType, &item.ColumnDefault, &item.IsNullable, &item.TableName, &item.IsIdentity, &item.IsGenerated, &item.UdtName, &item.CharacterMaximumLength, &item.NumericPrecision, &item.NumericScale, &item.OrdinalPosition, &item.IdentityGeneration, &item.GenerationExpression, &item.UdtSchema); err != nil {
 			return nil, fmt.Errorf("scan ListTableColumnsInSchema row: %w", err)
 		}
 		items = append(items, item)
@@ -198,7 +318,7 @@ func (q *DBQuerier) ListTableColumnsInSchemaScan(results pgx.BatchResults) ([]Li
 	items := []ListTableColumnsInSchemaRow{}
 	for rows.Next() {
 		var item ListTableColumnsInSchemaRow
-		if err := rows.Scan(&item.ColumnName, &item.DataType, &item.ColumnDefault, &item.IsNullable, &item.TableName); err != nil {
+		if err := rows.Scan(&item.ColumnName, &item.DataType, &item.ColumnDefault, &item.IsNullable, &item.TableName, &item.IsIdentity, &item.IsGenerated, &item.UdtName, &item.CharacterMaximumLength, &item.NumericPrecision, &item.NumericScale, &item.OrdinalPosition, &item.IdentityGeneration, &item.GenerationExpression, &item.UdtSchema); err != nil {
 			return nil, fmt.Errorf("scan ListTableColumnsInSchemaBatch row: %w", err)
 		}
 		items = append(items, item)
@@ -209,6 +329,655 @@ func (q *DBQuerier) ListTableColumnsInSchemaScan(results pgx.BatchResults) ([]Li
 	return items, err
 }
 
+const getServerVersionSQL = `SELECT current_setting('server_version') AS server_version;`
+
+// GetServerVersion implements Querier.GetServerVersion.
+func (q *DBQuerier) GetServerVersion(ctx context.Context) (*string, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "GetServerVersion")
+	row := q.conn.QueryRow(ctx, getServerVersionSQL)
+	var item *string
+	if err := row.Scan(&item); err != nil {
+		return item, fmt.Errorf("query GetServerVersion: %w", err)
+	}
+	return item, nil
+}
+
+// GetServerVersionBatch implements Querier.GetServerVersionBatch.
+func (q *DBQuerier) GetServerVersionBatch(batch genericBatch) {
+	batch.Queue(getServerVersionSQL)
+}
+
+// GetServerVersionScan implements Querier.GetServerVersionScan.
+func (q *DBQuerier) GetServerVersionScan(results pgx.BatchResults) (*string, error) {
+	row := results.QueryRow()
+	var item *string
+	if err := row.Scan(&item); err != nil {
+		return item, fmt.Errorf("scan GetServerVersionBatch row: %w", err)
+	}
+	return item, nil
+}
+
+const listForeignKeysInSchemaSQL = `SELECT
+    kcu.table_name,
+    kcu.column_name,
+    rcu.table_schema AS foreign_table_schema,
+    rcu.table_name AS foreign_table_name,
+    rcu.column_name AS foreign_column_name,
+    tc.constraint_name
+FROM
+    information_schema.table_constraints AS tc
+    JOIN information_schema.key_column_usage AS kcu
+        ON tc.constraint_name = kcu.constraint_name
+        AND tc.table_schema = kcu.table_schema
+    JOIN information_schema.referential_constraints AS rc
+        ON rc.constraint_name = tc.constraint_name
+        AND rc.constraint_schema = tc.constraint_schema
+    JOIN information_schema.key_column_usage AS rcu
+        ON rcu.constraint_name = rc.unique_constraint_name
+        AND rcu.constraint_schema = rc.unique_constraint_schema
+        AND rcu.ordinal_position = kcu.position_in_unique_constraint
+WHERE
+    tc.constraint_type = 'FOREIGN KEY'
+    AND tc.table_schema = $1
+ORDER BY kcu.table_name, kcu.column_name;`
+
+type ListForeignKeysInSchemaRow struct {
+	TableName          string `json:"table_name"`
+	ColumnName         string `json:"column_name"`
+	ForeignTableSchema string `json:"foreign_table_schema"`
+	ForeignTableName   string `json:"foreign_table_name"`
+	ForeignColumnName  string `json:"foreign_column_name"`
+	ConstraintName     string `json:"constraint_name"`
+}
+
+// ListForeignKeysInSchema implements Querier.ListForeignKeysInSchema.
+func (q *DBQuerier) ListForeignKeysInSchema(ctx context.Context, schemaName string) ([]ListForeignKeysInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListForeignKeysInSchema")
+	rows, err := q.conn.Query(ctx, listForeignKeysInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListForeignKeysInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListForeignKeysInSchemaRow{}
+	for rows.Next() {
+		var item ListForeignKeysInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ColumnName, &item.ForeignTableSchema, &item.ForeignTableName, &item.ForeignColumnName, &item.ConstraintName); err != nil {
+			return nil, fmt.Errorf("scan ListForeignKeysInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListForeignKeysInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListForeignKeysInSchemaBatch implements Querier.ListForeignKeysInSchemaBatch.
+func (q *DBQuerier) ListForeignKeysInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listForeignKeysInSchemaSQL, schemaName)
+}
+
+// ListForeignKeysInSchemaScan implements Querier.ListForeignKeysInSchemaScan.
+func (q *DBQuerier) ListForeignKeysInSchemaScan(results pgx.BatchResults) ([]ListForeignKeysInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListForeignKeysInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListForeignKeysInSchemaRow{}
+	for rows.Next() {
+		var item ListForeignKeysInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ColumnName, &item.ForeignTableSchema, &item.ForeignTableName, &item.ForeignColumnName, &item.ConstraintName); err != nil {
+			return nil, fmt.Errorf("scan ListForeignKeysInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListForeignKeysInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listEnumValuesSQL = `SELECT
+    n.nspname AS enum_schema,
+    t.typname AS enum_name,
+    e.enumlabel AS enum_value
+FROM
+    pg_type AS t
+    JOIN pg_enum AS e ON e.enumtypid = t.oid
+    JOIN pg_namespace AS n ON n.oid = t.typnamespace
+ORDER BY n.nspname, t.typname, e.enumsortorder;`
+
+type ListEnumValuesRow struct {
+	EnumSchema string `json:"enum_schema"`
+	EnumName   string `json:"enum_name"`
+	EnumValue  string `json:"enum_value"`
+}
+
+// ListEnumValues implements Querier.ListEnumValues.
+func (q *DBQuerier) ListEnumValues(ctx context.Context) ([]ListEnumValuesRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListEnumValues")
+	rows, err := q.conn.Query(ctx, listEnumValuesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("query ListEnumValues: %w", err)
+	}
+	defer rows.Close()
+	items := []ListEnumValuesRow{}
+	for rows.Next() {
+		var item ListEnumValuesRow
+		if err := rows.Scan(&item.EnumSchema, &item.EnumName, &item.EnumValue); err != nil {
+			return nil, fmt.Errorf("scan ListEnumValues row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListEnumValues rows: %w", err)
+	}
+	return items, err
+}
+
+// ListEnumValuesBatch implements Querier.ListEnumValuesBatch.
+func (q *DBQuerier) ListEnumValuesBatch(batch genericBatch) {
+	batch.Queue(listEnumValuesSQL)
+}
+
+// ListEnumValuesScan implements Querier.ListEnumValuesScan.
+func (q *DBQuerier) ListEnumValuesScan(results pgx.BatchResults) ([]ListEnumValuesRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListEnumValuesBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListEnumValuesRow{}
+	for rows.Next() {
+		var item ListEnumValuesRow
+		if err := rows.Scan(&item.EnumSchema, &item.EnumName, &item.EnumValue); err != nil {
+			return nil, fmt.Errorf("scan ListEnumValuesBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListEnumValuesBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listCommentsInSchemaSQL = `SELECT
+    c.relname AS table_name,
+    COALESCE(a.attname, '') AS column_name,
+    d.description
+FROM
+    pg_description AS d
+    JOIN pg_class AS c ON c.oid = d.objoid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    LEFT JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum = d.objsubid AND d.objsubid > 0
+WHERE
+    d.classoid = 'pg_class'::regclass
+    AND n.nspname = $1
+ORDER BY c.relname, column_name;`
+
+type ListCommentsInSchemaRow struct {
+	TableName   string  `json:"table_name"`
+	ColumnName  *string `json:"column_name"`
+	Description *string `json:"description"`
+}
+
+// ListCommentsInSchema implements Querier.ListCommentsInSchema.
+func (q *DBQuerier) ListCommentsInSchema(ctx context.Context, schemaName string) ([]ListCommentsInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListCommentsInSchema")
+	rows, err := q.conn.Query(ctx, listCommentsInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListCommentsInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListCommentsInSchemaRow{}
+	for rows.Next() {
+		var item ListCommentsInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ColumnName, &item.Description); err != nil {
+			return nil, fmt.Errorf("scan ListCommentsInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListCommentsInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListCommentsInSchemaBatch implements Querier.ListCommentsInSchemaBatch.
+func (q *DBQuerier) ListCommentsInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listCommentsInSchemaSQL, schemaName)
+}
+
+// ListCommentsInSchemaScan implements Querier.ListCommentsInSchemaScan.
+func (q *DBQuerier) ListCommentsInSchemaScan(results pgx.BatchResults) ([]ListCommentsInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListCommentsInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListCommentsInSchemaRow{}
+	for rows.Next() {
+		var item ListCommentsInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ColumnName, &item.Description); err != nil {
+			return nil, fmt.Errorf("scan ListCommentsInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListCommentsInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listIndexesInSchemaSQL = `SELECT
+    t.relname AS table_name,
+    i.relname AS index_name,
+    ix.indisunique AS is_unique,
+    ix.indisprimary AS is_primary,
+    array_agg(a.attname ORDER BY k.ordinality)::text[] AS column_names,
+    pg_get_indexdef(ix.indexrelid) AS definition
+FROM
+    pg_index AS ix
+    JOIN pg_class AS t ON t.oid = ix.indrelid
+    JOIN pg_class AS i ON i.oid = ix.indexrelid
+    JOIN pg_namespace AS n ON n.oid = t.relnamespace
+    CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ordinality)
+    JOIN pg_attribute AS a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE
+    n.nspname = $1
+GROUP BY t.relname, i.relname, ix.indexrelid, ix.indisunique, ix.indisprimary
+ORDER BY t.relname, i.relname;`
+
+type ListIndexesInSchemaRow struct {
+	TableName   string   `json:"table_name"`
+	IndexName   string   `json:"index_name"`
+	IsUnique    bool     `json:"is_unique"`
+	IsPrimary   bool     `json:"is_primary"`
+	ColumnNames []string `json:"column_names"`
+	Definition  string   `json:"definition"`
+}
+
+// ListIndexesInSchema implements Querier.ListIndexesInSchema.
+func (q *DBQuerier) ListIndexesInSchema(ctx context.Context, schemaName string) ([]ListIndexesInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListIndexesInSchema")
+	rows, err := q.conn.Query(ctx, listIndexesInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListIndexesInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListIndexesInSchemaRow{}
+	for rows.Next() {
+		var item ListIndexesInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.IndexName, &item.IsUnique, &item.IsPrimary, &item.ColumnNames, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListIndexesInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListIndexesInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListIndexesInSchemaBatch implements Querier.ListIndexesInSchemaBatch.
+func (q *DBQuerier) ListIndexesInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listIndexesInSchemaSQL, schemaName)
+}
+
+// ListIndexesInSchemaScan implements Querier.ListIndexesInSchemaScan.
+func (q *DBQuerier) ListIndexesInSchemaScan(results pgx.BatchResults) ([]ListIndexesInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListIndexesInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListIndexesInSchemaRow{}
+	for rows.Next() {
+		var item ListIndexesInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.IndexName, &item.IsUnique, &item.IsPrimary, &item.ColumnNames, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListIndexesInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListIndexesInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listConstraintsInSchemaSQL = `SELECT
+    t.relname AS table_name,
+    c.conname AS constraint_name,
+    c.contype::text AS constraint_type,
+    pg_get_constraintdef(c.oid) AS definition
+FROM
+    pg_constraint AS c
+    JOIN pg_class AS t ON t.oid = c.conrelid
+    JOIN pg_namespace AS n ON n.oid = t.relnamespace
+WHERE
+    n.nspname = $1
+ORDER BY t.relname, c.conname;`
+
+type ListConstraintsInSchemaRow struct {
+	TableName      string `json:"table_name"`
+	ConstraintName string `json:"constraint_name"`
+	ConstraintType string `json:"constraint_type"`
+	Definition     string `json:"definition"`
+}
+
+// ListConstraintsInSchema implements Querier.ListConstraintsInSchema.
+func (q *DBQuerier) ListConstraintsInSchema(ctx context.Context, schemaName string) ([]ListConstraintsInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListConstraintsInSchema")
+	rows, err := q.conn.Query(ctx, listConstraintsInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListConstraintsInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListConstraintsInSchemaRow{}
+	for rows.Next() {
+		var item ListConstraintsInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ConstraintName, &item.ConstraintType, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListConstraintsInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListConstraintsInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListConstraintsInSchemaBatch implements Querier.ListConstraintsInSchemaBatch.
+func (q *DBQuerier) ListConstraintsInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listConstraintsInSchemaSQL, schemaName)
+}
+
+// ListConstraintsInSchemaScan implements Querier.ListConstraintsInSchemaScan.
+func (q *DBQuerier) ListConstraintsInSchemaScan(results pgx.BatchResults) ([]ListConstraintsInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListConstraintsInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListConstraintsInSchemaRow{}
+	for rows.Next() {
+		var item ListConstraintsInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.ConstraintName, &item.ConstraintType, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListConstraintsInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListConstraintsInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listTriggersInSchemaSQL = `SELECT
+    c.relname AS table_name,
+    t.tgname AS trigger_name,
+    pn.nspname AS function_schema,
+    p.proname AS function_name,
+    p.prosrc AS function_source,
+    pg_get_triggerdef(t.oid) AS definition
+FROM
+    pg_trigger AS t
+    JOIN pg_class AS c ON c.oid = t.tgrelid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    JOIN pg_proc AS p ON p.oid = t.tgfoid
+    JOIN pg_namespace AS pn ON pn.oid = p.pronamespace
+WHERE
+    NOT t.tgisinternal
+    AND n.nspname = $1
+ORDER BY c.relname, t.tgname;`
+
+type ListTriggersInSchemaRow struct {
+	TableName      string `json:"table_name"`
+	TriggerName    string `json:"trigger_name"`
+	FunctionSchema string `json:"function_schema"`
+	FunctionName   string `json:"function_name"`
+	FunctionSource string `json:"function_source"`
+	Definition     string `json:"definition"`
+}
+
+// ListTriggersInSchema implements Querier.ListTriggersInSchema.
+func (q *DBQuerier) ListTriggersInSchema(ctx context.Context, schemaName string) ([]ListTriggersInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListTriggersInSchema")
+	rows, err := q.conn.Query(ctx, listTriggersInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListTriggersInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListTriggersInSchemaRow{}
+	for rows.Next() {
+		var item ListTriggersInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.TriggerName, &item.FunctionSchema, &item.FunctionName, &item.FunctionSource, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListTriggersInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListTriggersInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListTriggersInSchemaBatch implements Querier.ListTriggersInSchemaBatch.
+func (q *DBQuerier) ListTriggersInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listTriggersInSchemaSQL, schemaName)
+}
+
+// ListTriggersInSchemaScan implements Querier.ListTriggersInSchemaScan.
+func (q *DBQuerier) ListTriggersInSchemaScan(results pgx.BatchResults) ([]ListTriggersInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListTriggersInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListTriggersInSchemaRow{}
+	for rows.Next() {
+		var item ListTriggersInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.TriggerName, &item.FunctionSchema, &item.FunctionName, &item.FunctionSource, &item.Definition); err != nil {
+			return nil, fmt.Errorf("scan ListTriggersInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListTriggersInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listPartitionedTablesInSchemaSQL = `SELECT
+    c.relname AS table_name,
+    pt.partstrat::text AS strategy,
+    array_agg(a.attname ORDER BY k.ordinality)::text[] AS column_names
+FROM
+    pg_partitioned_table AS pt
+    JOIN pg_class AS c ON c.oid = pt.partrelid
+    JOIN pg_namespace AS n ON n.oid = c.relnamespace
+    CROSS JOIN LATERAL unnest(pt.partattrs) WITH ORDINALITY AS k(attnum, ordinality)
+    JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum = k.attnum
+WHERE
+    n.nspname = $1
+GROUP BY c.relname, pt.partstrat
+ORDER BY c.relname;`
+
+type ListPartitionedTablesInSchemaRow struct {
+	TableName   string   `json:"table_name"`
+	Strategy    string   `json:"strategy"`
+	ColumnNames []string `json:"column_names"`
+}
+
+// ListPartitionedTablesInSchema implements Querier.ListPartitionedTablesInSchema.
+func (q *DBQuerier) ListPartitionedTablesInSchema(ctx context.Context, schemaName string) ([]ListPartitionedTablesInSchemaRow, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListPartitionedTablesInSchema")
+	rows, err := q.conn.Query(ctx, listPartitionedTablesInSchemaSQL, schemaName)
+	if err != nil {
+		return nil, fmt.Errorf("query ListPartitionedTablesInSchema: %w", err)
+	}
+	defer rows.Close()
+	items := []ListPartitionedTablesInSchemaRow{}
+	for rows.Next() {
+		var item ListPartitionedTablesInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.Strategy, &item.ColumnNames); err != nil {
+			return nil, fmt.Errorf("scan ListPartitionedTablesInSchema row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListPartitionedTablesInSchema rows: %w", err)
+	}
+	return items, err
+}
+
+// ListPartitionedTablesInSchemaBatch implements Querier.ListPartitionedTablesInSchemaBatch.
+func (q *DBQuerier) ListPartitionedTablesInSchemaBatch(batch genericBatch, schemaName string) {
+	batch.Queue(listPartitionedTablesInSchemaSQL, schemaName)
+}
+
+// ListPartitionedTablesInSchemaScan implements Querier.ListPartitionedTablesInSchemaScan.
+func (q *DBQuerier) ListPartitionedTablesInSchemaScan(results pgx.BatchResults) ([]ListPartitionedTablesInSchemaRow, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListPartitionedTablesInSchemaBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []ListPartitionedTablesInSchemaRow{}
+	for rows.Next() {
+		var item ListPartitionedTablesInSchemaRow
+		if err := rows.Scan(&item.TableName, &item.Strategy, &item.ColumnNames); err != nil {
+			return nil, fmt.Errorf("scan ListPartitionedTablesInSchemaBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListPartitionedTablesInSchemaBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listSchemasSQL = `SELECT n.nspname::text AS schema_name
+FROM pg_namespace AS n
+WHERE
+    n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+    AND n.nspname NOT LIKE 'pg\_temp\_%'
+    AND n.nspname NOT LIKE 'pg\_toast\_temp\_%'
+    AND EXISTS (
+        SELECT 1
+        FROM pg_class AS c
+        WHERE c.relnamespace = n.oid AND c.relkind IN ('r', 'p')
+    )
+ORDER BY n.nspname;`
+
+// ListSchemas implements Querier.ListSchemas.
+func (q *DBQuerier) ListSchemas(ctx context.Context) ([]string, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListSchemas")
+	rows, err := q.conn.Query(ctx, listSchemasSQL)
+	if err != nil {
+		return nil, fmt.Errorf("query ListSchemas: %w", err)
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return nil, fmt.Errorf("scan ListSchemas row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListSchemas rows: %w", err)
+	}
+	return items, err
+}
+
+// ListSchemasBatch implements Querier.ListSchemasBatch.
+func (q *DBQuerier) ListSchemasBatch(batch genericBatch) {
+	batch.Queue(listSchemasSQL)
+}
+
+// ListSchemasScan implements Querier.ListSchemasScan.
+func (q *DBQuerier) ListSchemasScan(results pgx.BatchResults) ([]string, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListSchemasBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return nil, fmt.Errorf("scan ListSchemasBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListSchemasBatch rows: %w", err)
+	}
+	return items, err
+}
+
+const listTablesSQL = `SELECT (CASE WHEN n.nspname = 'public' THEN c.relname::text ELSE n.nspname || '.' || c.relname END)::text AS table_name
+FROM pg_class AS c
+JOIN pg_namespace AS n ON n.oid = c.relnamespace
+WHERE
+    c.relkind IN ('r', 'p')
+    AND NOT c.relispartition
+    AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+    AND n.nspname NOT LIKE 'pg\_temp\_%'
+    AND n.nspname NOT LIKE 'pg\_toast\_temp\_%'
+ORDER BY n.nspname, c.relname;`
+
+// ListTables implements Querier.ListTables.
+func (q *DBQuerier) ListTables(ctx context.Context) ([]string, error) {
+	ctx = context.WithValue(ctx, "pggen_query_name", "ListTables")
+	rows, err := q.conn.Query(ctx, listTablesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("query ListTables: %w", err)
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return nil, fmt.Errorf("scan ListTables row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListTables rows: %w", err)
+	}
+	return items, err
+}
+
+// ListTablesBatch implements Querier.ListTablesBatch.
+func (q *DBQuerier) ListTablesBatch(batch genericBatch) {
+	batch.Queue(listTablesSQL)
+}
+
+// ListTablesScan implements Querier.ListTablesScan.
+func (q *DBQuerier) ListTablesScan(results pgx.BatchResults) ([]string, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("query ListTablesBatch: %w", err)
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return nil, fmt.Errorf("scan ListTablesBatch row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close ListTablesBatch rows: %w", err)
+	}
+	return items, err
+}
+
 // textPreferrer wraps a pgtype.ValueTranscoder and sets the preferred encoding
 // format to text instead binary (the default). pggen uses the text format
 // when the OID is unknownOID because the binary format requires the OID.
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/iancoleman/strcase"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonType is the JSON representation of a column kind, as used by OpenAPI
+// and JSON Schema.
+type jsonType struct {
+	Type   string
+	Format string
+}
+
+var jsonTypes = map[TypeKind]jsonType{
+	KindInt16:       {Type: "integer", Format: "int32"},
+	KindInt32:       {Type: "integer", Format: "int32"},
+	KindInt64:       {Type: "integer", Format: "int64"},
+	KindFloat32:     {Type: "number", Format: "float"},
+	KindFloat64:     {Type: "number", Format: "double"},
+	KindNumeric:     {Type: "string", Format: "decimal"},
+	KindBool:        {Type: "boolean"},
+	KindString:      {Type: "string"},
+	KindUUID:        {Type: "string", Format: "uuid"},
+	KindTimestamp:   {Type: "string", Format: "date-time"},
+	KindTimestamptz: {Type: "string", Format: "date-time"},
+	KindDate:        {Type: "string", Format: "date"},
+	KindTime:        {Type: "string", Format: "time"},
+	KindInterval:    {Type: "string", Format: "duration"},
+	KindJSON:        {},
+	KindBytes:       {Type: "string", Format: "byte"},
+	KindEnum:        {Type: "string"},
+	KindUnknown:     {Type: "string"},
+}
+
+// OpenAPIDocument is the subset of an OpenAPI 3.0 document that is generated.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `yaml:"openapi"`
+	Info       OpenAPIInfo                `yaml:"info"`
+	Paths      map[string]OpenAPIPathItem `yaml:"paths"`
+	Components OpenAPIComponents          `yaml:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+type OpenAPIPathItem struct {
+	Parameters []OpenAPIParameter `yaml:"parameters,omitempty"`
+	Get        *OpenAPIOperation  `yaml:"get,omitempty"`
+	Post       *OpenAPIOperation  `yaml:"post,omitempty"`
+	Put        *OpenAPIOperation  `yaml:"put,omitempty"`
+	Delete     *OpenAPIOperation  `yaml:"delete,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `yaml:"name"`
+	In       string         `yaml:"in"`
+	Required bool           `yaml:"required"`
+	Schema   *OpenAPISchema `yaml:"schema"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                     `yaml:"operationId"`
+	Tags        []string                   `yaml:"tags,omitempty"`
+	RequestBody *OpenAPIRequestBody        `yaml:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `yaml:"responses"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `yaml:"required"`
+	Content  map[string]OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `yaml:"description"`
+	Content     map[string]OpenAPIMediaType `yaml:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `yaml:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `yaml:"schemas"`
+}
+
+type OpenAPISchema struct {
+	Ref         string                    `yaml:"$ref,omitempty"`
+	Type        string                    `yaml:"type,omitempty"`
+	Format      string                    `yaml:"format,omitempty"`
+	Description string                    `yaml:"description,omitempty"`
+	Nullable    bool                      `yaml:"nullable,omitempty"`
+	Items       *OpenAPISchema            `yaml:"items,omitempty"`
+	Required    []string                  `yaml:"required,omitempty"`
+	Properties  map[string]*OpenAPISchema `yaml:"properties,omitempty"`
+}
+
+// OpenAPISchema returns the schema describing the column's values.
+func (c Column) OpenAPISchema() *OpenAPISchema {
+	typ := c.Type()
+	mapping := jsonTypes[typ.Kind]
+	schema := &OpenAPISchema{Type: mapping.Type, Format: mapping.Format}
+	if typ.Array {
+		schema = &OpenAPISchema{Type: "array", Items: schema}
+	}
+	schema.Nullable = c.Nullable
+	if c.Relation.Table != nil {
+		schema.Description = fmt.Sprintf("References %s.%s", c.Relation.Table.Schema, c.Relation.Table.Name)
+		if c.Relation.Column != nil {
+			schema.Description += fmt.Sprintf("(%s)", c.Relation.Column.Name)
+		}
+	}
+	return schema
+}
+
+// openAPIObject returns an object schema for columns. Columns with a default
+// are optional when optionalDefaults is set, as they are for inserts.
+func openAPIObject(columns []Column, optionalDefaults bool) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for _, c := range columns {
+		schema.Properties[c.Name] = c.OpenAPISchema()
+		if !c.Nullable && !(optionalDefaults && c.Default != "") {
+			schema.Required = append(schema.Required, c.Name)
+		}
+	}
+	return schema
+}
+
+func openAPIRef(name string) *OpenAPISchema {
+	return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func openAPIJSON(schema *OpenAPISchema) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// openAPICollectionPath returns the path of a table's collection. Tables
+// outside the public schema are nested under their schema name, mirroring
+// Table.TypeName.
+func openAPICollectionPath(t *Table) string {
+	if t.Schema == "" || t.Schema == "public" {
+		return "/" + t.Name
+	}
+	return "/" + t.Schema + "/" + t.Name
+}
+
+// openAPIDocument describes the CRUD endpoints backed by the generated
+// queries. Operation IDs match the query names.
+func openAPIDocument(schemas []GenerationSchema) OpenAPIDocument {
+	doc := OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{Title: "pginspector", Version: "1.0.0"},
+		Paths:      map[string]OpenAPIPathItem{},
+		Components: OpenAPIComponents{Schemas: map[string]*OpenAPISchema{}},
+	}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			typeName := table.TypeName()
+			queryName := strcase.ToCamel(table.Name)
+			tags := []string{typeName}
+
+			doc.Components.Schemas[typeName] = openAPIObject(table.Columns, false)
+			doc.Components.Schemas[typeName+"Insert"] = openAPIObject(table.InsertColumns(), true)
+			updateColumns := table.UpdateColumns()
+			if len(updateColumns) > 0 {
+				doc.Components.Schemas[typeName+"Update"] = openAPIObject(updateColumns, false)
+			}
+
+			collection := openAPICollectionPath(&table.Table)
+			doc.Paths[collection] = OpenAPIPathItem{
+				Get: &OpenAPIOperation{
+					OperationID: "Select" + queryName + "List",
+					Tags:        tags,
+					Responses: map[string]OpenAPIResponse{
+						"200": {Description: "OK", Content: openAPIJSON(&OpenAPISchema{Type: "array", Items: openAPIRef(typeName)})},
+					},
+				},
+				Post: &OpenAPIOperation{
+					OperationID: "Insert" + queryName,
+					Tags:        tags,
+					RequestBody: &OpenAPIRequestBody{Required: true, Content: openAPIJSON(openAPIRef(typeName + "Insert"))},
+					Responses: map[string]OpenAPIResponse{
+						"201": {Description: "Created", Content: openAPIJSON(openAPIRef(typeName))},
+					},
+				},
+			}
+
+			primaryKey := table.PrimaryKeyColumn()
+			if !table.HasColumn(primaryKey.Name) {
+				continue
+			}
+			notFound := OpenAPIResponse{Description: "Not Found"}
+			item := OpenAPIPathItem{
+				Parameters: []OpenAPIParameter{
+					{Name: primaryKey.Name, In: "path", Required: true, Schema: Column{PGType: primaryKey.PGType, UDTName: primaryKey.UDTName}.OpenAPISchema()},
+				},
+				Get: &OpenAPIOperation{
+					OperationID: "Select" + queryName + "ByID",
+					Tags:        tags,
+					Responses: map[string]OpenAPIResponse{
+						"200": {Description: "OK", Content: openAPIJSON(openAPIRef(typeName))},
+						"404": notFound,
+					},
+				},
+			}
+			// Tables with every column managed get no Update query.
+			if len(updateColumns) > 0 {
+				item.Put = &OpenAPIOperation{
+					OperationID: "Update" + queryName,
+					Tags:        tags,
+					RequestBody: &OpenAPIRequestBody{Required: true, Content: openAPIJSON(openAPIRef(typeName + "Update"))},
+					Responses: map[string]OpenAPIResponse{
+						"200": {Description: "OK", Content: openAPIJSON(openAPIRef(typeName))},
+						"404": notFound,
+					},
+				}
+			}
+			if !table.Config.DisableDelete {
+				item.Delete = &OpenAPIOperation{
+					OperationID: "Delete" + queryName + "ByID",
+					Tags:        tags,
+					Responses: map[string]OpenAPIResponse{
+						"204": {Description: "No Content"},
+					},
+				}
+			}
+			doc.Paths[fmt.Sprintf("%s/{%s}", collection, primaryKey.Name)] = item
+		}
+	}
+	return doc
+}
+
+// generateOpenAPI writes an OpenAPI 3 document with CRUD paths and component
+// schemas for every table.
+func generateOpenAPI(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(openAPIDocument(schemas)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
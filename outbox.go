@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultOutboxSchema = "public"
+	outboxTriggerName   = "outbox_record_event"
+)
+
+// outboxTables returns the tables with generate_outbox set.
+func outboxTables(schemas []GenerationSchema) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Config.GenerateOutbox {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+	}
+	return tables
+}
+
+const outboxFunctionTemplate = `CREATE OR REPLACE FUNCTION %[1]s.outbox_record_event() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    payload jsonb := CASE WHEN TG_OP = 'DELETE' THEN to_jsonb(OLD) ELSE to_jsonb(NEW) END;
+    aggregates jsonb := '{}';
+BEGIN
+    -- TG_ARGV holds the primary key column followed by foreign key column and
+    -- referenced table pairs.
+    FOR i IN 1 .. TG_NARGS - 1 BY 2 LOOP
+        IF payload ->> TG_ARGV[i] IS NOT NULL THEN
+            aggregates := aggregates || jsonb_build_object(TG_ARGV[i + 1], payload ->> TG_ARGV[i]);
+        END IF;
+    END LOOP;
+    INSERT INTO %[1]s.outbox (aggregate_type, aggregate_id, event_type, payload, related_aggregates)
+    VALUES (
+        TG_TABLE_NAME,
+        payload ->> nullif(TG_ARGV[0], ''),
+        TG_TABLE_NAME || '.' || lower(TG_OP),
+        payload,
+        aggregates
+    );
+    RETURN NULL;
+END;
+$$;
+`
+
+// outboxMigration creates a transactional outbox table, a trigger function
+// writing an event for every row change into it, and a trigger on each table
+// with generate_outbox set. Events carry the row as a jsonb payload, the table
+// and primary key value as the aggregate, and the values of the table's
+// foreign keys keyed by referenced table, so consumers can route events for
+// child rows to their parent aggregates. Unpublished events are indexed for
+// relays polling the table.
+func outboxMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	tables := outboxTables(schemas)
+	if len(tables) == 0 {
+		return Migration{Name: "outbox"}, nil
+	}
+	outboxSchema := cfg.OutboxSchema
+	if outboxSchema == "" {
+		outboxSchema = defaultOutboxSchema
+	}
+	schema := ddlIdent(outboxSchema)
+
+	up := strings.Builder{}
+	if outboxSchema != "public" {
+		fmt.Fprintf(&up, "CREATE SCHEMA IF NOT EXISTS %s;\n\n", schema)
+	}
+	fmt.Fprintf(&up, `CREATE TABLE IF NOT EXISTS %[1]s.outbox (
+    id bigserial PRIMARY KEY,
+    aggregate_type text NOT NULL,
+    aggregate_id text,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    related_aggregates jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    published_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON %[1]s.outbox (id) WHERE published_at IS NULL;
+
+`, schema)
+	fmt.Fprintf(&up, outboxFunctionTemplate, schema)
+
+	down := strings.Builder{}
+	for _, table := range tables {
+		name := ddlTableName(&table.Table)
+		arguments := []string{"''"}
+		if table.HasColumn(table.Config.PrimaryKey) {
+			arguments[0] = ddlLiteral(table.Config.PrimaryKey)
+		}
+		for _, c := range table.ForeignKeyColumns() {
+			if c.Relation.Forward {
+				arguments = append(arguments, ddlLiteral(c.Name), ddlLiteral(c.Relation.Table.Name))
+			}
+		}
+		fmt.Fprintf(&up, "\nDROP TRIGGER IF EXISTS %s ON %s;\n", outboxTriggerName, name)
+		fmt.Fprintf(&up, "CREATE TRIGGER %s\n    AFTER INSERT OR UPDATE OR DELETE ON %s\n    FOR EACH ROW EXECUTE FUNCTION %s.outbox_record_event(%s);\n", outboxTriggerName, name, schema, strings.Join(arguments, ", "))
+		fmt.Fprintf(&down, "DROP TRIGGER IF EXISTS %s ON %s;\n", outboxTriggerName, name)
+	}
+	fmt.Fprintf(&down, "DROP FUNCTION IF EXISTS %s.outbox_record_event();\n", schema)
+	fmt.Fprintf(&down, "DROP TABLE IF EXISTS %s.outbox;\n", schema)
+	return Migration{Name: "outbox", Up: up.String(), Down: down.String()}, nil
+}
+
+// generateOutbox writes the outbox table, trigger function, and triggers from
+// outboxMigration.
+func generateOutbox(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(outboxTables(schemas)) == 0 {
+		return errors.New("No tables have generate_outbox set")
+	}
+	return writeMigrationUp(w, outboxMigration, schemas, cfg)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const defaultPartitionPremake = 3
+
+// partitionIntervals are the supported partition sizes, as date_trunc fields.
+var partitionIntervals = map[string]bool{
+	"day":   true,
+	"week":  true,
+	"month": true,
+	"year":  true,
+}
+
+// partitionKeyTypes are the column types a time-based partition key can have.
+var partitionKeyTypes = map[string]bool{
+	"date":                        true,
+	"timestamp without time zone": true,
+	"timestamp with time zone":    true,
+}
+
+// partitionTables returns the tables with a partitions interval set.
+func partitionTables(schemas []GenerationSchema) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Config.Partitions.Interval != "" {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+	}
+	return tables
+}
+
+// partitionKey returns the table's time partition key column, or an error if
+// the table isn't partitioned by range on a single date or timestamp column.
+func partitionKey(t *GenerationTable) (Column, error) {
+	name := t.Schema + "." + t.Name
+	if !partitionIntervals[t.Config.Partitions.Interval] {
+		return Column{}, errors.Errorf("Unknown partitions interval %q for table %s (expected day, week, month, or year)", t.Config.Partitions.Interval, name)
+	}
+	if t.Partitioning == nil || t.Partitioning.Strategy != PartitionRange || len(t.Partitioning.Columns) != 1 {
+		return Column{}, errors.Errorf("Table %s is not partitioned by range on a single column", name)
+	}
+	column, ok := t.GetColumn(t.Partitioning.Columns[0])
+	if !ok || !partitionKeyTypes[column.PGType] {
+		return Column{}, errors.Errorf("Table %s is not partitioned on a date or timestamp column", name)
+	}
+	return column, nil
+}
+
+const partitionFunctionTemplate = `CREATE OR REPLACE FUNCTION %[1]s() RETURNS void
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    period_start timestamp with time zone;
+    partition_name text;
+BEGIN
+    FOR i IN 0 .. %[5]d LOOP
+        period_start := date_trunc(%[4]s, now()) + i * interval %[6]s;
+        partition_name := %[7]s || to_char(period_start, 'YYYYMMDD');
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %%I.%%I PARTITION OF %%I.%%I FOR VALUES FROM (%%L) TO (%%L)',
+            %[2]s, partition_name, %[2]s, %[3]s, period_start, period_start + interval %[6]s);
+    END LOOP;
+%[8]sEND;
+$$;
+`
+
+const partitionRetentionTemplate = `    FOR partition_name IN
+        SELECT c.relname
+        FROM pg_inherits AS i
+            JOIN pg_class AS c ON c.oid = i.inhrelid
+        WHERE i.inhparent = %[1]s::regclass
+            AND c.relname ~ %[2]s
+            AND to_date(right(c.relname, 8), 'YYYYMMDD') < date_trunc(%[3]s, now()) - %[4]d * interval %[5]s
+    LOOP
+        EXECUTE format('DROP TABLE %%I.%%I', %[6]s, partition_name);
+    END LOOP;
+`
+
+// partitionMigration creates a maintenance function for each table with a
+// partitions interval set. The function creates the current partition and
+// the next premake ones, named <table>_pYYYYMMDD after the start of their
+// range, and drops the partitions named that way that are older than the
+// retention. The migration runs the function once, and schedules it with
+// pg_cron when cron_schedule is set. Dropping the migration leaves the
+// partitions in place.
+func partitionMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	up := strings.Builder{}
+	down := strings.Builder{}
+	for _, table := range partitionTables(schemas) {
+		if _, err := partitionKey(table); err != nil {
+			return Migration{}, err
+		}
+		partitions := table.Config.Partitions
+		premake := partitions.Premake
+		if premake == 0 {
+			premake = defaultPartitionPremake
+		}
+		name := ddlTableName(&table.Table)
+		function := ddlIdent(table.Schema) + "." + ddlIdent(table.Name+"_maintain_partitions")
+		interval := ddlLiteral("1 " + partitions.Interval)
+		prefix := table.Name + "_p"
+
+		retention := ""
+		if partitions.Retention > 0 {
+			retention = fmt.Sprintf(partitionRetentionTemplate,
+				ddlLiteral(name), ddlLiteral("^"+prefix+"[0-9]{8}$"), ddlLiteral(partitions.Interval),
+				partitions.Retention, interval, ddlLiteral(table.Schema))
+		}
+		fmt.Fprintf(&up, "\n"+partitionFunctionTemplate,
+			function, ddlLiteral(table.Schema), ddlLiteral(table.Name), ddlLiteral(partitions.Interval),
+			premake, interval, ddlLiteral(prefix), retention)
+		fmt.Fprintf(&up, "\nSELECT %s();\n", function)
+		if partitions.CronSchedule != "" {
+			job := ddlLiteral(table.Schema + "." + table.Name + "_maintain_partitions")
+			fmt.Fprintf(&up, "SELECT cron.schedule(%s, %s, %s);\n", job, ddlLiteral(partitions.CronSchedule), ddlLiteral("SELECT "+function+"()"))
+			fmt.Fprintf(&down, "SELECT cron.unschedule(%s);\n", job)
+		}
+		fmt.Fprintf(&down, "DROP FUNCTION IF EXISTS %s();\n", function)
+	}
+	return Migration{Name: "partitions", Up: strings.TrimPrefix(up.String(), "\n"), Down: down.String()}, nil
+}
+
+// generatePartitions writes the partition maintenance functions from
+// partitionMigration.
+func generatePartitions(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(partitionTables(schemas)) == 0 {
+		return errors.New("No tables have a partitions interval set")
+	}
+	return writeMigrationUp(w, partitionMigration, schemas, cfg)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tablePatternRegexp returns the regular expression of a table pattern
+// written between slashes, like /^audit_/, or nil for names and globs.
+func tablePatternRegexp(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return nil, nil
+	}
+	return regexp.Compile(pattern[1 : len(pattern)-1])
+}
+
+// isTablePattern reports whether a table pattern matches names by a glob or
+// a regular expression rather than being a table name.
+func isTablePattern(pattern string) bool {
+	re, err := tablePatternRegexp(pattern)
+	return re != nil || err != nil || strings.ContainsAny(pattern, "*?[")
+}
+
+// checkTablePattern returns an error if a table pattern doesn't compile.
+func checkTablePattern(pattern string) error {
+	re, err := tablePatternRegexp(pattern)
+	if err != nil {
+		return errors.WithMessagef(err, "Invalid regular expression %s", pattern)
+	}
+	if re != nil {
+		return nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return errors.WithMessagef(err, "Invalid glob %s", pattern)
+	}
+	return nil
+}
+
+// matchTablePattern reports whether a table name matches a pattern: the name
+// itself, a glob like django_*, or a regular expression between slashes like
+// /^audit_/. Patterns that don't compile match nothing.
+func matchTablePattern(pattern string, tableName string) bool {
+	if pattern == tableName {
+		return true
+	}
+	re, err := tablePatternRegexp(pattern)
+	if re != nil || err != nil {
+		return re != nil && re.MatchString(tableName)
+	}
+	matched, _ := path.Match(pattern, tableName)
+	return matched
+}
+
+// checkTablePatterns returns an error for the first table pattern of the
+// config that doesn't compile.
+func checkTablePatterns(cfg GeneratorConfiguration) error {
+	schemaNames := make([]string, 0, len(cfg.SchemaConfig))
+	for schemaName := range cfg.SchemaConfig {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	for _, schemaName := range schemaNames {
+		for _, pattern := range cfg.SchemaConfig[schemaName].SkipTables {
+			if err := checkTablePattern(pattern); err != nil {
+				return errors.WithMessagef(err, "Invalid skip_tables entry in schema %s", schemaName)
+			}
+		}
+		for _, pattern := range cfg.SchemaConfig[schemaName].IncludeTables {
+			if err := checkTablePattern(pattern); err != nil {
+				return errors.WithMessagef(err, "Invalid include_tables entry in schema %s", schemaName)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// pgtapArray renders names as a text[] literal for pgTAP functions taking
+// column lists.
+func pgtapArray(names []string) string {
+	literals := make([]string, 0, len(names))
+	for _, name := range names {
+		literals = append(literals, ddlLiteral(name))
+	}
+	return "ARRAY[" + strings.Join(literals, ", ") + "]"
+}
+
+// pgtapColumnType asserts the column's type. User-defined types are checked
+// with their schema, since pgTAP otherwise compares the type as displayed for
+// the current search_path.
+func pgtapColumnType(t *Table, c Column, description string) string {
+	schema, table, column := ddlLiteral(t.Schema), ddlLiteral(t.Name), ddlLiteral(c.Name)
+	if c.PGType == "USER-DEFINED" && c.UDTSchema != "" && c.UDTSchema != "pg_catalog" {
+		return fmt.Sprintf("SELECT col_type_is(%s, %s, %s, %s, %s, %s);", schema, table, column, ddlLiteral(c.UDTSchema), ddlLiteral(c.UDTName), description)
+	}
+	return fmt.Sprintf("SELECT col_type_is(%s, %s, %s, %s, %s);", schema, table, column, ddlLiteral(ddlType(c)), description)
+}
+
+// pgtapTableTests returns the assertions for a table: that it exists, its
+// columns' types and nullability, its primary key, unique constraints and
+// indexes, and its foreign keys. Every assertion has a description, which
+// keeps pgTAP from reading the schema name as the description in its
+// shorter overloads.
+func pgtapTableTests(t *Table) []string {
+	schema, table := ddlLiteral(t.Schema), ddlLiteral(t.Name)
+	qualified := t.Schema + "." + t.Name
+	tests := []string{
+		fmt.Sprintf("SELECT has_table(%s, %s, %s);", schema, table, ddlLiteral("Table "+qualified+" should exist")),
+	}
+
+	columns := make([]Column, len(t.Columns))
+	copy(columns, t.Columns)
+	sort.SliceStable(columns, func(i, j int) bool {
+		return columns[i].Position < columns[j].Position
+	})
+	for _, c := range columns {
+		name := qualified + "." + c.Name
+		column := ddlLiteral(c.Name)
+		tests = append(tests,
+			fmt.Sprintf("SELECT has_column(%s, %s, %s, %s);", schema, table, column, ddlLiteral("Column "+name+" should exist")),
+			pgtapColumnType(t, c, ddlLiteral("Column "+name+" should be type "+ddlType(c))),
+		)
+		if c.Nullable {
+			tests = append(tests, fmt.Sprintf("SELECT col_is_null(%s, %s, %s, %s);", schema, table, column, ddlLiteral("Column "+name+" should allow NULL")))
+		} else {
+			tests = append(tests, fmt.Sprintf("SELECT col_not_null(%s, %s, %s, %s);", schema, table, column, ddlLiteral("Column "+name+" should be NOT NULL")))
+		}
+	}
+
+	constraints := map[string]bool{}
+	for _, constraint := range t.Constraints {
+		constraints[constraint.Name] = true
+	}
+	for _, index := range t.Indexes {
+		columns := strings.Join(index.Columns, ", ")
+		switch {
+		case index.Primary:
+			tests = append(tests, fmt.Sprintf("SELECT col_is_pk(%s, %s, %s, %s);", schema, table, pgtapArray(index.Columns), ddlLiteral("Table "+qualified+" should have primary key ("+columns+")")))
+		case index.Unique && constraints[index.Name]:
+			tests = append(tests, fmt.Sprintf("SELECT col_is_unique(%s, %s, %s, %s);", schema, table, pgtapArray(index.Columns), ddlLiteral("Table "+qualified+" should have unique constraint ("+columns+")")))
+		case index.Unique:
+			tests = append(tests, fmt.Sprintf("SELECT index_is_unique(%s, %s, %s, %s);", schema, table, ddlLiteral(index.Name), ddlLiteral("Index "+index.Name+" should be unique")))
+		}
+	}
+
+	for _, c := range columns {
+		if !c.Relation.Forward || c.Relation.Table == nil || c.Relation.Column == nil {
+			continue
+		}
+		target := c.Relation.Table
+		tests = append(tests, fmt.Sprintf("SELECT fk_ok(%s, %s, %s, %s, %s, %s, %s);",
+			schema, table, ddlLiteral(c.Name),
+			ddlLiteral(target.Schema), ddlLiteral(target.Name), ddlLiteral(c.Relation.Column.Name),
+			ddlLiteral(fmt.Sprintf("Column %s.%s should reference %s.%s(%s)", qualified, c.Name, target.Schema, target.Name, c.Relation.Column.Name)),
+		))
+	}
+	return tests
+}
+
+// generatePgTAP writes a pgTAP test file asserting the inspected structure of
+// the selected tables. It runs in a transaction that's rolled back, so it can
+// be run with pg_prove against any database migrated to the same schema.
+func generatePgTAP(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	tests := []string{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			tests = append(tests, pgtapTableTests(&schema.Tables[i].Table)...)
+		}
+	}
+
+	b := strings.Builder{}
+	b.WriteString("-- Code generated by pginspector. DO NOT EDIT.\n\n")
+	b.WriteString("BEGIN;\n")
+	fmt.Fprintf(&b, "SELECT plan(%d);\n\n", len(tests))
+	for _, test := range tests {
+		b.WriteString(test + "\n")
+	}
+	b.WriteString("\nSELECT * FROM finish();\n")
+	b.WriteString("ROLLBACK;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
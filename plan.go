@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// ExtractPlan is what an extraction would visit, found by walking the
+// foreign keys between tables rather than between rows, so no rows are
+// fetched.
+type ExtractPlan struct {
+	// Source describes the seed rows or samples, as in the extraction.
+	Source string
+	// Tables are the tables visited, in the order they're reached.
+	Tables []ExtractPlanTable
+	// Rows is the estimated number of rows extracted, capped by max_rows.
+	Rows int64
+	// Excluded are the relations of the visited tables that aren't
+	// followed, with why.
+	Excluded []string
+}
+
+// ExtractPlanTable is a table an extraction would visit.
+type ExtractPlanTable struct {
+	Table *GenerationTable
+	// Depth is the number of foreign keys followed to reach the table.
+	Depth int
+	// Via are the relations the table is reached through, or how its seed
+	// rows are found.
+	Via []string
+	// Rows is the estimated number of rows extracted from the table, capped
+	// by max_rows_per_table.
+	Rows   int64
+	Capped bool
+	// queries name the subqueries selecting the table's rows.
+	queries []string
+}
+
+// extractPlan walks the foreign keys of an extraction from the tables of
+// its seed rows, breadth first, as far as the extraction would. The rows of
+// each table are estimated with a single count(*) query, selecting the seed
+// rows with their predicates and the rows of each table reached by the key
+// values of the tables it's reached from. Rows only reached through longer
+// paths, e.g. back to a table already visited, aren't counted.
+func extractPlan(ctx context.Context, q extractQuerier, schemas []GenerationSchema, cfg ExtractConfig) (*ExtractPlan, error) {
+	graph, err := newExtractGraph(schemas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ExtractPlan{Source: (&Extraction{Seed: graph.seed, SeedFilter: graph.filter}).source()}
+	visited := map[*GenerationTable]int{}
+	subqueries := []string{}
+	var args []interface{}
+
+	for _, lookup := range graph.start {
+		i, ok := visited[lookup.Table]
+		if !ok {
+			i = len(plan.Tables)
+			visited[lookup.Table] = i
+			plan.Tables = append(plan.Tables, ExtractPlanTable{Table: lookup.Table})
+		}
+		if lookup.Where == "" {
+			// The value is written as a literal, so the arguments of
+			// seed_where are the only ones bound.
+			column, _ := lookup.Table.GetColumn(lookup.Column)
+			lookup.Where = fmt.Sprintf("%s = %s::text::%s", pgx.Identifier{lookup.Column}.Sanitize(), ddlLiteral(extractText(lookup.Value)), column.SQLType())
+		} else {
+			args = append(args, lookup.Args...)
+		}
+		sql, _ := extractLookupSQL(lookup, "*")
+		name := fmt.Sprintf("plan_%d", len(subqueries))
+		subqueries = append(subqueries, fmt.Sprintf("%s AS (%s)", pgx.Identifier{name}.Sanitize(), sql))
+		plan.Tables[i].queries = append(plan.Tables[i].queries, name)
+		if len(plan.Tables[i].Via) == 0 {
+			plan.Tables[i].Via = append(plan.Tables[i].Via, lookup.Relation)
+		}
+	}
+
+	// A table is reached from those visited at the depth before it, through
+	// each foreign key followed from them.
+	for start, depth := 0, 1; start < len(plan.Tables); depth++ {
+		end := len(plan.Tables)
+		conditions := map[*GenerationTable][]string{}
+		via := map[*GenerationTable][]string{}
+		order := []*GenerationTable{}
+		for f, fk := range graph.fks {
+			for direction, parents := range []bool{true, false} {
+				// The columns of the table reached are matched against the
+				// selected columns of the table it's reached from.
+				from, to, columns, selected := fk.From, fk.To, fk.Referenced, fk.Columns
+				if !parents {
+					from, to, columns, selected = fk.To, fk.From, fk.Columns, fk.Referenced
+				}
+				i, ok := visited[from]
+				if !ok || i < start || i >= end || !graph.directions[f][direction] {
+					continue
+				}
+				if _, ok := visited[to]; ok || (cfg.MaxDepth > 0 && depth > cfg.MaxDepth) {
+					continue
+				}
+				selects := make([]string, 0, len(plan.Tables[i].queries))
+				for _, name := range plan.Tables[i].queries {
+					selects = append(selects, fmt.Sprintf("SELECT %s FROM %s", extractPlanColumns(selected), pgx.Identifier{name}.Sanitize()))
+				}
+				if conditions[to] == nil {
+					order = append(order, to)
+				}
+				conditions[to] = append(conditions[to], fmt.Sprintf("(%s) IN (%s)", extractPlanColumns(columns), strings.Join(selects, " UNION ALL ")))
+				via[to] = append(via[to], fk.Name)
+			}
+		}
+		for _, table := range order {
+			name := fmt.Sprintf("plan_%d", len(subqueries))
+			subqueries = append(subqueries, fmt.Sprintf("%s AS (SELECT * FROM %s WHERE %s)", pgx.Identifier{name}.Sanitize(), pgx.Identifier{table.Schema, table.Name}.Sanitize(), strings.Join(conditions[table], " OR ")))
+			visited[table] = len(plan.Tables)
+			plan.Tables = append(plan.Tables, ExtractPlanTable{Table: table, Depth: depth, Via: via[table], queries: []string{name}})
+		}
+		start = end
+	}
+
+	counts, err := extractPlanCounts(ctx, q, subqueries, args)
+	if err != nil {
+		return nil, err
+	}
+	for i := range plan.Tables {
+		table := &plan.Tables[i]
+		for _, name := range table.queries {
+			table.Rows += counts[name]
+		}
+		if cfg.MaxRowsPerTable > 0 && table.Rows > int64(cfg.MaxRowsPerTable) {
+			table.Rows, table.Capped = int64(cfg.MaxRowsPerTable), true
+		}
+		plan.Rows += table.Rows
+	}
+	if cfg.MaxRows > 0 && plan.Rows > int64(cfg.MaxRows) {
+		plan.Rows = int64(cfg.MaxRows)
+	}
+
+	for f, fk := range graph.fks {
+		_, fromVisited := visited[fk.From]
+		_, toVisited := visited[fk.To]
+		switch {
+		case !fromVisited && !toVisited:
+		case graph.skipped[fk.From]:
+			plan.Excluded = append(plan.Excluded, fmt.Sprintf("%s (skipped table %s)", fk.Name, ddlTableName(&fk.From.Table)))
+		case graph.skipped[fk.To]:
+			plan.Excluded = append(plan.Excluded, fmt.Sprintf("%s (skipped table %s)", fk.Name, ddlTableName(&fk.To.Table)))
+		case (fromVisited && graph.directions[f][0]) || (toVisited && graph.directions[f][1]):
+			if !fromVisited || !toVisited {
+				plan.Excluded = append(plan.Excluded, fk.Name+" (max_depth)")
+			}
+		case fromVisited && toVisited:
+			plan.Excluded = append(plan.Excluded, fk.Name+" (not followed)")
+		case fromVisited:
+			plan.Excluded = append(plan.Excluded, fk.Name+" (not followed to parents)")
+		default:
+			plan.Excluded = append(plan.Excluded, fk.Name+" (not followed to children)")
+		}
+	}
+	sort.Strings(plan.Excluded)
+	return plan, nil
+}
+
+// extractPlanColumns returns a list of quoted column names.
+func extractPlanColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, pgx.Identifier{c}.Sanitize())
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// extractPlanCounts counts the rows of each subquery of a plan in one query,
+// returning the counts by subquery name.
+func extractPlanCounts(ctx context.Context, q extractQuerier, subqueries []string, args []interface{}) (map[string]int64, error) {
+	names := make([]string, 0, len(subqueries))
+	counts := make([]string, 0, len(subqueries))
+	for i := range subqueries {
+		name := fmt.Sprintf("plan_%d", i)
+		names = append(names, name)
+		counts = append(counts, fmt.Sprintf("(SELECT count(*) FROM %s)", pgx.Identifier{name}.Sanitize()))
+	}
+	sql := fmt.Sprintf("WITH %s SELECT %s", strings.Join(subqueries, ", "), strings.Join(counts, ", "))
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Unable to count rows")
+	}
+	defer rows.Close()
+	result := map[string]int64{}
+	if rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, errors.WithMessage(err, "Unable to read row counts")
+		}
+		for i, value := range values {
+			if count, ok := value.(int64); ok && i < len(names) {
+				result[names[i]] = count
+			}
+		}
+	}
+	return result, rows.Err()
+}
+
+// writeExtractPlan writes a plan as text: the tables visited with their
+// estimated rows, then the relations not followed.
+func writeExtractPlan(w io.Writer, plan *ExtractPlan) error {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Would extract about %d rows from %s\n", plan.Rows, plan.Source)
+	width := 0
+	for _, table := range plan.Tables {
+		width = max(width, len(ddlTableName(&table.Table.Table)))
+	}
+	for _, table := range plan.Tables {
+		via := strings.Join(table.Via, ", ")
+		if table.Depth > 0 {
+			via = "via " + via
+		}
+		fmt.Fprintf(&b, "  %-*s %8d rows  depth %d  %s", width, ddlTableName(&table.Table.Table), table.Rows, table.Depth, via)
+		if table.Capped {
+			b.WriteString(" (max_rows_per_table)")
+		}
+		b.WriteString("\n")
+	}
+	for _, relation := range plan.Excluded {
+		fmt.Fprintf(&b, "Not followed %s\n", relation)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
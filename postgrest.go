@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPostgRESTSchema   = "api"
+	defaultPostgRESTAnonRole = "web_anon"
+)
+
+// postgrestRoles returns the web roles and their access to the API views,
+// sorted by name. Without postgrest_roles, the anonymous role gets read
+// access.
+func postgrestRoles(cfg GeneratorConfiguration, anonRole string) ([]string, map[string]string, error) {
+	access := cfg.PostgRESTRoles
+	if len(access) == 0 {
+		access = map[string]string{anonRole: AccessRead}
+	}
+	roles := make([]string, 0, len(access))
+	for role, level := range access {
+		if _, ok := accessPrivileges[level]; !ok {
+			return nil, nil, errors.Errorf("Unknown access %q for PostgREST role %s (expected %s, %s, or %s)", level, role, AccessRead, AccessWrite, AccessNone)
+		}
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, access, nil
+}
+
+// postgrestMigration exposes the selected tables to PostgREST: it creates
+// the API schema (postgrest_schema, api by default) with a view per table,
+// and grants the web roles in postgrest_roles their access to the views
+// (postgrest_anon_role, web_anon by default, gets read access if none are
+// configured). Views are simple enough for Postgres to make them updatable,
+// skip soft deleted rows, and run with the caller's privileges so row level
+// security on the tables still applies (which needs Postgres 15). The up
+// script starts with the matching PostgREST configuration as a comment.
+func postgrestMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	tables := ddlOrderedTables(schemas)
+	if len(tables) == 0 {
+		return Migration{Name: "postgrest"}, nil
+	}
+	apiSchema := cfg.PostgRESTSchema
+	if apiSchema == "" {
+		apiSchema = defaultPostgRESTSchema
+	}
+	anonRole := cfg.PostgRESTAnonRole
+	if anonRole == "" {
+		anonRole = defaultPostgRESTAnonRole
+	}
+	roles, access, err := postgrestRoles(cfg, anonRole)
+	if err != nil {
+		return Migration{}, err
+	}
+	schema := ddlIdent(apiSchema)
+
+	up := strings.Builder{}
+	up.WriteString("-- PostgREST configuration:\n")
+	fmt.Fprintf(&up, "--   db-schemas = %q\n", apiSchema)
+	fmt.Fprintf(&up, "--   db-anon-role = %q\n", anonRole)
+	fmt.Fprintf(&up, "\nCREATE SCHEMA IF NOT EXISTS %s;\n", schema)
+
+	down := strings.Builder{}
+	seen := map[string]string{}
+	views := []string{}
+	for _, table := range tables {
+		if other, ok := seen[table.Name]; ok {
+			return Migration{}, errors.Errorf("Tables %s.%s and %s.%s would both be exposed as %s.%s", other, table.Name, table.Schema, table.Name, apiSchema, table.Name)
+		}
+		seen[table.Name] = table.Schema
+		view := schema + "." + ddlIdent(table.Name)
+		views = append(views, view)
+
+		columns := make([]string, 0, len(table.Columns))
+		for _, c := range table.Columns {
+			columns = append(columns, ddlIdent(c.Name))
+		}
+		fmt.Fprintf(&up, "\nCREATE OR REPLACE VIEW %s WITH (security_invoker = true) AS\n    SELECT %s\n    FROM %s", view, strings.Join(columns, ", "), ddlTableName(&table.Table))
+		if softDelete := table.Config.SoftDeleteColumn; softDelete != "" {
+			fmt.Fprintf(&up, "\n    WHERE %s IS NULL", ddlIdent(softDelete))
+		}
+		up.WriteString(";\n")
+		if table.Comment != "" {
+			fmt.Fprintf(&up, "COMMENT ON VIEW %s IS %s;\n", view, ddlLiteral(table.Comment))
+		}
+	}
+
+	for _, role := range roles {
+		grantee := ddlIdent(role)
+		fmt.Fprintf(&up, "\nGRANT USAGE ON SCHEMA %s TO %s;\n", schema, grantee)
+		for _, view := range views {
+			fmt.Fprintf(&up, "REVOKE ALL ON %s FROM %s;\n", view, grantee)
+			if privileges := accessPrivileges[access[role]]; privileges != "" {
+				fmt.Fprintf(&up, "GRANT %s ON %s TO %s;\n", privileges, view, grantee)
+			}
+		}
+	}
+
+	for i := len(views) - 1; i >= 0; i-- {
+		fmt.Fprintf(&down, "DROP VIEW IF EXISTS %s;\n", views[i])
+	}
+	for _, role := range roles {
+		fmt.Fprintf(&down, "REVOKE USAGE ON SCHEMA %s FROM %s;\n", schema, ddlIdent(role))
+	}
+	return Migration{Name: "postgrest", Up: up.String(), Down: down.String()}, nil
+}
+
+// generatePostgREST writes the API schema, views, and grants from
+// postgrestMigration.
+func generatePostgREST(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	return writeMigrationUp(w, postgrestMigration, schemas, cfg)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// prismaTypes maps column kinds to Prisma scalar types. Kinds not listed are
+// declared as Unsupported with their SQL type.
+var prismaTypes = map[TypeKind]string{
+	KindInt16:       "Int",
+	KindInt32:       "Int",
+	KindInt64:       "BigInt",
+	KindFloat32:     "Float",
+	KindFloat64:     "Float",
+	KindNumeric:     "Decimal",
+	KindBool:        "Boolean",
+	KindString:      "String",
+	KindUUID:        "String",
+	KindTimestamp:   "DateTime",
+	KindTimestamptz: "DateTime",
+	KindDate:        "DateTime",
+	KindTime:        "DateTime",
+	KindJSON:        "Json",
+	KindBytes:       "Bytes",
+}
+
+// prismaNativeTypes are the @db attributes for columns whose SQL type isn't
+// the default for their Prisma type.
+var prismaNativeTypes = map[string]string{
+	"smallint":                    "@db.SmallInt",
+	"real":                        "@db.Real",
+	"uuid":                        "@db.Uuid",
+	"timestamp without time zone": "@db.Timestamp(6)",
+	"timestamp with time zone":    "@db.Timestamptz(6)",
+	"date":                        "@db.Date",
+	"time without time zone":      "@db.Time(6)",
+	"json":                        "@db.Json",
+}
+
+var prismaIdentPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// prismaIdent returns an identifier for a database name, and whether it
+// differs so that it needs an @map or @@map.
+func prismaIdent(name string) (string, bool) {
+	if prismaIdentPattern.MatchString(name) {
+		return name, false
+	}
+	ident := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+	if ident == "" || !unicode.IsLetter(rune(ident[0])) {
+		ident = "x" + ident
+	}
+	return ident, true
+}
+
+// prismaDefault translates a column default into a @default attribute.
+// Defaults Prisma can't express are kept as dbgenerated().
+func prismaDefault(c Column) string {
+	value := c.Default
+	switch {
+	case value == "":
+		return ""
+	case strings.HasPrefix(value, "nextval("):
+		return "@default(autoincrement())"
+	case value == "now()" || value == "CURRENT_TIMESTAMP":
+		return "@default(now())"
+	case value == "true" || value == "false":
+		return "@default(" + value + ")"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "@default(" + value + ")"
+	}
+	if literal, _, ok := strings.Cut(value, "::"); ok && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") && len(literal) > 1 {
+		text := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+		if c.Type().Kind == KindEnum {
+			if ident, mapped := prismaIdent(text); !mapped {
+				return "@default(" + ident + ")"
+			}
+		} else if c.Type().Kind == KindString {
+			return "@default(" + strconv.Quote(text) + ")"
+		}
+	}
+	return fmt.Sprintf("@default(dbgenerated(%s))", strconv.Quote(value))
+}
+
+// prismaRelation is a relation field on a model.
+type prismaRelation struct {
+	Field string
+	Type  string
+	// Attribute is the @relation attribute, set on the side with the
+	// foreign key.
+	Attribute string
+}
+
+// prismaRelations returns the relation fields of each model: one on the
+// referencing model per foreign key, and the back-relation on the referenced
+// model. Relations are named when a pair of models has more than one.
+func prismaRelations(schemas []GenerationSchema) map[*GenerationTable][]prismaRelation {
+	edges := diagramEdges(schemas)
+	pairs := map[[2]*GenerationTable]int{}
+	for _, edge := range edges {
+		pairs[[2]*GenerationTable{edge.From, edge.To}]++
+		if edge.From != edge.To {
+			pairs[[2]*GenerationTable{edge.To, edge.From}]++
+		}
+	}
+
+	relations := map[*GenerationTable][]prismaRelation{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		field, _ := prismaIdent(edge.Column.Name)
+		forward := strings.TrimSuffix(field, "_id")
+		if forward == field || edge.From.HasColumn(forward) {
+			forward = field + "_ref"
+		}
+		back, _ := prismaIdent(pluralize(edge.From.Name))
+		name := ""
+		if pairs[[2]*GenerationTable{edge.From, edge.To}] > 1 || edge.From == edge.To {
+			name = strconv.Quote(edge.From.TypeName()+exportedName(forward)) + ", "
+			back += "_" + forward
+		}
+		if edge.To.HasColumn(back) {
+			back += "_ref"
+		}
+
+		optional := ""
+		if edge.Column.Nullable {
+			optional = "?"
+		}
+		target, _ := prismaIdent(edge.Column.Relation.Column.Name)
+		relations[edge.From] = append(relations[edge.From], prismaRelation{
+			Field:     forward,
+			Type:      edge.To.TypeName() + optional,
+			Attribute: fmt.Sprintf("@relation(%sfields: [%s], references: [%s])", name, field, target),
+		})
+
+		backType := edge.From.TypeName() + "[]"
+		if edge.From.IsUnique(edge.Column.Name) {
+			backType = edge.From.TypeName() + "?"
+		}
+		backAttribute := ""
+		if name != "" {
+			backAttribute = "@relation(" + strings.TrimSuffix(name, ", ") + ")"
+		}
+		relations[edge.To] = append(relations[edge.To], prismaRelation{Field: back, Type: backType, Attribute: backAttribute})
+	}
+	return relations
+}
+
+func prismaFieldType(c Column) string {
+	typ := c.Type()
+	name, ok := prismaTypes[typ.Kind]
+	switch {
+	case typ.Kind == KindEnum:
+		name = c.EnumTypeName()
+	case !ok:
+		sqlType := ddlType(c)
+		if typ.Array {
+			sqlType = strings.TrimSuffix(sqlType, "[]")
+		}
+		name = fmt.Sprintf("Unsupported(%s)", strconv.Quote(sqlType))
+	}
+	switch {
+	case typ.Array:
+		return name + "[]"
+	case c.Nullable:
+		return name + "?"
+	}
+	return name
+}
+
+func prismaFieldAttributes(t *GenerationTable, c Column) []string {
+	attributes := []string{}
+	if c.Name == t.Config.PrimaryKey {
+		attributes = append(attributes, "@id")
+	} else if t.IsUnique(c.Name) {
+		attributes = append(attributes, "@unique")
+	}
+	if value := prismaDefault(c); value != "" {
+		attributes = append(attributes, value)
+	}
+	if _, mapped := prismaIdent(c.Name); mapped {
+		attributes = append(attributes, fmt.Sprintf("@map(%s)", strconv.Quote(c.Name)))
+	}
+	if native, ok := prismaNativeTypes[c.PGType]; ok {
+		attributes = append(attributes, native)
+	} else if (c.PGType == "character varying" || c.PGType == "character") && c.MaxLength > 0 {
+		native := "VarChar"
+		if c.PGType == "character" {
+			native = "Char"
+		}
+		attributes = append(attributes, fmt.Sprintf("@db.%s(%d)", native, c.MaxLength))
+	}
+	return attributes
+}
+
+// generatePrisma writes a schema.prisma with a model per table, a relation
+// field pair per foreign key between selected tables, and an enum per enum
+// type. Models and fields keep the database names; names that aren't valid
+// Prisma identifiers are mapped. Tables in schemas other than public use the
+// multiSchema preview feature.
+func generatePrisma(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	schemaNames := []string{}
+	multiSchema := false
+	for _, schema := range schemas {
+		if len(schema.Tables) == 0 {
+			continue
+		}
+		schemaNames = append(schemaNames, strconv.Quote(schema.Name))
+		multiSchema = multiSchema || schema.Name != "public"
+	}
+
+	b := strings.Builder{}
+	b.WriteString("// Code generated by pginspector. DO NOT EDIT.\n\n")
+	b.WriteString("datasource db {\n  provider = \"postgresql\"\n  url      = env(\"DATABASE_URL\")\n")
+	if multiSchema {
+		fmt.Fprintf(&b, "  schemas  = [%s]\n", strings.Join(schemaNames, ", "))
+	}
+	b.WriteString("}\n\ngenerator client {\n  provider = \"prisma-client-js\"\n")
+	if multiSchema {
+		b.WriteString("  previewFeatures = [\"multiSchema\"]\n")
+	}
+	b.WriteString("}\n")
+
+	relations := prismaRelations(schemas)
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			fmt.Fprintf(&b, "\nmodel %s {\n", table.TypeName())
+			aligned := strings.Builder{}
+			fields := tabwriter.NewWriter(&aligned, 0, 0, 1, ' ', 0)
+			for _, c := range table.Columns {
+				name, _ := prismaIdent(c.Name)
+				fmt.Fprintf(fields, "  %s\t%s\t%s\n", name, prismaFieldType(c), strings.Join(prismaFieldAttributes(table, c), " "))
+			}
+			for _, relation := range relations[table] {
+				fmt.Fprintf(fields, "  %s\t%s\t%s\n", relation.Field, relation.Type, relation.Attribute)
+			}
+			if err := fields.Flush(); err != nil {
+				return err
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(aligned.String(), "\n"), "\n") {
+				b.WriteString(strings.TrimRight(line, " ") + "\n")
+			}
+			b.WriteString("\n")
+			if !table.HasColumn(table.Config.PrimaryKey) {
+				// Prisma can't query models without a unique identifier.
+				b.WriteString("  @@ignore\n")
+			}
+			fmt.Fprintf(&b, "  @@map(%s)\n", strconv.Quote(table.Name))
+			if multiSchema {
+				fmt.Fprintf(&b, "  @@schema(%s)\n", strconv.Quote(table.Schema))
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	for _, enum := range prismaEnums(schemas) {
+		fmt.Fprintf(&b, "\nenum %s {\n", enum.Name)
+		for _, value := range enum.Values {
+			ident, mapped := prismaIdent(value)
+			if mapped {
+				fmt.Fprintf(&b, "  %s @map(%s)\n", ident, strconv.Quote(value))
+			} else {
+				fmt.Fprintf(&b, "  %s\n", ident)
+			}
+		}
+		fmt.Fprintf(&b, "\n  @@map(%s)\n", strconv.Quote(enum.PGName))
+		if multiSchema {
+			fmt.Fprintf(&b, "  @@schema(%s)\n", strconv.Quote(enum.Schema))
+		}
+		b.WriteString("}\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prismaEnum is an enum type used by the tables.
+type prismaEnum struct {
+	Name   string
+	PGName string
+	Schema string
+	Values []string
+}
+
+// prismaEnums returns the enum types used by the tables, in the order of
+// goEnums.
+func prismaEnums(schemas []GenerationSchema) []prismaEnum {
+	columns := map[string]Column{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for _, c := range table.Columns {
+				if c.Type().Kind == KindEnum {
+					columns[c.EnumTypeName()] = c
+				}
+			}
+		}
+	}
+	enums := []prismaEnum{}
+	for _, enum := range goEnums(schemas) {
+		c := columns[enum.Name]
+		enums = append(enums, prismaEnum{Name: enum.Name, PGName: enum.PGName, Schema: c.EnumSchema(), Values: c.EnumValues})
+	}
+	return enums
+}
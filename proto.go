@@ -0,0 +1,258 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+)
+
+// protoType describes how a column kind is represented in proto3. Nullable
+// scalars use the google.protobuf wrapper types.
+type protoType struct {
+	NotNull  string
+	Nullable string
+}
+
+var protoTypes = map[TypeKind]protoType{
+	KindInt16:       {NotNull: "int32", Nullable: "google.protobuf.Int32Value"},
+	KindInt32:       {NotNull: "int32", Nullable: "google.protobuf.Int32Value"},
+	KindInt64:       {NotNull: "int64", Nullable: "google.protobuf.Int64Value"},
+	KindFloat32:     {NotNull: "float", Nullable: "google.protobuf.FloatValue"},
+	KindFloat64:     {NotNull: "double", Nullable: "google.protobuf.DoubleValue"},
+	KindNumeric:     {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+	KindBool:        {NotNull: "bool", Nullable: "google.protobuf.BoolValue"},
+	KindString:      {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+	KindUUID:        {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+	KindTimestamp:   {NotNull: "google.protobuf.Timestamp", Nullable: "google.protobuf.Timestamp"},
+	KindTimestamptz: {NotNull: "google.protobuf.Timestamp", Nullable: "google.protobuf.Timestamp"},
+	KindDate:        {NotNull: "google.protobuf.Timestamp", Nullable: "google.protobuf.Timestamp"},
+	KindTime:        {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+	KindInterval:    {NotNull: "google.protobuf.Duration", Nullable: "google.protobuf.Duration"},
+	KindJSON:        {NotNull: "google.protobuf.Value", Nullable: "google.protobuf.Value"},
+	KindBytes:       {NotNull: "bytes", Nullable: "google.protobuf.BytesValue"},
+	KindEnum:        {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+	KindUnknown:     {NotNull: "string", Nullable: "google.protobuf.StringValue"},
+}
+
+var protoTypeImports = map[string]string{
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Duration":  "google/protobuf/duration.proto",
+	"google.protobuf.Value":     "google/protobuf/struct.proto",
+}
+
+// ProtoType returns the proto3 field type for the column.
+func (c Column) ProtoType() string {
+	typ := c.Type()
+	mapping := protoTypes[typ.Kind]
+	if typ.Array {
+		return "repeated " + mapping.NotNull
+	}
+	if c.Nullable {
+		return mapping.Nullable
+	}
+	return mapping.NotNull
+}
+
+func protoImport(fieldType string) string {
+	fieldType = strings.TrimPrefix(fieldType, "repeated ")
+	if path, ok := protoTypeImports[fieldType]; ok {
+		return path
+	}
+	if strings.HasPrefix(fieldType, "google.protobuf.") {
+		return "google/protobuf/wrappers.proto"
+	}
+	return ""
+}
+
+// ProtoMessage is a table rendered as a proto message.
+type ProtoMessage struct {
+	Name  string
+	Table GenerationTable
+}
+
+// ProtoService is the CRUD service for a table's message, generated with
+// proto_services set. Get, Update, and Delete are only generated for tables
+// with a primary key; Update and Delete follow the columns that can be
+// updated and disable_delete, like the generated queries.
+type ProtoService struct {
+	Message string
+	Plural  string
+	// ListField is the name of the repeated field in list responses.
+	ListField string
+	// KeyType and KeyName are the primary key field of requests by key.
+	KeyType   string
+	KeyName   string
+	HasKey    bool
+	HasUpdate bool
+	HasDelete bool
+}
+
+func protoService(name string, table GenerationTable) ProtoService {
+	plural := pluralize(name)
+	service := ProtoService{
+		Message:   name,
+		Plural:    plural,
+		ListField: strcase.ToSnake(plural),
+	}
+	if !table.HasColumn(table.Config.PrimaryKey) {
+		return service
+	}
+	keyColumn := table.PrimaryKeyColumn()
+	keyColumn.Nullable = false
+	service.HasKey = true
+	service.KeyType = keyColumn.ProtoType()
+	service.KeyName = keyColumn.Name
+	service.HasUpdate = len(table.UpdateColumns()) > 0
+	service.HasDelete = !table.Config.DisableDelete
+	return service
+}
+
+// splitProtoName splits a proto_name such as v1.Person into its package and
+// message name.
+func splitProtoName(protoName string) (string, string) {
+	i := strings.LastIndex(protoName, ".")
+	if i < 0 {
+		return "", protoName
+	}
+	return protoName[:i], protoName[i+1:]
+}
+
+const protoTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+syntax = "proto3";
+{{- with .Package }}
+
+package {{ . }};
+{{- end }}
+{{- with .Imports }}
+{{ range . }}
+import "{{ . }}";
+{{- end }}
+{{- end }}
+{{- range .Messages }}
+
+// {{ .Name }} is a row of {{ .Table.Schema }}.{{ .Table.Name }}.
+message {{ .Name }} {
+{{- range $index, $col := .Table.Columns }}
+  {{ $col.ProtoType }} {{ $col.Name }} = {{ ProtoFieldNumber $index }};
+  {{- with $col.Relation.Table }} // references {{ .Schema }}.{{ .Name }}{{ end }}
+  {{- with $col.Relation.Column }}({{ .Name }}){{ end }}
+{{- end }}
+}
+{{- end }}
+{{- range .Services }}
+
+// {{ .Message }}Service reads and writes {{ .Message }} rows.
+service {{ .Message }}Service {
+{{- if .HasKey }}
+  rpc Get{{ .Message }}(Get{{ .Message }}Request) returns ({{ .Message }});
+{{- end }}
+  rpc List{{ .Plural }}(List{{ .Plural }}Request) returns (List{{ .Plural }}Response);
+{{- if .HasUpdate }}
+  rpc Update{{ .Message }}(Update{{ .Message }}Request) returns ({{ .Message }});
+{{- end }}
+{{- if .HasDelete }}
+  rpc Delete{{ .Message }}(Delete{{ .Message }}Request) returns (google.protobuf.Empty);
+{{- end }}
+}
+{{- if .HasKey }}
+
+message Get{{ .Message }}Request {
+  {{ .KeyType }} {{ .KeyName }} = 1;
+}
+{{- end }}
+
+message List{{ .Plural }}Request {
+  // The maximum number of rows to return. The server picks a default if unset.
+  int32 page_size = 1;
+  // The next_page_token of the previous response, to get the following page.
+  string page_token = 2;
+}
+
+message List{{ .Plural }}Response {
+  repeated {{ .Message }} {{ .ListField }} = 1;
+  // Set when there may be more rows.
+  string next_page_token = 2;
+}
+{{- if .HasUpdate }}
+
+message Update{{ .Message }}Request {
+  // The row to update, identified by its {{ .KeyName }}.
+  {{ .Message }} {{ snake .Message }} = 1;
+  // The fields to update. All updatable fields are updated if unset.
+  google.protobuf.FieldMask update_mask = 2;
+}
+{{- end }}
+{{- if .HasDelete }}
+
+message Delete{{ .Message }}Request {
+  {{ .KeyType }} {{ .KeyName }} = 1;
+}
+{{- end }}
+{{- end }}
+`
+
+// generateProto writes a .proto file with one message per table. Message
+// names and the package come from each table's proto_name; tables without one
+// use their type name in the proto_package from the config. With
+// proto_services set, a CRUD service is written per message too.
+func generateProto(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	protoPackage := cfg.ProtoPackage
+	messages := []ProtoMessage{}
+	services := []ProtoService{}
+	imports := map[string]bool{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			messagePackage, name := splitProtoName(table.Config.ProtoName)
+			if name == "" {
+				name = table.TypeName()
+			}
+			if messagePackage != "" {
+				if protoPackage != "" && protoPackage != messagePackage {
+					return errors.Errorf("proto_name %s of table %s.%s is not in package %s (all messages must share one package)", table.Config.ProtoName, table.Schema, table.Name, protoPackage)
+				}
+				protoPackage = messagePackage
+			}
+			for _, c := range table.Columns {
+				if path := protoImport(c.ProtoType()); path != "" {
+					imports[path] = true
+				}
+			}
+			messages = append(messages, ProtoMessage{Name: name, Table: table})
+			if cfg.ProtoServices {
+				service := protoService(name, table)
+				if service.HasUpdate {
+					imports["google/protobuf/field_mask.proto"] = true
+				}
+				if service.HasDelete {
+					imports["google/protobuf/empty.proto"] = true
+				}
+				services = append(services, service)
+			}
+		}
+	}
+
+	sortedImports := make([]string, 0, len(imports))
+	for path := range imports {
+		sortedImports = append(sortedImports, path)
+	}
+	sort.Strings(sortedImports)
+
+	tmpl, err := template.New("Proto").Funcs(template.FuncMap{
+		"ProtoFieldNumber": func(index int) int { return index + 1 },
+		"snake":            strcase.ToSnake,
+	}).Parse(protoTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"Package":  protoPackage,
+		"Imports":  sortedImports,
+		"Messages": messages,
+		"Services": services,
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// extractReplaceActions are how the rows an extraction replaces are cleared.
+var extractReplaceActions = map[string]bool{
+	"truncate": true,
+	"delete":   true,
+}
+
+// extractReplaceStatements returns the statements clearing what the
+// extracted rows replace in the database they're loaded into, so loading
+// them again replaces the rows rather than conflicting with them. truncate
+// empties the tables with extracted rows, and the tables referencing them,
+// with TRUNCATE ... CASCADE. delete deletes the extracted rows by primary
+// key, the tables referencing others first; rows referencing them from
+// outside the extraction only load back if the foreign keys are deferrable,
+// since they're checked once the rows are inserted again.
+func extractReplaceStatements(schemas []GenerationSchema, extraction *Extraction, cfg ExtractConfig) []string {
+	tables := []*GenerationTable{}
+	for _, table := range ddlOrderedTables(schemas) {
+		if extracted, ok := extraction.Tables[table]; ok && extracted.count > 0 {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	switch cfg.Replace {
+	case "truncate":
+		names := make([]string, 0, len(tables))
+		for _, table := range tables {
+			names = append(names, ddlTableName(&table.Table))
+		}
+		return []string{fmt.Sprintf("TRUNCATE %s CASCADE", strings.Join(names, ", "))}
+	case "delete":
+		statements := []string{}
+		for i := len(tables) - 1; i >= 0; i-- {
+			table := tables[i]
+			keys := extraction.writtenKeys(table)
+			for start := 0; start < len(keys); start += extractChunkSize(cfg) {
+				end := min(start+extractChunkSize(cfg), len(keys))
+				statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", ddlTableName(&table.Table), ddlIdent(table.Config.PrimaryKey), strings.Join(keys[start:end], ", ")))
+			}
+		}
+		return statements
+	}
+	return nil
+}
+
+// writtenKeys returns the primary keys of the rows written from a table as
+// literals, masked as the rows are, sorted.
+func (e *Extraction) writtenKeys(table *GenerationTable) []string {
+	extracted := e.Tables[table]
+	masker := func(value interface{}) interface{} { return value }
+	for i, c := range table.Columns {
+		if c.Name == table.Config.PrimaryKey && e.maskers[table][i] != nil {
+			masker = e.maskers[table][i]
+		}
+	}
+	keys := []string{}
+	for key := range extracted.keys {
+		if !extracted.known[key] {
+			keys = append(keys, ddlLiteral(extractText(masker(key))))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// extractReplaceCheck checks the replace action of the config, which can
+// only delete rows from tables with a primary key.
+func extractReplaceCheck(schemas []GenerationSchema, cfg ExtractConfig) error {
+	if cfg.Replace == "" {
+		return nil
+	}
+	if !extractReplaceActions[cfg.Replace] {
+		return errors.Errorf("Unknown replace action %q, expected truncate or delete", cfg.Replace)
+	}
+	if cfg.Replace != "delete" {
+		return nil
+	}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			schemaConfig := cfg.SchemaConfig[table.Schema]
+			if schemaConfig.ShouldSkipTable(table.Name) {
+				continue
+			}
+			if !table.HasColumn(table.Config.PrimaryKey) {
+				return errors.Errorf("Rows of %s.%s can't be deleted without a primary key", table.Schema, table.Name)
+			}
+		}
+	}
+	return nil
+}
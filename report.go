@@ -0,0 +1,225 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// ReportTable is a table as presented in the HTML report.
+type ReportTable struct {
+	Anchor       string
+	Name         string
+	Comment      string
+	Columns      []ReportColumn
+	ReferencedBy []ReportLink
+	Box          ReportBox
+}
+
+// ReportColumn is a single row of a table's column listing.
+type ReportColumn struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	Default    string
+	PrimaryKey bool
+	Comment    string
+	References *ReportLink
+}
+
+// ReportLink points at another table (and column) in the report.
+type ReportLink struct {
+	Anchor string
+	Label  string
+}
+
+// ReportBox places a table in the embedded diagram.
+type ReportBox struct {
+	X, Y, Width, Height int
+}
+
+// ReportEdge is a foreign key line in the embedded diagram.
+type ReportEdge struct {
+	X1, Y1, X2, Y2 int
+	Dashed         bool
+}
+
+const (
+	reportBoxWidth   = 180
+	reportBoxHeight  = 32
+	reportGapX       = 60
+	reportGapY       = 48
+	reportBoxPerRow  = 4
+	reportDiagramPad = 16
+)
+
+func reportAnchor(t *Table) string {
+	return "table-" + t.Schema + "-" + t.Name
+}
+
+// reportTables builds the report's view of every table, laying tables out on
+// a grid for the diagram.
+func reportTables(schemas []GenerationSchema) ([]ReportTable, []ReportEdge, int, int) {
+	tables := []ReportTable{}
+	index := map[string]int{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			n := len(tables)
+			report := ReportTable{
+				Anchor:  reportAnchor(&table.Table),
+				Name:    table.Schema + "." + table.Name,
+				Comment: table.Comment,
+				Box: ReportBox{
+					X:      reportDiagramPad + (n%reportBoxPerRow)*(reportBoxWidth+reportGapX),
+					Y:      reportDiagramPad + (n/reportBoxPerRow)*(reportBoxHeight+reportGapY),
+					Width:  reportBoxWidth,
+					Height: reportBoxHeight,
+				},
+			}
+			for _, c := range table.Columns {
+				report.Columns = append(report.Columns, ReportColumn{
+					Name:       c.Name,
+					Type:       c.SQLType(),
+					Nullable:   c.Nullable,
+					Default:    c.Default,
+					PrimaryKey: c.Name == table.Config.PrimaryKey,
+					Comment:    c.Comment,
+				})
+			}
+			index[table.Schema+"."+table.Name] = n
+			tables = append(tables, report)
+		}
+	}
+
+	edges := []ReportEdge{}
+	for _, edge := range diagramEdges(schemas) {
+		from := &tables[index[edge.From.Schema+"."+edge.From.Name]]
+		to := &tables[index[edge.To.Schema+"."+edge.To.Name]]
+		label := to.Name
+		if edge.Column.Relation.Column != nil {
+			label += "(" + edge.Column.Relation.Column.Name + ")"
+		}
+		for i := range from.Columns {
+			if from.Columns[i].Name == edge.Column.Name {
+				from.Columns[i].References = &ReportLink{Anchor: to.Anchor, Label: label}
+			}
+		}
+		to.ReferencedBy = append(to.ReferencedBy, ReportLink{Anchor: from.Anchor, Label: from.Name + "." + edge.Column.Name})
+		edges = append(edges, ReportEdge{
+			X1:     from.Box.X + from.Box.Width/2,
+			Y1:     from.Box.Y + from.Box.Height/2,
+			X2:     to.Box.X + to.Box.Width/2,
+			Y2:     to.Box.Y + to.Box.Height/2,
+			Dashed: edge.Column.Nullable,
+		})
+	}
+
+	rows := (len(tables) + reportBoxPerRow - 1) / reportBoxPerRow
+	columns := len(tables)
+	if columns > reportBoxPerRow {
+		columns = reportBoxPerRow
+	}
+	width := 2*reportDiagramPad + columns*reportBoxWidth + max(columns-1, 0)*reportGapX
+	height := 2*reportDiagramPad + rows*reportBoxHeight + max(rows-1, 0)*reportGapY
+	return tables, edges, width, height
+}
+
+const reportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 0; display: flex; color: #222; }
+nav { width: 260px; height: 100vh; overflow-y: auto; position: sticky; top: 0; border-right: 1px solid #ddd; padding: 16px; box-sizing: border-box; }
+nav input { width: 100%; padding: 6px; box-sizing: border-box; margin-bottom: 8px; }
+nav ul { list-style: none; padding: 0; margin: 0; }
+nav li a { display: block; padding: 2px 0; color: #0645ad; text-decoration: none; }
+main { flex: 1; padding: 16px 32px; overflow-x: auto; }
+table { border-collapse: collapse; margin-bottom: 8px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+code { font-size: 90%; }
+.muted { color: #777; }
+svg rect { fill: #eef4fb; stroke: #5b7ba6; }
+svg line { stroke: #888; }
+svg line.dashed { stroke-dasharray: 4 3; }
+svg text { font-size: 12px; }
+</style>
+</head>
+<body>
+<nav>
+<input id="search" type="search" placeholder="Search tables" autocomplete="off">
+<ul id="table-list">
+{{- range .Tables }}
+<li data-name="{{ .Name }}"><a href="#{{ .Anchor }}">{{ .Name }}</a></li>
+{{- end }}
+</ul>
+</nav>
+<main>
+<h1>{{ .Title }}</h1>
+<h2>Diagram</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{ .Width }}" height="{{ .Height }}" viewBox="0 0 {{ .Width }} {{ .Height }}">
+{{- range .Edges }}
+<line x1="{{ .X1 }}" y1="{{ .Y1 }}" x2="{{ .X2 }}" y2="{{ .Y2 }}"{{ if .Dashed }} class="dashed"{{ end }}/>
+{{- end }}
+{{- range .Tables }}
+<a href="#{{ .Anchor }}"><rect x="{{ .Box.X }}" y="{{ .Box.Y }}" width="{{ .Box.Width }}" height="{{ .Box.Height }}" rx="4"/><text x="{{ .Box.X }}" y="{{ .Box.Y }}" dx="8" dy="20">{{ .Name }}</text></a>
+{{- end }}
+</svg>
+{{- range .Tables }}
+<section id="{{ .Anchor }}" data-name="{{ .Name }}">
+<h2>{{ .Name }}</h2>
+{{- with .Comment }}
+<p>{{ . }}</p>
+{{- end }}
+<table>
+<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Default</th><th>References</th><th>Comment</th></tr>
+{{- range .Columns }}
+<tr><td><code>{{ .Name }}</code>{{ if .PrimaryKey }} <span class="muted">PK</span>{{ end }}</td><td><code>{{ .Type }}</code></td><td>{{ if .Nullable }}yes{{ else }}no{{ end }}</td><td>{{ with .Default }}<code>{{ . }}</code>{{ end }}</td><td>{{ with .References }}<a href="#{{ .Anchor }}">{{ .Label }}</a>{{ end }}</td><td>{{ .Comment }}</td></tr>
+{{- end }}
+</table>
+{{- with .ReferencedBy }}
+<p>Referenced by:
+{{- range $i, $link := . }}{{ if $i }},{{ end }} <a href="#{{ $link.Anchor }}">{{ $link.Label }}</a>{{ end }}
+</p>
+{{- end }}
+</section>
+{{- end }}
+</main>
+<script>
+document.getElementById("search").addEventListener("input", function (event) {
+  var query = event.target.value.toLowerCase();
+  document.querySelectorAll("[data-name]").forEach(function (element) {
+    element.style.display = element.dataset.name.toLowerCase().indexOf(query) === -1 ? "none" : "";
+  });
+});
+</script>
+</body>
+</html>
+`
+
+// generateReport writes a self-contained HTML report of the selected tables
+// with a searchable table list, column details, links between related tables,
+// and an embedded SVG diagram.
+func generateReport(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	tables, edges, width, height := reportTables(schemas)
+
+	names := make([]string, 0, len(schemas))
+	for _, schema := range schemas {
+		names = append(names, schema.Name)
+	}
+
+	tmpl, err := template.New("Report").Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"Title":  "Schema report: " + strings.Join(names, ", "),
+		"Tables": tables,
+		"Edges":  edges,
+		"Width":  width,
+		"Height": height,
+	})
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+)
+
+// RepositoryTable is a table rendered as a Repository interface with a pgx
+// implementation. The SQL mirrors the generated queries of the same name
+// (soft deletes, order_by, insert and update columns) but always returns full
+// rows so results can be scanned into the table's struct.
+type RepositoryTable struct {
+	Table       *GenerationTable
+	TypeName    string
+	ScanFields  []string
+	KeyParam    string
+	KeyType     string
+	HasKey      bool
+	GetSQL      string
+	ListSQL     string
+	ListPageSQL string
+	InsertSQL   string
+	InsertArgs  []string
+	UpdateSQL   string
+	UpdateArgs  []string
+	DeleteSQL   string
+	QueryPrefix string
+}
+
+// goParamName returns a lower camel case parameter name for a column that
+// doesn't collide with a Go keyword.
+func goParamName(name string) string {
+	param := strcase.ToLowerCamel(name)
+	if token.IsKeyword(param) {
+		return param + "_"
+	}
+	return param
+}
+
+func columnNames(columns []Column) []string {
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func positionalPlaceholders(start int, count int) []string {
+	placeholders := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", start+i))
+	}
+	return placeholders
+}
+
+func repositoryTable(table *GenerationTable) RepositoryTable {
+	repo := RepositoryTable{
+		Table:       table,
+		TypeName:    table.TypeName(),
+		QueryPrefix: strcase.ToCamel(table.Name),
+	}
+	for _, c := range table.Columns {
+		repo.ScanFields = append(repo.ScanFields, "&item."+c.GoField())
+	}
+
+	qualified := table.Schema + "." + table.Name
+	selectColumns := strings.Join(columnNames(table.Columns), ", ")
+	returning := " RETURNING " + selectColumns
+	softDelete := table.Config.SoftDeleteColumn
+	key := table.Config.PrimaryKey
+
+	repo.ListSQL = fmt.Sprintf("SELECT %s FROM %s", selectColumns, qualified)
+	if softDelete != "" {
+		repo.ListSQL += fmt.Sprintf(" WHERE %s IS NULL", softDelete)
+	}
+	if orderBy := table.OrderByClause(""); orderBy != "" {
+		repo.ListSQL += " ORDER BY " + orderBy
+	}
+	repo.ListPageSQL = repo.ListSQL + " LIMIT $1 OFFSET $2"
+
+	insertColumns := table.InsertColumns()
+	if len(insertColumns) == 0 {
+		repo.InsertSQL = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES%s", qualified, returning)
+	} else {
+		repo.InsertSQL = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)%s", qualified,
+			strings.Join(columnNames(insertColumns), ", "),
+			strings.Join(positionalPlaceholders(1, len(insertColumns)), ", "), returning)
+		for _, c := range insertColumns {
+			repo.InsertArgs = append(repo.InsertArgs, "row."+c.GoField())
+		}
+	}
+
+	if !table.HasColumn(key) {
+		return repo
+	}
+	keyColumn := table.PrimaryKeyColumn()
+	repo.HasKey = true
+	repo.KeyParam = goParamName(key)
+	repo.KeyType = keyColumn.GoType()
+
+	repo.GetSQL = fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1", selectColumns, qualified, key)
+	if softDelete != "" {
+		repo.GetSQL += fmt.Sprintf(" AND %s IS NULL", softDelete)
+	}
+
+	updateColumns := table.UpdateColumns()
+	if len(updateColumns) > 0 {
+		assignments := make([]string, 0, len(updateColumns))
+		for i, c := range updateColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = $%d", c.Name, i+2))
+		}
+		repo.UpdateSQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1%s", qualified, strings.Join(assignments, ", "), key, returning)
+		repo.UpdateArgs = append(repo.UpdateArgs, "row."+keyColumn.GoField())
+		for _, c := range updateColumns {
+			repo.UpdateArgs = append(repo.UpdateArgs, "row."+c.GoField())
+		}
+	}
+
+	if !table.Config.DisableDelete {
+		if softDelete != "" {
+			repo.DeleteSQL = fmt.Sprintf("UPDATE %s SET %s = now() WHERE %s = $1 AND %s IS NULL", qualified, softDelete, key, softDelete)
+		} else {
+			repo.DeleteSQL = fmt.Sprintf("DELETE FROM %s WHERE %s = $1", qualified, key)
+		}
+	}
+	return repo
+}
+
+func repositoryTables(schemas []GenerationSchema) []RepositoryTable {
+	tables := []RepositoryTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			tables = append(tables, repositoryTable(&schema.Tables[i]))
+		}
+	}
+	return tables
+}
+
+// repositoryImports returns the imports needed by the repository file. Row
+// structs come from the go target, so only key types add imports.
+func repositoryImports(tables []RepositoryTable, mocks bool) []string {
+	seen := map[string]bool{
+		"context":                 true,
+		"fmt":                     true,
+		"github.com/jackc/pgconn": true,
+		"github.com/jackc/pgx/v4": true,
+		"errors":                  mocks,
+	}
+	for _, table := range tables {
+		if qualifier, _, ok := strings.Cut(table.KeyType, "."); ok && table.HasKey {
+			seen[goPackageImports[qualifier]] = true
+		}
+	}
+	imports := make([]string, 0, len(seen))
+	for path, used := range seen {
+		if used {
+			imports = append(imports, path)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+const repositoryTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+// Repositories for the row structs generated by the go target. Generate both
+// into the same package.
+
+package {{ .Package }}
+
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+)
+
+// DBTX is the database handle used by repositories. It is satisfied by
+// *pgx.Conn, pgx.Tx, and *pgxpool.Pool.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// TxStarter begins transactions. It is satisfied by *pgx.Conn and
+// *pgxpool.Pool.
+type TxStarter interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Use a repository's WithTx to make it part of the
+// transaction.
+func InTx(ctx context.Context, db TxStarter, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+{{- range .Tables }}
+
+// {{ .TypeName }}Repository reads and writes {{ .Table.Schema }}.{{ .Table.Name }} rows.
+type {{ .TypeName }}Repository interface {
+{{- if .HasKey }}
+	Get(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) ({{ .TypeName }}, error)
+{{- end }}
+	List(ctx context.Context) ([]{{ .TypeName }}, error)
+	// ListPage returns up to limit rows after skipping offset rows.
+	ListPage(ctx context.Context, limit int, offset int) ([]{{ .TypeName }}, error)
+	Insert(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error)
+{{- if .UpdateSQL }}
+	Update(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error)
+{{- end }}
+{{- if .DeleteSQL }}
+	Delete(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) error
+{{- end }}
+	// WithTx returns a repository that runs its queries in tx.
+	WithTx(tx pgx.Tx) {{ .TypeName }}Repository
+}
+
+// Pgx{{ .TypeName }}Repository implements {{ .TypeName }}Repository with pgx.
+type Pgx{{ .TypeName }}Repository struct {
+	db DBTX
+}
+
+var _ {{ .TypeName }}Repository = (*Pgx{{ .TypeName }}Repository)(nil)
+
+// New{{ .TypeName }}Repository creates a {{ .TypeName }}Repository backed by db.
+func New{{ .TypeName }}Repository(db DBTX) *Pgx{{ .TypeName }}Repository {
+	return &Pgx{{ .TypeName }}Repository{db: db}
+}
+
+// WithTx implements {{ .TypeName }}Repository.WithTx.
+func (r *Pgx{{ .TypeName }}Repository) WithTx(tx pgx.Tx) {{ .TypeName }}Repository {
+	return &Pgx{{ .TypeName }}Repository{db: tx}
+}
+
+func scan{{ .TypeName }}(row pgx.Row) ({{ .TypeName }}, error) {
+	var item {{ .TypeName }}
+	err := row.Scan({{ join .ScanFields ", " }})
+	return item, err
+}
+{{- if .HasKey }}
+
+const select{{ .TypeName }}ByIDSQL = ` + "`{{ .GetSQL }}`" + `
+
+// Get implements {{ .TypeName }}Repository.Get.
+func (r *Pgx{{ .TypeName }}Repository) Get(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) ({{ .TypeName }}, error) {
+	item, err := scan{{ .TypeName }}(r.db.QueryRow(ctx, select{{ .TypeName }}ByIDSQL, {{ .KeyParam }}))
+	if err != nil {
+		return {{ .TypeName }}{}, fmt.Errorf("query Select{{ .QueryPrefix }}ByID: %w", err)
+	}
+	return item, nil
+}
+{{- end }}
+
+const select{{ .TypeName }}ListSQL = ` + "`{{ .ListSQL }}`" + `
+
+// List implements {{ .TypeName }}Repository.List.
+func (r *Pgx{{ .TypeName }}Repository) List(ctx context.Context) ([]{{ .TypeName }}, error) {
+	rows, err := r.db.Query(ctx, select{{ .TypeName }}ListSQL)
+	if err != nil {
+		return nil, fmt.Errorf("query Select{{ .QueryPrefix }}List: %w", err)
+	}
+	defer rows.Close()
+	items := []{{ .TypeName }}{}
+	for rows.Next() {
+		item, err := scan{{ .TypeName }}(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan Select{{ .QueryPrefix }}List row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close Select{{ .QueryPrefix }}List rows: %w", err)
+	}
+	return items, nil
+}
+
+const select{{ .TypeName }}PageSQL = ` + "`{{ .ListPageSQL }}`" + `
+
+// ListPage implements {{ .TypeName }}Repository.ListPage.
+func (r *Pgx{{ .TypeName }}Repository) ListPage(ctx context.Context, limit int, offset int) ([]{{ .TypeName }}, error) {
+	rows, err := r.db.Query(ctx, select{{ .TypeName }}PageSQL, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query Select{{ .QueryPrefix }}Page: %w", err)
+	}
+	defer rows.Close()
+	items := []{{ .TypeName }}{}
+	for rows.Next() {
+		item, err := scan{{ .TypeName }}(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan Select{{ .QueryPrefix }}Page row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("close Select{{ .QueryPrefix }}Page rows: %w", err)
+	}
+	return items, nil
+}
+
+const insert{{ .TypeName }}SQL = ` + "`{{ .InsertSQL }}`" + `
+
+// Insert implements {{ .TypeName }}Repository.Insert.
+func (r *Pgx{{ .TypeName }}Repository) Insert(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error) {
+	item, err := scan{{ .TypeName }}(r.db.QueryRow(ctx, insert{{ .TypeName }}SQL{{ range .InsertArgs }}, {{ . }}{{ end }}))
+	if err != nil {
+		return {{ .TypeName }}{}, fmt.Errorf("query Insert{{ .QueryPrefix }}: %w", err)
+	}
+	return item, nil
+}
+{{- if .UpdateSQL }}
+
+const update{{ .TypeName }}SQL = ` + "`{{ .UpdateSQL }}`" + `
+
+// Update implements {{ .TypeName }}Repository.Update.
+func (r *Pgx{{ .TypeName }}Repository) Update(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error) {
+	item, err := scan{{ .TypeName }}(r.db.QueryRow(ctx, update{{ .TypeName }}SQL{{ range .UpdateArgs }}, {{ . }}{{ end }}))
+	if err != nil {
+		return {{ .TypeName }}{}, fmt.Errorf("query Update{{ .QueryPrefix }}: %w", err)
+	}
+	return item, nil
+}
+{{- end }}
+{{- if .DeleteSQL }}
+
+const delete{{ .TypeName }}ByIDSQL = ` + "`{{ .DeleteSQL }}`" + `
+
+// Delete implements {{ .TypeName }}Repository.Delete.
+func (r *Pgx{{ .TypeName }}Repository) Delete(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) error {
+	if _, err := r.db.Exec(ctx, delete{{ .TypeName }}ByIDSQL, {{ .KeyParam }}); err != nil {
+		return fmt.Errorf("exec Delete{{ .QueryPrefix }}ByID: %w", err)
+	}
+	return nil
+}
+{{- end }}
+{{- end }}
+`
+
+// repositoryMockTemplate renders a hand-rolled mock per Repository interface.
+// Each method calls the matching func field; unset fields return ErrNotMocked.
+const repositoryMockTemplate = `
+// ErrNotMocked is returned by mock repository methods without a func set.
+var ErrNotMocked = errors.New("repository method not mocked")
+{{- range .Tables }}
+
+// Mock{{ .TypeName }}Repository is a {{ .TypeName }}Repository for tests. Set the
+// func fields for the methods under test.
+type Mock{{ .TypeName }}Repository struct {
+{{- if .HasKey }}
+	GetFunc    func(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) ({{ .TypeName }}, error)
+{{- end }}
+	ListFunc     func(ctx context.Context) ([]{{ .TypeName }}, error)
+	ListPageFunc func(ctx context.Context, limit int, offset int) ([]{{ .TypeName }}, error)
+	InsertFunc func(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error)
+{{- if .UpdateSQL }}
+	UpdateFunc func(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error)
+{{- end }}
+{{- if .DeleteSQL }}
+	DeleteFunc func(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) error
+{{- end }}
+	// WithTxFunc defaults to returning the mock itself.
+	WithTxFunc func(tx pgx.Tx) {{ .TypeName }}Repository
+}
+
+var _ {{ .TypeName }}Repository = (*Mock{{ .TypeName }}Repository)(nil)
+{{- if .HasKey }}
+
+// Get implements {{ .TypeName }}Repository.Get.
+func (m *Mock{{ .TypeName }}Repository) Get(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) ({{ .TypeName }}, error) {
+	if m.GetFunc == nil {
+		return {{ .TypeName }}{}, fmt.Errorf("Mock{{ .TypeName }}Repository.Get: %w", ErrNotMocked)
+	}
+	return m.GetFunc(ctx, {{ .KeyParam }})
+}
+{{- end }}
+
+// List implements {{ .TypeName }}Repository.List.
+func (m *Mock{{ .TypeName }}Repository) List(ctx context.Context) ([]{{ .TypeName }}, error) {
+	if m.ListFunc == nil {
+		return nil, fmt.Errorf("Mock{{ .TypeName }}Repository.List: %w", ErrNotMocked)
+	}
+	return m.ListFunc(ctx)
+}
+
+// ListPage implements {{ .TypeName }}Repository.ListPage.
+func (m *Mock{{ .TypeName }}Repository) ListPage(ctx context.Context, limit int, offset int) ([]{{ .TypeName }}, error) {
+	if m.ListPageFunc == nil {
+		return nil, fmt.Errorf("Mock{{ .TypeName }}Repository.ListPage: %w", ErrNotMocked)
+	}
+	return m.ListPageFunc(ctx, limit, offset)
+}
+
+// Insert implements {{ .TypeName }}Repository.Insert.
+func (m *Mock{{ .TypeName }}Repository) Insert(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error) {
+	if m.InsertFunc == nil {
+		return {{ .TypeName }}{}, fmt.Errorf("Mock{{ .TypeName }}Repository.Insert: %w", ErrNotMocked)
+	}
+	return m.InsertFunc(ctx, row)
+}
+{{- if .UpdateSQL }}
+
+// Update implements {{ .TypeName }}Repository.Update.
+func (m *Mock{{ .TypeName }}Repository) Update(ctx context.Context, row {{ .TypeName }}) ({{ .TypeName }}, error) {
+	if m.UpdateFunc == nil {
+		return {{ .TypeName }}{}, fmt.Errorf("Mock{{ .TypeName }}Repository.Update: %w", ErrNotMocked)
+	}
+	return m.UpdateFunc(ctx, row)
+}
+{{- end }}
+{{- if .DeleteSQL }}
+
+// Delete implements {{ .TypeName }}Repository.Delete.
+func (m *Mock{{ .TypeName }}Repository) Delete(ctx context.Context, {{ .KeyParam }} {{ .KeyType }}) error {
+	if m.DeleteFunc == nil {
+		return fmt.Errorf("Mock{{ .TypeName }}Repository.Delete: %w", ErrNotMocked)
+	}
+	return m.DeleteFunc(ctx, {{ .KeyParam }})
+}
+{{- end }}
+
+// WithTx implements {{ .TypeName }}Repository.WithTx.
+func (m *Mock{{ .TypeName }}Repository) WithTx(tx pgx.Tx) {{ .TypeName }}Repository {
+	if m.WithTxFunc == nil {
+		return m
+	}
+	return m.WithTxFunc(tx)
+}
+{{- end }}
+`
+
+// generateRepositories writes a Go package with a Repository interface and a
+// pgx implementation per table. It builds on the structs from the go target,
+// which must be generated into the same package. With repository_mocks set, a
+// mock implementation of each interface is written alongside.
+func generateRepositories(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	packageName := cfg.GoPackage
+	if packageName == "" {
+		packageName = "models"
+	}
+	tables := repositoryTables(schemas)
+
+	templates := []string{repositoryTemplate}
+	if cfg.RepositoryMocks {
+		templates = append(templates, repositoryMockTemplate)
+	}
+	data := map[string]interface{}{
+		"Package": packageName,
+		"Imports": repositoryImports(tables, cfg.RepositoryMocks),
+		"Tables":  tables,
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, text := range templates {
+		tmpl, err := template.New("Repositories").Funcs(template.FuncMap{
+			"join": strings.Join,
+		}).Parse(text)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return err
+		}
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to format generated Go code:\n%s", buf.String()))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
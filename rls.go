@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RLSStyleSetting  = "setting"
+	RLSStyleSupabase = "supabase"
+
+	defaultRLSSetting = "app.tenant_id"
+)
+
+// rlsCommands are the commands a policy is generated for, with whether the
+// policy checks existing rows (USING) and new rows (WITH CHECK).
+var rlsCommands = []struct {
+	Command string
+	Using   bool
+	Check   bool
+}{
+	{"SELECT", true, false},
+	{"INSERT", false, true},
+	{"UPDATE", true, true},
+	{"DELETE", true, false},
+}
+
+// rlsTables returns the tables with rls_column set.
+func rlsTables(schemas []GenerationSchema) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			if schema.Tables[i].Config.RLSColumn != "" {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+	}
+	return tables
+}
+
+// rlsPredicate returns the condition restricting rows to the current tenant
+// or owner: the column compared to the rls_setting setting cast to the
+// column's type, or to auth.uid() for the supabase style.
+func rlsPredicate(c Column, cfg GeneratorConfiguration) (string, error) {
+	switch cfg.RLSStyle {
+	case "", RLSStyleSetting:
+		setting := cfg.RLSSetting
+		if setting == "" {
+			setting = defaultRLSSetting
+		}
+		return fmt.Sprintf("%s = current_setting(%s, true)::%s", ddlIdent(c.Name), ddlLiteral(setting), ddlType(c)), nil
+	case RLSStyleSupabase:
+		return fmt.Sprintf("%s = auth.uid()", ddlIdent(c.Name)), nil
+	}
+	return "", errors.Errorf("Unknown rls_style %q (expected %s or %s)", cfg.RLSStyle, RLSStyleSetting, RLSStyleSupabase)
+}
+
+// rlsMigration enables row level security on each table with rls_column set
+// and creates a policy per command restricting rows to those whose column
+// matches the current tenant or owner.
+func rlsMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	up := strings.Builder{}
+	down := strings.Builder{}
+	for _, table := range rlsTables(schemas) {
+		name := ddlTableName(&table.Table)
+		column, ok := table.GetColumn(table.Config.RLSColumn)
+		if !ok {
+			return Migration{}, errors.Errorf("Table %s has no rls_column %q", name, table.Config.RLSColumn)
+		}
+		predicate, err := rlsPredicate(column, cfg)
+		if err != nil {
+			return Migration{}, err
+		}
+
+		fmt.Fprintf(&up, "\nALTER TABLE %s ENABLE ROW LEVEL SECURITY;\n", name)
+		for _, command := range rlsCommands {
+			policy := ddlIdent(table.Name + "_" + strings.ToLower(command.Command))
+			fmt.Fprintf(&up, "DROP POLICY IF EXISTS %s ON %s;\n", policy, name)
+			fmt.Fprintf(&up, "CREATE POLICY %s ON %s FOR %s", policy, name, command.Command)
+			if command.Using {
+				fmt.Fprintf(&up, "\n    USING (%s)", predicate)
+			}
+			if command.Check {
+				fmt.Fprintf(&up, "\n    WITH CHECK (%s)", predicate)
+			}
+			up.WriteString(";\n")
+			fmt.Fprintf(&down, "DROP POLICY IF EXISTS %s ON %s;\n", policy, name)
+		}
+		fmt.Fprintf(&down, "ALTER TABLE %s DISABLE ROW LEVEL SECURITY;\n", name)
+	}
+	return Migration{Name: "rls", Up: strings.TrimPrefix(up.String(), "\n"), Down: down.String()}, nil
+}
+
+// generateRLS writes the row level security policies from rlsMigration.
+func generateRLS(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	if len(rlsTables(schemas)) == 0 {
+		return errors.New("No tables have rls_column set")
+	}
+	return writeMigrationUp(w, rlsMigration, schemas, cfg)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExtractSample samples the rows of a root table, for extracting a subset of
+// the whole database rather than the rows around a seed. The rows sampled
+// are walked like seed rows, to the parents they need by default.
+type ExtractSample struct {
+	// Table is qualified by its schema unless it's in public.
+	Table string `yaml:"table"`
+	// Rows is the number of rows sampled at random.
+	Rows int `yaml:"rows"`
+	// Percent samples about this percentage of the rows with TABLESAMPLE
+	// BERNOULLI, which doesn't sort the table like sampling by Rows. Rows
+	// then caps the rows sampled. With neither, every row is taken.
+	Percent float64 `yaml:"percent"`
+	// Where limits the rows sampled to those matching a predicate.
+	Where string `yaml:"where"`
+}
+
+// extractSamples returns the lookups sampling the root tables, and a
+// description of them.
+func extractSamples(schemas []GenerationSchema, samples []ExtractSample) ([]extractLookup, string, error) {
+	lookups := make([]extractLookup, 0, len(samples))
+	descriptions := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		table, err := extractSeedTable(schemas, sample.Table)
+		if err != nil {
+			return nil, "", errors.Errorf("Sampled table %s is not one of the selected tables", sample.Table)
+		}
+		name := table.Schema + "." + table.Name
+		if sample.Rows < 0 || sample.Percent < 0 || sample.Percent > 100 {
+			return nil, "", errors.Errorf("Invalid sample of %s, expected a positive number of rows and a percentage up to 100", name)
+		}
+		description := "all rows"
+		switch {
+		case sample.Percent > 0 && sample.Rows > 0:
+			description = fmt.Sprintf("%g%% up to %d rows", sample.Percent, sample.Rows)
+		case sample.Percent > 0:
+			description = fmt.Sprintf("%g%%", sample.Percent)
+		case sample.Rows > 0:
+			description = fmt.Sprintf("%d rows", sample.Rows)
+		}
+		description += " of " + name
+		where := sample.Where
+		if where == "" {
+			where = "TRUE"
+		} else {
+			description += " where " + where
+		}
+		lookups = append(lookups, extractLookup{Table: table, Where: where, Limit: sample.Rows, Percent: sample.Percent, Relation: "sample of " + name})
+		descriptions = append(descriptions, description)
+	}
+	return lookups, "a sample of " + strings.Join(descriptions, ", "), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"gopkg.in/yaml.v3"
+)
+
+// scaffoldPrimaryKey returns the column of a table's primary key, or "" if
+// it has none or it spans several columns.
+func scaffoldPrimaryKey(table Table) string {
+	for _, index := range table.Indexes {
+		if index.Primary && len(index.Columns) == 1 {
+			return index.Columns[0]
+		}
+	}
+	return ""
+}
+
+// scaffoldValue returns a string as a YAML scalar, quoted if it has to be.
+func scaffoldValue(s string) string {
+	out, err := yaml.Marshal(s)
+	value := strings.TrimSuffix(string(out), "\n")
+	if err != nil || strings.Contains(value, "\n") {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	return value
+}
+
+// writeScaffoldConfig writes a config for the inspected schemas, ready to
+// edit: a table_config per table with its primary key and comments, and the
+// options that are usually set next commented out. The default primary key
+// is the one most tables have, for tables without one of their own.
+func writeScaffoldConfig(w io.Writer, schemas map[string]Schema) error {
+	b := strings.Builder{}
+	b.WriteString("# Configuration written by pginspector init. Commented out options are the\n")
+	b.WriteString("# ones usually set next; run pginspector help for the commands reading it.\n\n")
+	fmt.Fprintf(&b, "# dialect: %s\n", DialectPositional)
+	b.WriteString("# go_package: models\n")
+	b.WriteString("# proto_package: foo.v1\n")
+	b.WriteString("schema_config:\n")
+
+	schemaNames := make([]string, 0, len(schemas))
+	for schemaName := range schemas {
+		schemaNames = append(schemaNames, schemaName)
+	}
+	sort.Strings(schemaNames)
+	for _, schemaName := range schemaNames {
+		schema := schemas[schemaName]
+		tableNames := make([]string, 0, len(schema.Tables))
+		keys := map[string]int{}
+		for tableName, table := range schema.Tables {
+			tableNames = append(tableNames, tableName)
+			if key := scaffoldPrimaryKey(table); key != "" {
+				keys[key]++
+			}
+		}
+		sort.Strings(tableNames)
+		defaultKey := "id"
+		for key, count := range keys {
+			if count > keys[defaultKey] || (count == keys[defaultKey] && key < defaultKey) {
+				defaultKey = key
+			}
+		}
+
+		fmt.Fprintf(&b, "  %s:\n", scaffoldValue(schemaName))
+		fmt.Fprintf(&b, "    default_primary_key_name: %s\n", scaffoldValue(defaultKey))
+		b.WriteString("    # Tables left out of everything generated, e.g. a migrations table, by\n")
+		b.WriteString("    # name, glob (django_*), or regular expression (/^audit_/).\n")
+		b.WriteString("    skip_tables: []\n")
+		b.WriteString("    # Or the only tables generated, by name or pattern, in a large schema.\n")
+		b.WriteString("    # include_tables: []\n")
+		b.WriteString("    # soft_delete_column: deleted_at\n")
+		b.WriteString("    table_config:")
+		if len(tableNames) == 0 {
+			b.WriteString(" {}")
+		}
+		b.WriteString("\n")
+		for _, tableName := range tableNames {
+			table := schema.Tables[tableName]
+			fmt.Fprintf(&b, "      %s:\n", scaffoldValue(tableName))
+			if key := scaffoldPrimaryKey(table); key != "" {
+				fmt.Fprintf(&b, "        primary_key: %s\n", scaffoldValue(key))
+			} else {
+				fmt.Fprintf(&b, "        # No single column primary key was found, so %s is assumed.\n", defaultKey)
+				b.WriteString("        # Set the column identifying a row, or skip the table.\n")
+				fmt.Fprintf(&b, "        # primary_key: %s\n", scaffoldValue(defaultKey))
+			}
+			fmt.Fprintf(&b, "        # proto_name: %s\n", scaffoldValue("foo.v1."+strcase.ToCamel(tableName)))
+			b.WriteString("        # generate_field_mask_update: true\n")
+			b.WriteString("        # generate_upsert: true\n")
+			b.WriteString("        # generate_paginated_list: true\n")
+			b.WriteString("        # exclude_columns: []\n")
+			if table.Comment != "" {
+				fmt.Fprintf(&b, "        description: %s\n", scaffoldValue(table.Comment))
+			}
+			commented := false
+			for _, c := range table.Columns {
+				if c.Comment == "" {
+					continue
+				}
+				if !commented {
+					b.WriteString("        column_descriptions:\n")
+					commented = true
+				}
+				fmt.Fprintf(&b, "          %s: %s\n", scaffoldValue(c.Name), scaffoldValue(c.Comment))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
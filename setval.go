@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// extractNextvalPattern matches a column default taking the next value of a
+// sequence, capturing the sequence as a regclass literal.
+var extractNextvalPattern = regexp.MustCompile(`^nextval\(('(?:[^']|'')+'::regclass)\)$`)
+
+// extractSequenceStatements returns the statements moving the sequences of
+// the tables with extracted rows past the values inserted, so the database
+// they're loaded into doesn't generate them again. Each sequence is set to
+// its column's greatest value, which covers the rows inserted, for identity
+// columns and columns defaulting to nextval.
+func extractSequenceStatements(schemas []GenerationSchema, extraction *Extraction) []string {
+	statements := []string{}
+	for _, table := range ddlOrderedTables(schemas) {
+		extracted, ok := extraction.Tables[table]
+		if !ok || extracted.count == 0 {
+			continue
+		}
+		name := ddlTableName(&table.Table)
+		for _, c := range table.Columns {
+			if extracted.Omitted[c.Name] {
+				continue
+			}
+			sequence := ""
+			if match := extractNextvalPattern.FindStringSubmatch(c.Default); match != nil {
+				sequence = match[1]
+			} else if c.Identity != "" {
+				sequence = fmt.Sprintf("pg_get_serial_sequence(%s, %s)", ddlLiteral(name), ddlLiteral(c.Name))
+			}
+			if sequence != "" {
+				statements = append(statements, fmt.Sprintf("SELECT setval(%s, max(%s)) FROM %s", sequence, ddlIdent(c.Name), name))
+			}
+		}
+	}
+	return statements
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaSnapshot holds the inspected schemas, saved to a file by the snapshot
+// command so the extract command can load them instead of inspecting the
+// database, e.g. on a read replica without access to the catalogs.
+type SchemaSnapshot struct {
+	TakenAt time.Time `json:"taken_at"`
+	// Schemas are the inspected schemas by name, before any configuration is
+	// applied.
+	Schemas map[string]Schema `json:"schemas"`
+}
+
+// takeSchemaSnapshot inspects each configured schema.
+func takeSchemaSnapshot(ctx context.Context, databaseURL string, cfg GeneratorConfiguration, debug bool) (SchemaSnapshot, error) {
+	snapshot := SchemaSnapshot{TakenAt: time.Now().UTC(), Schemas: map[string]Schema{}}
+	for schemaName := range cfg.SchemaConfig {
+		schema, err := inspectTablesInSchema(ctx, databaseURL, schemaName, []string{}, debug)
+		if err != nil {
+			return SchemaSnapshot{}, errors.WithMessage(err, "Unable to inspect schema")
+		}
+		snapshot.Schemas[schemaName] = schema
+	}
+	return snapshot, nil
+}
+
+// writeSchemaSnapshot writes a snapshot as JSON. Relations are written as the
+// names of the tables and columns they reference, since resolved relations
+// can reference their own table.
+func writeSchemaSnapshot(w io.Writer, snapshot SchemaSnapshot) error {
+	written := SchemaSnapshot{TakenAt: snapshot.TakenAt, Schemas: map[string]Schema{}}
+	for schemaName, schema := range snapshot.Schemas {
+		tables := map[string]Table{}
+		for tableName, table := range schema.Tables {
+			table.Columns = append([]Column{}, table.Columns...)
+			for i, c := range table.Columns {
+				if c.Relation.Table != nil {
+					table.Columns[i].Relation.Table = &Table{Schema: c.Relation.Table.Schema, Name: c.Relation.Table.Name}
+				}
+				if c.Relation.Column != nil {
+					table.Columns[i].Relation.Column = &Column{Name: c.Relation.Column.Name}
+				}
+			}
+			tables[tableName] = table
+		}
+		written.Schemas[schemaName] = Schema{Tables: tables}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(written)
+}
+
+// readSchemaSnapshot reads a snapshot written by writeSchemaSnapshot,
+// resolving its relations as inspecting does.
+func readSchemaSnapshot(r io.Reader) (SchemaSnapshot, error) {
+	snapshot := SchemaSnapshot{}
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return SchemaSnapshot{}, errors.WithMessage(err, "Unable to read schema snapshot")
+	}
+	for schemaName, schema := range snapshot.Schemas {
+		if schema.Tables == nil {
+			schema.Tables = map[string]Table{}
+		}
+		schema.ResolveRelations(schemaName)
+		snapshot.Schemas[schemaName] = schema
+	}
+	return snapshot, nil
+}
+
+// inspect returns a schema from the snapshot in place of inspecting it.
+func (s SchemaSnapshot) inspect(schemaName string, excludedTableNames []string) (Schema, error) {
+	schema, ok := s.Schemas[schemaName]
+	if !ok {
+		return Schema{}, errors.Errorf("Schema %s is not in the snapshot", schemaName)
+	}
+	return schema, nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sqlalchemyType is how values of a column kind are typed in Python, and the
+// column type SQLAlchemy declares for them.
+type sqlalchemyType struct {
+	// Python is the type annotation of the values, qualified by its module.
+	Python string
+	// Column is the SQLAlchemy column type.
+	Column string
+}
+
+// sqlalchemyTypes maps column kinds to Python and SQLAlchemy types. Kinds not
+// listed are mapped to NullType, which passes values through untouched.
+var sqlalchemyTypes = map[TypeKind]sqlalchemyType{
+	KindInt16:       {Python: "int", Column: "SmallInteger"},
+	KindInt32:       {Python: "int", Column: "Integer"},
+	KindInt64:       {Python: "int", Column: "BigInteger"},
+	KindFloat32:     {Python: "float", Column: "REAL"},
+	KindFloat64:     {Python: "float", Column: "Double"},
+	KindNumeric:     {Python: "decimal.Decimal", Column: "Numeric"},
+	KindBool:        {Python: "bool", Column: "Boolean"},
+	KindString:      {Python: "str", Column: "Text"},
+	KindUUID:        {Python: "uuid.UUID", Column: "Uuid"},
+	KindTimestamp:   {Python: "datetime.datetime", Column: "DateTime"},
+	KindTimestamptz: {Python: "datetime.datetime", Column: "DateTime(timezone=True)"},
+	KindDate:        {Python: "datetime.date", Column: "Date"},
+	KindTime:        {Python: "datetime.time", Column: "Time"},
+	KindInterval:    {Python: "datetime.timedelta", Column: "Interval"},
+	KindJSON:        {Python: "Any", Column: "JSON"},
+	KindBytes:       {Python: "bytes", Column: "LargeBinary"},
+}
+
+var (
+	pythonIdentPattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	pythonInvalidIdentPattern = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	pythonKeywords            = map[string]bool{
+		"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+		"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+		"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+		"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+		"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+	}
+)
+
+// pythonIdent returns an identifier for a database name, and whether it
+// differs so that the name has to be given separately.
+func pythonIdent(name string) (string, bool) {
+	if pythonIdentPattern.MatchString(name) && !pythonKeywords[name] {
+		return name, false
+	}
+	ident := pythonInvalidIdentPattern.ReplaceAllString(name, "_")
+	if ident == "" || (ident[0] >= '0' && ident[0] <= '9') || pythonKeywords[ident] {
+		ident = "_" + ident
+	}
+	return ident, true
+}
+
+// pythonImports collects the names imported from each Python module, and the
+// modules imported whole.
+type pythonImports map[string]map[string]bool
+
+func (p pythonImports) add(module string, name string) {
+	if p[module] == nil {
+		p[module] = map[string]bool{}
+	}
+	p[module][name] = true
+}
+
+// pythonStandardModules are the standard library modules generated code
+// imports from.
+var pythonStandardModules = map[string]bool{
+	"datetime": true,
+	"decimal":  true,
+	"enum":     true,
+	"typing":   true,
+	"uuid":     true,
+}
+
+// write writes the standard library imports followed by the third-party
+// imports, each sorted with plain imports before from imports.
+func (p pythonImports) write(b *strings.Builder) {
+	modules := make([]string, 0, len(p))
+	for module := range p {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	sections := [2][]string{}
+	for _, section := range []int{0, 1} {
+		plain := []string{}
+		from := []string{}
+		for _, module := range modules {
+			if pythonStandardModules[module] != (section == 0) {
+				continue
+			}
+			names := []string{}
+			for name := range p[module] {
+				if name == "" {
+					plain = append(plain, "import "+module)
+				} else {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			if len(names) > 0 {
+				from = append(from, fmt.Sprintf("from %s import %s", module, strings.Join(names, ", ")))
+			}
+		}
+		sections[section] = append(plain, from...)
+	}
+	for i, section := range sections {
+		if len(section) == 0 {
+			continue
+		}
+		if i == 1 && len(sections[0]) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.Join(section, "\n") + "\n")
+	}
+}
+
+// addPythonType imports the module a qualified Python type comes from.
+func (p pythonImports) addPythonType(typ string) {
+	if module, _, ok := strings.Cut(typ, "."); ok {
+		p.add(module, "")
+	} else if typ == "Any" {
+		p.add("typing", "Any")
+	}
+}
+
+// pythonEnumMembers returns the member names of an enum class for its values.
+// Values that don't make a usable name fall back to their position.
+func pythonEnumMembers(values []string) []string {
+	members := []string{}
+	seen := map[string]bool{}
+	for i, value := range values {
+		name, _ := pythonIdent(value)
+		if seen[name] {
+			name = fmt.Sprintf("value_%d", i)
+		}
+		seen[name] = true
+		members = append(members, name)
+	}
+	return members
+}
+
+// sqlalchemyColumnType returns the Python type of the column's values and the
+// SQLAlchemy type declared for it.
+func sqlalchemyColumnType(c Column, imports pythonImports) (string, string) {
+	typ := c.Type()
+	mapping, ok := sqlalchemyTypes[typ.Kind]
+	switch {
+	case typ.Kind == KindEnum:
+		mapping.Python = c.EnumTypeName()
+		mapping.Column = fmt.Sprintf("Enum(%s, name=%s", c.EnumTypeName(), strconv.Quote(strings.TrimPrefix(c.UDTName, "_")))
+		if c.EnumSchema() != "public" {
+			mapping.Column += ", schema=" + strconv.Quote(c.EnumSchema())
+		}
+		mapping.Column += ", values_callable=lambda e: [m.value for m in e])"
+		imports.add("sqlalchemy", "Enum")
+	case !ok:
+		mapping = sqlalchemyType{Python: "Any", Column: "NullType()"}
+		imports.add("sqlalchemy.types", "NullType")
+	case typ.Kind == KindNumeric && c.NumericPrecision > 0:
+		mapping.Column = fmt.Sprintf("Numeric(%d, %d)", c.NumericPrecision, c.NumericScale)
+	case typ.Kind == KindString && c.MaxLength > 0:
+		mapping.Column = fmt.Sprintf("String(%d)", c.MaxLength)
+		if c.PGType == "character" {
+			mapping.Column = fmt.Sprintf("CHAR(%d)", c.MaxLength)
+		}
+	case typ.Kind == KindJSON && (c.PGType == "jsonb" || c.UDTName == "_jsonb"):
+		mapping.Column = "postgresql.JSONB"
+	}
+	if typ.Kind != KindEnum {
+		if name, _, _ := strings.Cut(mapping.Column, "("); strings.HasPrefix(name, "postgresql.") {
+			imports.add("sqlalchemy.dialects", "postgresql")
+		} else if ok {
+			imports.add("sqlalchemy", name)
+		}
+	}
+	imports.addPythonType(mapping.Python)
+
+	if typ.Array {
+		imports.add("sqlalchemy.dialects", "postgresql")
+		imports.add("typing", "List")
+		return "List[" + mapping.Python + "]", "postgresql.ARRAY(" + mapping.Column + ")"
+	}
+	return mapping.Python, mapping.Column
+}
+
+// sqlalchemyRelation is a relationship attribute on a model.
+type sqlalchemyRelation struct {
+	Name string
+	Type string
+	// Arguments are the arguments to relationship().
+	Arguments []string
+}
+
+// sqlalchemyRelations returns the relationship attributes of each model: one
+// on the referencing model per foreign key, and the back-reference on the
+// referenced model. Foreign keys are spelled out when a pair of models has
+// more than one relationship between them.
+func sqlalchemyRelations(edges []DiagramEdge) map[*GenerationTable][]sqlalchemyRelation {
+	pairs := map[[2]*GenerationTable]int{}
+	for _, edge := range edges {
+		pairs[[2]*GenerationTable{edge.From, edge.To}]++
+		if edge.From != edge.To {
+			pairs[[2]*GenerationTable{edge.To, edge.From}]++
+		}
+	}
+
+	relations := map[*GenerationTable][]sqlalchemyRelation{}
+	for _, edge := range edges {
+		column, _ := pythonIdent(edge.Column.Name)
+		forward := strings.TrimSuffix(column, "_id")
+		if forward == column || edge.From.HasColumn(forward) {
+			forward = column + "_ref"
+		}
+		back, _ := pythonIdent(pluralize(edge.From.Name))
+		ambiguous := pairs[[2]*GenerationTable{edge.From, edge.To}] > 1 || edge.From == edge.To
+		if ambiguous {
+			back += "_" + forward
+		}
+		if edge.To.HasColumn(back) {
+			back += "_ref"
+		}
+
+		forwardType := edge.To.TypeName()
+		if edge.Column.Nullable {
+			forwardType = "Optional[" + forwardType + "]"
+		}
+		forwardArguments := []string{"back_populates=" + strconv.Quote(back)}
+		backArguments := []string{"back_populates=" + strconv.Quote(forward)}
+		if ambiguous {
+			forwardArguments = append(forwardArguments, "foreign_keys=["+column+"]")
+			backArguments = append(backArguments, fmt.Sprintf("foreign_keys=%s", strconv.Quote("["+edge.From.TypeName()+"."+column+"]")))
+		}
+		if edge.From == edge.To {
+			remote, _ := pythonIdent(edge.Column.Relation.Column.Name)
+			forwardArguments = append(forwardArguments, "remote_side=["+remote+"]")
+		}
+		relations[edge.From] = append(relations[edge.From], sqlalchemyRelation{Name: forward, Type: forwardType, Arguments: forwardArguments})
+
+		backType := "List[" + edge.From.TypeName() + "]"
+		if edge.From.IsUnique(edge.Column.Name) {
+			backType = "Optional[" + edge.From.TypeName() + "]"
+		}
+		relations[edge.To] = append(relations[edge.To], sqlalchemyRelation{Name: back, Type: backType, Arguments: backArguments})
+	}
+	return relations
+}
+
+// sqlalchemyPrimaryKey returns the columns of the table's primary key, from
+// its primary key index or else the configured primary key.
+func sqlalchemyPrimaryKey(t *GenerationTable) map[string]bool {
+	columns := map[string]bool{}
+	for _, index := range t.Indexes {
+		if index.Primary {
+			for _, column := range index.Columns {
+				columns[column] = true
+			}
+			return columns
+		}
+	}
+	if t.HasColumn(t.Config.PrimaryKey) {
+		columns[t.Config.PrimaryKey] = true
+	}
+	return columns
+}
+
+// generateSQLAlchemy writes SQLAlchemy 2.0 declarative models: an enum class
+// per enum type, and a model per table with its columns, foreign keys, and a
+// relationship pair per foreign key between selected tables. SQLAlchemy can't
+// map tables without a primary key, so they're left out with a comment.
+func generateSQLAlchemy(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	imports := pythonImports{}
+	imports.add("sqlalchemy.orm", "DeclarativeBase")
+
+	edges := diagramEdges(schemas)
+	foreignKeys := map[*GenerationTable]map[string]string{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column == nil {
+			continue
+		}
+		if foreignKeys[edge.From] == nil {
+			foreignKeys[edge.From] = map[string]string{}
+		}
+		foreignKeys[edge.From][edge.Column.Name] = edge.To.Schema + "." + edge.To.Name + "." + edge.Column.Relation.Column.Name
+	}
+	mapped := []DiagramEdge{}
+	for _, edge := range edges {
+		if edge.Column.Relation.Column != nil && len(sqlalchemyPrimaryKey(edge.From)) > 0 && len(sqlalchemyPrimaryKey(edge.To)) > 0 {
+			mapped = append(mapped, edge)
+		}
+	}
+	relations := sqlalchemyRelations(mapped)
+
+	body := strings.Builder{}
+	body.WriteString("\n\nclass Base(DeclarativeBase):\n    pass\n")
+
+	for _, enum := range prismaEnums(schemas) {
+		imports.add("enum", "")
+		fmt.Fprintf(&body, "\n\nclass %s(enum.Enum):\n", enum.Name)
+		for i, member := range pythonEnumMembers(enum.Values) {
+			fmt.Fprintf(&body, "    %s = %s\n", member, strconv.Quote(enum.Values[i]))
+		}
+	}
+
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			primaryKey := sqlalchemyPrimaryKey(table)
+			if len(primaryKey) == 0 {
+				fmt.Fprintf(&body, "\n\n# %s.%s has no primary key, which SQLAlchemy needs to map it.\n", table.Schema, table.Name)
+				continue
+			}
+			imports.add("sqlalchemy.orm", "Mapped")
+			imports.add("sqlalchemy.orm", "mapped_column")
+
+			fmt.Fprintf(&body, "\n\nclass %s(Base):\n", table.TypeName())
+			if table.Comment != "" {
+				fmt.Fprintf(&body, "    %s\n\n", pythonDocstring(table.Comment, "    "))
+			}
+			fmt.Fprintf(&body, "    __tablename__ = %s\n", strconv.Quote(table.Name))
+			fmt.Fprintf(&body, "    __table_args__ = {\"schema\": %s}\n\n", strconv.Quote(table.Schema))
+
+			for _, c := range table.Columns {
+				name, renamed := pythonIdent(c.Name)
+				pythonType, columnType := sqlalchemyColumnType(c, imports)
+				if c.Nullable {
+					imports.add("typing", "Optional")
+					pythonType = "Optional[" + pythonType + "]"
+				}
+				arguments := []string{}
+				if renamed {
+					arguments = append(arguments, strconv.Quote(c.Name))
+				}
+				arguments = append(arguments, columnType)
+				if target, ok := foreignKeys[table][c.Name]; ok {
+					imports.add("sqlalchemy", "ForeignKey")
+					arguments = append(arguments, fmt.Sprintf("ForeignKey(%s)", strconv.Quote(target)))
+				}
+				switch {
+				case c.Identity == "ALWAYS":
+					imports.add("sqlalchemy", "Identity")
+					arguments = append(arguments, "Identity(always=True)")
+				case c.Identity != "":
+					imports.add("sqlalchemy", "Identity")
+					arguments = append(arguments, "Identity()")
+				case c.GenerationExpression != "":
+					imports.add("sqlalchemy", "Computed")
+					arguments = append(arguments, fmt.Sprintf("Computed(%s, persisted=True)", strconv.Quote(c.GenerationExpression)))
+				}
+				if primaryKey[c.Name] {
+					arguments = append(arguments, "primary_key=True")
+				} else if table.IsUnique(c.Name) {
+					arguments = append(arguments, "unique=True")
+				}
+				if c.Default != "" && c.Identity == "" {
+					imports.add("sqlalchemy", "text")
+					arguments = append(arguments, fmt.Sprintf("server_default=text(%s)", strconv.Quote(c.Default)))
+				}
+				if c.Comment != "" {
+					arguments = append(arguments, "comment="+strconv.Quote(c.Comment))
+				}
+				fmt.Fprintf(&body, "    %s: Mapped[%s] = mapped_column(%s)\n", name, pythonType, strings.Join(arguments, ", "))
+			}
+
+			if len(relations[table]) > 0 {
+				imports.add("sqlalchemy.orm", "relationship")
+				body.WriteString("\n")
+			}
+			for _, relation := range relations[table] {
+				if strings.HasPrefix(relation.Type, "Optional[") {
+					imports.add("typing", "Optional")
+				} else if strings.HasPrefix(relation.Type, "List[") {
+					imports.add("typing", "List")
+				}
+				fmt.Fprintf(&body, "    %s: Mapped[%s] = relationship(%s)\n", relation.Name, relation.Type, strings.Join(relation.Arguments, ", "))
+			}
+		}
+	}
+
+	b := strings.Builder{}
+	// Annotations are evaluated lazily so models can reference models defined
+	// after them.
+	b.WriteString("# Code generated by pginspector. DO NOT EDIT.\n\nfrom __future__ import annotations\n\n")
+	imports.write(&b)
+	b.WriteString(body.String())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pythonDocstring quotes text as a docstring indented by indent.
+func pythonDocstring(text string, indent string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), `"""`, `\"\"\"`)
+	return `"""` + strings.ReplaceAll(text, "\n", "\n"+indent) + `"""`
+}
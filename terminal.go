@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultTerminalHeight is the number of lines assumed when the terminal's
+// size can't be read.
+const defaultTerminalHeight = 24
+
+// rawTerminal puts the terminal f reads from in raw mode, so keys are read
+// as they're pressed without being echoed, returning the function restoring
+// it. It reports false if f isn't a terminal or its mode can't be changed.
+// stty changes the mode, as the standard library has no portable way to.
+func rawTerminal(f *os.File) (func(), bool) {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, false
+	}
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, false
+	}
+	if _, err := stty(f, "raw", "-echo"); err != nil {
+		return nil, false
+	}
+	return func() { stty(f, strings.TrimSpace(state)) }, true
+}
+
+// terminalHeight returns the number of lines of the terminal f reads from.
+func terminalHeight(f *os.File) int {
+	size, err := stty(f, "size")
+	if err != nil {
+		return defaultTerminalHeight
+	}
+	fields := strings.Fields(size)
+	if len(fields) != 2 {
+		return defaultTerminalHeight
+	}
+	rows, err := strconv.Atoi(fields[0])
+	if err != nil || rows <= 0 {
+		return defaultTerminalHeight
+	}
+	return rows
+}
+
+// stty runs stty on the terminal f reads from, returning its output.
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// TypeKind is a target-independent classification of a column's Postgres
+// type. Each code generator maps kinds to its own type system so that every
+// target agrees on how a column is represented.
+type TypeKind int
+
+const (
+	KindUnknown TypeKind = iota
+	KindInt16
+	KindInt32
+	KindInt64
+	KindFloat32
+	KindFloat64
+	KindNumeric
+	KindBool
+	KindString
+	KindUUID
+	KindTimestamp
+	KindTimestamptz
+	KindDate
+	KindTime
+	KindInterval
+	KindJSON
+	KindBytes
+	KindEnum
+)
+
+// ColumnType is the classified type of a column. Array columns report the kind
+// of their elements.
+type ColumnType struct {
+	Kind  TypeKind
+	Array bool
+}
+
+// typeKinds maps both information_schema data_type names and udt names (used
+// for array elements) to kinds.
+var typeKinds = map[string]TypeKind{
+	"smallint":                    KindInt16,
+	"int2":                        KindInt16,
+	"integer":                     KindInt32,
+	"int4":                        KindInt32,
+	"bigint":                      KindInt64,
+	"int8":                        KindInt64,
+	"real":                        KindFloat32,
+	"float4":                      KindFloat32,
+	"double precision":            KindFloat64,
+	"float8":                      KindFloat64,
+	"numeric":                     KindNumeric,
+	"money":                       KindNumeric,
+	"boolean":                     KindBool,
+	"bool":                        KindBool,
+	"text":                        KindString,
+	"character varying":           KindString,
+	"varchar":                     KindString,
+	"character":                   KindString,
+	"bpchar":                      KindString,
+	"citext":                      KindString,
+	"name":                        KindString,
+	"uuid":                        KindUUID,
+	"timestamp without time zone": KindTimestamp,
+	"timestamp":                   KindTimestamp,
+	"timestamp with time zone":    KindTimestamptz,
+	"timestamptz":                 KindTimestamptz,
+	"date":                        KindDate,
+	"time without time zone":      KindTime,
+	"time":                        KindTime,
+	"interval":                    KindInterval,
+	"json":                        KindJSON,
+	"jsonb":                       KindJSON,
+	"bytea":                       KindBytes,
+}
+
+// Type classifies the column's Postgres type.
+func (c Column) Type() ColumnType {
+	if len(c.EnumValues) > 0 {
+		return ColumnType{Kind: KindEnum, Array: c.PGType == "ARRAY"}
+	}
+	switch c.PGType {
+	case "ARRAY":
+		return ColumnType{Kind: typeKinds[strings.TrimPrefix(c.UDTName, "_")], Array: true}
+	case "USER-DEFINED":
+		return ColumnType{Kind: typeKinds[c.UDTName]}
+	}
+	return ColumnType{Kind: typeKinds[c.PGType]}
+}
+
+// TypeName returns the name used for the table's type in generated code.
+// Tables outside the public schema are prefixed with their schema name so
+// names stay unique across schemas.
+func (t *Table) TypeName() string {
+	if t.Schema == "" || t.Schema == "public" {
+		return exportedName(t.Name)
+	}
+	return exportedName(t.Schema) + exportedName(t.Name)
+}
+
+var initialisms = map[string]bool{
+	"api":  true,
+	"html": true,
+	"http": true,
+	"id":   true,
+	"ip":   true,
+	"json": true,
+	"sql":  true,
+	"uri":  true,
+	"url":  true,
+	"uuid": true,
+}
+
+// exportedName converts a snake_case identifier to an exported Go-style name,
+// upper-casing common initialisms (user_id becomes UserID).
+func exportedName(name string) string {
+	parts := strings.Split(name, "_")
+	result := strings.Builder{}
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if initialisms[strings.ToLower(part)] {
+			result.WriteString(strings.ToUpper(part))
+			continue
+		}
+		result.WriteString(strcase.ToCamel(part))
+	}
+	return result.String()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// typescriptTypes maps column kinds to TypeScript types. 64-bit integers and
+// numerics are strings because they don't fit in a JS number, which is also
+// how node-postgres returns them. Time kinds are resolved by
+// typescriptTimestampType.
+var typescriptTypes = map[TypeKind]string{
+	KindInt16:    "number",
+	KindInt32:    "number",
+	KindInt64:    "string",
+	KindFloat32:  "number",
+	KindFloat64:  "number",
+	KindNumeric:  "string",
+	KindBool:     "boolean",
+	KindString:   "string",
+	KindUUID:     "string",
+	KindTime:     "string",
+	KindInterval: "string",
+	KindJSON:     "unknown",
+	KindBytes:    "string",
+	KindUnknown:  "string",
+}
+
+// typescriptTimestampType returns the type used for timestamp and date
+// columns, validating the typescript_timestamp_type config value.
+func typescriptTimestampType(cfg GeneratorConfiguration) (string, error) {
+	switch cfg.TypeScriptTimestampType {
+	case "", "string":
+		return "string", nil
+	case "Date":
+		return "Date", nil
+	}
+	return "", errors.Errorf("Unsupported typescript_timestamp_type %q (expected \"string\" or \"Date\")", cfg.TypeScriptTimestampType)
+}
+
+// EnumTypeName returns the exported name of the column's enum type.
+func (c Column) EnumTypeName() string {
+	return exportedName(strings.TrimPrefix(c.UDTName, "_"))
+}
+
+// EnumSchema returns the schema the column's enum type is defined in.
+func (c Column) EnumSchema() string {
+	if c.UDTSchema == "" {
+		return "public"
+	}
+	return c.UDTSchema
+}
+
+// typescriptEnumUnion renders enum labels as a union of string literals.
+func typescriptEnumUnion(values []string) string {
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literals = append(literals, fmt.Sprintf("%q", value))
+	}
+	return strings.Join(literals, " | ")
+}
+
+// TypeScriptType returns the TypeScript type for the column. Enum columns use
+// the named union type emitted alongside the interfaces.
+func (c Column) TypeScriptType(timestampType string) string {
+	typ := c.Type()
+	name := typescriptTypes[typ.Kind]
+	switch typ.Kind {
+	case KindTimestamp, KindTimestamptz, KindDate:
+		name = timestampType
+	case KindEnum:
+		name = c.EnumTypeName()
+	}
+	if typ.Array {
+		name += "[]"
+	}
+	if c.Nullable {
+		name += " | null"
+	}
+	return name
+}
+
+// TypeScriptEnum is a Postgres enum rendered as a union type.
+type TypeScriptEnum struct {
+	Name   string
+	Union  string
+	Values []string
+}
+
+func typescriptEnums(schemas []GenerationSchema) []TypeScriptEnum {
+	seen := map[string]bool{}
+	enums := []TypeScriptEnum{}
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for _, c := range table.Columns {
+				if c.Type().Kind != KindEnum || seen[c.EnumTypeName()] {
+					continue
+				}
+				seen[c.EnumTypeName()] = true
+				enums = append(enums, TypeScriptEnum{Name: c.EnumTypeName(), Union: typescriptEnumUnion(c.EnumValues), Values: c.EnumValues})
+			}
+		}
+	}
+	sort.Slice(enums, func(i, j int) bool {
+		return enums[i].Name < enums[j].Name
+	})
+	return enums
+}
+
+const typescriptTemplate = `// Code generated by pginspector. DO NOT EDIT.
+{{- range .Enums }}
+
+export type {{ .Name }} = {{ .Union }};
+{{- end }}
+{{- range .Schemas }}
+{{- range .Tables }}
+
+/** A row of {{ .Schema }}.{{ .Name }}. */
+export interface {{ .TypeName }} {
+{{- range .Columns }}
+  {{ .Name }}: {{ .TypeScriptType $.TimestampType }};
+  {{- with .Relation.Table }} // references {{ .Schema }}.{{ .Name }}{{ end }}
+  {{- with .Relation.Column }}({{ .Name }}){{ end }}
+{{- end }}
+}
+{{- end }}
+{{- end }}
+`
+
+// generateTypeScript writes a TypeScript module with one interface per table
+// and a string union type per enum.
+func generateTypeScript(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	timestampType, err := typescriptTimestampType(cfg)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("TypeScript").Parse(typescriptTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"TimestampType": timestampType,
+		"Enums":         typescriptEnums(schemas),
+		"Schemas":       schemas,
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultUpdatedAtColumn = "updated_at"
+	updatedAtFunction      = "public.set_updated_at"
+	updatedAtTriggerName   = "set_updated_at"
+)
+
+// updatedAtTriggerPattern matches row triggers that fire before updates.
+var updatedAtTriggerPattern = regexp.MustCompile(`\bBEFORE (?:[A-Z]+ OR )*UPDATE\b.* FOR EACH ROW\b`)
+
+// hasUpdatedAtTrigger reports whether the table already has a trigger that
+// maintains the column: a BEFORE UPDATE row trigger that either calls a
+// set_updated_at function or assigns the column in its function body.
+func hasUpdatedAtTrigger(t *Table, column string) bool {
+	assignment := regexp.MustCompile(`(?i)\bNEW\s*\.\s*"?` + regexp.QuoteMeta(column) + `"?\s*:?=`)
+	for _, trigger := range t.Triggers {
+		if !updatedAtTriggerPattern.MatchString(trigger.Definition) {
+			continue
+		}
+		_, function, _ := strings.Cut(trigger.Function, ".")
+		if function == "set_updated_at" || assignment.MatchString(trigger.FunctionSource) {
+			return true
+		}
+	}
+	return false
+}
+
+// updatedAtTables returns the tables with the updated_at column that don't
+// already maintain it with a trigger.
+func updatedAtTables(schemas []GenerationSchema, column string) []*GenerationTable {
+	tables := []*GenerationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			if table.HasColumn(column) && !hasUpdatedAtTrigger(&table.Table, column) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables
+}
+
+// updatedAtMigration creates a shared set_updated_at() trigger function and a
+// BEFORE UPDATE trigger on each table with the updated_at_column column
+// (updated_at by default). Tables that already have an equivalent trigger are
+// skipped.
+func updatedAtMigration(schemas []GenerationSchema, cfg GeneratorConfiguration) (Migration, error) {
+	column := cfg.UpdatedAtColumn
+	if column == "" {
+		column = defaultUpdatedAtColumn
+	}
+	tables := updatedAtTables(schemas, column)
+	if len(tables) == 0 {
+		return Migration{Name: "updated_at"}, nil
+	}
+
+	// The function is only managed here if no inspected trigger uses it yet.
+	ownsFunction := true
+	for _, schema := range schemas {
+		for _, table := range schema.Tables {
+			for _, trigger := range table.Triggers {
+				ownsFunction = ownsFunction && trigger.Function != updatedAtFunction
+			}
+		}
+	}
+
+	up := strings.Builder{}
+	if ownsFunction {
+		fmt.Fprintf(&up, `CREATE OR REPLACE FUNCTION %s() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    NEW.%s := now();
+    RETURN NEW;
+END;
+$$;
+`, updatedAtFunction, ddlIdent(column))
+	}
+
+	down := strings.Builder{}
+	for _, table := range tables {
+		name := ddlTableName(&table.Table)
+		fmt.Fprintf(&up, "\nDROP TRIGGER IF EXISTS %s ON %s;\n", updatedAtTriggerName, name)
+		fmt.Fprintf(&up, "CREATE TRIGGER %s\n    BEFORE UPDATE ON %s\n    FOR EACH ROW EXECUTE FUNCTION %s();\n", updatedAtTriggerName, name, updatedAtFunction)
+		fmt.Fprintf(&down, "DROP TRIGGER IF EXISTS %s ON %s;\n", updatedAtTriggerName, name)
+	}
+	if ownsFunction {
+		fmt.Fprintf(&down, "DROP FUNCTION IF EXISTS %s();\n", updatedAtFunction)
+	}
+	return Migration{Name: "updated_at", Up: strings.TrimPrefix(up.String(), "\n"), Down: down.String()}, nil
+}
+
+// generateUpdatedAt writes the trigger function and triggers from
+// updatedAtMigration.
+func generateUpdatedAt(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	migration, err := updatedAtMigration(schemas, cfg)
+	if err != nil {
+		return err
+	}
+	if migration.Up == "" {
+		return errors.New("No tables need an updated_at trigger")
+	}
+	return writeMigrationUp(w, updatedAtMigration, schemas, cfg)
+}
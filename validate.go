@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationCheck is one condition in a generated Validate method. Condition
+// is a Go expression that is true when the row would be rejected.
+type ValidationCheck struct {
+	Condition  string
+	Column     string
+	Constraint string
+	Message    string
+}
+
+// ValidationTable is a table rendered as a Validate method on its struct.
+type ValidationTable struct {
+	Table *GenerationTable
+	// Checks are the conditions the method tests, in column order followed
+	// by CHECK constraints.
+	Checks []ValidationCheck
+	// Unvalidated lists CHECK constraints that couldn't be translated to Go.
+	Unvalidated []Constraint
+}
+
+// validationImports tracks the packages used by generated conditions.
+type validationImports struct {
+	pgtype bool
+	utf8   bool
+}
+
+// validationValue returns the Go expression for a column's value in a row r,
+// and the condition under which the value is present (empty when the field
+// can't be NULL). Only columns that CHECK constraints can be translated for
+// are supported.
+func validationValue(c Column, imports *validationImports) (string, string, bool) {
+	typ := c.Type()
+	field := "r." + c.GoField()
+	if typ.Array {
+		return "", "", false
+	}
+	switch typ.Kind {
+	case KindInt16, KindInt32, KindInt64, KindFloat32, KindFloat64, KindString:
+	case KindEnum:
+		if c.Nullable {
+			return field + "." + c.EnumTypeName(), field + ".Valid", true
+		}
+		return field, "", true
+	default:
+		return "", "", false
+	}
+	if !c.Nullable {
+		return field, "", true
+	}
+	imports.pgtype = true
+	present := field + ".Status == pgtype.Present"
+	switch typ.Kind {
+	case KindString:
+		return field + ".String", present, true
+	case KindFloat32, KindFloat64:
+		return field + ".Float", present, true
+	}
+	return field + ".Int", present, true
+}
+
+// validationNotNull returns the condition for a NOT NULL column holding NULL.
+// Most Go types can't represent NULL; pgtype values and byte slices can.
+func validationNotNull(c Column, imports *validationImports) (string, bool) {
+	if c.Nullable || c.Default != "" || c.Generated {
+		return "", false
+	}
+	field := "r." + c.GoField()
+	goType := c.GoType()
+	switch {
+	case strings.HasPrefix(goType, "pgtype."):
+		imports.pgtype = true
+		return field + ".Status != pgtype.Present", true
+	case goType == "[]byte" || goType == "json.RawMessage":
+		return field + " == nil", true
+	}
+	return "", false
+}
+
+// columnChecks returns the checks derived from a column's definition: NOT
+// NULL, varchar length, and enum membership.
+func columnChecks(c Column, imports *validationImports) []ValidationCheck {
+	checks := []ValidationCheck{}
+	if condition, ok := validationNotNull(c, imports); ok {
+		checks = append(checks, ValidationCheck{Condition: condition, Column: c.Name, Message: "must not be null"})
+	}
+	typ := c.Type()
+	if typ.Array {
+		return checks
+	}
+	value, present, ok := validationValue(c, imports)
+	if !ok {
+		return checks
+	}
+	switch {
+	case typ.Kind == KindString && c.MaxLength > 0:
+		imports.utf8 = true
+		checks = append(checks, ValidationCheck{
+			Condition: validationWhenPresent(present, fmt.Sprintf("utf8.RuneCountInString(%s) > %d", value, c.MaxLength)),
+			Column:    c.Name,
+			Message:   fmt.Sprintf("must be at most %d characters", c.MaxLength),
+		})
+	case typ.Kind == KindEnum:
+		checks = append(checks, ValidationCheck{
+			Condition: validationWhenPresent(present, fmt.Sprintf("!%s.Valid()", value)),
+			Column:    c.Name,
+			Message:   "must be one of " + strings.Join(c.EnumValues, ", "),
+		})
+	}
+	return checks
+}
+
+func validationWhenPresent(present string, condition string) string {
+	if present == "" {
+		return condition
+	}
+	return present + " && " + condition
+}
+
+var (
+	// checkCastPattern matches the casts pg_get_constraintdef adds to values,
+	// such as ::text, ::character varying, or ::numeric(10,2)[].
+	checkCastPattern = regexp.MustCompile(`::"?[a-z_][a-z0-9_]*"?( [a-z]+)*(\(\d+(,\s*\d+)?\))?(\[\])?`)
+	// checkIdentParenPattern matches identifiers and literals wrapped in their
+	// own parentheses, e.g. (status) in (status)::text once the cast is gone.
+	checkIdentParenPattern = regexp.MustCompile(`(^|[^a-zA-Z0-9_])\(([a-z_][a-z0-9_]*|-?[0-9][0-9.]*|'[^']*')\)`)
+	checkIdentPattern      = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	checkLengthPattern     = regexp.MustCompile(`^(char_length|character_length|length|octet_length)\(([a-z_][a-z0-9_]*)\)$`)
+	checkAnyPattern        = regexp.MustCompile(`^([a-z_][a-z0-9_]*) = ANY \(\(?ARRAY\[(.*)\]\)?\)$`)
+)
+
+// normalizeCheck strips the CHECK keyword, casts, and redundant parentheses
+// from a constraint definition, leaving an expression like price > 0.
+func normalizeCheck(definition string) string {
+	expr := strings.TrimSuffix(strings.TrimPrefix(definition, "CHECK "), " NOT VALID")
+	expr = checkCastPattern.ReplaceAllString(expr, "")
+	for {
+		next := checkIdentParenPattern.ReplaceAllString(expr, "$1$2")
+		if next == expr {
+			break
+		}
+		expr = next
+	}
+	return trimOuterParens(expr)
+}
+
+// trimOuterParens removes parentheses that wrap the whole expression.
+func trimOuterParens(expr string) string {
+	expr = strings.TrimSpace(expr)
+	for strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		depth := 0
+		wrapped := true
+		for i, r := range expr {
+			switch r {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 && i < len(expr)-1 {
+				wrapped = false
+				break
+			}
+		}
+		if !wrapped {
+			break
+		}
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// splitTopLevel splits expr on sep where sep is outside parentheses, brackets,
+// and string literals.
+func splitTopLevel(expr string, sep string) []string {
+	parts := []string{}
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\'':
+			quoted = !quoted
+		case '(', '[':
+			if !quoted {
+				depth++
+			}
+		case ')', ']':
+			if !quoted {
+				depth--
+			}
+		}
+		if depth == 0 && !quoted && strings.HasPrefix(expr[i:], sep) {
+			parts = append(parts, expr[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+// checkOperators are tried longest first so >= isn't read as >.
+var checkOperators = []string{" >= ", " <= ", " <> ", " != ", " = ", " > ", " < "}
+
+// negatedOperators map SQL comparisons to the Go comparison that fails them.
+var negatedOperators = map[string]string{
+	">=": "<",
+	"<=": ">",
+	"<>": "==",
+	"!=": "==",
+	"=":  "!=",
+	">":  "<=",
+	"<":  ">=",
+}
+
+// checkLiteral converts a SQL literal to a Go literal of the column's kind.
+func checkLiteral(literal string, kind TypeKind) (string, bool) {
+	literal = trimOuterParens(literal)
+	unquoted := literal
+	quoted := strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") && len(literal) >= 2
+	if quoted {
+		unquoted = strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	}
+	switch kind {
+	case KindString, KindEnum:
+		if !quoted {
+			return "", false
+		}
+		return strconv.Quote(unquoted), true
+	case KindInt16, KindInt32, KindInt64:
+		if _, err := strconv.ParseInt(unquoted, 10, 64); err != nil {
+			return "", false
+		}
+		return unquoted, true
+	case KindFloat32, KindFloat64:
+		if _, err := strconv.ParseFloat(unquoted, 64); err != nil {
+			return "", false
+		}
+		return unquoted, true
+	}
+	return "", false
+}
+
+// checkViolation translates a normalized CHECK expression into a Go condition
+// that is true when the row violates it. Like Postgres, a comparison against
+// a NULL value never violates the constraint. Only comparisons of a column
+// (or its length) with a literal, = ANY (ARRAY[...]), AND, and OR are
+// supported.
+func checkViolation(expr string, table *Table, imports *validationImports) (string, bool) {
+	present, condition, ok := checkCondition(expr, table, imports)
+	if !ok {
+		return "", false
+	}
+	return validationWhenPresent(present, condition), true
+}
+
+// checkCondition returns the violation condition for expr separately from the
+// presence condition guarding it, so that guards shared by every part of an
+// AND or OR are only tested once.
+func checkCondition(expr string, table *Table, imports *validationImports) (string, string, bool) {
+	expr = trimOuterParens(expr)
+	if parts := splitTopLevel(expr, " AND "); len(parts) > 1 {
+		return checkCombine(parts, " || ", table, imports)
+	}
+	if parts := splitTopLevel(expr, " OR "); len(parts) > 1 {
+		return checkCombine(parts, " && ", table, imports)
+	}
+
+	if match := checkAnyPattern.FindStringSubmatch(expr); match != nil {
+		c, ok := table.GetColumn(match[1])
+		if !ok {
+			return "", "", false
+		}
+		value, present, ok := validationValue(c, imports)
+		if !ok {
+			return "", "", false
+		}
+		conditions := []string{}
+		for _, literal := range splitTopLevel(match[2], ", ") {
+			goLiteral, ok := checkLiteral(strings.TrimSpace(literal), c.Type().Kind)
+			if !ok {
+				return "", "", false
+			}
+			conditions = append(conditions, fmt.Sprintf("%s != %s", value, goLiteral))
+		}
+		return present, strings.Join(conditions, " && "), true
+	}
+
+	for _, operator := range checkOperators {
+		parts := splitTopLevel(expr, operator)
+		if len(parts) != 2 {
+			continue
+		}
+		lhs, rhs := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		negated := negatedOperators[strings.TrimSpace(operator)]
+
+		if match := checkLengthPattern.FindStringSubmatch(lhs); match != nil {
+			c, ok := table.GetColumn(match[2])
+			if !ok || c.Type().Kind != KindString {
+				return "", "", false
+			}
+			value, present, ok := validationValue(c, imports)
+			if !ok {
+				return "", "", false
+			}
+			if _, err := strconv.Atoi(rhs); err != nil {
+				return "", "", false
+			}
+			length := fmt.Sprintf("len(%s)", value)
+			if match[1] != "octet_length" {
+				imports.utf8 = true
+				length = fmt.Sprintf("utf8.RuneCountInString(%s)", value)
+			}
+			return present, fmt.Sprintf("%s %s %s", length, negated, rhs), true
+		}
+
+		if !checkIdentPattern.MatchString(lhs) {
+			return "", "", false
+		}
+		c, ok := table.GetColumn(lhs)
+		if !ok {
+			return "", "", false
+		}
+		kind := c.Type().Kind
+		if (kind == KindString || kind == KindEnum) && negated != "==" && negated != "!=" {
+			// Ordering strings depends on the database collation.
+			return "", "", false
+		}
+		value, present, ok := validationValue(c, imports)
+		if !ok {
+			return "", "", false
+		}
+		literal, ok := checkLiteral(rhs, kind)
+		if !ok {
+			return "", "", false
+		}
+		return present, fmt.Sprintf("%s %s %s", value, negated, literal), true
+	}
+	return "", "", false
+}
+
+func checkCombine(parts []string, join string, table *Table, imports *validationImports) (string, string, bool) {
+	presents := make([]string, 0, len(parts))
+	conditions := make([]string, 0, len(parts))
+	for _, part := range parts {
+		present, condition, ok := checkCondition(part, table, imports)
+		if !ok {
+			return "", "", false
+		}
+		presents = append(presents, present)
+		conditions = append(conditions, condition)
+	}
+	shared := true
+	for _, present := range presents {
+		shared = shared && present == presents[0]
+	}
+	for i := range conditions {
+		if shared {
+			conditions[i] = "(" + conditions[i] + ")"
+		} else {
+			conditions[i] = "(" + validationWhenPresent(presents[i], conditions[i]) + ")"
+		}
+	}
+	if !shared {
+		return "", strings.Join(conditions, join), true
+	}
+	if presents[0] == "" {
+		return "", strings.Join(conditions, join), true
+	}
+	return presents[0], "(" + strings.Join(conditions, join) + ")", true
+}
+
+// validationTable builds the checks for a table's Validate method.
+func validationTable(table *GenerationTable, imports *validationImports) ValidationTable {
+	validation := ValidationTable{Table: table}
+	for _, c := range table.Columns {
+		validation.Checks = append(validation.Checks, columnChecks(c, imports)...)
+	}
+	for _, constraint := range table.Constraints {
+		if constraint.Type != ConstraintCheck {
+			continue
+		}
+		// Conditions are built against a copy of the imports so that an
+		// untranslatable constraint doesn't add unused imports.
+		used := *imports
+		expr := normalizeCheck(constraint.Definition)
+		condition, ok := checkViolation(expr, &table.Table, &used)
+		if !ok {
+			validation.Unvalidated = append(validation.Unvalidated, constraint)
+			continue
+		}
+		*imports = used
+		validation.Checks = append(validation.Checks, ValidationCheck{
+			Condition:  condition,
+			Constraint: constraint.Name,
+			Message:    fmt.Sprintf("violates check constraint %s (%s)", constraint.Name, expr),
+		})
+	}
+	return validation
+}
+
+const validateTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+// Validate methods for the row structs generated by the go target. Generate
+// both into the same package.
+
+package {{ .Package }}
+
+import (
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
+)
+
+// ValidationError describes a value the database would reject.
+type ValidationError struct {
+	Column     string
+	Constraint string
+	Message    string
+}
+
+func (e ValidationError) Error() string {
+	if e.Column == "" {
+		return e.Message
+	}
+	return e.Column + " " + e.Message
+}
+
+// ValidationErrors collects every ValidationError found in a row.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+{{- range .Tables }}
+
+// Validate checks the row against the NOT NULL, length, enum, and CHECK
+// constraints of {{ .Table.Schema }}.{{ .Table.Name }}. It returns
+// ValidationErrors when any fail.
+func (r {{ .Table.TypeName }}) Validate() error {
+	var errs ValidationErrors
+{{- range .Checks }}
+	if {{ .Condition }} {
+		errs = append(errs, ValidationError{ {{- with .Column }}Column: {{ printf "%q" . }}, {{ end }}{{ with .Constraint }}Constraint: {{ printf "%q" . }}, {{ end }}Message: {{ printf "%q" .Message }}})
+	}
+{{- end }}
+{{- range .Unvalidated }}
+	// Not validated: {{ .Name }} {{ .Definition }}
+{{- end }}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+{{- end }}
+`
+
+// generateValidation writes a Validate method per table struct, derived from
+// NOT NULL columns, varchar lengths, enum types, and CHECK constraints. CHECK
+// constraints that can't be translated are listed as comments in the method.
+func generateValidation(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	packageName := cfg.GoPackage
+	if packageName == "" {
+		packageName = "models"
+	}
+
+	imports := validationImports{}
+	tables := []ValidationTable{}
+	for _, schema := range schemas {
+		for i := range schema.Tables {
+			tables = append(tables, validationTable(&schema.Tables[i], &imports))
+		}
+	}
+	paths := []string{"strings"}
+	if imports.pgtype {
+		paths = append(paths, goPackageImports["pgtype"])
+	}
+	if imports.utf8 {
+		paths = append(paths, "unicode/utf8")
+	}
+
+	tmpl, err := template.New("Validation").Parse(validateTemplate)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = tmpl.Execute(buf, map[string]interface{}{
+		"Package": packageName,
+		"Imports": paths,
+		"Tables":  tables,
+	})
+	if err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("Unable to format generated Go code:\n%s", buf.String()))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// zodTypes maps column kinds to Zod validators. The representation matches
+// the TypeScript target: 64-bit integers and numerics are strings, and time
+// kinds follow typescript_timestamp_type.
+var zodTypes = map[TypeKind]string{
+	KindInt16:       "z.number().int().min(-32768).max(32767)",
+	KindInt32:       "z.number().int().min(-2147483648).max(2147483647)",
+	KindInt64:       `z.string().regex(/^-?\d+$/)`,
+	KindFloat32:     "z.number()",
+	KindFloat64:     "z.number()",
+	KindNumeric:     "z.string()",
+	KindBool:        "z.boolean()",
+	KindString:      "z.string()",
+	KindUUID:        "z.string().uuid()",
+	KindTimestamp:   "z.string()",
+	KindTimestamptz: "z.string().datetime({ offset: true })",
+	KindDate:        "z.string()",
+	KindTime:        "z.string()",
+	KindInterval:    "z.string()",
+	KindJSON:        "z.unknown()",
+	KindBytes:       "z.string()",
+	KindUnknown:     "z.string()",
+}
+
+// ZodType returns the Zod validator for the column.
+func (c Column) ZodType(timestampType string) string {
+	typ := c.Type()
+	validator := zodTypes[typ.Kind]
+	switch typ.Kind {
+	case KindTimestamp, KindTimestamptz, KindDate:
+		if timestampType == "Date" {
+			validator = "z.coerce.date()"
+		}
+	case KindString:
+		if c.MaxLength > 0 {
+			validator += fmt.Sprintf(".max(%d)", c.MaxLength)
+		}
+	case KindNumeric:
+		if c.NumericPrecision > 0 {
+			validator += fmt.Sprintf(".regex(/%s/)", numericPattern(c.NumericPrecision, c.NumericScale))
+		}
+	case KindEnum:
+		validator = c.EnumTypeName() + "Schema"
+	}
+	if typ.Array {
+		validator = "z.array(" + validator + ")"
+	}
+	if c.Nullable {
+		validator += ".nullable()"
+	}
+	return validator
+}
+
+// zodEnum renders enum labels as the argument to z.enum.
+func zodEnum(values []string) string {
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literals = append(literals, fmt.Sprintf("%q", value))
+	}
+	return "[" + strings.Join(literals, ", ") + "]"
+}
+
+const zodTemplate = `// Code generated by pginspector. DO NOT EDIT.
+
+import { z } from "zod";
+{{- range .Enums }}
+
+export const {{ .Name }}Schema = z.enum({{ ZodEnum .Values }});
+export type {{ .Name }} = z.infer<typeof {{ .Name }}Schema>;
+{{- end }}
+{{- range .Schemas }}
+{{- range .Tables }}
+
+/** A row of {{ .Schema }}.{{ .Name }}. */
+export const {{ .TypeName }}Schema = z.object({
+{{- range .Columns }}
+  {{ .Name }}: {{ .ZodType $.TimestampType }},
+  {{- with .Relation.Table }} // references {{ .Schema }}.{{ .Name }}{{ end }}
+  {{- with .Relation.Column }}({{ .Name }}){{ end }}
+{{- end }}
+});
+export type {{ .TypeName }} = z.infer<typeof {{ .TypeName }}Schema>;
+{{- end }}
+{{- end }}
+`
+
+// generateZod writes a TypeScript module with a Zod schema (and inferred
+// type) per table and enum.
+func generateZod(w io.Writer, schemas []GenerationSchema, cfg GeneratorConfiguration) error {
+	timestampType, err := typescriptTimestampType(cfg)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("Zod").Funcs(template.FuncMap{
+		"ZodEnum": zodEnum,
+	}).Parse(zodTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"TimestampType": timestampType,
+		"Enums":         typescriptEnums(schemas),
+		"Schemas":       schemas,
+	})
+}